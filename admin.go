@@ -0,0 +1,165 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strings"
+)
+
+// an admin can not lock themselves out, so there is always one admin left
+var ErrOwnAccount = errors.New("can not change your own account here")
+
+// target of an admin action, never the admin themselves
+func adminTarget(actor *User, userID int) (*User, error) {
+    if actor.ID == userID {
+        return nil, ErrOwnAccount
+    }
+    return userStore.GetByID(userID)
+}
+
+func logAdminAction(actor *User, target *User, action string) {
+    log.Printf("admin %s: %s %s", actor.Username, action, target.Username)
+}
+
+func AdminSetRole(actor *User, userID int, role string) error {
+    if !ValidRole(role) {
+        return ErrInvalidRole
+    }
+    target, err := adminTarget(actor, userID)
+    if err != nil {
+        return err
+    }
+    if err := userStore.SetRole(target.ID, role); err != nil {
+        return err
+    }
+    logAdminAction(actor, target, "role "+role+" for")
+    return nil
+}
+
+func AdminSetDisabled(actor *User, userID int, disabled bool) error {
+    target, err := adminTarget(actor, userID)
+    if err != nil {
+        return err
+    }
+    if err := userStore.SetDisabled(target.ID, disabled); err != nil {
+        return err
+    }
+    if disabled {
+        logAdminAction(actor, target, "disabled")
+    } else {
+        logAdminAction(actor, target, "enabled")
+    }
+    return nil
+}
+
+func AdminDeleteUser(actor *User, userID int) error {
+    target, err := adminTarget(actor, userID)
+    if err != nil {
+        return err
+    }
+    if err := userStore.Delete(target.ID); err != nil {
+        return err
+    }
+    logAdminAction(actor, target, "deleted")
+    return nil
+}
+
+// new password set by the admin; sessions and tokens of the user end, a lockout too
+func AdminResetPassword(actor *User, userID int, password string) error {
+    if err := ValidatePassword(password); err != nil {
+        return err
+    }
+    target, err := adminTarget(actor, userID)
+    if err != nil {
+        return err
+    }
+    if _, err := setPassword(target.ID, password); err != nil {
+        return err
+    }
+    RecordLoginSuccess(LoginAttempt{Username: target.Username})
+    logAdminAction(actor, target, "reset password of")
+    return nil
+}
+
+// same as "2fa reset <username>" on the command line
+func AdminResetTwoFactor(actor *User, userID int) error {
+    target, err := adminTarget(actor, userID)
+    if err != nil {
+        return err
+    }
+    if err := mfaStore.Reset(target.ID); err != nil {
+        return err
+    }
+    logAdminAction(actor, target, "reset 2FA of")
+    return nil
+}
+
+// "user add <username> <role>" (password on stdin) for the first admin of an installation,
+// "user role <username> <role>" for an existing account or a way back in;
+// registration only ever creates plain users
+func runUserCommand(args []string) error {
+    if len(args) != 3 || args[0] != "add" && args[0] != "role" {
+        return errors.New("usage: user add|role <username> user|manager|admin")
+    }
+    if !ValidRole(args[2]) {
+        return ErrInvalidRole
+    }
+
+    if err := InitDB(config.DatabaseDriver, config.DatabaseDSN(), false); err != nil {
+        return err
+    }
+    defer db.Close()
+
+    if args[0] == "add" {
+        if err := addUserFromStdin(args[1]); err != nil {
+            return err
+        }
+    }
+    user, err := userStore.GetByUsername(args[1])
+    if err != nil {
+        return fmt.Errorf("user: %s: %w", args[1], err)
+    }
+    if err := userStore.SetRole(user.ID, args[2]); err != nil {
+        return err
+    }
+    fmt.Printf("%s is now %s\n", user.Username, args[2])
+    return nil
+}
+
+// new account, the password is the first line of stdin (echo ... | my-tracker user add ...)
+func addUserFromStdin(username string) error {
+    if _, err := userStore.GetByUsername(username); err == nil {
+        return fmt.Errorf("user: %s already exists", username)
+    } else if err != ErrUserNotFound {
+        return err
+    }
+    fmt.Fprintf(os.Stderr, "password for %s: ", username)
+    password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && err != io.EOF {
+        return err
+    }
+    password = strings.TrimRight(password, "\r\n")
+    if err := ValidatePassword(password); err != nil {
+        return err
+    }
+    return CreateUser(username, password)
+}
+
+// no admin yet (fresh installation): say how to make one, registration does not
+func warnIfNoAdmin() {
+    users, err := userStore.List()
+    if err != nil {
+        log.Println("admin check:", err)
+        return
+    }
+    for _, u := range users {
+        if u.Role == RoleAdmin && !u.Disabled {
+            return
+        }
+    }
+    log.Println("no admin account yet, create one with: my-tracker user add <username> admin (password on stdin)")
+}
@@ -1,40 +1,49 @@
 package main
 
 import (
+    "errors"
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
     "net/http"
     "time"
+    "strconv"
     "strings"
 )
 
-var jwtSecret = []byte("your-super-secret-jwt-key-change-in-production")
-
 type Claims struct {
-    UserID   int    `json:"user_id"`
-    Username string `json:"username"`
+    UserID      int    `json:"user_id"`
+    Username    string `json:"username"`
+    AuthVersion int    `json:"ver"` // users.auth_version, older tokens die with a password change
+    Role        string `json:"role"` // a role change also changes auth_version
     jwt.RegisteredClaims
 }
 
-// 
-func GenerateJWT(userID int, username string) (string, error) {
+// short lived access token, jti lets logout revoke it before it expires
+func GenerateJWT(user *User) (string, error) {
+    jti, err := randomToken()
+    if err != nil {
+        return "", err
+    }
     claims := Claims{
-        UserID:   userID,
-        Username: username,
+        UserID:      user.ID,
+        Username:    user.Username,
+        AuthVersion: user.AuthVersion,
+        Role:        user.Role,
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+            ID:        jti,
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.JWTTTL)),
             IssuedAt:  jwt.NewNumericDate(time.Now()),
         },
     }
     
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString(jwtSecret)
+    return token.SignedString([]byte(config.JWTSecret))
 }
 
 // Проверка JWT 
 func ValidateJWT(tokenString string) (*Claims, error) {
     token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-        return jwtSecret, nil
+        return []byte(config.JWTSecret), nil
     })
     
     if err != nil {
@@ -65,19 +74,71 @@ func JWTAuthMiddleware() gin.HandlerFunc {
             return
         }
         
+        if isPersonalToken(parts[1]) {
+            personalTokenAuth(c, parts[1])
+            return
+        }
+        
         claims, err := ValidateJWT(parts[1])
-        if err != nil {
+        if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
             c.Abort()
             return
         }
         
+        revoked, err := tokenStore.IsAccessRevoked(claims.ID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+            c.Abort()
+            return
+        }
+        if revoked {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+            c.Abort()
+            return
+        }
+        user, err := userStore.GetByID(claims.UserID)
+        if err != nil && err != ErrUserNotFound {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+            c.Abort()
+            return
+        }
+        if err == ErrUserNotFound || user.AuthVersion != claims.AuthVersion || user.Disabled {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+            c.Abort()
+            return
+        }
+        
         c.Set("user_id", claims.UserID)
         c.Set("username", claims.Username)
+        c.Set("role", claims.Role)
+        c.Set("jti", claims.ID)
+        c.Set("token_expires_at", claims.ExpiresAt.Time)
         c.Next()
     }
 }
 
+// personal access token instead of a JWT, scopes are checked by RequireScope
+func personalTokenAuth(c *gin.Context, token string) {
+    t, err := patStore.Authenticate(hashToken(token), time.Now())
+    if err == ErrPersonalTokenNotFound {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+        c.Abort()
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        c.Abort()
+        return
+    }
+    
+    c.Set("user_id", t.UserID)
+    c.Set("username", t.Username)
+    c.Set("role", t.Role)
+    c.Set("token_scopes", t.Scopes)
+    c.Next()
+}
+
 // API: 
 func APILogin(c *gin.Context) {
     var req struct {
@@ -90,25 +151,123 @@ func APILogin(c *gin.Context) {
         return
     }
     
+    attempt := LoginAttempt{Username: req.Username, IP: c.ClientIP(), Channel: ChannelAPI}
+    if err := CheckLoginAllowed(&attempt); err != nil {
+        apiLoginRefused(c, err)
+        return
+    }
+    defer FinishLoginAttempt(&attempt)
+    
+    // unknown user and wrong password look the same, also in time
     user, err := GetUserByUsername(req.Username)
-    if err != nil || !CheckPassword(req.Password, user.Password) {
+    if err != nil && err != ErrUserNotFound {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if !CheckPasswordOrDummy(user, req.Password) {
+        RecordLoginFailure(&attempt, LoginFailPassword)
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
     }
+    if user.Disabled {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+        return
+    }
+    
+    // with 2FA the tokens come from /auth/2fa/verify
+    if user.TOTPEnabled {
+        challenge, err := NewMFAChallenge(user.ID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{
+            "mfa_required": true,
+            "challenge":    challenge,
+            "expires_in":   int(mfaChallengeTTL / time.Second),
+        })
+        return
+    }
     
-    token, err := GenerateJWT(user.ID, user.Username)
+    RecordLoginSuccess(attempt)
+    tokens, err := IssueTokens(user)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
         return
     }
     
-    c.JSON(http.StatusOK, gin.H{
-        "token": token,
+    c.JSON(http.StatusOK, tokensJSON(tokens, user))
+}
+
+// 429 with Retry-After for throttled logins
+func apiLoginRefused(c *gin.Context, err error) {
+    if t, ok := err.(*LoginThrottledError); ok {
+        c.Header("Retry-After", strconv.Itoa(t.Seconds()))
+        c.JSON(http.StatusTooManyRequests, gin.H{
+            "error":       "Too many login attempts",
+            "retry_after": t.Seconds(),
+        })
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+}
+
+// token = access token (JWT), refresh_token is exchanged at /auth/refresh
+func tokensJSON(tokens *TokenPair, user *User) gin.H {
+    return gin.H{
+        "token":         tokens.AccessToken,
+        "refresh_token": tokens.RefreshToken,
+        "expires_in":    tokens.ExpiresIn,
         "user": gin.H{
             "id":       user.ID,
             "username": user.Username,
+            "role":     user.Role,
         },
-    })
+    }
+}
+
+// API: new token pair for a refresh token, the old refresh token is used up
+func APIRefreshToken(c *gin.Context) {
+    var req struct {
+        RefreshToken string `json:"refresh_token" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    
+    tokens, user, err := RefreshTokens(req.RefreshToken)
+    if err == ErrInvalidRefreshToken || err == ErrRefreshTokenReused {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
+    
+    c.JSON(http.StatusOK, tokensJSON(tokens, user))
+}
+
+// API: revoke the current access token and, if given, the refresh token family
+func APILogout(c *gin.Context) {
+    var req struct {
+        RefreshToken string `json:"refresh_token"`
+    }
+    // body is optional
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+            return
+        }
+    }
+    
+    err := RevokeTokens(c.GetInt("user_id"), c.GetString("jti"), c.GetTime("token_expires_at"), req.RefreshToken)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.Status(http.StatusNoContent)
 }
 
 // API:
@@ -116,12 +275,18 @@ func APIRegister(c *gin.Context) {
     var req struct {
         Username string `json:"username" binding:"required,min=3"`
         Password string `json:"password" binding:"required,min=6"`
+        Email    string `json:"email"`
     }
     
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
         return
     }
+    email, err := NormalizeEmail(req.Email)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+        return
+    }
     
     existingUser, _ := GetUserByUsername(req.Username)
     if existingUser != nil {
@@ -129,183 +294,285 @@ func APIRegister(c *gin.Context) {
         return
     }
     
-    err := CreateUser(req.Username, req.Password)
+    err = CreateUser(req.Username, req.Password)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
         return
     }
     
-    user, _ := GetUserByUsername(req.Username)
-    token, _ := GenerateJWT(user.ID, user.Username)
+    user, err := GetUserByUsername(req.Username)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+        return
+    }
+    // the email is set from the link mailed to it, a taken one is not told apart
+    if email != "" {
+        if err := RequestEmailChange(user, email); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save email"})
+            return
+        }
+    }
+    tokens, err := IssueTokens(user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
     
-    c.JSON(http.StatusCreated, gin.H{
-        "token": token,
-        "user": gin.H{
-            "id":       user.ID,
-            "username": user.Username,
-        },
-    })
+    c.JSON(http.StatusCreated, tokensJSON(tokens, user))
 }
 
 // API: 
 func APIGetWorkLogs(c *gin.Context) {
     userID := c.GetInt("user_id")
     
-    dateFrom := c.Query("date_from")
-    dateTo := c.Query("date_to")
-    search := c.Query("search")
-    
-    query := `SELECT id, date, description, hours FROM worklogs WHERE user_id = ?`
-    args := []interface{}{userID}
-    
-    if dateFrom != "" {
-        query += ` AND date >= ?`
-        args = append(args, dateFrom)
-    }
-    if dateTo != "" {
-        query += ` AND date <= ?`
-        args = append(args, dateTo)
-    }
-    if search != "" {
-        query += ` AND description LIKE ?`
-        args = append(args, "%"+search+"%")
+    // date_from, date_to, search, project_id, tag, sort, limit, offset
+    filter, err := ParseWorkLogFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
     }
     
-    query += ` ORDER BY date DESC`
-    
-    rows, err := db.Query(query, args...)
+    logs, err := worklogStore.List(userID, filter)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
         return
     }
-    defer rows.Close()
     
-    var logs []map[string]interface{}
-    for rows.Next() {
-        var id int
-        var date, description string
-        var hours float64
-        rows.Scan(&id, &date, &description, &hours)
-        
-        logs = append(logs, map[string]interface{}{
-            "id":          id,
-            "date":        date,
-            "description": description,
-            "hours":       hours,
-        })
+    var data []gin.H
+    for _, log := range logs {
+        data = append(data, workLogJSON(log))
     }
     
-    c.JSON(http.StatusOK, gin.H{"data": logs})
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: file download like /worklog/export, same filters and formats
+func APIExportWorkLogs(c *gin.Context) {
+    filter, err := ParseWorkLogFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    opts, err := ParseExportOptions(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    setExportHeaders(c, c.GetString("username"), opts)
+    if err := WriteWorkLogExport(c.Writer, c.GetInt("user_id"), filter, opts); err != nil && !exportInterrupted(c, err) {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// worklog as returned by the API
+func workLogJSON(log WorkLog) gin.H {
+    return gin.H{
+        "id":            log.ID,
+        "project_id":    nullID(log.ProjectID),
+        "project":       log.ProjectName,
+        "date":          log.Date.Format("2006-01-02"),
+        "start_time":    nullString(log.StartTime),
+        "end_time":      nullString(log.EndTime),
+        "break_minutes": log.BreakMinutes,
+        "description":   log.Description,
+        "hours":         log.Hours,
+        "billable":      log.Billable,
+        "invoice_id":    nullID(log.InvoiceID),
+        "tags":          tagsJSON(log.Tags),
+    }
+}
+
+// [] instead of null for entries without tags
+func tagsJSON(tags []string) []string {
+    if tags == nil {
+        return []string{}
+    }
+    return tags
+}
+
+// request body for create and update
+type workLogRequest struct {
+    Date         string   `json:"date" binding:"required"`
+    Description  string   `json:"description" binding:"required"`
+    Hours        float64  `json:"hours" binding:"min=0,max=24"` // ignored when start_time/end_time are set
+    StartTime    string   `json:"start_time"`
+    EndTime      string   `json:"end_time"`
+    BreakMinutes int      `json:"break_minutes" binding:"min=0"`
+    ProjectID    int      `json:"project_id" binding:"min=0"`
+    Tags         []string `json:"tags"`
+    Billable     bool     `json:"billable"`
+}
+
+func (req workLogRequest) toWorkLog(userID int) (*WorkLog, error) {
+    date, err := time.Parse("2006-01-02", req.Date)
+    if err != nil {
+        return nil, err
+    }
+    return &WorkLog{
+        UserID:       userID,
+        ProjectID:    req.ProjectID,
+        Date:         date,
+        StartTime:    req.StartTime,
+        EndTime:      req.EndTime,
+        BreakMinutes: req.BreakMinutes,
+        Description:  req.Description,
+        Hours:        req.Hours,
+        Tags:         req.Tags,
+        Billable:     req.Billable,
+    }, nil
+}
+
+// worklog rule errors of a change as API responses: 409 for invoiced worklogs,
+// submitted or approved weeks and closed periods, and for overlaps the store found at the write;
+// anything else is a database error
+func apiWorkLogLocked(c *gin.Context, err error) {
+    if isWorkLogRuleError(err) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+}
+
+// PrepareWorkLog errors as API responses, false if the request was answered
+func apiPrepareWorkLog(c *gin.Context, log *WorkLog, overrideLock bool) bool {
+    err := PrepareWorkLog(log, overrideLock)
+    switch {
+    case err == nil:
+        return true
+    case errors.Is(err, ErrProjectNotFound):
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+    case errors.Is(err, ErrOverlap), errors.Is(err, ErrDayLimit), errors.Is(err, ErrWeekApproved), errors.Is(err, ErrWeekSubmitted),
+        errors.Is(err, ErrPeriodLocked):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case isWorkLogRuleError(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+    return false
 }
 
 // API:
 func APICreateWorkLog(c *gin.Context) {
     userID := c.GetInt("user_id")
     
-    var req struct {
-        Date        string  `json:"date" binding:"required"`
-        Description string  `json:"description" binding:"required"`
-        Hours       float64 `json:"hours" binding:"required,min=0,max=24"`
-    }
-    
+    var req workLogRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
         return
     }
     
-    result, err := db.Exec(
-        "INSERT INTO worklogs (user_id, date, description, hours) VALUES (?, ?, ?, ?)",
-        userID, req.Date, req.Description, req.Hours,
-    )
-    
+    log, err := req.toWorkLog(userID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create worklog"})
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+        return
+    }
+    a := apiAudit(c)
+    if !apiPrepareWorkLog(c, log, a.OverrideLock) {
         return
     }
     
-    id, _ := result.LastInsertId()
+    if err := worklogStore.Create(log, a); err != nil {
+        if isWorkLogRuleError(err) {
+            apiWorkLogLocked(c, err)
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create worklog"})
+        return
+    }
     
     c.JSON(http.StatusCreated, gin.H{
         "message": "Worklog created",
-        "id":      id,
+        "id":      log.ID,
     })
 }
 
-// API: 
+// API: ownership checked by WorkLogOwnerRequired
 func APIUpdateWorkLog(c *gin.Context) {
     userID := c.GetInt("user_id")
-    id := c.Param("id")
-    
-    var req struct {
-        Date        string  `json:"date" binding:"required"`
-        Description string  `json:"description" binding:"required"`
-        Hours       float64 `json:"hours" binding:"required,min=0,max=24"`
-    }
+    id := CurrentWorkLog(c).ID
     
+    var req workLogRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
         return
     }
     
-    result, err := db.Exec(
-        "UPDATE worklogs SET date = ?, description = ?, hours = ? WHERE id = ? AND user_id = ?",
-        req.Date, req.Description, req.Hours, id, userID,
-    )
-    
+    log, err := req.toWorkLog(userID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worklog"})
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+        return
+    }
+    log.ID = id
+    a := apiAudit(c)
+    if err := CheckWorkLogEditable(CurrentWorkLog(c), a.OverrideLock); err != nil {
+        apiWorkLogLocked(c, err)
+        return
+    }
+    if !apiPrepareWorkLog(c, log, a.OverrideLock) {
         return
     }
     
-    rows, _ := result.RowsAffected()
-    if rows == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Worklog not found"})
+    err = worklogStore.Update(log, a)
+    if err == ErrWorkLogNotFound {
+        APIWorkLogNotFound(c)
+        return
+    }
+    if isWorkLogRuleError(err) {
+        apiWorkLogLocked(c, err)
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worklog"})
         return
     }
     
     c.JSON(http.StatusOK, gin.H{"message": "Worklog updated"})
 }
 
-// API:
+// API: ownership checked by WorkLogOwnerRequired
 func APIDeleteWorkLog(c *gin.Context) {
     userID := c.GetInt("user_id")
-    id := c.Param("id")
+    id := CurrentWorkLog(c).ID
     
-    result, err := db.Exec("DELETE FROM worklogs WHERE id = ? AND user_id = ?", id, userID)
-    
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete worklog"})
+    a := apiAudit(c)
+    if err := CheckWorkLogEditable(CurrentWorkLog(c), a.OverrideLock); err != nil {
+        apiWorkLogLocked(c, err)
         return
     }
-    
-    rows, _ := result.RowsAffected()
-    if rows == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Worklog not found"})
+    err := worklogStore.Delete(userID, id, a)
+    if err == ErrWorkLogNotFound {
+        APIWorkLogNotFound(c)
+        return
+    }
+    if isWorkLogRuleError(err) {
+        apiWorkLogLocked(c, err)
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete worklog"})
         return
     }
     
-    c.JSON(http.StatusOK, gin.H{"message": "Worklog deleted"})
+    c.JSON(http.StatusOK, gin.H{"message": "Worklog moved to trash"})
 }
 
 // API: 
 func APIGetStats(c *gin.Context) {
     userID := c.GetInt("user_id")
     
-    rows, err := db.Query("SELECT date, hours FROM worklogs WHERE user_id = ? ORDER BY date ASC", userID)
+    logs, err := worklogStore.List(userID, WorkLogFilter{Sort: SortDateAsc})
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
         return
     }
-    defer rows.Close()
     
     var totalHours float64
     var daysCount int
     
-    for rows.Next() {
-        var date string
-        var hours float64
-        rows.Scan(&date, &hours)
-        totalHours += hours
+    for _, log := range logs {
+        totalHours += log.Hours
         daysCount++
     }
     
@@ -314,9 +581,29 @@ func APIGetStats(c *gin.Context) {
         avgHours = totalHours / float64(daysCount)
     }
     
+    book, err := LoadRateBook(userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    billing := book.Summarize(logs)
+    
+    // an entry with several tags counts for each of them
+    byTag, untagged := HoursByTag(logs)
+    tags := []gin.H{}
+    for _, t := range byTag {
+        tags = append(tags, gin.H{"tag": t.Tag, "hours": t.Hours})
+    }
+    
     c.JSON(http.StatusOK, gin.H{
-        "total_hours": totalHours,
-        "days_count":  daysCount,
-        "avg_hours":   avgHours,
+        "total_hours":        totalHours,
+        "days_count":         daysCount,
+        "avg_hours":          avgHours,
+        "tags":               tags,
+        "untagged_hours":     untagged,
+        "billable_hours":     billing.BillableHours,
+        "non_billable_hours": billing.NonBillableHours,
+        "amount":             billing.Amount,
+        "currency":           config.Currency,
     })
 }
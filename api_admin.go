@@ -0,0 +1,223 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func adminUserJSON(user *User) gin.H {
+    return gin.H{
+        "id":           user.ID,
+        "username":     user.Username,
+        "email":        user.Email,
+        "role":         user.Role,
+        "disabled":     user.Disabled,
+        "totp_enabled": user.TOTPEnabled,
+    }
+}
+
+// errors of the admin actions
+func apiAdminError(c *gin.Context, err error) {
+    switch err {
+    case ErrOwnAccount:
+        c.JSON(http.StatusConflict, gin.H{"error": "Can not change your own account"})
+    case ErrUserNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+    case ErrInvalidRole, ErrPasswordTooShort:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// actor and :id of an admin action, false if the answer is already sent
+func apiAdminTarget(c *gin.Context) (*User, int, bool) {
+    actor, ok := apiCurrentUser(c)
+    if !ok {
+        return nil, 0, false
+    }
+    userID, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        apiAdminError(c, ErrUserNotFound)
+        return nil, 0, false
+    }
+    return actor, userID, true
+}
+
+func APIAdminGetUsers(c *gin.Context) {
+    users, err := userStore.List()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    data := make([]gin.H, 0, len(users))
+    for i := range users {
+        data = append(data, adminUserJSON(&users[i]))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: {"role": "manager"} and/or {"disabled": true}
+func APIAdminUpdateUser(c *gin.Context) {
+    var req struct {
+        Role     *string `json:"role"`
+        Disabled *bool   `json:"disabled"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    actor, userID, ok := apiAdminTarget(c)
+    if !ok {
+        return
+    }
+
+    if req.Role != nil {
+        if err := AdminSetRole(actor, userID, *req.Role); err != nil {
+            apiAdminError(c, err)
+            return
+        }
+    }
+    if req.Disabled != nil {
+        if err := AdminSetDisabled(actor, userID, *req.Disabled); err != nil {
+            apiAdminError(c, err)
+            return
+        }
+    }
+
+    user, err := userStore.GetByID(userID)
+    if err != nil {
+        apiAdminError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, adminUserJSON(user))
+}
+
+func APIAdminDeleteUser(c *gin.Context) {
+    actor, userID, ok := apiAdminTarget(c)
+    if !ok {
+        return
+    }
+    if err := AdminDeleteUser(actor, userID); err != nil {
+        apiAdminError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// API: {"password": "..."}, the user's sessions and tokens are revoked
+func APIAdminResetPassword(c *gin.Context) {
+    var req struct {
+        Password string `json:"password" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    actor, userID, ok := apiAdminTarget(c)
+    if !ok {
+        return
+    }
+    if err := AdminResetPassword(actor, userID, req.Password); err != nil {
+        apiAdminError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+func APIAdminResetTwoFactor(c *gin.Context) {
+    actor, userID, ok := apiAdminTarget(c)
+    if !ok {
+        return
+    }
+    if err := AdminResetTwoFactor(actor, userID); err != nil {
+        apiAdminError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+func lockJSON(l PeriodLock) gin.H {
+    return gin.H{
+        "user_id":    l.UserID, // 0 = global
+        "username":   l.Username,
+        "lock_date":  l.LockDate.Format("2006-01-02"),
+        "updated_by": l.UpdatedBy,
+        "updated_at": l.UpdatedAt,
+    }
+}
+
+func apiLockError(c *gin.Context, err error) {
+    switch err {
+    case ErrInvalidLockDate:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case ErrUserNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+    case ErrPeriodLockNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "Lock not found"})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// actor and user of /admin/locks/users/:id, user 0 on /admin/locks/global
+func apiLockTarget(c *gin.Context) (*User, int, bool) {
+    if c.Param("id") == "" {
+        actor, ok := apiCurrentUser(c)
+        return actor, 0, ok
+    }
+    return apiAdminTarget(c)
+}
+
+// API: {"global": {...} or null, "users": [...]}
+func APIAdminGetLocks(c *gin.Context) {
+    locks, err := lockStore.List()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    var global interface{}
+    users := []gin.H{}
+    for _, l := range locks {
+        if l.UserID == 0 {
+            global = lockJSON(l)
+        } else {
+            users = append(users, lockJSON(l))
+        }
+    }
+    c.JSON(http.StatusOK, gin.H{"global": global, "users": users})
+}
+
+// API: {"lock_date": "2026-09-30"}, worklogs up to and including that day are closed
+func APIAdminSetLock(c *gin.Context) {
+    var req struct {
+        LockDate string `json:"lock_date" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    actor, userID, ok := apiLockTarget(c)
+    if !ok {
+        return
+    }
+    if err := SetPeriodLock(actor, userID, req.LockDate); err != nil {
+        apiLockError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"user_id": userID, "lock_date": req.LockDate})
+}
+
+func APIAdminDeleteLock(c *gin.Context) {
+    actor, userID, ok := apiLockTarget(c)
+    if !ok {
+        return
+    }
+    if err := ClearPeriodLock(actor, userID); err != nil {
+        apiLockError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func changeJSON(ch WorkLogChange) gin.H {
+    version := func(log *WorkLog) interface{} {
+        if log == nil {
+            return nil
+        }
+        return workLogJSON(*log)
+    }
+    return gin.H{
+        "id":            ch.ID,
+        "worklog_id":    ch.WorkLogID,
+        "action":        ch.Action,
+        "old":           version(ch.Old),
+        "new":           version(ch.New),
+        "restored_from": nullID(ch.RestoredFrom),
+        "actor":         ch.ActorName,
+        "actor_id":      nullID(ch.ActorID),
+        "ip":            ch.IP,
+        "channel":       ch.Channel,
+        "lock_override": ch.LockOverride,
+        "created_at":    ch.CreatedAt,
+    }
+}
+
+func changesJSON(changes []WorkLogChange) []gin.H {
+    result := make([]gin.H, 0, len(changes))
+    for _, ch := range changes {
+        result = append(result, changeJSON(ch))
+    }
+    return result
+}
+
+// API: latest changes of own worklogs, newest first
+func APIGetWorkLogHistory(c *gin.Context) {
+    changes, err := historyStore.Recent(c.GetInt("user_id"), recentHistory)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"history": changesJSON(changes)})
+}
+
+// API: all versions of one worklog, oldest first; works for deleted ones too
+func APIGetWorkLogVersions(c *gin.Context) {
+    worklogID, _ := strconv.Atoi(c.Param("id"))
+    changes, err := historyStore.ForWorkLog(c.GetInt("user_id"), worklogID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if len(changes) == 0 {
+        APIWorkLogNotFound(c)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"history": changesJSON(changes)})
+}
+
+// API: :id is the history entry whose version comes back
+func APIRestoreWorkLog(c *gin.Context) {
+    historyID, _ := strconv.Atoi(c.Param("id"))
+    restored, err := RestoreWorkLogVersion(c.GetInt("user_id"), historyID, apiAudit(c))
+    switch {
+    case err == nil:
+        c.JSON(http.StatusOK, workLogJSON(*restored))
+    case errors.Is(err, ErrHistoryNotFound):
+        c.JSON(http.StatusNotFound, gin.H{"error": "History entry not found"})
+    case errors.Is(err, ErrNothingToRestore), errors.Is(err, ErrProjectNotFound):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case errors.Is(err, ErrWorkLogNotFound), errors.Is(err, ErrWorkLogPurged), isWorkLogRuleError(err):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "errors"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// API: multipart "file", or the file as the body (text/csv or the xlsx type, ?filename= optional).
+// ?source=toggl|clockify|harvest for exports of those trackers (csv or json), ?dry_run=true only
+// checks, ?include_duplicates=true imports rows that exist already, ?override_lock=true like on create.
+func APIImportWorkLogs(c *gin.Context) {
+    filename, content, err := apiImportUpload(c)
+    if err != nil {
+        apiImportError(c, err)
+        return
+    }
+
+    opts := ImportOptions{
+        DryRun:            c.Query("dry_run") == "true",
+        IncludeDuplicates: c.Query("include_duplicates") == "true",
+    }
+    var result *ImportResult
+    var mapping *ImportMapping
+    if source := c.Query("source"); source == "" {
+        result, err = ImportWorkLogs(c.GetInt("user_id"), filename, content, opts, apiAudit(c))
+    } else {
+        result, mapping, err = ImportTrackerFile(c.GetInt("user_id"), source, filename, content, opts, apiAudit(c))
+    }
+    if err != nil {
+        apiImportError(c, err)
+        return
+    }
+
+    rows := make([]gin.H, 0, len(result.Rows))
+    for _, row := range result.Rows {
+        entry := gin.H{"line": row.Line, "status": "ok", "error": nil, "worklog": nil}
+        switch {
+        case row.Err != nil:
+            entry["status"], entry["error"] = "error", row.Err.Error()
+        case row.Duplicate:
+            entry["status"] = "duplicate"
+        }
+        if row.Err == nil {
+            entry["worklog"] = workLogJSON(row.Log)
+        }
+        rows = append(rows, entry)
+    }
+    status := http.StatusOK
+    switch {
+    case result.Imported:
+        status = http.StatusCreated
+    case !opts.DryRun:
+        // nothing valid, nothing created
+        status = http.StatusUnprocessableEntity
+    }
+    c.JSON(status, gin.H{
+        "mapping":    mappingJSON(mapping),
+        "dry_run":    opts.DryRun,
+        "imported":   result.Imported,
+        "valid":      result.Valid,
+        "invalid":    result.Invalid,
+        "duplicates": result.Duplicates,
+        "hours":      result.Hours,
+        "rows":       rows,
+    })
+}
+
+// null for spreadsheet imports
+func mappingJSON(m *ImportMapping) interface{} {
+    if m == nil {
+        return nil
+    }
+    projects := make([]gin.H, 0, len(m.Projects))
+    for _, p := range m.Projects {
+        projects = append(projects, gin.H{
+            "name":       p.Name,
+            "client":     nullString(p.Client),
+            "project_id": nullID(p.ID),
+            "new":        p.New,
+            "entries":    p.Entries,
+        })
+    }
+    ignored := m.IgnoredFields
+    if ignored == nil {
+        ignored = []string{}
+    }
+    return gin.H{
+        "source":         m.Source,
+        "projects":       projects,
+        "ignored_fields": ignored,
+        "skipped":        m.Skipped,
+    }
+}
+
+func apiImportError(c *gin.Context, err error) {
+    if errors.Is(err, ErrImportChanged) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if isImportFileError(err) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+}
+
+func apiImportUpload(c *gin.Context) (string, []byte, error) {
+    mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+    if mediaType == "multipart/form-data" {
+        header, err := c.FormFile("file")
+        if errors.Is(err, http.ErrMissingFile) {
+            return "", nil, ErrImportEmpty
+        }
+        if err != nil {
+            return "", nil, ErrImportFormat
+        }
+        if header.Size > maxImportSize {
+            return "", nil, ErrImportTooLarge
+        }
+        f, err := header.Open()
+        if err != nil {
+            return "", nil, err
+        }
+        defer f.Close()
+        content, err := io.ReadAll(f)
+        return header.Filename, content, err
+    }
+
+    content, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize+1))
+    if err != nil {
+        return "", nil, err
+    }
+    if len(content) > maxImportSize {
+        return "", nil, ErrImportTooLarge
+    }
+    filename := c.Query("filename")
+    if filename == "" {
+        switch {
+        case strings.Contains(mediaType, "spreadsheetml"):
+            filename = "import.xlsx"
+        case mediaType == "text/csv":
+            filename = "import.csv"
+        case mediaType == "application/json":
+            filename = "import.json"
+        }
+    }
+    return filename, content, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+    "errors"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+)
+
+func invoiceJSON(inv *Invoice) gin.H {
+    data := gin.H{
+        "id":           inv.ID,
+        "number":       inv.Number,
+        "client_id":    nullID(inv.ClientID),
+        "client":       inv.ClientName,
+        "date_from":    inv.PeriodFrom.Format("2006-01-02"),
+        "date_to":      inv.PeriodTo.Format("2006-01-02"),
+        "currency":     inv.Currency,
+        "total_hours":  inv.TotalHours,
+        "total_amount": inv.TotalAmount,
+    }
+    if !inv.IssuedAt.IsZero() {
+        data["issued_at"] = inv.IssuedAt.UTC().Format("2006-01-02T15:04:05Z")
+    }
+    if inv.Status != "" {
+        data["status"] = inv.Status // a draft has none
+    }
+    if !inv.CancelledAt.IsZero() {
+        data["cancelled_at"] = inv.CancelledAt.UTC().Format("2006-01-02T15:04:05Z")
+    }
+    if inv.Lines != nil {
+        lines := []gin.H{}
+        for _, line := range inv.Lines {
+            lines = append(lines, gin.H{
+                "worklog_id":  nullID(line.WorkLogID),
+                "date":        line.Date.Format("2006-01-02"),
+                "project":     line.ProjectName,
+                "description": line.Description,
+                "hours":       line.Hours,
+                "rate":        line.Rate,
+                "amount":      line.Amount,
+            })
+        }
+        data["lines"] = lines
+    }
+    return data
+}
+
+type invoiceRequest struct {
+    ClientID int    `json:"client_id" binding:"required,min=1"`
+    DateFrom string `json:"date_from" binding:"required"`
+    DateTo   string `json:"date_to" binding:"required"`
+    DryRun   bool   `json:"dry_run"` // only return the draft
+    // fingerprint of the dry run: issue only what it returned, 409 when it changed since
+    Fingerprint string `json:"fingerprint"`
+}
+
+// API: newest first, without lines
+func APIGetInvoices(c *gin.Context) {
+    invoices, err := invoiceStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for i := range invoices {
+        data = append(data, invoiceJSON(&invoices[i]))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: invoice for uninvoiced billable worklogs of a client in a period
+func APICreateInvoice(c *gin.Context) {
+    var req invoiceRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    from, to, err := parseInvoicePeriod(req.DateFrom, req.DateTo)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    userID := c.GetInt("user_id")
+    var inv *Invoice
+    if req.DryRun {
+        inv, err = DraftInvoice(userID, req.ClientID, from, to)
+    } else {
+        inv, err = IssueInvoice(userID, req.ClientID, from, to, req.Fingerprint)
+    }
+    switch {
+    case err == nil:
+    case errors.Is(err, ErrClientNotFound):
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+        return
+    case errors.Is(err, ErrNothingToInvoice), errors.Is(err, ErrAlreadyInvoiced), errors.Is(err, ErrDraftChanged):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invoice"})
+        return
+    }
+
+    if req.DryRun {
+        data := invoiceJSON(inv)
+        data["fingerprint"] = inv.Fingerprint()
+        c.JSON(http.StatusOK, gin.H{"data": data})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{
+        "message": "Invoice created",
+        "data":    invoiceJSON(inv),
+    })
+}
+
+// invoice of the current user, false (and 404 sent) otherwise
+func apiCurrentInvoice(c *gin.Context) (*Invoice, bool) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err == nil {
+        inv, err := invoiceStore.Get(c.GetInt("user_id"), id)
+        if err == nil {
+            return inv, true
+        }
+        if err != ErrInvoiceNotFound {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+            return nil, false
+        }
+    }
+    c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+    return nil, false
+}
+
+// API: with lines
+func APIGetInvoice(c *gin.Context) {
+    inv, ok := apiCurrentInvoice(c)
+    if !ok {
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"data": invoiceJSON(inv)})
+}
+
+func APIInvoicePDF(c *gin.Context) {
+    inv, ok := apiCurrentInvoice(c)
+    if !ok {
+        return
+    }
+    c.Header("Content-Type", "application/pdf")
+    c.Header("Content-Disposition", "attachment; filename=invoice_"+inv.Number+".pdf")
+    if err := WriteInvoicePDF(c.Writer, inv); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+    }
+}
+
+func APIInvoiceXLSX(c *gin.Context) {
+    inv, ok := apiCurrentInvoice(c)
+    if !ok {
+        return
+    }
+    c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+    c.Header("Content-Disposition", "attachment; filename=invoice_"+inv.Number+".xlsx")
+    if err := WriteInvoiceXLSX(c.Writer, inv); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+    }
+}
+
+// API: cancel, the worklogs become editable again, the invoice stays with its number
+func APICancelInvoice(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := invoiceStore.Cancel(c.GetInt("user_id"), id)
+    if err == ErrInvoiceNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+        return
+    }
+    if err == ErrInvoiceCancelled {
+        c.JSON(http.StatusConflict, gin.H{"error": "Invoice is already cancelled"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel invoice"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Invoice cancelled"})
+}
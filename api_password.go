@@ -0,0 +1,163 @@
+package main
+
+import (
+    "log"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// API: mail a reset link; the answer does not tell whether the account exists
+func APIForgotPassword(c *gin.Context) {
+    var req struct {
+        Login string `json:"login" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    if err := RequestPasswordReset(req.Login); err != nil {
+        log.Println("password reset:", err)
+    }
+    c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and has an email, a reset link has been sent"})
+}
+
+// API: new password with the token from the reset link
+func APIResetPassword(c *gin.Context) {
+    var req struct {
+        Token    string `json:"token" binding:"required"`
+        Password string `json:"password" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    switch err := ResetPassword(req.Token, req.Password); err {
+    case nil:
+        c.Status(http.StatusNoContent)
+    case ErrPasswordTooShort:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case ErrInvalidResetToken:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+    }
+}
+
+func accountJSON(user *User) gin.H {
+    return gin.H{
+        "id":       user.ID,
+        "username": user.Username,
+        "email":    user.Email,
+    }
+}
+
+func APIGetAccount(c *gin.Context) {
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    c.JSON(http.StatusOK, accountJSON(user))
+}
+
+// API: set or remove ("") the email for reset links; a new email waits for the
+// link mailed to it (202), the same answer when another account has it
+func APIUpdateAccount(c *gin.Context) {
+    var req struct {
+        Email           string `json:"email"`
+        CurrentPassword string `json:"current_password" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+
+    pending, err := ChangeEmail(user, req.CurrentPassword, req.Email, LoginAttempt{IP: c.ClientIP(), Channel: ChannelAPI})
+    if t, ok := err.(*LoginThrottledError); ok {
+        apiLoginRefused(c, t)
+        return
+    }
+    switch err {
+    case nil:
+    case ErrWrongPassword:
+        c.JSON(http.StatusForbidden, gin.H{"error": "Current password is wrong"})
+        return
+    case ErrInvalidEmail:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if pending {
+        c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new email"})
+        return
+    }
+    APIGetAccount(c)
+}
+
+// API: the token from the confirmation mail sets the new email
+func APIConfirmEmail(c *gin.Context) {
+    var req struct {
+        Token string `json:"token" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    switch err := ConfirmEmail(req.Token); err {
+    case nil:
+        c.Status(http.StatusNoContent)
+    case ErrInvalidEmailToken:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+    case ErrEmailTaken:
+        c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm email"})
+    }
+}
+
+// API: change password; all other tokens die, the caller gets a new pair
+func APIChangePassword(c *gin.Context) {
+    var req struct {
+        CurrentPassword string `json:"current_password" binding:"required"`
+        NewPassword     string `json:"new_password" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+
+    user, err := ChangePassword(user, req.CurrentPassword, req.NewPassword, LoginAttempt{IP: c.ClientIP(), Channel: ChannelAPI})
+    if t, ok := err.(*LoginThrottledError); ok {
+        apiLoginRefused(c, t)
+        return
+    }
+    switch err {
+    case nil:
+    case ErrWrongPassword:
+        c.JSON(http.StatusForbidden, gin.H{"error": "Current password is wrong"})
+        return
+    case ErrPasswordTooShort:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+        return
+    }
+
+    tokens, err := IssueTokens(user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
+    c.JSON(http.StatusOK, tokensJSON(tokens, user))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+)
+
+func projectJSON(p Project) gin.H {
+    return gin.H{
+        "id":        p.ID,
+        "name":      p.Name,
+        "client_id": nullID(p.ClientID),
+        "client":    p.ClientName,
+    }
+}
+
+func clientJSON(cl Client) gin.H {
+    return gin.H{
+        "id":   cl.ID,
+        "name": cl.Name,
+    }
+}
+
+type projectRequest struct {
+    Name     string `json:"name" binding:"required"`
+    ClientID int    `json:"client_id" binding:"min=0"`
+}
+
+type clientRequest struct {
+    Name string `json:"name" binding:"required"`
+}
+
+// API: 
+func APIGetProjects(c *gin.Context) {
+    projects, err := projectStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for _, p := range projects {
+        data = append(data, projectJSON(p))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: 
+func APIGetProject(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    p, err := projectStore.Get(c.GetInt("user_id"), id)
+    if err == ErrProjectNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"data": projectJSON(*p)})
+}
+
+// API: 
+func APICreateProject(c *gin.Context) {
+    var req projectRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    p := &Project{UserID: c.GetInt("user_id"), ClientID: req.ClientID, Name: req.Name}
+    if err := ValidateProjectClient(p); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+        return
+    }
+
+    if err := projectStore.Create(p); err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "Project already exists"})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{
+        "message": "Project created",
+        "id":      p.ID,
+    })
+}
+
+// API: 
+func APIUpdateProject(c *gin.Context) {
+    var req projectRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    p := &Project{UserID: c.GetInt("user_id"), ClientID: req.ClientID, Name: req.Name}
+    p.ID, _ = strconv.Atoi(c.Param("id"))
+    if err := ValidateProjectClient(p); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+        return
+    }
+
+    err := projectStore.Update(p)
+    if err == ErrProjectNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "Failed to update project"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Project updated"})
+}
+
+// API: worklogs of the project stay, without project
+func APIDeleteProject(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := projectStore.Delete(c.GetInt("user_id"), id)
+    if err == ErrProjectNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Project deleted"})
+}
+
+// API: 
+func APIGetClients(c *gin.Context) {
+    clients, err := clientStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for _, cl := range clients {
+        data = append(data, clientJSON(cl))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: 
+func APICreateClient(c *gin.Context) {
+    var req clientRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    cl := &Client{UserID: c.GetInt("user_id"), Name: req.Name}
+    if err := clientStore.Create(cl); err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "Client already exists"})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{
+        "message": "Client created",
+        "id":      cl.ID,
+    })
+}
+
+// API: 
+func APIUpdateClient(c *gin.Context) {
+    var req clientRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    cl := &Client{UserID: c.GetInt("user_id"), Name: req.Name}
+    cl.ID, _ = strconv.Atoi(c.Param("id"))
+
+    err := clientStore.Update(cl)
+    if err == ErrClientNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "Failed to update client"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Client updated"})
+}
+
+// API: projects of the client stay, without client
+func APIDeleteClient(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := clientStore.Delete(c.GetInt("user_id"), id)
+    if err == ErrClientNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
+}
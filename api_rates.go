@@ -0,0 +1,100 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+func rateJSON(r HourlyRate) gin.H {
+    var from interface{}
+    if !r.EffectiveFrom.IsZero() {
+        from = r.EffectiveFrom.Format("2006-01-02")
+    }
+    return gin.H{
+        "id":             r.ID,
+        "project_id":     nullID(r.ProjectID),
+        "project":        r.ProjectName,
+        "client_id":      nullID(r.ClientID),
+        "client":         r.ClientName,
+        "rate":           r.Rate,
+        "effective_from": from,
+    }
+}
+
+// no project_id and no client_id = default rate of the user
+type rateRequest struct {
+    ProjectID     int     `json:"project_id" binding:"min=0"`
+    ClientID      int     `json:"client_id" binding:"min=0"`
+    Rate          float64 `json:"rate" binding:"min=0"`
+    EffectiveFrom string  `json:"effective_from"` // YYYY-MM-DD, empty = from the beginning
+}
+
+// API: all rates with their history
+func APIGetRates(c *gin.Context) {
+    rates, err := rateStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for _, r := range rates {
+        data = append(data, rateJSON(r))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data, "currency": config.Currency})
+}
+
+// API: a new rate, older rates of the same scope stay for earlier dates
+func APICreateRate(c *gin.Context) {
+    var req rateRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    r := &HourlyRate{
+        UserID:    c.GetInt("user_id"),
+        ProjectID: req.ProjectID,
+        ClientID:  req.ClientID,
+        Rate:      req.Rate,
+    }
+    if req.EffectiveFrom != "" {
+        from, err := time.Parse("2006-01-02", req.EffectiveFrom)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective_from, expected YYYY-MM-DD"})
+            return
+        }
+        r.EffectiveFrom = from
+    }
+    if err := ValidateRateScope(r); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id or client_id"})
+        return
+    }
+
+    if err := rateStore.Create(r); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rate"})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{
+        "message": "Rate created",
+        "id":      r.ID,
+    })
+}
+
+// API: 
+func APIDeleteRate(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := rateStore.Delete(c.GetInt("user_id"), id)
+    if err == ErrRateNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Rate not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rate"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Rate deleted"})
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+)
+
+// API: tags in use, tags are created by saving worklogs
+func APIGetTags(c *gin.Context) {
+    tags, err := tagStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for _, t := range tags {
+        data = append(data, gin.H{"id": t.ID, "name": t.Name})
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func teamJSON(t *Team) gin.H {
+    return gin.H{
+        "id":           t.ID,
+        "name":         t.Name,
+        "member_count": t.MemberCount,
+        "created_at":   t.CreatedAt,
+    }
+}
+
+// API: teams the caller may see (admin: all, manager: managed ones)
+func APIGetTeams(c *gin.Context) {
+    teams, err := VisibleTeams(c.GetInt("user_id"), CurrentRole(c))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    data := []gin.H{}
+    for i := range teams {
+        data = append(data, teamJSON(&teams[i]))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: team + members, the team is loaded by TeamViewerRequired
+func APIGetTeam(c *gin.Context) {
+    team := CurrentTeam(c)
+    members, err := teamStore.Members(team.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    list := []gin.H{}
+    for _, m := range members {
+        list = append(list, gin.H{
+            "user_id":  m.UserID,
+            "username": m.Username,
+            "role":     m.Role,
+            "disabled": m.Disabled,
+        })
+    }
+    data := teamJSON(team)
+    data["members"] = list
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func APICreateTeam(c *gin.Context) {
+    var req struct {
+        Name string `json:"name" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    team, err := CreateTeam(req.Name)
+    if err == ErrInvalidTeamName {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "Team already exists"})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{
+        "message": "Team created",
+        "id":      team.ID,
+    })
+}
+
+func APIDeleteTeam(c *gin.Context) {
+    if err := teamStore.Delete(CurrentTeam(c).ID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Team deleted"})
+}
+
+// API: {"username": "alice", "role": "member"}, adds the user or changes the team role
+func APISetTeamMember(c *gin.Context) {
+    var req struct {
+        Username string `json:"username" binding:"required"`
+        Role     string `json:"role"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    if req.Role == "" {
+        req.Role = TeamRoleMember
+    }
+    user, err := SetTeamMember(CurrentTeam(c).ID, req.Username, req.Role)
+    switch err {
+    case nil:
+    case ErrUserNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    case ErrInvalidTeamRole, ErrTeamManagerRole:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "user_id":  user.ID,
+        "username": user.Username,
+        "role":     req.Role,
+    })
+}
+
+func APIRemoveTeamMember(c *gin.Context) {
+    userID, err := strconv.Atoi(c.Param("user_id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+        return
+    }
+    err = teamStore.RemoveMember(CurrentTeam(c).ID, userID)
+    if err == ErrTeamMemberNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// API: hours per member and for the team, same filters as /worklogs (limit/offset ignored)
+func APIGetTeamStats(c *gin.Context) {
+    filter, err := ParseWorkLogFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    stats, err := TeamStatsFor(CurrentTeam(c).ID, filter)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    members := []gin.H{}
+    for _, ms := range stats.Members {
+        members = append(members, gin.H{
+            "user_id":            ms.UserID,
+            "username":           ms.Username,
+            "total_hours":        ms.Hours,
+            "entries":            ms.Entries,
+            "billable_hours":     ms.BillableHours,
+            "non_billable_hours": ms.NonBillableHours,
+            "amount":             ms.Amount,
+        })
+    }
+    tags := []gin.H{}
+    for _, t := range stats.Tags {
+        tags = append(tags, gin.H{"tag": t.Tag, "hours": t.Hours})
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "members":            members,
+        "total_hours":        stats.TotalHours,
+        "billable_hours":     stats.Billing.BillableHours,
+        "non_billable_hours": stats.Billing.NonBillableHours,
+        "amount":             stats.Billing.Amount,
+        "currency":           config.Currency,
+        "tags":               tags,
+        "untagged_hours":     stats.Untagged,
+    })
+}
+
+// API: worklogs of all members (or ?user_id=), read-only, same filters as /worklogs
+func APIGetTeamWorkLogs(c *gin.Context) {
+    filter, err := ParseWorkLogFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    ids, names, err := teamMemberIDs(CurrentTeam(c).ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    if v := c.Query("user_id"); v != "" {
+        userID, err := strconv.Atoi(v)
+        if _, ok := names[userID]; err != nil || !ok {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is not a member of the team"})
+            return
+        }
+        ids = []int{userID}
+    }
+
+    logs, err := worklogStore.ListForUsers(ids, filter)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    data := []gin.H{}
+    for _, log := range logs {
+        entry := workLogJSON(log)
+        entry["user_id"] = log.UserID
+        entry["username"] = names[log.UserID]
+        data = append(data, entry)
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "time"
+)
+
+func timerJSON(t *Timer) gin.H {
+    return gin.H{
+        "project_id":      nullID(t.ProjectID),
+        "project":         t.ProjectName,
+        "description":     t.Description,
+        "started_at":      t.StartedAt.UTC().Format(time.RFC3339),
+        "elapsed_seconds": int(time.Since(t.StartedAt).Seconds()),
+    }
+}
+
+// API: running timer or {"running": false}
+func APIGetTimer(c *gin.Context) {
+    t, err := timerStore.Get(c.GetInt("user_id"))
+    if err == ErrTimerNotRunning {
+        c.JSON(http.StatusOK, gin.H{"running": false})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"running": true, "timer": timerJSON(t)})
+}
+
+// API: 
+func APIStartTimer(c *gin.Context) {
+    var req struct {
+        Description string `json:"description"`
+        ProjectID   int    `json:"project_id" binding:"min=0"`
+    }
+    // empty body is fine
+    if c.Request.ContentLength != 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+            return
+        }
+    }
+
+    t, err := StartTimer(c.GetInt("user_id"), req.ProjectID, req.Description)
+    switch err {
+    case nil:
+    case ErrTimerRunning:
+        c.JSON(http.StatusConflict, gin.H{"error": "Timer already running"})
+        return
+    case ErrProjectNotFound:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start timer"})
+        return
+    }
+
+    c.JSON(http.StatusCreated, gin.H{"message": "Timer started", "timer": timerJSON(t)})
+}
+
+// API: creates the worklog; the timer also ends when nothing could be recorded,
+// "reason" then says why (or why the worklog has less than timer_hours)
+func APIStopTimer(c *gin.Context) {
+    stopped, err := StopTimer(c.GetInt("user_id"), apiAudit(c))
+    switch {
+    case err == nil:
+    case err == ErrTimerNotRunning:
+        c.JSON(http.StatusConflict, gin.H{"error": "Timer not running"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop timer"})
+        return
+    }
+
+    resp := gin.H{"message": "Timer stopped", "worklog": nil, "timer_hours": stopped.Hours}
+    if stopped.Log != nil {
+        resp["worklog"] = workLogJSON(*stopped.Log)
+    } else {
+        resp["message"] = "Timer stopped without a worklog"
+    }
+    if stopped.Problem != nil {
+        resp["reason"] = stopped.Problem.Error()
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// API: ends the timer, nothing is recorded
+func APIDiscardTimer(c *gin.Context) {
+    switch err := DiscardTimer(c.GetInt("user_id")); err {
+    case nil:
+    case ErrTimerNotRunning:
+        c.JSON(http.StatusConflict, gin.H{"error": "Timer not running"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard timer"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Timer discarded"})
+}
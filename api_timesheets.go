@@ -0,0 +1,144 @@
+package main
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+func timesheetJSON(w WeekSummary) gin.H {
+    var updatedAt interface{}
+    if w.ID != 0 {
+        updatedAt = w.UpdatedAt
+    }
+    return gin.H{
+        "id":         w.ID, // 0 for a draft that was never submitted
+        "user_id":    w.UserID,
+        "username":   w.Username,
+        "week":       w.Key,
+        "date_from":  w.Start.Format("2006-01-02"),
+        "date_to":    w.End.Format("2006-01-02"),
+        "status":     w.Status,
+        "hours":      w.Hours,
+        "updated_at": updatedAt,
+    }
+}
+
+func apiTimesheetError(c *gin.Context, err error) {
+    switch err {
+    case ErrInvalidWeek, ErrInvalidReview, ErrCommentRequired:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case ErrOwnTimesheet:
+        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+    case ErrInvalidTransition, ErrTimesheetChanged:
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// API: own weeks, the last 8 (drafts included) and all older submitted ones
+func APIGetTimesheets(c *gin.Context) {
+    weeks, err := RecentWeeks(c.GetInt("user_id"), c.GetString("username"), recentWeeks)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    data := []gin.H{}
+    for _, w := range weeks {
+        data = append(data, timesheetJSON(w))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: submitted weeks the caller may approve (team managers, admins)
+func APIGetTimesheetQueue(c *gin.Context) {
+    queue, err := ReviewQueue(c.GetInt("user_id"), CurrentRole(c))
+    if err == nil {
+        var weeks []WeekSummary
+        if weeks, err = summarizeWeeks(queue); err == nil {
+            data := []gin.H{}
+            for _, w := range weeks {
+                data = append(data, timesheetJSON(w))
+            }
+            c.JSON(http.StatusOK, gin.H{"data": data})
+            return
+        }
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+}
+
+// API: {"week": "2026-W07", "comment": "..."}
+func APISubmitTimesheet(c *gin.Context) {
+    var req struct {
+        Week    string `json:"week" binding:"required"`
+        Comment string `json:"comment"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    t, err := SubmitWeek(c.GetInt("user_id"), c.GetString("username"), req.Week, req.Comment)
+    if err != nil {
+        apiTimesheetError(c, err)
+        return
+    }
+    t.Username = c.GetString("username")
+    w, err := SummarizeWeek(*t)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{"data": timesheetJSON(w)})
+}
+
+// API: timesheet with its history, loaded by TimesheetAccessRequired
+func APIGetTimesheet(c *gin.Context) {
+    t := CurrentTimesheet(c)
+    w, err := SummarizeWeek(*t)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    events, err := sheetStore.Events(t.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    history := []gin.H{}
+    for _, e := range events {
+        history = append(history, gin.H{
+            "from_status": e.FromStatus,
+            "to_status":   e.ToStatus,
+            "actor_id":    e.ActorID,
+            "actor":       e.ActorName,
+            "comment":     e.Comment,
+            "created_at":  e.CreatedAt,
+        })
+    }
+    data := timesheetJSON(w)
+    data["history"] = history
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: {"action": "approve|reject|reopen", "comment": "..."}
+func APIReviewTimesheet(c *gin.Context) {
+    var req struct {
+        Action  string `json:"action" binding:"required"`
+        Comment string `json:"comment"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    t := CurrentTimesheet(c)
+    if err := ReviewTimesheet(t, req.Action, c.GetInt("user_id"), c.GetString("username"), req.Comment); err != nil {
+        apiTimesheetError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "id":     t.ID,
+        "status": t.Status,
+    })
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+)
+
+func personalTokenJSON(t PersonalToken) gin.H {
+    var lastUsed interface{}
+    if !t.LastUsedAt.IsZero() {
+        lastUsed = t.LastUsedAt
+    }
+    return gin.H{
+        "id":           t.ID,
+        "name":         t.Name,
+        "prefix":       t.Prefix,
+        "scopes":       t.Scopes,
+        "created_at":   t.CreatedAt,
+        "expires_at":   t.ExpiresAt,
+        "last_used_at": lastUsed,
+    }
+}
+
+// API: personal tokens of the user, without the tokens themselves
+func APIGetPersonalTokens(c *gin.Context) {
+    tokens, err := patStore.List(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+
+    var data []gin.H
+    for _, t := range tokens {
+        data = append(data, personalTokenJSON(t))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// API: new token, the only response that contains it
+func APICreatePersonalToken(c *gin.Context) {
+    var req struct {
+        Name          string   `json:"name" binding:"required"`
+        Scopes        []string `json:"scopes" binding:"required"`
+        ExpiresInDays int      `json:"expires_in_days" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    scopes, err := ParseScopes(req.Scopes)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    raw, t, err := CreatePersonalToken(c.GetInt("user_id"), req.Name, scopes, req.ExpiresInDays)
+    if err == ErrInvalidTokenName || err == ErrInvalidTokenExpiry {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+        return
+    }
+
+    data := personalTokenJSON(*t)
+    data["token"] = raw
+    c.JSON(http.StatusCreated, data)
+}
+
+// API: revoke, the token stops working immediately
+func APIDeletePersonalToken(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := patStore.Delete(c.GetInt("user_id"), id)
+    if err == ErrPersonalTokenNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete token"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
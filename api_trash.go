@@ -0,0 +1,67 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func trashedJSON(w WorkLog) gin.H {
+    j := workLogJSON(w)
+    j["deleted_at"] = w.DeletedAt
+    if at := TrashPurgeAt(&w); !at.IsZero() {
+        j["purge_at"] = at
+    } else {
+        j["purge_at"] = nil
+    }
+    return j
+}
+
+// API: deleted worklogs, last deleted first
+func APIGetTrash(c *gin.Context) {
+    logs, err := worklogStore.ListTrash(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    result := make([]gin.H, 0, len(logs))
+    for _, w := range logs {
+        result = append(result, trashedJSON(w))
+    }
+    c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// API: back into the worklogs, ?override_lock=true like on update
+func APIRestoreTrash(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    restored, err := RestoreTrashedWorkLog(c.GetInt("user_id"), id, apiAudit(c))
+    switch {
+    case err == nil:
+        c.JSON(http.StatusOK, workLogJSON(*restored))
+    case errors.Is(err, ErrWorkLogNotFound):
+        APIWorkLogNotFound(c)
+    case errors.Is(err, ErrProjectNotFound):
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+    case isWorkLogRuleError(err):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// API: delete for good, only the history keeps the values
+func APIPurgeTrash(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    err := worklogStore.Purge(c.GetInt("user_id"), id, apiAudit(c))
+    if err == ErrWorkLogNotFound {
+        APIWorkLogNotFound(c)
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Worklog deleted permanently"})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+)
+
+type twoFactorCodeRequest struct {
+    Code string `json:"code" binding:"required"`
+}
+
+// API: second login step, challenge from /auth/login + TOTP or recovery code
+func APIVerifyTwoFactor(c *gin.Context) {
+    var req struct {
+        Challenge string `json:"challenge" binding:"required"`
+        Code      string `json:"code" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    user, err := VerifyMFAChallenge(req.Challenge, req.Code, LoginAttempt{IP: c.ClientIP(), Channel: ChannelAPI})
+    if _, ok := err.(*LoginThrottledError); ok {
+        apiLoginRefused(c, err)
+        return
+    }
+    switch err {
+    case nil:
+    case ErrInvalidChallenge:
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+        return
+    case ErrInvalidOTP:
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+        return
+    case ErrUserDisabled:
+        c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+        return
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+        return
+    }
+
+    tokens, err := IssueTokens(user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+        return
+    }
+    c.JSON(http.StatusOK, tokensJSON(tokens, user))
+}
+
+func apiCurrentUser(c *gin.Context) (*User, bool) {
+    user, err := userStore.GetByID(c.GetInt("user_id"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+        return nil, false
+    }
+    return user, true
+}
+
+// 2FA errors of the settings endpoints
+func apiTwoFactorError(c *gin.Context, err error) {
+    switch err {
+    case ErrInvalidOTP:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+    case ErrTwoFactorEnabled, ErrTwoFactorNotEnabled, ErrTwoFactorNotSetUp:
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+    }
+}
+
+// API: 2FA status
+func APIGetTwoFactor(c *gin.Context) {
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    left := 0
+    if user.TOTPEnabled {
+        left, _ = mfaStore.CountRecoveryCodes(user.ID)
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "enabled":             user.TOTPEnabled,
+        "recovery_codes_left": left,
+    })
+}
+
+// API: new secret, confirm it with /2fa/enable
+func APISetupTwoFactor(c *gin.Context) {
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    secret, err := StartTwoFactorSetup(user)
+    if err != nil {
+        apiTwoFactorError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "secret":           secret,
+        "provisioning_uri": TOTPProvisioningURI(user.Username, secret),
+    })
+}
+
+// API: first code turns 2FA on, response has the recovery codes (only here)
+func APIEnableTwoFactor(c *gin.Context) {
+    var req twoFactorCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    codes, err := ConfirmTwoFactor(user, req.Code)
+    if err != nil {
+        apiTwoFactorError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// API: new recovery codes, the old ones stop working
+func APIRegenerateRecoveryCodes(c *gin.Context) {
+    var req twoFactorCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    codes, err := RegenerateRecoveryCodes(user, req.Code)
+    if err != nil {
+        apiTwoFactorError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// API: turn 2FA off, needs a current code
+func APIDisableTwoFactor(c *gin.Context) {
+    var req twoFactorCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+    user, ok := apiCurrentUser(c)
+    if !ok {
+        return
+    }
+    if err := DisableTwoFactor(user, req.Code); err != nil {
+        apiTwoFactorError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
@@ -7,9 +7,12 @@ import (
     "net/http"
 )
 
+// bcrypt cost of new hashes, tests turn it down to bcrypt.MinCost
+var passwordCost = 14
+
 // easy to understand hash password 
 func HashPassword(password string) (string, error) {
-    bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+    bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
     return string(bytes), err
 }
 
@@ -26,20 +29,22 @@ func CreateUser(username, password string) error {
         return err
     }
     
-    _, err = db.Exec("INSERT INTO users (username, password) VALUES (?, ?)", username, hash)
-    return err
+    return userStore.Create(username, hash)
 }
 
 // get user according to  username
 func GetUserByUsername(username string) (*User, error) {
-    user := &User{}
-    err := db.QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).
-        Scan(&user.ID, &user.Username, &user.Password)
-    
-    if err != nil {
-        return nil, err
-    }
-    return user, nil
+    return userStore.GetByUsername(username)
+}
+
+// logged in: a fresh session for the user (anything from before is dropped)
+func StartSession(c *gin.Context, user *User) {
+    session := sessions.Default(c)
+    session.Clear()
+    session.Set("user_id", user.ID)
+    session.Set("username", user.Username)
+    session.Set("auth_version", user.AuthVersion)
+    session.Save()
 }
 
 // Middleware check auth cred 
@@ -54,6 +59,22 @@ func AuthRequired() gin.HandlerFunc {
             return
         }
         
+        // cookie sessions can not be deleted on the server: a password change bumps
+        // the user's auth_version and every session with the old one ends here
+        version, _ := session.Get("auth_version").(int)
+        user, err := userStore.GetByID(userID.(int))
+        if err != nil || user.AuthVersion != version || user.Disabled {
+            session.Clear()
+            session.Save()
+            c.Redirect(http.StatusFound, "/login")
+            c.Abort()
+            return
+        }
+        
+        // same keys as JWTAuthMiddleware, see CurrentUserID
+        c.Set("user_id", userID.(int))
+        c.Set("username", session.Get("username"))
+        c.Set("role", user.Role)
         c.Next()
     }
 }
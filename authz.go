@@ -0,0 +1,95 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+// same answer for "does not exist" and "belongs to someone else",
+// so ids of other users can not be probed
+var ErrWorkLogNotFound = errors.New("worklog not found")
+
+// load worklog only if it belongs to userID
+func GetOwnedWorkLog(userID int, id string) (*WorkLog, error) {
+    worklogID, err := strconv.Atoi(id)
+    if err != nil {
+        return nil, ErrWorkLogNotFound
+    }
+    return worklogStore.Get(userID, worklogID)
+}
+
+// user id set by AuthRequired (session) or JWTAuthMiddleware (token)
+func CurrentUserID(c *gin.Context) int {
+    return c.GetInt("user_id")
+}
+
+// Middleware for routes with :id - both web and API go through it.
+// Foreign or missing worklog -> 404, otherwise worklog is put into context.
+func WorkLogOwnerRequired(notFound gin.HandlerFunc) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        log, err := GetOwnedWorkLog(CurrentUserID(c), c.Param("id"))
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+
+        c.Set("worklog", log)
+        c.Next()
+    }
+}
+
+// worklog loaded by WorkLogOwnerRequired
+func CurrentWorkLog(c *gin.Context) *WorkLog {
+    return c.MustGet("worklog").(*WorkLog)
+}
+
+// 404 for web routes
+func WebWorkLogNotFound(c *gin.Context) {
+    c.String(http.StatusNotFound, "Запись не найдена")
+}
+
+// 404 for API routes
+func APIWorkLogNotFound(c *gin.Context) {
+    c.JSON(http.StatusNotFound, gin.H{"error": "Worklog not found"})
+}
+
+// worklog may only point to a project of the same user
+func ValidateWorkLogProject(log *WorkLog) error {
+    if log.ProjectID == 0 {
+        return nil
+    }
+    _, err := projectStore.Get(log.UserID, log.ProjectID)
+    return err
+}
+
+// rate is either for a project, for a client or the default (neither),
+// project/client must belong to the same user
+func ValidateRateScope(r *HourlyRate) error {
+    if r.ProjectID != 0 && r.ClientID != 0 {
+        return errors.New("rate is either for a project or for a client")
+    }
+    if r.ProjectID != 0 {
+        if _, err := projectStore.Get(r.UserID, r.ProjectID); err != nil {
+            return err
+        }
+    }
+    if r.ClientID != 0 {
+        if _, err := clientStore.Get(r.UserID, r.ClientID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// project may only point to a client of the same user
+func ValidateProjectClient(p *Project) error {
+    if p.ClientID == 0 {
+        return nil
+    }
+    _, err := clientStore.Get(p.UserID, p.ClientID)
+    return err
+}
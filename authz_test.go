@@ -0,0 +1,187 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strconv"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// worklogs of alice that bob tries to reach: a live one, one in the trash, and a history entry
+type foreignWorkLogs struct {
+    alice     *User
+    live      *WorkLog
+    trashed   *WorkLog
+    historyID int
+    versions  int // history entries of live
+}
+
+func setupForeignWorkLogs(t *testing.T) *foreignWorkLogs {
+    t.Helper()
+    f := &foreignWorkLogs{alice: createTestUser(t, "alice")}
+    createTestUser(t, "bob")
+
+    day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+    f.live = createTestWorkLog(t, WorkLog{UserID: f.alice.ID, Date: day, Description: "alice live", Hours: 2})
+    f.trashed = createTestWorkLog(t, WorkLog{UserID: f.alice.ID, Date: day, Description: "alice trashed", Hours: 1})
+    if err := worklogStore.Delete(f.alice.ID, f.trashed.ID, &Audit{ActorName: "test", Channel: ChannelSystem}); err != nil {
+        t.Fatal(err)
+    }
+
+    changes, err := historyStore.ForWorkLog(f.alice.ID, f.live.ID)
+    if err != nil || len(changes) == 0 {
+        t.Fatalf("history of the live worklog: %v", err)
+    }
+    f.historyID, f.versions = changes[0].ID, len(changes)
+    return f
+}
+
+// alice's worklogs look exactly as before
+func (f *foreignWorkLogs) assertUnchanged(t *testing.T) {
+    t.Helper()
+    live, err := worklogStore.Get(f.alice.ID, f.live.ID)
+    if err != nil {
+        t.Fatalf("live worklog is gone: %v", err)
+    }
+    if live.Description != f.live.Description || live.Hours != f.live.Hours || !live.Date.Equal(f.live.Date) {
+        t.Fatalf("live worklog changed: %+v", live)
+    }
+    if _, err := worklogStore.GetTrashed(f.alice.ID, f.trashed.ID); err != nil {
+        t.Fatalf("trashed worklog left the trash: %v", err)
+    }
+    changes, err := historyStore.ForWorkLog(f.alice.ID, f.live.ID)
+    if err != nil || len(changes) != f.versions {
+        t.Fatalf("history of the live worklog: %d entries, want %d (%v)", len(changes), f.versions, err)
+    }
+}
+
+func TestForeignWorkLogWeb(t *testing.T) {
+    router := setupTestServer(t)
+    f := setupForeignWorkLogs(t)
+    bob := loginWeb(t, router, "bob")
+
+    live, trashed := strconv.Itoa(f.live.ID), strconv.Itoa(f.trashed.ID)
+    update := url.Values{"date": {"2026-03-02"}, "description": {"bob was here"}, "hours": {"8"}}
+    tests := []struct {
+        name string
+        do   func() *httptest.ResponseRecorder
+    }{
+        {"edit page", func() *httptest.ResponseRecorder { return bob.get("/worklog/edit/" + live) }},
+        {"update", func() *httptest.ResponseRecorder { return bob.postForm("/worklog/update/"+live, update) }},
+        {"delete", func() *httptest.ResponseRecorder { return bob.postForm("/worklog/delete/"+live, nil) }},
+        {"history", func() *httptest.ResponseRecorder { return bob.get("/worklog/history/" + live) }},
+        {"restore version", func() *httptest.ResponseRecorder {
+            return bob.postForm("/worklog/restore/"+strconv.Itoa(f.historyID), nil)
+        }},
+        {"restore from trash", func() *httptest.ResponseRecorder { return bob.postForm("/worklog/trash/restore/"+trashed, nil) }},
+        {"purge from trash", func() *httptest.ResponseRecorder { return bob.postForm("/worklog/trash/delete/"+trashed, nil) }},
+        {"unknown id", func() *httptest.ResponseRecorder { return bob.get("/worklog/edit/999999") }},
+        {"bad id", func() *httptest.ResponseRecorder { return bob.postForm("/worklog/delete/abc", nil) }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if w := tt.do(); w.Code != http.StatusNotFound {
+                t.Fatalf("status %d, want 404: %s", w.Code, w.Body.String())
+            }
+            f.assertUnchanged(t)
+        })
+    }
+
+    // the same routes work for the owner, so the 404s above are not broken routes
+    alice := loginWeb(t, router, "alice")
+    if w := alice.get("/worklog/edit/" + live); w.Code != http.StatusOK {
+        t.Fatalf("owner edit page: %d", w.Code)
+    }
+}
+
+func TestForeignWorkLogAPI(t *testing.T) {
+    router := setupTestServer(t)
+    f := setupForeignWorkLogs(t)
+    bob := loginAPI(t, router, "bob")
+
+    live, trashed := strconv.Itoa(f.live.ID), strconv.Itoa(f.trashed.ID)
+    update := gin.H{"date": "2026-03-02", "description": "bob was here", "hours": 8}
+    tests := []struct {
+        name string
+        do   func() *httptest.ResponseRecorder
+    }{
+        {"PUT", func() *httptest.ResponseRecorder {
+            return bob.sendJSON(http.MethodPut, "/api/v1/worklogs/"+live, update)
+        }},
+        {"DELETE", func() *httptest.ResponseRecorder { return bob.do(http.MethodDelete, "/api/v1/worklogs/"+live, "", nil) }},
+        {"GET history", func() *httptest.ResponseRecorder { return bob.get("/api/v1/worklogs/" + live + "/history") }},
+        {"POST restore version", func() *httptest.ResponseRecorder {
+            return bob.do(http.MethodPost, "/api/v1/worklogs/history/"+strconv.Itoa(f.historyID)+"/restore", "", nil)
+        }},
+        {"POST restore from trash", func() *httptest.ResponseRecorder {
+            return bob.do(http.MethodPost, "/api/v1/worklogs/trash/"+trashed+"/restore", "", nil)
+        }},
+        {"DELETE from trash", func() *httptest.ResponseRecorder {
+            return bob.do(http.MethodDelete, "/api/v1/worklogs/trash/"+trashed, "", nil)
+        }},
+        {"unknown id", func() *httptest.ResponseRecorder {
+            return bob.sendJSON(http.MethodPut, "/api/v1/worklogs/999999", update)
+        }},
+        {"bad id", func() *httptest.ResponseRecorder { return bob.do(http.MethodDelete, "/api/v1/worklogs/abc", "", nil) }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if w := tt.do(); w.Code != http.StatusNotFound {
+                t.Fatalf("status %d, want 404: %s", w.Code, w.Body.String())
+            }
+            f.assertUnchanged(t)
+        })
+    }
+
+    // bob's list does not show them either
+    w := bob.get("/api/v1/worklogs")
+    if w.Code != http.StatusOK || w.Body.String() != `{"data":null}` {
+        t.Fatalf("bob's list: %d %s", w.Code, w.Body.String())
+    }
+
+    alice := loginAPI(t, router, "alice")
+    if w := alice.sendJSON(http.MethodPut, "/api/v1/worklogs/"+live, update); w.Code != http.StatusOK {
+        t.Fatalf("owner update: %d %s", w.Code, w.Body.String())
+    }
+}
+
+func TestGetOwnedWorkLog(t *testing.T) {
+    useMemoryWorkLogStore(t)
+    log := createTestWorkLog(t, WorkLog{UserID: 1, Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), Hours: 1})
+    id := strconv.Itoa(log.ID)
+
+    if got, err := GetOwnedWorkLog(1, id); err != nil || got.ID != log.ID {
+        t.Fatalf("owner: %+v %v", got, err)
+    }
+    for _, tt := range []struct {
+        userID int
+        id     string
+    }{{2, id}, {1, "999"}, {1, "abc"}, {1, ""}} {
+        if _, err := GetOwnedWorkLog(tt.userID, tt.id); err != ErrWorkLogNotFound {
+            t.Fatalf("user %d, id %q: %v", tt.userID, tt.id, err)
+        }
+    }
+}
+
+// on a fresh installation whoever registers first is a plain user, admins come from the command line
+func TestFirstRegistrationIsNotAdmin(t *testing.T) {
+    router := setupTestServer(t)
+    c := newTestClient(t, router)
+    c.postForm("/register", url.Values{"username": {"mallory"}, "password": {testPassword}, "password_confirm": {testPassword}})
+
+    user, err := userStore.GetByUsername("mallory")
+    if err != nil {
+        t.Fatalf("registration failed: %v", err)
+    }
+    if user.Role != RoleUser {
+        t.Fatalf("first registered user got role %q", user.Role)
+    }
+    c = loginWeb(t, router, "mallory")
+    if w := c.get("/admin/users"); w.Code != http.StatusForbidden {
+        t.Fatalf("admin area for the first user: %d", w.Code)
+    }
+}
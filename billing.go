@@ -0,0 +1,105 @@
+package main
+
+import (
+    "math"
+    "sort"
+)
+
+type rateScope struct {
+    ProjectID int
+    ClientID  int
+}
+
+// hourly rates of one user, answers "what is this worklog worth"
+type RateBook struct {
+    byScope  map[rateScope][]HourlyRate // newest first
+    clientOf map[int]int                // project id -> client id
+}
+
+func NewRateBook(rates []HourlyRate, projects []Project) *RateBook {
+    b := &RateBook{
+        byScope:  make(map[rateScope][]HourlyRate),
+        clientOf: make(map[int]int),
+    }
+    for _, r := range rates {
+        scope := rateScope{ProjectID: r.ProjectID, ClientID: r.ClientID}
+        b.byScope[scope] = append(b.byScope[scope], r)
+    }
+    for _, list := range b.byScope {
+        sort.SliceStable(list, func(i, j int) bool {
+            return list[i].EffectiveFrom.After(list[j].EffectiveFrom)
+        })
+    }
+    for _, p := range projects {
+        b.clientOf[p.ID] = p.ClientID
+    }
+    return b
+}
+
+// rates + projects of userID from the stores
+func LoadRateBook(userID int) (*RateBook, error) {
+    rates, err := rateStore.List(userID)
+    if err != nil {
+        return nil, err
+    }
+    projects, err := projectStore.List(userID)
+    if err != nil {
+        return nil, err
+    }
+    return NewRateBook(rates, projects), nil
+}
+
+// Rate valid on the day of log: rate of the project, else of the project's client,
+// else the default rate of the user; 0 if none is set. Within a scope the newest
+// rate with EffectiveFrom <= log.Date wins.
+func (b *RateBook) RateFor(log WorkLog) float64 {
+    var scopes []rateScope
+    if log.ProjectID != 0 {
+        scopes = append(scopes, rateScope{ProjectID: log.ProjectID})
+        if clientID := b.clientOf[log.ProjectID]; clientID != 0 {
+            scopes = append(scopes, rateScope{ClientID: clientID})
+        }
+    }
+    scopes = append(scopes, rateScope{})
+
+    for _, scope := range scopes {
+        for _, r := range b.byScope[scope] {
+            if !r.EffectiveFrom.After(log.Date) {
+                return r.Rate
+            }
+        }
+    }
+    return 0
+}
+
+// hours * rate for billable entries, 0 otherwise; rounded to cents
+func (b *RateBook) Amount(log WorkLog) float64 {
+    if !log.Billable {
+        return 0
+    }
+    return roundMoney(log.Hours * b.RateFor(log))
+}
+
+type BillingSummary struct {
+    BillableHours    float64
+    NonBillableHours float64
+    Amount           float64
+}
+
+func (b *RateBook) Summarize(logs []WorkLog) BillingSummary {
+    var s BillingSummary
+    for _, log := range logs {
+        if log.Billable {
+            s.BillableHours += log.Hours
+            s.Amount += b.Amount(log)
+        } else {
+            s.NonBillableHours += log.Hours
+        }
+    }
+    s.Amount = roundMoney(s.Amount)
+    return s
+}
+
+func roundMoney(v float64) float64 {
+    return math.Round(v*100) / 100
+}
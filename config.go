@@ -0,0 +1,525 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/goccy/go-yaml"
+    "github.com/pelletier/go-toml/v2"
+)
+
+// defaults, only good enough for local development
+const (
+    defaultSessionSecret = "super-secret-key-change-me-in-production"
+    defaultJWTSecret     = "your-super-secret-jwt-key-change-in-production"
+)
+
+// all runtime settings in one place
+type Config struct {
+    Port              string
+    GinMode           string
+    DatabaseDriver    string // "sqlite" or "postgres"
+    DatabasePath      string // sqlite file
+    DatabaseURL       string // postgres DSN, e.g. postgres://user:pass@db:5432/tracker?sslmode=disable
+    SessionSecret     string
+    CookieSecure      bool
+    JWTSecret         string
+    JWTTTL            time.Duration // access token lifetime
+    RefreshTTL        time.Duration // refresh token lifetime, rotated on every use
+    InactivityTimeout time.Duration
+    AutoMigrate       bool // apply pending migrations at startup
+    TimerRounding     RoundingRule
+    Currency          string        // label for billable amounts, e.g. "EUR"
+    TrashRetention    time.Duration // deleted worklogs are purged after this, 0 = kept forever
+
+    // login throttling, see login_throttle.go
+    LoginMaxFailures   int           // failed logins of a username before the lockout
+    LoginIPMaxFailures int           // failed logins from one ip before it is blocked
+    LoginLockout       time.Duration // lockout length, also the window failures are counted in
+    TrustedProxies     []string      // proxies allowed to set X-Forwarded-For, empty = none
+
+    // password reset mails, see mailer.go
+    BaseURL          string        // public address for links in mails, e.g. https://tracker.example.com
+    PasswordResetTTL time.Duration // reset link lifetime
+    Mailer           string        // "log", "file" or "smtp"
+    MailFrom         string
+    MailFile         string // file mailer: mails are appended here
+    SMTPHost         string
+    SMTPPort         int
+    SMTPUsername     string
+    SMTPPassword     string
+}
+
+// what the config file may contain; empty keys keep the defaults
+// (durations are strings like "30m" so yaml and toml read them the same way)
+type fileConfig struct {
+    Port              string `yaml:"port" toml:"port"`
+    GinMode           string `yaml:"gin_mode" toml:"gin_mode"`
+    DatabaseDriver    string `yaml:"database_driver" toml:"database_driver"`
+    DatabasePath      string `yaml:"database_path" toml:"database_path"`
+    DatabaseURL       string `yaml:"database_url" toml:"database_url"`
+    SessionSecret     string `yaml:"session_secret" toml:"session_secret"`
+    CookieSecure      *bool  `yaml:"cookie_secure" toml:"cookie_secure"`
+    JWTSecret         string `yaml:"jwt_secret" toml:"jwt_secret"`
+    JWTTTL            string `yaml:"jwt_ttl" toml:"jwt_ttl"`
+    RefreshTTL        string `yaml:"refresh_ttl" toml:"refresh_ttl"`
+    InactivityTimeout string `yaml:"inactivity_timeout" toml:"inactivity_timeout"`
+    AutoMigrate       *bool  `yaml:"auto_migrate" toml:"auto_migrate"`
+    TimerRounding     string `yaml:"timer_rounding" toml:"timer_rounding"`
+    Currency          string `yaml:"currency" toml:"currency"`
+    TrashRetention    string `yaml:"trash_retention" toml:"trash_retention"`
+
+    LoginMaxFailures   int      `yaml:"login_max_failures" toml:"login_max_failures"`
+    LoginIPMaxFailures int      `yaml:"login_ip_max_failures" toml:"login_ip_max_failures"`
+    LoginLockout       string   `yaml:"login_lockout" toml:"login_lockout"`
+    TrustedProxies     []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+
+    BaseURL          string `yaml:"base_url" toml:"base_url"`
+    PasswordResetTTL string `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+    Mailer           string `yaml:"mailer" toml:"mailer"`
+    MailFrom         string `yaml:"mail_from" toml:"mail_from"`
+    MailFile         string `yaml:"mail_file" toml:"mail_file"`
+    SMTPHost         string `yaml:"smtp_host" toml:"smtp_host"`
+    SMTPPort         int    `yaml:"smtp_port" toml:"smtp_port"`
+    SMTPUsername     string `yaml:"smtp_username" toml:"smtp_username"`
+    SMTPPassword     string `yaml:"smtp_password" toml:"smtp_password"`
+}
+
+// loaded once in main, read by handlers and middleware
+var config *Config
+
+func DefaultConfig() *Config {
+    return &Config{
+        Port:              "8080",
+        GinMode:           gin.DebugMode,
+        DatabaseDriver:    DialectSQLite,
+        DatabasePath:      "./database.db",
+        SessionSecret:     defaultSessionSecret,
+        CookieSecure:      false,
+        JWTSecret:         defaultJWTSecret,
+        JWTTTL:            15 * time.Minute,
+        RefreshTTL:        30 * 24 * time.Hour,
+        InactivityTimeout: 30 * time.Minute,
+        AutoMigrate:       true,
+        TimerRounding:     RoundingRule{Mode: "none"},
+        Currency:          "RUB",
+        TrashRetention:    30 * 24 * time.Hour,
+
+        LoginMaxFailures:   5,
+        LoginIPMaxFailures: 20,
+        LoginLockout:       15 * time.Minute,
+
+        PasswordResetTTL: time.Hour,
+        Mailer:           "log",
+        MailFrom:         "my-tracker@localhost",
+        MailFile:         "./mail.log",
+        SMTPPort:         587,
+    }
+}
+
+// defaults -> config file (optional) -> env vars
+func LoadConfig(path string) (*Config, error) {
+    cfg := DefaultConfig()
+
+    if path == "" {
+        path = os.Getenv("CONFIG_FILE")
+    }
+    if path != "" {
+        if err := cfg.loadFile(path); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := cfg.loadEnv(); err != nil {
+        return nil, err
+    }
+
+    if err := cfg.Validate(); err != nil {
+        return nil, err
+    }
+    return cfg, nil
+}
+
+// yaml or toml, chosen by extension
+func (cfg *Config) loadFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("config file: %w", err)
+    }
+
+    var fc fileConfig
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        err = yaml.Unmarshal(data, &fc)
+    case ".toml":
+        err = toml.Unmarshal(data, &fc)
+    default:
+        return fmt.Errorf("config file: unsupported format %q", filepath.Ext(path))
+    }
+    if err != nil {
+        return fmt.Errorf("config file %s: %w", path, err)
+    }
+
+    if fc.Port != "" {
+        cfg.Port = fc.Port
+    }
+    if fc.GinMode != "" {
+        cfg.GinMode = fc.GinMode
+    }
+    if fc.DatabaseDriver != "" {
+        cfg.DatabaseDriver = fc.DatabaseDriver
+    }
+    if fc.DatabasePath != "" {
+        cfg.DatabasePath = fc.DatabasePath
+    }
+    if fc.DatabaseURL != "" {
+        cfg.DatabaseURL = fc.DatabaseURL
+    }
+    if fc.SessionSecret != "" {
+        cfg.SessionSecret = fc.SessionSecret
+    }
+    if fc.CookieSecure != nil {
+        cfg.CookieSecure = *fc.CookieSecure
+    }
+    if fc.AutoMigrate != nil {
+        cfg.AutoMigrate = *fc.AutoMigrate
+    }
+    if fc.TimerRounding != "" {
+        if cfg.TimerRounding, err = ParseRoundingRule(fc.TimerRounding); err != nil {
+            return fmt.Errorf("config file: timer_rounding: %w", err)
+        }
+    }
+    if fc.Currency != "" {
+        cfg.Currency = fc.Currency
+    }
+    if fc.TrashRetention != "" {
+        if cfg.TrashRetention, err = time.ParseDuration(fc.TrashRetention); err != nil {
+            return fmt.Errorf("config file: trash_retention: %w", err)
+        }
+    }
+    if fc.JWTSecret != "" {
+        cfg.JWTSecret = fc.JWTSecret
+    }
+    if fc.JWTTTL != "" {
+        if cfg.JWTTTL, err = time.ParseDuration(fc.JWTTTL); err != nil {
+            return fmt.Errorf("config file: jwt_ttl: %w", err)
+        }
+    }
+    if fc.RefreshTTL != "" {
+        if cfg.RefreshTTL, err = time.ParseDuration(fc.RefreshTTL); err != nil {
+            return fmt.Errorf("config file: refresh_ttl: %w", err)
+        }
+    }
+    if fc.LoginMaxFailures != 0 {
+        cfg.LoginMaxFailures = fc.LoginMaxFailures
+    }
+    if fc.LoginIPMaxFailures != 0 {
+        cfg.LoginIPMaxFailures = fc.LoginIPMaxFailures
+    }
+    if fc.LoginLockout != "" {
+        if cfg.LoginLockout, err = time.ParseDuration(fc.LoginLockout); err != nil {
+            return fmt.Errorf("config file: login_lockout: %w", err)
+        }
+    }
+    if fc.TrustedProxies != nil {
+        cfg.TrustedProxies = fc.TrustedProxies
+    }
+    if fc.PasswordResetTTL != "" {
+        if cfg.PasswordResetTTL, err = time.ParseDuration(fc.PasswordResetTTL); err != nil {
+            return fmt.Errorf("config file: password_reset_ttl: %w", err)
+        }
+    }
+    if fc.BaseURL != "" {
+        cfg.BaseURL = fc.BaseURL
+    }
+    if fc.Mailer != "" {
+        cfg.Mailer = fc.Mailer
+    }
+    if fc.MailFrom != "" {
+        cfg.MailFrom = fc.MailFrom
+    }
+    if fc.MailFile != "" {
+        cfg.MailFile = fc.MailFile
+    }
+    if fc.SMTPHost != "" {
+        cfg.SMTPHost = fc.SMTPHost
+    }
+    if fc.SMTPUsername != "" {
+        cfg.SMTPUsername = fc.SMTPUsername
+    }
+    if fc.SMTPPassword != "" {
+        cfg.SMTPPassword = fc.SMTPPassword
+    }
+    if fc.SMTPPort != 0 {
+        cfg.SMTPPort = fc.SMTPPort
+    }
+    if fc.InactivityTimeout != "" {
+        if cfg.InactivityTimeout, err = time.ParseDuration(fc.InactivityTimeout); err != nil {
+            return fmt.Errorf("config file: inactivity_timeout: %w", err)
+        }
+    }
+    return nil
+}
+
+func (cfg *Config) loadEnv() error {
+    if v := os.Getenv("PORT"); v != "" {
+        cfg.Port = v
+    }
+    if v := os.Getenv("GIN_MODE"); v != "" {
+        cfg.GinMode = v
+    }
+    if v := os.Getenv("DATABASE_DRIVER"); v != "" {
+        cfg.DatabaseDriver = v
+    }
+    if v := os.Getenv("DATABASE_PATH"); v != "" {
+        cfg.DatabasePath = v
+    }
+    if v := os.Getenv("DATABASE_URL"); v != "" {
+        cfg.DatabaseURL = v
+    }
+    if v := os.Getenv("SESSION_SECRET"); v != "" {
+        cfg.SessionSecret = v
+    }
+    if v := os.Getenv("JWT_SECRET"); v != "" {
+        cfg.JWTSecret = v
+    }
+    if v := os.Getenv("CURRENCY"); v != "" {
+        cfg.Currency = v
+    }
+    if v := os.Getenv("COOKIE_SECURE"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            return fmt.Errorf("COOKIE_SECURE: %w", err)
+        }
+        cfg.CookieSecure = b
+    }
+    if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            return fmt.Errorf("AUTO_MIGRATE: %w", err)
+        }
+        cfg.AutoMigrate = b
+    }
+    if v := os.Getenv("TIMER_ROUNDING"); v != "" {
+        rule, err := ParseRoundingRule(v)
+        if err != nil {
+            return fmt.Errorf("TIMER_ROUNDING: %w", err)
+        }
+        cfg.TimerRounding = rule
+    }
+    if v := os.Getenv("TRASH_RETENTION"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("TRASH_RETENTION: %w", err)
+        }
+        cfg.TrashRetention = d
+    }
+    if v := os.Getenv("JWT_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("JWT_TTL: %w", err)
+        }
+        cfg.JWTTTL = d
+    }
+    if v := os.Getenv("REFRESH_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("REFRESH_TTL: %w", err)
+        }
+        cfg.RefreshTTL = d
+    }
+    if v := os.Getenv("LOGIN_MAX_FAILURES"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return fmt.Errorf("LOGIN_MAX_FAILURES: %w", err)
+        }
+        cfg.LoginMaxFailures = n
+    }
+    if v := os.Getenv("LOGIN_IP_MAX_FAILURES"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return fmt.Errorf("LOGIN_IP_MAX_FAILURES: %w", err)
+        }
+        cfg.LoginIPMaxFailures = n
+    }
+    if v := os.Getenv("LOGIN_LOCKOUT"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("LOGIN_LOCKOUT: %w", err)
+        }
+        cfg.LoginLockout = d
+    }
+    if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+        cfg.TrustedProxies = strings.Split(v, ",")
+    }
+    if v := os.Getenv("PASSWORD_RESET_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("PASSWORD_RESET_TTL: %w", err)
+        }
+        cfg.PasswordResetTTL = d
+    }
+    if v := os.Getenv("BASE_URL"); v != "" {
+        cfg.BaseURL = v
+    }
+    if v := os.Getenv("MAILER"); v != "" {
+        cfg.Mailer = v
+    }
+    if v := os.Getenv("MAIL_FROM"); v != "" {
+        cfg.MailFrom = v
+    }
+    if v := os.Getenv("MAIL_FILE"); v != "" {
+        cfg.MailFile = v
+    }
+    if v := os.Getenv("SMTP_HOST"); v != "" {
+        cfg.SMTPHost = v
+    }
+    if v := os.Getenv("SMTP_USERNAME"); v != "" {
+        cfg.SMTPUsername = v
+    }
+    if v := os.Getenv("SMTP_PASSWORD"); v != "" {
+        cfg.SMTPPassword = v
+    }
+    if v := os.Getenv("SMTP_PORT"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return fmt.Errorf("SMTP_PORT: %w", err)
+        }
+        cfg.SMTPPort = n
+    }
+    if v := os.Getenv("INACTIVITY_TIMEOUT"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("INACTIVITY_TIMEOUT: %w", err)
+        }
+        cfg.InactivityTimeout = d
+    }
+    return nil
+}
+
+// refuse to boot with obviously broken settings
+func (cfg *Config) Validate() error {
+    switch cfg.GinMode {
+    case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+    default:
+        return fmt.Errorf("config: unknown gin mode %q", cfg.GinMode)
+    }
+
+    if cfg.Port == "" {
+        return errors.New("config: port is empty")
+    }
+    switch cfg.DatabaseDriver {
+    case DialectSQLite:
+        if cfg.DatabasePath == "" {
+            return errors.New("config: database path is empty")
+        }
+    case DialectPostgres:
+        if cfg.DatabaseURL == "" {
+            return errors.New("config: database url is required for postgres")
+        }
+    default:
+        return fmt.Errorf("config: unknown database driver %q", cfg.DatabaseDriver)
+    }
+    if cfg.SessionSecret == "" || cfg.JWTSecret == "" {
+        return errors.New("config: secrets must not be empty")
+    }
+    if cfg.JWTTTL <= 0 {
+        return errors.New("config: jwt ttl must be positive")
+    }
+    if cfg.RefreshTTL <= cfg.JWTTTL {
+        return errors.New("config: refresh ttl must be longer than jwt ttl")
+    }
+    if cfg.InactivityTimeout <= 0 {
+        return errors.New("config: inactivity timeout must be positive")
+    }
+    if cfg.LoginMaxFailures < 1 || cfg.LoginIPMaxFailures < 1 {
+        return errors.New("config: login max failures must be at least 1")
+    }
+    if cfg.LoginLockout <= 0 {
+        return errors.New("config: login lockout must be positive")
+    }
+    for i, p := range cfg.TrustedProxies {
+        cfg.TrustedProxies[i] = strings.TrimSpace(p)
+    }
+    if cfg.PasswordResetTTL <= 0 {
+        return errors.New("config: password reset ttl must be positive")
+    }
+    switch cfg.Mailer {
+    case "log":
+    case "file":
+        if cfg.MailFile == "" {
+            return errors.New("config: mail file is required for the file mailer")
+        }
+    case "smtp":
+        if cfg.SMTPHost == "" || cfg.SMTPPort <= 0 {
+            return errors.New("config: smtp host and port are required for the smtp mailer")
+        }
+    default:
+        return fmt.Errorf("config: unknown mailer %q", cfg.Mailer)
+    }
+    if cfg.BaseURL == "" {
+        cfg.BaseURL = "http://localhost" + cfg.ListenAddr()
+    }
+    cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+    if strings.TrimSpace(cfg.Currency) == "" {
+        return errors.New("config: currency is empty")
+    }
+    if cfg.TrashRetention < 0 {
+        return errors.New("config: trash retention must not be negative")
+    }
+
+    if cfg.GinMode == gin.ReleaseMode {
+        if err := checkSecret("SESSION_SECRET", cfg.SessionSecret); err != nil {
+            return err
+        }
+        if err := checkSecret("JWT_SECRET", cfg.JWTSecret); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// shortest secret accepted in release mode, in bytes
+const minSecretLength = 32
+
+// parts of the defaults and of the usual sample values (.env.example, config.example.yaml, guides)
+var placeholderSecrets = []string{"change-me", "changeme", "change_me", "change-in-production", "your-", "example", "placeholder", "secret-key"}
+
+// release mode: no defaults, no sample values, at least minSecretLength bytes
+func checkSecret(name, value string) error {
+    if value == defaultSessionSecret || value == defaultJWTSecret {
+        return fmt.Errorf("config: %s is a default secret, not allowed in release mode", name)
+    }
+    lower := strings.ToLower(value)
+    for _, part := range placeholderSecrets {
+        if strings.Contains(lower, part) {
+            return fmt.Errorf("config: %s looks like a placeholder (%q), set a random value in release mode", name, part)
+        }
+    }
+    if len(value) < minSecretLength {
+        return fmt.Errorf("config: %s must be at least %d bytes in release mode", name, minSecretLength)
+    }
+    return nil
+}
+
+// file path for sqlite, DSN for postgres
+func (cfg *Config) DatabaseDSN() string {
+    if cfg.DatabaseDriver == DialectPostgres {
+        return cfg.DatabaseURL
+    }
+    return cfg.DatabasePath
+}
+
+// ":8080" for gin
+func (cfg *Config) ListenAddr() string {
+    if strings.Contains(cfg.Port, ":") {
+        return cfg.Port
+    }
+    return ":" + cfg.Port
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+)
+
+func TestValidateReleaseSecrets(t *testing.T) {
+    random := "q3J9vX0mZk2T8wLr5bHc7YpN4sGd6FaE"
+    tests := []struct {
+        name    string
+        session string
+        jwt     string
+        ok      bool
+    }{
+        {"random", random, strings.ToUpper(random), true},
+        {"defaults", defaultSessionSecret, defaultJWTSecret, false},
+        {"old .env sample", random, "your-super-secret-jwt-key-change-in-production-randomly-generated", false},
+        {"change-me", "change-me-session-secret-0123456789abcdef", random, false},
+        {"example", random, "tracker.example.com-0123456789abcdef0123", false},
+        {"short", "q3J9vX0mZk2T8wLr", random, false},
+        {"empty", "", random, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := DefaultConfig()
+            cfg.GinMode = gin.ReleaseMode
+            cfg.SessionSecret, cfg.JWTSecret = tt.session, tt.jwt
+            if err := cfg.Validate(); (err == nil) != tt.ok {
+                t.Fatalf("Validate() = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+// debug mode keeps the defaults so a fresh checkout starts without a config
+func TestValidateDebugDefaults(t *testing.T) {
+    if err := DefaultConfig().Validate(); err != nil {
+        t.Fatalf("Validate() = %v", err)
+    }
+}
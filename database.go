@@ -2,40 +2,185 @@ package main
 
 import (
     "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    _ "github.com/lib/pq"
     _ "github.com/mattn/go-sqlite3"
 )
 
-var db *sql.DB
+// supported backends (config.DatabaseDriver)
+const (
+    DialectSQLite   = "sqlite"
+    DialectPostgres = "postgres"
+)
+
+// *sql.DB that accepts "?" placeholders on every backend,
+// queries are written once in SQLite style and rebound for Postgres
+type DB struct {
+    *sql.DB
+    Dialect string
+}
+
+var db *DB
 
-func InitDB() error {
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return d.DB.Exec(rebind(d.Dialect, query), args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return d.DB.Query(rebind(d.Dialect, query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+    return d.DB.QueryRow(rebind(d.Dialect, query), args...)
+}
+
+func (d *DB) Begin() (*Tx, error) {
+    tx, err := d.DB.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &Tx{Tx: tx, Dialect: d.Dialect}, nil
+}
+
+// case-insensitive LIKE: SQLite LIKE already is (for ASCII), Postgres needs ILIKE
+func (d *DB) Like() string {
+    if d.Dialect == DialectPostgres {
+        return "ILIKE"
+    }
+    return "LIKE"
+}
+
+// "no limit" for "LIMIT x OFFSET y" when only the offset is set
+func (d *DB) NoLimit() string {
+    if d.Dialect == DialectPostgres {
+        return "ALL"
+    }
+    return "-1"
+}
+
+// same as DB, inside a transaction
+type Tx struct {
+    *sql.Tx
+    Dialect string
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return t.Tx.Exec(rebind(t.Dialect, query), args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return t.Tx.Query(rebind(t.Dialect, query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+    return t.Tx.QueryRow(rebind(t.Dialect, query), args...)
+}
+
+// "?" -> "$1", "$2", ... for Postgres, question marks inside '...' are left alone
+func rebind(dialect, query string) string {
+    if dialect != DialectPostgres || !strings.Contains(query, "?") {
+        return query
+    }
+
+    var b strings.Builder
+    n := 0
+    inString := false
+    for _, r := range query {
+        switch {
+        case r == '\'':
+            inString = !inString
+        case r == '?' && !inString:
+            n++
+            b.WriteString("$" + strconv.Itoa(n))
+            continue
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+// open db and set up the stores, schema is not touched
+func OpenDB(driver, dsn string) error {
+    var sqlDB *sql.DB
     var err error
-    db, err = sql.Open("sqlite3", "./database.db")
+
+    switch driver {
+    case DialectSQLite:
+        // wait for the write lock instead of failing with "database is locked"
+        sep := "?"
+        if strings.Contains(dsn, "?") {
+            sep = "&"
+        }
+        sqlDB, err = sql.Open("sqlite3", dsn+sep+"_busy_timeout=5000")
+    case DialectPostgres:
+        sqlDB, err = sql.Open("postgres", dsn)
+        if err == nil {
+            sqlDB.SetMaxOpenConns(20)
+            sqlDB.SetConnMaxIdleTime(5 * time.Minute)
+        }
+    default:
+        return fmt.Errorf("unknown database driver %q", driver)
+    }
     if err != nil {
         return err
     }
 
-    // 
-    _, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS users (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            username TEXT UNIQUE NOT NULL,
-            password TEXT NOT NULL
-        )
-    `)
+    if err := sqlDB.Ping(); err != nil {
+        sqlDB.Close()
+        return err
+    }
+
+    db = &DB{DB: sqlDB, Dialect: driver}
+    worklogStore = NewSQLWorkLogStore(db)
+    projectStore = NewSQLProjectStore(db)
+    clientStore = NewSQLClientStore(db)
+    timerStore = NewSQLTimerStore(db)
+    tagStore = NewSQLTagStore(db)
+    rateStore = NewSQLRateStore(db)
+    invoiceStore = NewSQLInvoiceStore(db)
+    userStore = NewSQLUserStore(db)
+    tokenStore = NewSQLTokenStore(db)
+    patStore = NewSQLPersonalTokenStore(db)
+    mfaStore = NewSQLTwoFactorStore(db)
+    loginStore = NewSQLLoginAttemptStore(db)
+    resetStore = NewSQLPasswordResetStore(db)
+    emailStore = NewSQLEmailChangeStore(db)
+    teamStore = NewSQLTeamStore(db)
+    sheetStore = NewSQLTimesheetStore(db)
+    lockStore = NewSQLPeriodLockStore(db)
+    historyStore = NewSQLWorkLogHistoryStore(db)
+    return nil
+}
+
+// open db and bring schema up to date (or refuse to start if autoMigrate is off
+// and migrations are pending - then run "migrate up" by hand)
+func InitDB(driver, dsn string, autoMigrate bool) error {
+    if err := OpenDB(driver, dsn); err != nil {
+        return err
+    }
+
+    migrator, err := NewMigrator(db)
     if err != nil {
         return err
     }
 
-    _, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS worklogs (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            user_id INTEGER NOT NULL,
-            date TEXT NOT NULL,
-            description TEXT,
-            hours REAL NOT NULL,
-            FOREIGN KEY (user_id) REFERENCES users(id)
-        )
-    `)
-    
-    return err
+    if autoMigrate {
+        migrator.Log = os.Stdout
+        return migrator.Up(0)
+    }
+
+    pending, err := migrator.Pending()
+    if err != nil {
+        return err
+    }
+    if len(pending) > 0 {
+        return fmt.Errorf("%d migration(s) pending, run: migrate up", len(pending))
+    }
+    log.Println("schema is up to date")
+    return nil
 }
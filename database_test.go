@@ -0,0 +1,101 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRebind(t *testing.T) {
+    tests := []struct {
+        dialect, query, want string
+    }{
+        {DialectSQLite, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+        {DialectPostgres, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+        {DialectPostgres, "SELECT * FROM t WHERE a = '?' AND b = ?", "SELECT * FROM t WHERE a = '?' AND b = $1"},
+        {DialectPostgres, "SELECT * FROM t WHERE a = 'it''s?' AND b IN (?, ?)", "SELECT * FROM t WHERE a = 'it''s?' AND b IN ($1, $2)"},
+        {DialectPostgres, "SELECT 1", "SELECT 1"},
+    }
+    for _, tt := range tests {
+        if got := rebind(tt.dialect, tt.query); got != tt.want {
+            t.Errorf("rebind(%s, %q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+        }
+    }
+}
+
+// what differs between the backends: placeholders, LIKE / ILIKE, DATE and BOOLEAN columns
+func TestDialects(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            testDialect(t)
+        })
+    }
+}
+
+func testDialect(t *testing.T) {
+    user := createTestUser(t, "alice")
+
+    // dates around a year end and a leap day come back as the same day
+    dates := []time.Time{
+        time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+        time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+        time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+    }
+    descriptions := []string{"Code REVIEW", "reviewing", "question?"}
+    var logs []*WorkLog
+    for i, date := range dates {
+        logs = append(logs, createTestWorkLog(t, WorkLog{UserID: user.ID, Date: date,
+            Description: descriptions[i], Hours: 1, Billable: i != 1}))
+    }
+    for i, log := range logs {
+        got, err := worklogStore.Get(user.ID, log.ID)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !got.Date.Equal(dates[i]) || got.Date.Format("2006-01-02") != dates[i].Format("2006-01-02") {
+            t.Errorf("date %s came back as %s", dates[i].Format("2006-01-02"), got.Date)
+        }
+        if got.Billable != log.Billable {
+            t.Errorf("billable %v came back as %v", log.Billable, got.Billable)
+        }
+    }
+    if got, err := worklogStore.List(user.ID, WorkLogFilter{DateFrom: "2027-01-01", DateTo: "2027-01-01"}); err != nil ||
+        len(got) != 1 || got[0].ID != logs[1].ID {
+        t.Errorf("date filter: %v %v", got, err)
+    }
+    if got, err := worklogStore.List(user.ID, WorkLogFilter{BillableOnly: true}); err != nil || len(got) != 2 {
+        t.Errorf("billable filter: %d entries, want 2 (%v)", len(got), err)
+    }
+
+    for _, search := range []string{"review", "REVIEW", "Review"} {
+        if got, err := worklogStore.List(user.ID, WorkLogFilter{Search: search}); err != nil || len(got) != 2 {
+            t.Errorf("search %q: %d entries, want 2 (%v)", search, len(got), err)
+        }
+    }
+
+    // a question mark in a literal is not a placeholder, in and out of a transaction
+    var n int
+    err := db.QueryRow("SELECT COUNT(*) FROM worklogs WHERE description <> 'question?' AND user_id = ?", user.ID).Scan(&n)
+    if err != nil || n != 2 {
+        t.Errorf("literal question mark: %d %v", n, err)
+    }
+    tx, err := db.Begin()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer tx.Rollback()
+    err = tx.QueryRow("SELECT COUNT(*) FROM worklogs WHERE user_id = ? AND billable = ?", user.ID, false).Scan(&n)
+    if err != nil || n != 1 {
+        t.Errorf("placeholders in a transaction: %d %v", n, err)
+    }
+    tx.Rollback()
+
+    for _, disabled := range []bool{true, false} {
+        if err := userStore.SetDisabled(user.ID, disabled); err != nil {
+            t.Fatal(err)
+        }
+        if got, err := userStore.GetByID(user.ID); err != nil || got.Disabled != disabled {
+            t.Errorf("disabled %v came back as %+v %v", disabled, got, err)
+        }
+    }
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+    "archive/zip"
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/xuri/excelize/v2"
+)
+
+// formats of /worklog/export and /api/v1/worklogs/export
+const (
+    ExportXLSX  = "xlsx"
+    ExportCSV   = "csv"
+    ExportJSONL = "jsonl"
+    ExportODS   = "ods"
+)
+
+var (
+    ErrExportFormat    = errors.New("format must be xlsx, csv, jsonl or ods")
+    ErrExportDelimiter = errors.New("delimiter must be one of , ; | tab")
+    ErrExportDecimal   = errors.New("decimal must be . or , and differ from the delimiter")
+)
+
+var exportContentTypes = map[string]string{
+    ExportXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+    ExportCSV:   "text/csv; charset=utf-8",
+    ExportJSONL: "application/x-ndjson",
+    ExportODS:   "application/vnd.oasis.opendocument.spreadsheet",
+}
+
+type ExportOptions struct {
+    Format    string
+    Delimiter rune   // csv only
+    Decimal   string // csv only: "." or ","
+}
+
+// format (xlsx by default), delimiter and decimal from the query string;
+// csv defaults to ";" and "," which a Russian Excel opens as is
+func ParseExportOptions(c *gin.Context) (ExportOptions, error) {
+    o := ExportOptions{
+        Format:  c.DefaultQuery("format", ExportXLSX),
+        Decimal: c.DefaultQuery("decimal", ","),
+    }
+    if _, ok := exportContentTypes[o.Format]; !ok {
+        return o, ErrExportFormat
+    }
+
+    switch c.DefaultQuery("delimiter", ";") {
+    case ";":
+        o.Delimiter = ';'
+    case ",":
+        o.Delimiter = ','
+    case "|":
+        o.Delimiter = '|'
+    case "tab", "\t":
+        o.Delimiter = '\t'
+    default:
+        return o, ErrExportDelimiter
+    }
+    if o.Decimal != "." && o.Decimal != "," || o.Decimal == string(o.Delimiter) {
+        return o, ErrExportDecimal
+    }
+    return o, nil
+}
+
+// Content-Type and Content-Disposition of the download
+func setExportHeaders(c *gin.Context, username string, o ExportOptions) {
+    fileName := fmt.Sprintf("worklog_%s_%s.%s", username, time.Now().Format("2006-01-02"), o.Format)
+    c.Header("Content-Type", exportContentTypes[o.Format])
+    c.Header("Content-Disposition", "attachment; filename="+fileName)
+}
+
+// true if a part of the file went out already: the error is only logged, the download is cut short;
+// otherwise the download headers are dropped so the caller can answer with an error
+func exportInterrupted(c *gin.Context, err error) bool {
+    if c.Writer.Written() {
+        log.Printf("export of user %d failed: %v", c.GetInt("user_id"), err)
+        c.Abort()
+        return true
+    }
+    c.Header("Content-Type", "")
+    c.Header("Content-Disposition", "")
+    return false
+}
+
+// sums below the entries, where the format has room for them
+type exportTotals struct {
+    Hours            float64
+    BillableHours    float64
+    NonBillableHours float64
+    Amount           float64
+}
+
+// one format; Row gets the entries in the order of the filter, Close finishes the file
+type exportWriter interface {
+    Row(log WorkLog, rate, amount float64) error
+    Close(t exportTotals) error
+}
+
+// worklogs of userID matching filter into w, row by row as they come from the database
+// (xlsx rows are collected by excelize first, on disk once they get large, and zipped at the end)
+func WriteWorkLogExport(w io.Writer, userID int, filter WorkLogFilter, o ExportOptions) error {
+    book, err := LoadRateBook(userID)
+    if err != nil {
+        return err
+    }
+
+    var out exportWriter
+    switch o.Format {
+    case ExportCSV:
+        out = newCSVExport(w, o)
+    case ExportJSONL:
+        out = newJSONLExport(w)
+    case ExportODS:
+        out = newODSExport(w)
+    default:
+        x := newXLSXExport(w)
+        // temp files of the stream writer, also when the export stops halfway
+        defer x.f.Close()
+        out = x
+    }
+
+    var t exportTotals
+    err = worklogStore.Each(userID, filter, func(log WorkLog) error {
+        var rate, amount float64
+        if log.Billable {
+            rate, amount = book.RateFor(log), book.Amount(log)
+            t.BillableHours += log.Hours
+            t.Amount += amount
+        } else {
+            t.NonBillableHours += log.Hours
+        }
+        t.Hours += log.Hours
+        return out.Row(log, rate, amount)
+    })
+    if err != nil {
+        return err
+    }
+    t.Amount = roundMoney(t.Amount)
+    return out.Close(t)
+}
+
+// columns of csv and ods: the xlsx ones first, then what the import reads besides them
+func exportColumns() []string {
+    return []string{"Дата", "Описание", "Часы", "Оплачиваемо", "Ставка, " + config.Currency, "Сумма, " + config.Currency,
+        "Проект", "Теги", "Начало", "Окончание", "Перерыв, мин"}
+}
+
+// text a spreadsheet would take for a formula (=, +, -, @, tab, CR first) gets a leading ',
+// which shows it as text; the import drops it again
+func exportText(s string) string {
+    if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+        return "'" + s
+    }
+    return s
+}
+
+func exportYesNo(v bool) string {
+    if v {
+        return "да"
+    }
+    return "нет"
+}
+
+// the original single sheet with Russian headers and totals; rows go through excelize's
+// StreamWriter, which keeps them in a temp file instead of the workbook in memory
+type xlsxExport struct {
+    w     io.Writer
+    f     *excelize.File
+    sw    *excelize.StreamWriter
+    total int // style of the totals row
+    row   int
+    err   error // of the setup, reported with the first row
+}
+
+func newXLSXExport(w io.Writer) *xlsxExport {
+    e := &xlsxExport{w: w, f: excelize.NewFile(), row: 1}
+    const sheet = "Рабочие часы"
+    var header int
+    // the default sheet is renamed, a stream writer can not be used on a sheet that was deleted afterwards
+    e.err = e.f.SetSheetName("Sheet1", sheet)
+    if e.err == nil {
+        header, e.err = e.f.NewStyle(&excelize.Style{
+            Font:      &excelize.Font{Bold: true, Size: 12},
+            Fill:      excelize.Fill{Type: "pattern", Color: []string{"#667eea"}, Pattern: 1},
+            Alignment: &excelize.Alignment{Horizontal: "center"},
+        })
+    }
+    if e.err == nil {
+        e.total, e.err = e.f.NewStyle(&excelize.Style{
+            Font: &excelize.Font{Bold: true, Size: 12},
+            Fill: excelize.Fill{Type: "pattern", Color: []string{"#4CAF50"}, Pattern: 1},
+        })
+    }
+    if e.err == nil {
+        e.sw, e.err = e.f.NewStreamWriter(sheet)
+    }
+    // widths have to come before the first row
+    for _, c := range []struct {
+        from, to int
+        width    float64
+    }{{1, 1, 15}, {2, 2, 50}, {3, 3, 10}, {4, 6, 14}} {
+        if e.err == nil {
+            e.err = e.sw.SetColWidth(c.from, c.to, c.width)
+        }
+    }
+
+    // billing columns after the original three
+    titles := []string{"Дата", "Описание", "Часы", "Оплачиваемо", "Ставка, " + config.Currency, "Сумма, " + config.Currency}
+    cells := make([]interface{}, len(titles))
+    for i, title := range titles {
+        cells[i] = excelize.Cell{StyleID: header, Value: title}
+    }
+    e.setRow(cells...)
+    return e
+}
+
+// next row from column A, nil cells stay empty
+func (e *xlsxExport) setRow(cells ...interface{}) {
+    if e.err == nil {
+        e.err = e.sw.SetRow("A"+strconv.Itoa(e.row), cells)
+    }
+    e.row++
+}
+
+func (e *xlsxExport) Row(log WorkLog, rate, amount float64) error {
+    var rateCell, amountCell interface{}
+    if log.Billable {
+        rateCell, amountCell = rate, amount
+    }
+    e.setRow(log.Date.Format("02.01.2006"), log.Description, log.Hours, exportYesNo(log.Billable), rateCell, amountCell)
+    return e.err
+}
+
+func (e *xlsxExport) Close(t exportTotals) error {
+    e.row++
+    total := func(v interface{}) excelize.Cell { return excelize.Cell{StyleID: e.total, Value: v} }
+    e.setRow(nil, total("ИТОГО:"), total(t.Hours), total(nil), total(nil), total(t.Amount))
+    // billable vs non-billable below the total
+    e.setRow(nil, "Оплачиваемые часы:", t.BillableHours)
+    e.setRow(nil, "Неоплачиваемые часы:", t.NonBillableHours)
+
+    if e.err != nil {
+        return e.err
+    }
+    if err := e.sw.Flush(); err != nil {
+        return err
+    }
+    return e.f.Write(e.w)
+}
+
+// plain rows without totals, so the file imports back as is
+type csvExport struct {
+    w       *csv.Writer
+    decimal string
+    err     error // of the header, reported with the first row
+}
+
+func newCSVExport(w io.Writer, o ExportOptions) *csvExport {
+    // Excel takes the file for UTF-8 only with the BOM
+    _, err := io.WriteString(w, "\ufeff")
+    e := &csvExport{w: csv.NewWriter(w), decimal: o.Decimal, err: err}
+    e.w.Comma = o.Delimiter
+    if e.err == nil {
+        e.err = e.w.Write(exportColumns())
+    }
+    return e
+}
+
+func (e *csvExport) number(v float64) string {
+    return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", e.decimal, 1)
+}
+
+func (e *csvExport) Row(log WorkLog, rate, amount float64) error {
+    if e.err != nil {
+        return e.err
+    }
+    record := []string{log.Date.Format("2006-01-02"), exportText(log.Description), e.number(log.Hours), exportYesNo(log.Billable), "", "",
+        exportText(log.ProjectName), exportText(strings.Join(log.Tags, ", ")), log.StartTime, log.EndTime, strconv.Itoa(log.BreakMinutes)}
+    if log.Billable {
+        record[4], record[5] = e.number(rate), e.number(amount)
+    }
+    return e.w.Write(record)
+}
+
+func (e *csvExport) Close(exportTotals) error {
+    if e.err != nil {
+        return e.err
+    }
+    e.w.Flush()
+    return e.w.Error()
+}
+
+// one worklog of the API per line, with rate and amount (null if not billable)
+type jsonlExport struct {
+    w   *bufio.Writer
+    enc *json.Encoder
+}
+
+func newJSONLExport(w io.Writer) *jsonlExport {
+    buf := bufio.NewWriter(w)
+    return &jsonlExport{w: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *jsonlExport) Row(log WorkLog, rate, amount float64) error {
+    entry := workLogJSON(log)
+    entry["rate"], entry["amount"] = nil, nil
+    if log.Billable {
+        entry["rate"], entry["amount"] = rate, amount
+    }
+    return e.enc.Encode(entry)
+}
+
+func (e *jsonlExport) Close(exportTotals) error {
+    return e.w.Flush()
+}
+
+// OpenDocument spreadsheet: a zip with the mimetype first (stored), the manifest and
+// content.xml, which is written while the rows come in
+type odsExport struct {
+    zip     *zip.Writer
+    content io.Writer
+    err     error // first write error, the rest is skipped
+}
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+const odsManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="` + odsMimeType + `"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+// ce1 header, ce2 totals, columns as wide as in the xlsx
+const odsContentStart = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+ xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+ xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+ xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+ xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" office:version="1.2">
+<office:automatic-styles>
+ <style:style style:name="co1" style:family="table-column"><style:table-column-properties style:column-width="3cm"/></style:style>
+ <style:style style:name="co2" style:family="table-column"><style:table-column-properties style:column-width="9cm"/></style:style>
+ <style:style style:name="ce1" style:family="table-cell"><style:table-cell-properties fo:background-color="#667eea"/><style:text-properties fo:font-weight="bold"/></style:style>
+ <style:style style:name="ce2" style:family="table-cell"><style:table-cell-properties fo:background-color="#4CAF50"/><style:text-properties fo:font-weight="bold"/></style:style>
+</office:automatic-styles>
+<office:body><office:spreadsheet><table:table table:name="Рабочие часы">
+<table:table-column table:style-name="co1"/><table:table-column table:style-name="co2"/><table:table-column table:style-name="co1" table:number-columns-repeated="9"/>
+`
+
+const odsContentEnd = `</table:table></office:spreadsheet></office:body></office:document-content>
+`
+
+func newODSExport(w io.Writer) *odsExport {
+    e := &odsExport{zip: zip.NewWriter(w)}
+
+    // readers find the type in the first bytes, so no compression and no data descriptor
+    mime, err := e.zip.CreateRaw(&zip.FileHeader{
+        Name:               "mimetype",
+        Method:             zip.Store,
+        CRC32:              crc32.ChecksumIEEE([]byte(odsMimeType)),
+        CompressedSize64:   uint64(len(odsMimeType)),
+        UncompressedSize64: uint64(len(odsMimeType)),
+    })
+    if err == nil {
+        _, err = io.WriteString(mime, odsMimeType)
+    }
+    var manifest io.Writer
+    if err == nil {
+        manifest, err = e.zip.Create("META-INF/manifest.xml")
+    }
+    if err == nil {
+        _, err = io.WriteString(manifest, odsManifest)
+    }
+    if err == nil {
+        e.content, err = e.zip.Create("content.xml")
+    }
+    e.err = err
+
+    e.write(odsContentStart)
+    e.write(`<table:table-row>`)
+    for _, name := range exportColumns() {
+        e.text("ce1", name)
+    }
+    e.write("</table:table-row>\n")
+    return e
+}
+
+func (e *odsExport) write(s string) {
+    if e.err == nil {
+        _, e.err = io.WriteString(e.content, s)
+    }
+}
+
+func (e *odsExport) text(style, s string) {
+    if style != "" {
+        style = ` table:style-name="` + style + `"`
+    }
+    e.write(`<table:table-cell` + style + ` office:value-type="string"><text:p>`)
+    if e.err == nil {
+        e.err = xml.EscapeText(e.content, []byte(s))
+    }
+    e.write(`</text:p></table:table-cell>`)
+}
+
+func (e *odsExport) number(style string, v float64) {
+    if style != "" {
+        style = ` table:style-name="` + style + `"`
+    }
+    n := strconv.FormatFloat(v, 'f', -1, 64)
+    e.write(`<table:table-cell` + style + ` office:value-type="float" office:value="` + n + `"><text:p>` + n + `</text:p></table:table-cell>`)
+}
+
+func (e *odsExport) empty(style string) {
+    if style != "" {
+        style = ` table:style-name="` + style + `"`
+    }
+    e.write(`<table:table-cell` + style + `/>`)
+}
+
+func (e *odsExport) Row(log WorkLog, rate, amount float64) error {
+    e.write(`<table:table-row><table:table-cell office:value-type="date" office:date-value="` + log.Date.Format("2006-01-02") +
+        `"><text:p>` + log.Date.Format("02.01.2006") + `</text:p></table:table-cell>`)
+    e.text("", exportText(log.Description))
+    e.number("", log.Hours)
+    e.text("", exportYesNo(log.Billable))
+    if log.Billable {
+        e.number("", rate)
+        e.number("", amount)
+    } else {
+        e.empty("")
+        e.empty("")
+    }
+    e.text("", exportText(log.ProjectName))
+    e.text("", exportText(strings.Join(log.Tags, ", ")))
+    e.text("", log.StartTime)
+    e.text("", log.EndTime)
+    e.number("", float64(log.BreakMinutes))
+    e.write("</table:table-row>\n")
+    return e.err
+}
+
+// the same totals as the xlsx, after an empty row
+func (e *odsExport) Close(t exportTotals) error {
+    e.write("<table:table-row><table:table-cell/></table:table-row>\n")
+    e.write(`<table:table-row>`)
+    e.empty("")
+    e.text("ce2", "ИТОГО:")
+    e.number("ce2", t.Hours)
+    e.empty("ce2")
+    e.empty("ce2")
+    e.number("ce2", t.Amount)
+    e.write("</table:table-row>\n")
+    for _, total := range []struct {
+        name  string
+        hours float64
+    }{{"Оплачиваемые часы:", t.BillableHours}, {"Неоплачиваемые часы:", t.NonBillableHours}} {
+        e.write(`<table:table-row>`)
+        e.empty("")
+        e.text("", total.name)
+        e.number("", total.hours)
+        e.write("</table:table-row>\n")
+    }
+    e.write(odsContentEnd)
+    if e.err != nil {
+        return e.err
+    }
+    return e.zip.Close()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "archive/zip"
+    "bytes"
+    "io"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/xuri/excelize/v2"
+)
+
+// alice with a billable and a non-billable worklog whose texts look like formulas
+func setupExportTest(t *testing.T) *User {
+    t.Helper()
+    setupTestDB(t)
+    user := createTestUser(t, "alice")
+    if err := rateStore.Create(&HourlyRate{UserID: user.ID, Rate: 100}); err != nil {
+        t.Fatal(err)
+    }
+    day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+    createTestWorkLog(t, WorkLog{UserID: user.ID, Date: day, Description: "=HYPERLINK(\"http://x\")", Hours: 2, Billable: true})
+    createTestWorkLog(t, WorkLog{UserID: user.ID, Date: day.AddDate(0, 0, 1), Description: "@SUM(1)", Hours: 1.5,
+        Tags: []string{"-x"}})
+    return user
+}
+
+func writeTestExport(t *testing.T, userID int, o ExportOptions) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    if err := WriteWorkLogExport(&buf, userID, WorkLogFilter{Sort: SortDateAsc}, o); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func TestExportXLSX(t *testing.T) {
+    user := setupExportTest(t)
+    data := writeTestExport(t, user.ID, ExportOptions{Format: ExportXLSX})
+
+    f, err := excelize.OpenReader(bytes.NewReader(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    if sheets := f.GetSheetList(); len(sheets) != 1 || sheets[0] != "Рабочие часы" {
+        t.Fatalf("sheets: %v", sheets)
+    }
+    rows, err := f.GetRows("Рабочие часы")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := [][]string{
+        {"Дата", "Описание", "Часы", "Оплачиваемо", "Ставка, " + config.Currency, "Сумма, " + config.Currency},
+        {"02.03.2026", "=HYPERLINK(\"http://x\")", "2", "да", "100", "200"},
+        {"03.03.2026", "@SUM(1)", "1.5", "нет"},
+        nil,
+        {"", "ИТОГО:", "3.5", "", "", "200"},
+        {"", "Оплачиваемые часы:", "2"},
+        {"", "Неоплачиваемые часы:", "1.5"},
+    }
+    if len(rows) != len(want) {
+        t.Fatalf("%d rows, want %d: %q", len(rows), len(want), rows)
+    }
+    for i := range want {
+        if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+            t.Errorf("row %d: %q, want %q", i+1, rows[i], want[i])
+        }
+    }
+    // text stays text, the stream writer does not turn it into a formula
+    if formula, err := f.GetCellFormula("Рабочие часы", "B2"); err != nil || formula != "" {
+        t.Errorf("B2 has formula %q %v", formula, err)
+    }
+}
+
+// text that starts like a formula gets a ', the import takes it back off
+func TestExportCSVFormulas(t *testing.T) {
+    user := setupExportTest(t)
+    data := writeTestExport(t, user.ID, ExportOptions{Format: ExportCSV, Delimiter: ';', Decimal: ","})
+
+    for _, cell := range []string{`"'=HYPERLINK(""http://x"")"`, "'@SUM(1)", "'-x"} {
+        if !bytes.Contains(data, []byte(";"+cell+";")) {
+            t.Errorf("csv has no %s:\n%s", cell, data)
+        }
+    }
+
+    result, err := ImportWorkLogs(user.ID, "export.csv", data, ImportOptions{DryRun: true, IncludeDuplicates: true},
+        &Audit{ActorName: "test", Channel: ChannelCLI})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(result.Rows) != 2 || result.Rows[0].Log.Description != "=HYPERLINK(\"http://x\")" ||
+        result.Rows[1].Log.Description != "@SUM(1)" {
+        t.Fatalf("imported back: %+v", result.Rows)
+    }
+}
+
+func TestExportODSFormulas(t *testing.T) {
+    user := setupExportTest(t)
+    data := writeTestExport(t, user.ID, ExportOptions{Format: ExportODS})
+
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var content []byte
+    for _, file := range zr.File {
+        if file.Name == "content.xml" {
+            r, err := file.Open()
+            if err != nil {
+                t.Fatal(err)
+            }
+            content, err = io.ReadAll(r)
+            r.Close()
+            if err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    for _, text := range []string{"<text:p>&#39;=HYPERLINK(&#34;http://x&#34;)</text:p>", "<text:p>&#39;@SUM(1)</text:p>", "<text:p>&#39;-x</text:p>"} {
+        if !bytes.Contains(content, []byte(text)) {
+            t.Errorf("content.xml has no %s", text)
+        }
+    }
+}
+
+// a download that stalls at its first write until the test lets it go on
+type stalledWriter struct {
+    stalled, resume chan struct{}
+    once            sync.Once
+    buf             bytes.Buffer
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+    w.once.Do(func() {
+        close(w.stalled)
+        <-w.resume
+    })
+    return w.buf.Write(p)
+}
+
+// a slow client must not hold up the writes of everybody else
+func TestExportDoesNotBlockWrites(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            defer func(n int) { eachChunk = n }(eachChunk)
+            eachChunk = 5
+            user := createTestUser(t, "alice")
+            day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+            for i := 0; i < 40; i++ {
+                createTestWorkLog(t, WorkLog{UserID: user.ID, Date: day.AddDate(0, 0, i), Description: strings.Repeat("x", 100), Hours: 1})
+            }
+
+            w := &stalledWriter{stalled: make(chan struct{}), resume: make(chan struct{})}
+            done := make(chan error, 1)
+            go func() {
+                done <- WriteWorkLogExport(w, user.ID, WorkLogFilter{Sort: SortDateAsc}, ExportOptions{Format: ExportJSONL})
+            }()
+            <-w.stalled
+
+            // SQLite would wait for the read lock of the export and give up after the busy timeout
+            start := time.Now()
+            err := worklogStore.Create(&WorkLog{UserID: user.ID, Date: day, Description: "meanwhile", Hours: 1},
+                &Audit{ActorID: user.ID, ActorName: "test", Channel: ChannelSystem})
+            close(w.resume)
+            if err != nil {
+                t.Fatalf("write during the export: %v", err)
+            }
+            if d := time.Since(start); d > time.Second {
+                t.Fatalf("write during the export waited %v", d)
+            }
+            if err := <-done; err != nil {
+                t.Fatal(err)
+            }
+            if n := bytes.Count(w.buf.Bytes(), []byte("\n")); n < 40 {
+                t.Fatalf("%d exported rows, want at least 40", n)
+            }
+        })
+    }
+}
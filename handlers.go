@@ -3,10 +3,13 @@ package main
 import (
     "github.com/gin-gonic/gin"
     "github.com/gin-contrib/sessions"
-    "github.com/xuri/excelize/v2"
     "net/http"
     "time"
     "fmt"
+    "strconv"
+    "strings"
+    "errors"
+    "log"
 )
 
 // main page 
@@ -22,6 +25,7 @@ func LoginPage(c *gin.Context) {
     
     c.HTML(http.StatusOK, "login.html", gin.H{
         "timeout": timeout == "1",
+        "reset":   c.Query("reset") == "1",
     })
 }
 // username 
@@ -29,26 +33,48 @@ func LoginHandler(c *gin.Context) {
     username := c.PostForm("username")
     password := c.PostForm("password")
     
-    user, err := GetUserByUsername(username)
-    if err != nil {
-        c.HTML(http.StatusOK, "login.html", gin.H{
-            "error": "WTF your creds are wrong",
+    attempt := LoginAttempt{Username: username, IP: c.ClientIP(), Channel: ChannelWeb}
+    if err := CheckLoginAllowed(&attempt); err != nil {
+        status, text := loginRefused(err)
+        c.HTML(status, "login.html", gin.H{
+            "error": text,
         })
         return
     }
+    defer FinishLoginAttempt(&attempt)
     
-    if !CheckPassword(password, user.Password) {
+    // one message for unknown user and wrong password
+    user, err := GetUserByUsername(username)
+    if err != nil && err != ErrUserNotFound {
+        c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+            "error": "Ошибка входа, попробуйте позже",
+        })
+        return
+    }
+    if !CheckPasswordOrDummy(user, password) {
+        RecordLoginFailure(&attempt, LoginFailPassword)
         c.HTML(http.StatusOK, "login.html", gin.H{
-            "error": "shitty you are doing creds",
+            "error": "Неверный логин или пароль",
+        })
+        return
+    }
+    if user.Disabled {
+        c.HTML(http.StatusForbidden, "login.html", gin.H{
+            "error": "Учётная запись отключена администратором",
         })
         return
     }
     
+    // with 2FA the session gets user_id only after the code
+    if user.TOTPEnabled {
+        startTwoFactorLogin(c, user)
+        return
+    }
+    
+    RecordLoginSuccess(attempt)
+    
     // save sessions
-    session := sessions.Default(c)
-    session.Set("user_id", user.ID)
-    session.Set("username", user.Username)
-    session.Save()
+    StartSession(c, user)
     
     c.Redirect(http.StatusFound, "/dashboard")
 }
@@ -57,73 +83,136 @@ func LoginHandler(c *gin.Context) {
 func DashboardPage(c *gin.Context) {
     username := GetCurrentUsername(c)
     
+    // running timer, nil if there is none
+    timer, _ := timerStore.Get(GetCurrentUserID(c))
+    
     c.HTML(http.StatusOK, "dashboard.html", gin.H{
         "username": username,
+        "timer":    timer,
+        "projects": userProjects(c),
+        "error":    c.Query("error"),
+        "notice":   c.Query("notice"),
+        "isAdmin":  HasPermission(CurrentRole(c), PermViewUsers),
+        "hasTeams": HasPermission(CurrentRole(c), PermViewTeams),
     })
 }
 
 // new entry form 
 func NewWorkLogPage(c *gin.Context) {
-    c.HTML(http.StatusOK, "new_worklog.html", gin.H{})
+    c.HTML(http.StatusOK, "new_worklog.html", gin.H{
+        "projects":    userProjects(c),
+        "tags":        userTags(c),
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+    })
 }
 
-// save new entry
-func CreateWorkLogHandler(c *gin.Context) {
-    date := c.PostForm("date")
-    description := c.PostForm("description")
-    hours := c.PostForm("hours")
+// date, times, hours, project, tags, billable from web form
+func parseWorkLogForm(c *gin.Context) (*WorkLog, error) {
+    date, err := time.Parse("2006-01-02", c.PostForm("date"))
+    if err != nil {
+        return nil, fmt.Errorf("неверная дата")
+    }
     
-    userID := GetCurrentUserID(c)
+    log := &WorkLog{
+        UserID:      GetCurrentUserID(c),
+        Date:        date,
+        StartTime:   c.PostForm("start_time"),
+        EndTime:     c.PostForm("end_time"),
+        Description: c.PostForm("description"),
+        Tags:        strings.Split(c.PostForm("tags"), ","), // normalized by PrepareWorkLog
+        Billable:    c.PostForm("billable") != "",
+    }
     
-    _, err := db.Exec(
-        "INSERT INTO worklogs (user_id, date, description, hours) VALUES (?, ?, ?, ?)",
-        userID, date, description, hours,
-    )
+    // hours may be empty when start/end are given, PrepareWorkLog computes them
+    if v := c.PostForm("hours"); v != "" {
+        if log.Hours, err = strconv.ParseFloat(v, 64); err != nil {
+            return nil, fmt.Errorf("часы должны быть от 0 до 24")
+        }
+    }
+    if v := c.PostForm("break_minutes"); v != "" {
+        if log.BreakMinutes, err = strconv.Atoi(v); err != nil {
+            return nil, fmt.Errorf("неверный перерыв")
+        }
+    }
+    if v := c.PostForm("project_id"); v != "" {
+        if log.ProjectID, err = strconv.Atoi(v); err != nil {
+            return nil, fmt.Errorf("неверный проект")
+        }
+    }
+    return log, nil
+}
+
+// projects for the <select> in worklog forms
+func userProjects(c *gin.Context) []Project {
+    userID, _ := worklogOwner(c)
+    projects, _ := projectStore.List(userID)
+    return projects
+}
+
+// existing tags for the suggestions in worklog forms and the list filter
+func userTags(c *gin.Context) []Tag {
+    userID, _ := worklogOwner(c)
+    tags, _ := tagStore.List(userID)
+    return tags
+}
+
+// save new entry
+func CreateWorkLogHandler(c *gin.Context) {
+    a := webAudit(c)
+    log, err := parseWorkLogForm(c)
+    if err == nil {
+        if err = PrepareWorkLog(log, a.OverrideLock); err != nil {
+            err = fmt.Errorf("%s", workLogErrorText(err))
+        }
+    }
+    if err != nil {
+        c.HTML(http.StatusOK, "new_worklog.html", gin.H{
+            "error":       "error to save entry: " + err.Error(),
+            "projects":    userProjects(c),
+            "tags":        userTags(c),
+            "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+        })
+        return
+    }
     
+    if err = worklogStore.Create(log, a); isWorkLogRuleError(err) {
+        err = fmt.Errorf("%s", workLogErrorText(err))
+    }
     if err != nil {
         c.HTML(http.StatusOK, "new_worklog.html", gin.H{
-            "error": "error to save entry: " + err.Error(),
+            "error":       "error to save entry: " + err.Error(),
+            "projects":    userProjects(c),
+            "tags":        userTags(c),
+            "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
         })
         return
     }
     
     c.HTML(http.StatusOK, "new_worklog.html", gin.H{
-        "success": "✅ Save new entry!",
+        "success":     "✅ Save new entry!",
+        "projects":    userProjects(c),
+        "tags":        userTags(c),
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
     })
 }
 
 // List all entris 
 // list after filtered
 func WorkLogListPage(c *gin.Context) {
-    userID := GetCurrentUserID(c)
+    // own worklogs, or a team member's (read-only) under /teams/:id/members/:user_id
+    userID, _ := worklogOwner(c)
+    listURL, exportURL := worklogLinks(c)
     
     // values filters
-    dateFrom := c.Query("date_from")
-    dateTo := c.Query("date_to")
-    search := c.Query("search")
-    
-    //  SQL build filters 
-    query := `SELECT id, date, description, hours FROM worklogs WHERE user_id = ?`
-    args := []interface{}{userID}
-    
-    if dateFrom != "" {
-        query += ` AND date >= ?`
-        args = append(args, dateFrom)
-    }
-    
-    if dateTo != "" {
-        query += ` AND date <= ?`
-        args = append(args, dateTo)
-    }
-    
-    if search != "" {
-        query += ` AND description LIKE ?`
-        args = append(args, "%"+search+"%")
+    filter, err := ParseWorkLogFilter(c)
+    if err != nil {
+        c.HTML(http.StatusOK, "worklog_list.html", gin.H{
+            "error": "errors filter: " + err.Error(),
+        })
+        return
     }
     
-    query += ` ORDER BY date DESC`
-    
-    rows, err := db.Query(query, args...)
+    logs, err := worklogStore.List(userID, filter)
     
     if err != nil {
         c.HTML(http.StatusOK, "worklog_list.html", gin.H{
@@ -131,23 +220,31 @@ func WorkLogListPage(c *gin.Context) {
         })
         return
     }
-    defer rows.Close()
     
-    var logs []WorkLog
-    for rows.Next() {
-        var log WorkLog
-        var dateStr string
-        rows.Scan(&log.ID, &dateStr, &log.Description, &log.Hours)
-        
-        log.Date, _ = time.Parse("2006-01-02", dateStr)
-        logs = append(logs, log)
+    // without rates the amounts are just 0
+    var billing *BillingSummary
+    if book, err := LoadRateBook(userID); err == nil {
+        summary := book.Summarize(logs)
+        billing = &summary
     }
     
     c.HTML(http.StatusOK, "worklog_list.html", gin.H{
-        "logs":     logs,
-        "dateFrom": dateFrom,
-        "dateTo":   dateTo,
-        "search":   search,
+        "frozen":      frozenWorkLogs(userID, logs),
+        "lockDay":     lockDay(userID),
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+        "billing":     billing,
+        "currency":    config.Currency,
+        "logs":        logs,
+        "dateFrom":    filter.DateFrom,
+        "dateTo":      filter.DateTo,
+        "search":      filter.Search,
+        "projectID":   filter.ProjectID,
+        "tag":         strings.Join(filter.Tags, ", "),
+        "projects":    userProjects(c),
+        "tags":        userTags(c),
+        "listURL":     listURL,
+        "exportURL":   exportURL,
+        "view":        memberView(c),
     })
 }
 
@@ -156,14 +253,9 @@ func WorkLogListPage(c *gin.Context) {
 // reports
 // reports analytics 
 func ReportsPage(c *gin.Context) {
-    userID := GetCurrentUserID(c)
+    userID, _ := worklogOwner(c)
     
-    rows, err := db.Query(`
-        SELECT date, hours 
-        FROM worklogs 
-        WHERE user_id = ? 
-        ORDER BY date ASC
-    `, userID)
+    logs, err := worklogStore.List(userID, WorkLogFilter{Sort: SortDateAsc})
     
     if err != nil {
         c.HTML(http.StatusOK, "reports.html", gin.H{
@@ -171,7 +263,6 @@ func ReportsPage(c *gin.Context) {
         })
         return
     }
-    defer rows.Close()
     
     var dates []string
     var hours []float64
@@ -180,14 +271,11 @@ func ReportsPage(c *gin.Context) {
     // for goupe monthe 
     monthsMap := make(map[string]float64)
     // for grpoup weeks  
-    weeksMap := make(map[string]fl at64)
+    weeksMap := make(map[string]float64)
     
-    for rows.Next() {
-        var date string
-        var hour float64
-        rows.Scan(&date, &hour)
-        
-        t, _ := time.Parse("2006-01-02", date)
+    for _, log := range logs {
+        t := log.Date
+        hour := log.Hours
         
         // group dates
 	dates = append(dates, t.Format("02.01"))
@@ -227,7 +315,33 @@ func ReportsPage(c *gin.Context) {
         avgHours = totalHours / float64(len(hours))
     }
     
+    book, err := LoadRateBook(userID)
+    if err != nil {
+        c.HTML(http.StatusOK, "reports.html", gin.H{
+            "error": "errors loads rates",
+        })
+        return
+    }
+    billing := book.Summarize(logs)
+    
+    // per tag
+    var tagNames []string
+    var tagHours []float64
+    byTag, untagged := HoursByTag(logs)
+    for _, t := range byTag {
+        tagNames = append(tagNames, t.Tag)
+        tagHours = append(tagHours, t.Hours)
+    }
+    if untagged > 0 {
+        tagNames = append(tagNames, "без тега")
+        tagHours = append(tagHours, untagged)
+    }
+    
     c.HTML(http.StatusOK, "reports.html", gin.H{
+        "billing":    billing,
+        "currency":   config.Currency,
+        "tagNames":   tagNames,
+        "tagHours":   tagHours,
         "dates":      dates,
         "hours":      hours,
         "months":     months,
@@ -237,45 +351,68 @@ func ReportsPage(c *gin.Context) {
         "totalHours": totalHours,
         "avgHours":   avgHours,
         "daysCount":  len(hours),
+        "view":       memberView(c),
     })
 }
 
-// 
+// ownership checked by WorkLogOwnerRequired
 func EditWorkLogPage(c *gin.Context) {
-    id := c.Param("id")
+    log := CurrentWorkLog(c)
     
-    var log WorkLog
-    var dateStr string
-    err := db.QueryRow("SELECT id, date, description, hours FROM worklogs WHERE id = ?", id).
-        Scan(&log.ID, &dateStr, &log.Description, &log.Hours)
-    
-    if err != nil {
-        c.Redirect(http.StatusFound, "/worklog/list")
-        return
+    data := gin.H{
+        "log":         log,
+        "projects":    userProjects(c),
+        "tags":        userTags(c),
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
     }
-    
-    log.Date, _ = time.Parse("2006-01-02", dateStr)
-    
-    c.HTML(http.StatusOK, "edit_worklog.html", gin.H{
-        "log": log,
-    })
+    err := CheckWorkLogEditable(log, false)
+    switch {
+    case errors.Is(err, ErrPeriodLocked) && data["canOverride"] == true:
+        // admins may still save, with the override box ticked
+        data["closed"] = workLogErrorText(err)
+    case err != nil:
+        data["locked"] = workLogErrorText(err)
+    }
+    c.HTML(http.StatusOK, "edit_worklog.html", data)
 }
 
-//
+// ownership checked by WorkLogOwnerRequired
 func UpdateWorkLogHandler(c *gin.Context) {
-    id := c.Param("id")
-    date := c.PostForm("date")
-    description := c.PostForm("description")
-    hours := c.PostForm("hours")
-    
-    _, err := db.Exec(
-        "UPDATE worklogs SET date = ?, description = ?, hours = ? WHERE id = ?",
-        date, description, hours, id,
-    )
-    
+    log := CurrentWorkLog(c)
+    a := webAudit(c)
+    
+    updated, err := parseWorkLogForm(c)
+    if err == nil {
+        updated.ID = log.ID
+        if err = CheckWorkLogEditable(log, a.OverrideLock); err == nil {
+            err = PrepareWorkLog(updated, a.OverrideLock)
+        }
+        if err != nil {
+            err = fmt.Errorf("%s", workLogErrorText(err))
+        }
+    }
     if err != nil {
         c.HTML(http.StatusOK, "edit_worklog.html", gin.H{
-            "error": "Ошибка обновления",
+            "log":         log,
+            "projects":    userProjects(c),
+            "tags":        userTags(c),
+            "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+            "error":       "Ошибка обновления: " + err.Error(),
+        })
+        return
+    }
+    
+    if err := worklogStore.Update(updated, a); err != nil {
+        text := "Ошибка обновления"
+        if isWorkLogRuleError(err) {
+            text += ": " + workLogErrorText(err)
+        }
+        c.HTML(http.StatusOK, "edit_worklog.html", gin.H{
+            "log":         log,
+            "projects":    userProjects(c),
+            "tags":        userTags(c),
+            "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+            "error":       text,
         })
         return
     }
@@ -283,12 +420,20 @@ func UpdateWorkLogHandler(c *gin.Context) {
     c.Redirect(http.StatusFound, "/worklog/list")
 }
 
-// 
+// ownership checked by WorkLogOwnerRequired
 func DeleteWorkLogHandler(c *gin.Context) {
-    id := c.Param("id")
+    log := CurrentWorkLog(c)
+    a := webAudit(c)
     
-    _, err := db.Exec("DELETE FROM worklogs WHERE id = ?", id)
-    if err != nil {
+    if err := CheckWorkLogEditable(log, a.OverrideLock); err != nil {
+        c.String(http.StatusConflict, workLogErrorText(err))
+        return
+    }
+    if err := worklogStore.Delete(log.UserID, log.ID, a); err != nil {
+        if isWorkLogRuleError(err) {
+            c.String(http.StatusConflict, workLogErrorText(err))
+            return
+        }
         c.String(http.StatusInternalServerError, "Ошибка удаления")
         return
     }
@@ -305,107 +450,24 @@ func LogoutHandler(c *gin.Context) {
     c.Redirect(http.StatusFound, "/login")
 }
 
-//  Excel
+// xlsx by default, ?format=csv|jsonl|ods (see ParseExportOptions)
 func ExportWorkLogHandler(c *gin.Context) {
-    userID := GetCurrentUserID(c)
-    username := GetCurrentUsername(c)
-
-    // 
-    dateFrom := c.Query("date_from")
-    dateTo := c.Query("date_to")
-    search := c.Query("search")
-
-    // SQL
-    query := `SELECT date, description, hours FROM worklogs WHERE user_id = ?`
-    args := []interface{}{userID}
-
-    if dateFrom != "" {
-        query += ` AND date >= ?`
-        args = append(args, dateFrom)
-    }
-
-    if dateTo != "" {
-        query += ` AND date <= ?`
-        args = append(args, dateTo)
-    }
-
-    if search != "" {
-        query += ` AND description LIKE ?`
-        args = append(args, "%"+search+"%")
-    }
+    userID, username := worklogOwner(c)
 
-    query += ` ORDER BY date DESC`
-
-    rows, err := db.Query(query, args...)
+    // same filters as the list page
+    filter, err := ParseWorkLogFilter(c)
     if err != nil {
-        c.String(http.StatusInternalServerError, "Ошибка получения данных")
+        c.String(http.StatusBadRequest, "Неверный фильтр: "+err.Error())
         return
     }
-    defer rows.Close()
-
-    // Excel 
-    f := excelize.NewFile()
-    sheetName := "Рабочие часы"
-    index, _ := f.NewSheet(sheetName)
-
-    // 
-    f.SetCellValue(sheetName, "A1", "Дата")
-    f.SetCellValue(sheetName, "B1", "Описание")
-    f.SetCellValue(sheetName, "C1", "Часы")
-
-    //
-    headerStyle, _ := f.NewStyle(&excelize.Style{
-        Font: &excelize.Font{Bold: true, Size: 12},
-        Fill: excelize.Fill{Type: "pattern", Color: []string{"#667eea"}, Pattern: 1},
-        Alignment: &excelize.Alignment{Horizontal: "center"},
-    })
-    f.SetCellStyle(sheetName, "A1", "C1", headerStyle)
-
-    // 
-    row := 2
-    totalHours := 0.0
-
-    for rows.Next() {
-        var date, description string
-        var hours float64
-        rows.Scan(&date, &description, &hours)
-
-        t, _ := time.Parse("2006-01-02", date)
-
-        f.SetCellValue(sheetName, "A"+fmt.Sprintf("%d", row), t.Format("02.01.2006"))
-        f.SetCellValue(sheetName, "B"+fmt.Sprintf("%d", row), description)
-        f.SetCellValue(sheetName, "C"+fmt.Sprintf("%d", row), hours)
-
-        totalHours += hours
-        row++
+    opts, err := ParseExportOptions(c)
+    if err != nil {
+        c.String(http.StatusBadRequest, "Неверный формат: "+err.Error())
+        return
     }
 
-    // 
-    row++
-    f.SetCellValue(sheetName, "B"+fmt.Sprintf("%d", row), "ИТОГО:")
-    f.SetCellValue(sheetName, "C"+fmt.Sprintf("%d", row), totalHours)
-
-    totalStyle, _ := f.NewStyle(&excelize.Style{
-        Font: &excelize.Font{Bold: true, Size: 12},
-        Fill: excelize.Fill{Type: "pattern", Color: []string{"#4CAF50"}, Pattern: 1},
-    })
-    f.SetCellStyle(sheetName, "B"+fmt.Sprintf("%d", row), "C"+fmt.Sprintf("%d", row), totalStyle)
-
-    //
-    f.SetColWidth(sheetName, "A", "A", 15)
-    f.SetColWidth(sheetName, "B", "B", 50)
-    f.SetColWidth(sheetName, "C", "C", 10)
-
-    f.SetActiveSheet(index)
-    f.DeleteSheet("Sheet1")
-
-    // 
-    fileName := fmt.Sprintf("worklog_%s_%s.xlsx", username, time.Now().Format("2006-01-02"))
-
-    c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-    c.Header("Content-Disposition", "attachment; filename="+fileName)
-
-    if err := f.Write(c.Writer); err != nil {
+    setExportHeaders(c, username, opts)
+    if err := WriteWorkLogExport(c.Writer, userID, filter, opts); err != nil && !exportInterrupted(c, err) {
         c.String(http.StatusInternalServerError, "Ошибка создания файла")
     }
 }
@@ -420,6 +482,7 @@ func RegisterHandler(c *gin.Context) {
     username := c.PostForm("username")
     password := c.PostForm("password")
     passwordConfirm := c.PostForm("password_confirm")
+    email, emailErr := NormalizeEmail(c.PostForm("email"))
 
     // 
     if username == "" || password == "" {
@@ -450,6 +513,13 @@ func RegisterHandler(c *gin.Context) {
         return
     }
 
+    if emailErr != nil {
+        c.HTML(http.StatusOK, "register.html", gin.H{
+            "error": "Некорректный email",
+        })
+        return
+    }
+
     // 
     existingUser, _ := GetUserByUsername(username)
     if existingUser != nil {
@@ -470,10 +540,13 @@ func RegisterHandler(c *gin.Context) {
 
     // 
     user, _ := GetUserByUsername(username)
-    session := sessions.Default(c)
-    session.Set("user_id", user.ID)
-    session.Set("username", user.Username)
-    session.Save()
+    // the email is set from the link mailed to it, a taken one is not told apart
+    if email != "" {
+        if err := RequestEmailChange(user, email); err != nil {
+            log.Println("email confirmation:", err)
+        }
+    }
+    StartSession(c, user)
 
     c.Redirect(http.StatusFound, "/dashboard")
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func adminErrorText(err error) string {
+    switch err {
+    case ErrOwnAccount:
+        return "Свою учётную запись здесь менять нельзя"
+    case ErrUserNotFound:
+        return "Пользователь не найден"
+    case ErrInvalidRole:
+        return "Неизвестная роль"
+    case ErrPasswordTooShort:
+        return "Пароль должен быть не короче 6 символов"
+    }
+    return "Ошибка сохранения"
+}
+
+func AdminUsersPage(c *gin.Context) {
+    renderAdminUsersPage(c, gin.H{})
+}
+
+func renderAdminUsersPage(c *gin.Context, data gin.H) {
+    users, err := userStore.List()
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки пользователей")
+        return
+    }
+    data["users"] = users
+    data["roles"] = allRoles
+    data["currentUserID"] = GetCurrentUserID(c)
+    data["canManage"] = HasPermission(CurrentRole(c), PermManageUsers)
+    data["canManageLocks"] = HasPermission(CurrentRole(c), PermManageLocks)
+    c.HTML(http.StatusOK, "admin_users.html", data)
+}
+
+// runs an admin action on the user from :id and shows the list again
+func adminAction(c *gin.Context, success string, action func(actor *User, userID int) error) {
+    actor, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    userID, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        renderAdminUsersPage(c, gin.H{"error": adminErrorText(ErrUserNotFound)})
+        return
+    }
+    if err := action(actor, userID); err != nil {
+        renderAdminUsersPage(c, gin.H{"error": adminErrorText(err)})
+        return
+    }
+    renderAdminUsersPage(c, gin.H{"success": success})
+}
+
+func AdminSetRoleHandler(c *gin.Context) {
+    adminAction(c, "Роль изменена", func(actor *User, userID int) error {
+        return AdminSetRole(actor, userID, c.PostForm("role"))
+    })
+}
+
+func AdminDisableUserHandler(c *gin.Context) {
+    adminAction(c, "Пользователь отключён", func(actor *User, userID int) error {
+        return AdminSetDisabled(actor, userID, true)
+    })
+}
+
+func AdminEnableUserHandler(c *gin.Context) {
+    adminAction(c, "Пользователь включён", func(actor *User, userID int) error {
+        return AdminSetDisabled(actor, userID, false)
+    })
+}
+
+func AdminDeleteUserHandler(c *gin.Context) {
+    adminAction(c, "Пользователь удалён", AdminDeleteUser)
+}
+
+func AdminResetPasswordHandler(c *gin.Context) {
+    adminAction(c, "Пароль изменён, сессии и токены пользователя отозваны", func(actor *User, userID int) error {
+        return AdminResetPassword(actor, userID, c.PostForm("password"))
+    })
+}
+
+func AdminResetTwoFactorHandler(c *gin.Context) {
+    adminAction(c, "Двухфакторная аутентификация сброшена", AdminResetTwoFactor)
+}
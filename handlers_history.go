@@ -0,0 +1,83 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func historyErrorText(err error) string {
+    switch {
+    case errors.Is(err, ErrHistoryNotFound):
+        return "Запись истории не найдена"
+    case errors.Is(err, ErrNothingToRestore):
+        return "Эту версию нельзя восстановить"
+    case errors.Is(err, ErrWorkLogPurged):
+        return "Запись удалена навсегда, её версии нельзя восстановить"
+    case errors.Is(err, ErrWorkLogNotFound):
+        return "Запись уже изменилась, обновите страницу"
+    case isWorkLogRuleError(err):
+        return workLogErrorText(err)
+    }
+    return "Ошибка восстановления"
+}
+
+// latest changes of all own worklogs, deleted ones included
+func WorkLogHistoryPage(c *gin.Context) {
+    changes, err := historyStore.Recent(GetCurrentUserID(c), recentHistory)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки истории")
+        return
+    }
+    c.HTML(http.StatusOK, "worklog_history.html", gin.H{
+        "changes":     changes,
+        "purged":      purgedWorkLogs(changes),
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+    })
+}
+
+// every version of one worklog, also after it was deleted
+func WorkLogVersionsPage(c *gin.Context) {
+    worklogID, _ := strconv.Atoi(c.Param("id"))
+    renderWorkLogVersions(c, worklogID, gin.H{})
+}
+
+func renderWorkLogVersions(c *gin.Context, worklogID int, data gin.H) {
+    changes, err := historyStore.ForWorkLog(GetCurrentUserID(c), worklogID)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки истории")
+        return
+    }
+    // no history = not an own worklog
+    if len(changes) == 0 {
+        WebWorkLogNotFound(c)
+        return
+    }
+
+    _, err = worklogStore.Get(GetCurrentUserID(c), worklogID)
+    data["changes"] = changes
+    data["worklogID"] = worklogID
+    data["deleted"] = err == ErrWorkLogNotFound
+    data["purged"] = purgedWorkLogs(changes)
+    data["canOverride"] = HasPermission(CurrentRole(c), PermOverrideLocks)
+    c.HTML(http.StatusOK, "worklog_history.html", data)
+}
+
+// :id is the history entry whose version comes back
+func RestoreWorkLogHandler(c *gin.Context) {
+    historyID, _ := strconv.Atoi(c.Param("id"))
+    restored, err := RestoreWorkLogVersion(GetCurrentUserID(c), historyID, webAudit(c))
+    if err != nil {
+        ch, getErr := historyStore.Get(GetCurrentUserID(c), historyID)
+        if getErr != nil {
+            WebWorkLogNotFound(c)
+            return
+        }
+        renderWorkLogVersions(c, ch.WorkLogID, gin.H{"error": historyErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, fmt.Sprintf("/worklog/history/%d", restored.ID))
+}
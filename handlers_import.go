@@ -0,0 +1,100 @@
+package main
+
+import (
+    "encoding/base64"
+    "errors"
+    "io"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+func ImportPage(c *gin.Context) {
+    c.HTML(http.StatusOK, "import.html", gin.H{
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+    })
+}
+
+// The upload is always a dry run; the preview page posts the same file back
+// (base64 in "content") with commit=1 to import it. source = "" for our own
+// spreadsheet layout, otherwise the tracker the export comes from.
+func ImportWorkLogsHandler(c *gin.Context) {
+    filename, content, err := importUpload(c)
+    commit := c.PostForm("commit") == "1"
+    source := c.PostForm("source")
+    data := gin.H{
+        "source":            source,
+        "canOverride":       HasPermission(CurrentRole(c), PermOverrideLocks),
+        "includeDuplicates": c.PostForm("include_duplicates") == "1",
+        "overrideLock":      c.PostForm("override_lock") == "1",
+    }
+    if err != nil {
+        data["error"] = importErrorText(err)
+        c.HTML(http.StatusBadRequest, "import.html", data)
+        return
+    }
+
+    opts := ImportOptions{
+        DryRun:            !commit,
+        IncludeDuplicates: data["includeDuplicates"].(bool),
+    }
+    var result *ImportResult
+    var mapping *ImportMapping
+    if source == "" {
+        result, err = ImportWorkLogs(GetCurrentUserID(c), filename, content, opts, webAudit(c))
+    } else {
+        result, mapping, err = ImportTrackerFile(GetCurrentUserID(c), source, filename, content, opts, webAudit(c))
+    }
+    if err != nil {
+        if !isImportFileError(err) {
+            c.String(http.StatusInternalServerError, "Ошибка импорта")
+            return
+        }
+        data["error"] = importErrorText(err)
+        c.HTML(http.StatusBadRequest, "import.html", data)
+        return
+    }
+
+    rowErrors := make(map[int]string)
+    for _, row := range result.Rows {
+        if row.Err != nil {
+            rowErrors[row.Line] = importErrorText(row.Err)
+        }
+    }
+    data["result"] = result
+    data["mapping"] = mapping
+    data["skipTexts"] = importSkipTexts
+    data["rowErrors"] = rowErrors
+    data["filename"] = filename
+    data["content"] = base64.StdEncoding.EncodeToString(content)
+    c.HTML(http.StatusOK, "import.html", data)
+}
+
+// the file input, or the file of the preview sent back
+func importUpload(c *gin.Context) (string, []byte, error) {
+    if encoded := c.PostForm("content"); encoded != "" {
+        content, err := base64.StdEncoding.DecodeString(encoded)
+        if err != nil {
+            return "", nil, ErrImportFormat
+        }
+        return c.PostForm("filename"), content, nil
+    }
+
+    header, err := c.FormFile("file")
+    if errors.Is(err, http.ErrMissingFile) {
+        return "", nil, ErrImportEmpty
+    }
+    if err != nil {
+        return "", nil, ErrImportFormat
+    }
+    if header.Size > maxImportSize {
+        return "", nil, ErrImportTooLarge
+    }
+    f, err := header.Open()
+    if err != nil {
+        return "", nil, err
+    }
+    defer f.Close()
+    content, err := io.ReadAll(f)
+    return header.Filename, content, err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+    "errors"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// invoice list, form for a new one and (with ?client_id=&date_from=&date_to=) its preview
+func InvoicesPage(c *gin.Context) {
+    data := gin.H{}
+    if c.Query("client_id") != "" {
+        clientID, from, to, err := parseInvoiceForm(c.Query)
+        var draft *Invoice
+        if err == nil {
+            draft, err = DraftInvoice(GetCurrentUserID(c), clientID, from, to)
+        }
+        if err != nil {
+            data["error"] = invoiceErrorText(err)
+        } else {
+            data["draft"] = draft
+        }
+    }
+    renderInvoicesPage(c, data)
+}
+
+func renderInvoicesPage(c *gin.Context, data gin.H) {
+    userID := GetCurrentUserID(c)
+
+    invoices, err := invoiceStore.List(userID)
+    if err != nil {
+        data["error"] = "errors loads invoices"
+    }
+    clients, _ := clientStore.List(userID)
+
+    data["invoices"] = invoices
+    data["clients"] = clients
+    data["clientID"], _ = strconv.Atoi(c.Query("client_id"))
+    data["dateFrom"] = c.Query("date_from")
+    data["dateTo"] = c.Query("date_to")
+    c.HTML(http.StatusOK, "invoices.html", data)
+}
+
+// client_id, date_from, date_to from query (preview) or form (create)
+func parseInvoiceForm(value func(string) string) (int, time.Time, time.Time, error) {
+    clientID, err := strconv.Atoi(value("client_id"))
+    if err != nil {
+        return 0, time.Time{}, time.Time{}, ErrClientNotFound
+    }
+    from, to, err := parseInvoicePeriod(value("date_from"), value("date_to"))
+    return clientID, from, to, err
+}
+
+func invoiceErrorText(err error) string {
+    switch {
+    case errors.Is(err, ErrClientNotFound):
+        return "Клиент не найден"
+    case errors.Is(err, ErrInvalidPeriod):
+        return "Неверный период"
+    case errors.Is(err, ErrNothingToInvoice):
+        return "Нет оплачиваемых записей без счёта за этот период"
+    case errors.Is(err, ErrAlreadyInvoiced):
+        return "Часть записей уже выставлена или изменилась, проверьте ещё раз"
+    case errors.Is(err, ErrDraftChanged):
+        return "Записи или ставки изменились после предпросмотра, проверьте счёт ещё раз"
+    }
+    return "Ошибка создания счёта"
+}
+
+// issues the previewed draft; when the worklogs changed since, the new preview is shown instead
+func CreateInvoiceHandler(c *gin.Context) {
+    clientID, from, to, err := parseInvoiceForm(c.PostForm)
+    fingerprint := c.PostForm("fingerprint")
+    if err == nil && fingerprint == "" {
+        err = ErrDraftChanged
+    }
+    var inv *Invoice
+    if err == nil {
+        inv, err = IssueInvoice(GetCurrentUserID(c), clientID, from, to, fingerprint)
+    }
+    if err != nil {
+        data := gin.H{"error": invoiceErrorText(err)}
+        if errors.Is(err, ErrDraftChanged) || errors.Is(err, ErrAlreadyInvoiced) {
+            if draft, err := DraftInvoice(GetCurrentUserID(c), clientID, from, to); err == nil {
+                data["draft"] = draft
+            }
+        }
+        renderInvoicesPage(c, data)
+        return
+    }
+    c.Redirect(http.StatusFound, "/invoices/"+strconv.Itoa(inv.ID))
+}
+
+// invoice of the current user or 404 (also for foreign ids)
+func currentInvoice(c *gin.Context) (*Invoice, bool) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err == nil {
+        inv, err := invoiceStore.Get(GetCurrentUserID(c), id)
+        if err == nil {
+            return inv, true
+        }
+        if err != ErrInvoiceNotFound {
+            c.String(http.StatusInternalServerError, "Ошибка загрузки счёта")
+            return nil, false
+        }
+    }
+    c.String(http.StatusNotFound, "Счёт не найден")
+    return nil, false
+}
+
+func InvoicePage(c *gin.Context) {
+    inv, ok := currentInvoice(c)
+    if !ok {
+        return
+    }
+    c.HTML(http.StatusOK, "invoice.html", gin.H{"invoice": inv})
+}
+
+func InvoicePDFHandler(c *gin.Context) {
+    inv, ok := currentInvoice(c)
+    if !ok {
+        return
+    }
+    c.Header("Content-Type", "application/pdf")
+    c.Header("Content-Disposition", "attachment; filename=invoice_"+inv.Number+".pdf")
+    if err := WriteInvoicePDF(c.Writer, inv); err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка создания файла")
+    }
+}
+
+func InvoiceXLSXHandler(c *gin.Context) {
+    inv, ok := currentInvoice(c)
+    if !ok {
+        return
+    }
+    c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+    c.Header("Content-Disposition", "attachment; filename=invoice_"+inv.Number+".xlsx")
+    if err := WriteInvoiceXLSX(c.Writer, inv); err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка создания файла")
+    }
+}
+
+// cancel: worklogs become editable again, the invoice stays with its number
+func CancelInvoiceHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := invoiceStore.Cancel(GetCurrentUserID(c), id)
+    if err == ErrInvoiceNotFound {
+        c.String(http.StatusNotFound, "Счёт не найден")
+        return
+    }
+    if err == ErrInvoiceCancelled {
+        c.String(http.StatusConflict, "Счёт уже аннулирован")
+        return
+    }
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка аннулирования")
+        return
+    }
+    c.Redirect(http.StatusFound, "/invoices/"+strconv.Itoa(id))
+}
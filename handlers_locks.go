@@ -0,0 +1,83 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func lockErrorText(err error) string {
+    switch err {
+    case ErrInvalidLockDate:
+        return "Неверная дата"
+    case ErrUserNotFound:
+        return "Пользователь не найден"
+    case ErrPeriodLockNotFound:
+        return "Блокировка не найдена"
+    }
+    return "Ошибка сохранения"
+}
+
+// global lock date and the lock dates per user
+func AdminLocksPage(c *gin.Context) {
+    renderAdminLocksPage(c, gin.H{})
+}
+
+func renderAdminLocksPage(c *gin.Context, data gin.H) {
+    locks, err := lockStore.List()
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки блокировок")
+        return
+    }
+    users, err := userStore.List()
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки пользователей")
+        return
+    }
+
+    var global *PeriodLock
+    var perUser []PeriodLock
+    for i := range locks {
+        if locks[i].UserID == 0 {
+            global = &locks[i]
+        } else {
+            perUser = append(perUser, locks[i])
+        }
+    }
+    data["global"] = global
+    data["locks"] = perUser
+    data["users"] = users
+    c.HTML(http.StatusOK, "admin_locks.html", data)
+}
+
+// user_id empty or 0 = the global lock
+func SetLockHandler(c *gin.Context) {
+    actor, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    userID, _ := strconv.Atoi(c.PostForm("user_id"))
+    if err := SetPeriodLock(actor, userID, c.PostForm("lock_date")); err != nil {
+        renderAdminLocksPage(c, gin.H{"error": lockErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, "/admin/locks")
+}
+
+func DeleteLockHandler(c *gin.Context) {
+    actor, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    userID, err := strconv.Atoi(c.Param("user_id"))
+    if err != nil {
+        renderAdminLocksPage(c, gin.H{"error": lockErrorText(ErrPeriodLockNotFound)})
+        return
+    }
+    if err := ClearPeriodLock(actor, userID); err != nil {
+        renderAdminLocksPage(c, gin.H{"error": lockErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, "/admin/locks")
+}
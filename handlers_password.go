@@ -0,0 +1,135 @@
+package main
+
+import (
+    "log"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// ========== account ==========
+
+func passwordErrorText(err error) string {
+    if _, ok := err.(*LoginThrottledError); ok {
+        _, text := loginRefused(err)
+        return text
+    }
+    switch err {
+    case ErrWrongPassword:
+        return "Неверный текущий пароль"
+    case ErrPasswordTooShort:
+        return "Пароль должен быть не короче 6 символов"
+    case ErrInvalidEmail:
+        return "Некорректный email"
+    case ErrEmailTaken:
+        return "Этот email уже используется"
+    case ErrInvalidResetToken, ErrInvalidEmailToken:
+        return "Ссылка недействительна или устарела"
+    }
+    return "Ошибка сохранения"
+}
+
+func AccountPage(c *gin.Context) {
+    renderAccountPage(c, gin.H{})
+}
+
+func renderAccountPage(c *gin.Context, data gin.H) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    data["username"] = user.Username
+    data["email"] = user.Email
+    c.HTML(http.StatusOK, "account.html", data)
+}
+
+func UpdateEmailHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    attempt := LoginAttempt{IP: c.ClientIP(), Channel: ChannelWeb}
+    pending, err := ChangeEmail(user, c.PostForm("current_password"), c.PostForm("email"), attempt)
+    if err != nil {
+        renderAccountPage(c, gin.H{"error": passwordErrorText(err)})
+        return
+    }
+    if pending {
+        renderAccountPage(c, gin.H{"success": "На новый адрес отправлена ссылка, email сменится после перехода по ней"})
+        return
+    }
+    renderAccountPage(c, gin.H{"success": "Email сохранён"})
+}
+
+// link from the confirmation mail, works without a session
+func ConfirmEmailPage(c *gin.Context) {
+    if err := ConfirmEmail(c.Query("token")); err != nil {
+        c.HTML(http.StatusOK, "email_confirm.html", gin.H{"error": passwordErrorText(err)})
+        return
+    }
+    c.HTML(http.StatusOK, "email_confirm.html", gin.H{})
+}
+
+func ChangePasswordHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    if c.PostForm("password") != c.PostForm("password_confirm") {
+        renderAccountPage(c, gin.H{"error": "Пароли не совпадают"})
+        return
+    }
+
+    attempt := LoginAttempt{IP: c.ClientIP(), Channel: ChannelWeb}
+    user, err := ChangePassword(user, c.PostForm("current_password"), c.PostForm("password"), attempt)
+    if err != nil {
+        renderAccountPage(c, gin.H{"error": passwordErrorText(err)})
+        return
+    }
+
+    // every session with the old auth version is over, this one goes on with the new
+    StartSession(c, user)
+    renderAccountPage(c, gin.H{"success": "Пароль изменён, остальные сессии и токены отозваны"})
+}
+
+// ========== reset by email ==========
+
+func ForgotPasswordPage(c *gin.Context) {
+    c.HTML(http.StatusOK, "password_forgot.html", gin.H{})
+}
+
+func ForgotPasswordHandler(c *gin.Context) {
+    // the same answer whether the account exists or not
+    if err := RequestPasswordReset(c.PostForm("login")); err != nil {
+        log.Println("password reset:", err)
+    }
+    c.HTML(http.StatusOK, "password_forgot.html", gin.H{"sent": true})
+}
+
+func ResetPasswordPage(c *gin.Context) {
+    token := c.Query("token")
+    if err := CheckResetToken(token); err != nil {
+        c.HTML(http.StatusOK, "password_reset.html", gin.H{"error": passwordErrorText(err)})
+        return
+    }
+    c.HTML(http.StatusOK, "password_reset.html", gin.H{"token": token})
+}
+
+func ResetPasswordHandler(c *gin.Context) {
+    token := c.PostForm("token")
+    if c.PostForm("password") != c.PostForm("password_confirm") {
+        c.HTML(http.StatusOK, "password_reset.html", gin.H{"error": "Пароли не совпадают", "token": token})
+        return
+    }
+
+    err := ResetPassword(token, c.PostForm("password"))
+    if err == ErrPasswordTooShort {
+        c.HTML(http.StatusOK, "password_reset.html", gin.H{"error": passwordErrorText(err), "token": token})
+        return
+    }
+    if err != nil {
+        c.HTML(http.StatusOK, "password_reset.html", gin.H{"error": passwordErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, "/login?reset=1")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "errors"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+// projects, clients and hourly rates on one page
+func ProjectsPage(c *gin.Context) {
+    renderProjectsPage(c, gin.H{})
+}
+
+func renderProjectsPage(c *gin.Context, data gin.H) {
+    userID := GetCurrentUserID(c)
+
+    projects, err := projectStore.List(userID)
+    if err != nil {
+        data["error"] = "errors loads projects"
+    }
+    clients, err := clientStore.List(userID)
+    if err != nil {
+        data["error"] = "errors loads clients"
+    }
+
+    rates, err := rateStore.List(userID)
+    if err != nil {
+        data["error"] = "errors loads rates"
+    }
+
+    data["projects"] = projects
+    data["clients"] = clients
+    data["rates"] = rates
+    data["currency"] = config.Currency
+    c.HTML(http.StatusOK, "projects.html", data)
+}
+
+// name + client_id from form
+func parseProjectForm(c *gin.Context) (*Project, error) {
+    p := &Project{
+        UserID: GetCurrentUserID(c),
+        Name:   strings.TrimSpace(c.PostForm("name")),
+    }
+    if p.Name == "" {
+        return nil, errors.New("укажите название проекта")
+    }
+
+    if v := c.PostForm("client_id"); v != "" {
+        id, err := strconv.Atoi(v)
+        if err != nil {
+            return nil, errors.New("неверный клиент")
+        }
+        p.ClientID = id
+    }
+    if err := ValidateProjectClient(p); err != nil {
+        return nil, errors.New("клиент не найден")
+    }
+    return p, nil
+}
+
+func CreateProjectHandler(c *gin.Context) {
+    p, err := parseProjectForm(c)
+    if err != nil {
+        renderProjectsPage(c, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := projectStore.Create(p); err != nil {
+        renderProjectsPage(c, gin.H{"error": "Проект с таким названием уже существует"})
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func UpdateProjectHandler(c *gin.Context) {
+    p, err := parseProjectForm(c)
+    if err != nil {
+        renderProjectsPage(c, gin.H{"error": err.Error()})
+        return
+    }
+
+    p.ID, _ = strconv.Atoi(c.Param("id"))
+    err = projectStore.Update(p)
+    if err == ErrProjectNotFound {
+        c.String(http.StatusNotFound, "Проект не найден")
+        return
+    }
+    if err != nil {
+        renderProjectsPage(c, gin.H{"error": "Ошибка обновления проекта"})
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func DeleteProjectHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := projectStore.Delete(GetCurrentUserID(c), id)
+    if err == ErrProjectNotFound {
+        c.String(http.StatusNotFound, "Проект не найден")
+        return
+    }
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка удаления")
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func CreateClientHandler(c *gin.Context) {
+    cl := &Client{
+        UserID: GetCurrentUserID(c),
+        Name:   strings.TrimSpace(c.PostForm("name")),
+    }
+    if cl.Name == "" {
+        renderProjectsPage(c, gin.H{"error": "укажите название клиента"})
+        return
+    }
+
+    if err := clientStore.Create(cl); err != nil {
+        renderProjectsPage(c, gin.H{"error": "Клиент с таким названием уже существует"})
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func UpdateClientHandler(c *gin.Context) {
+    cl := &Client{
+        UserID: GetCurrentUserID(c),
+        Name:   strings.TrimSpace(c.PostForm("name")),
+    }
+    cl.ID, _ = strconv.Atoi(c.Param("id"))
+    if cl.Name == "" {
+        renderProjectsPage(c, gin.H{"error": "укажите название клиента"})
+        return
+    }
+
+    err := clientStore.Update(cl)
+    if err == ErrClientNotFound {
+        c.String(http.StatusNotFound, "Клиент не найден")
+        return
+    }
+    if err != nil {
+        renderProjectsPage(c, gin.H{"error": "Ошибка обновления клиента"})
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func DeleteClientHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := clientStore.Delete(GetCurrentUserID(c), id)
+    if err == ErrClientNotFound {
+        c.String(http.StatusNotFound, "Клиент не найден")
+        return
+    }
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка удаления")
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
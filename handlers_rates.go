@@ -0,0 +1,76 @@
+package main
+
+import (
+    "errors"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// rate form: scope ("" = default, "p:<id>" project, "c:<id>" client), rate, effective_from
+func parseRateForm(c *gin.Context) (*HourlyRate, error) {
+    r := &HourlyRate{UserID: GetCurrentUserID(c)}
+
+    rate, err := strconv.ParseFloat(strings.Replace(c.PostForm("rate"), ",", ".", 1), 64)
+    if err != nil || rate < 0 {
+        return nil, errors.New("неверная ставка")
+    }
+    r.Rate = rate
+
+    if v := c.PostForm("effective_from"); v != "" {
+        if r.EffectiveFrom, err = time.Parse("2006-01-02", v); err != nil {
+            return nil, errors.New("неверная дата")
+        }
+    }
+
+    if scope := c.PostForm("scope"); scope != "" {
+        kind, idStr, _ := strings.Cut(scope, ":")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+            return nil, errors.New("неверная область ставки")
+        }
+        switch kind {
+        case "p":
+            r.ProjectID = id
+        case "c":
+            r.ClientID = id
+        default:
+            return nil, errors.New("неверная область ставки")
+        }
+    }
+    if err := ValidateRateScope(r); err != nil {
+        return nil, errors.New("проект или клиент не найден")
+    }
+    return r, nil
+}
+
+func CreateRateHandler(c *gin.Context) {
+    r, err := parseRateForm(c)
+    if err != nil {
+        renderProjectsPage(c, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := rateStore.Create(r); err != nil {
+        renderProjectsPage(c, gin.H{"error": "Ошибка сохранения ставки"})
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
+
+func DeleteRateHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+
+    err := rateStore.Delete(GetCurrentUserID(c), id)
+    if err == ErrRateNotFound {
+        c.String(http.StatusNotFound, "Ставка не найдена")
+        return
+    }
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка удаления")
+        return
+    }
+    c.Redirect(http.StatusFound, "/projects")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+func teamErrorText(err error) string {
+    switch err {
+    case ErrInvalidTeamName:
+        return "Название команды: от 1 до 100 символов"
+    case ErrInvalidTeamRole:
+        return "Неизвестная роль в команде"
+    case ErrTeamManagerRole:
+        return "Руководителем команды может быть только пользователь с ролью manager или admin"
+    case ErrUserNotFound:
+        return "Пользователь не найден"
+    case ErrTeamNotFound:
+        return "Команда не найдена"
+    case ErrTeamMemberNotFound:
+        return "Пользователь не состоит в команде"
+    }
+    return "Ошибка сохранения"
+}
+
+func TeamsPage(c *gin.Context) {
+    renderTeamsPage(c, gin.H{})
+}
+
+func renderTeamsPage(c *gin.Context, data gin.H) {
+    teams, err := VisibleTeams(CurrentUserID(c), CurrentRole(c))
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки команд")
+        return
+    }
+    data["teams"] = teams
+    data["canManage"] = HasPermission(CurrentRole(c), PermManageTeams)
+    c.HTML(http.StatusOK, "teams.html", data)
+}
+
+func CreateTeamHandler(c *gin.Context) {
+    team, err := CreateTeam(c.PostForm("name"))
+    if err == ErrInvalidTeamName {
+        renderTeamsPage(c, gin.H{"error": teamErrorText(err)})
+        return
+    }
+    if err != nil {
+        renderTeamsPage(c, gin.H{"error": "Команда с таким названием уже существует"})
+        return
+    }
+    c.Redirect(http.StatusFound, fmt.Sprintf("/teams/%d", team.ID))
+}
+
+func DeleteTeamHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    if err := teamStore.Delete(id); err != nil {
+        renderTeamsPage(c, gin.H{"error": teamErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, "/teams")
+}
+
+// members with their hours; without dates the current month
+func TeamPage(c *gin.Context) {
+    renderTeamPage(c, gin.H{})
+}
+
+func renderTeamPage(c *gin.Context, data gin.H) {
+    team := CurrentTeam(c)
+
+    filter := WorkLogFilter{DateFrom: c.Query("date_from"), DateTo: c.Query("date_to")}
+    if filter.DateFrom == "" && filter.DateTo == "" {
+        now := time.Now()
+        filter.DateFrom = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+        filter.DateTo = now.Format("2006-01-02")
+    }
+    for _, d := range []string{filter.DateFrom, filter.DateTo} {
+        if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+            data["error"] = "Неверная дата"
+            filter = WorkLogFilter{}
+            break
+        }
+    }
+
+    members, err := teamStore.Members(team.ID)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки команды")
+        return
+    }
+    stats, err := TeamStatsFor(team.ID, filter)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки команды")
+        return
+    }
+    memberStats := make(map[int]MemberStats, len(stats.Members))
+    for _, ms := range stats.Members {
+        memberStats[ms.UserID] = ms
+    }
+
+    data["team"] = team
+    data["members"] = members
+    data["memberStats"] = memberStats
+    data["stats"] = stats
+    data["dateFrom"] = filter.DateFrom
+    data["dateTo"] = filter.DateTo
+    data["currency"] = config.Currency
+    data["teamRoles"] = allTeamRoles
+    data["canManage"] = HasPermission(CurrentRole(c), PermManageTeams)
+    c.HTML(http.StatusOK, "team.html", data)
+}
+
+func SetTeamMemberHandler(c *gin.Context) {
+    if _, err := SetTeamMember(CurrentTeam(c).ID, c.PostForm("username"), c.PostForm("role")); err != nil {
+        renderTeamPage(c, gin.H{"error": teamErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, fmt.Sprintf("/teams/%d", CurrentTeam(c).ID))
+}
+
+func RemoveTeamMemberHandler(c *gin.Context) {
+    userID, _ := strconv.Atoi(c.Param("user_id"))
+    if err := teamStore.RemoveMember(CurrentTeam(c).ID, userID); err != nil {
+        renderTeamPage(c, gin.H{"error": teamErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, fmt.Sprintf("/teams/%d", CurrentTeam(c).ID))
+}
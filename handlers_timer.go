@@ -0,0 +1,86 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "net/url"
+    "strconv"
+)
+
+// dashboard "start" button
+func StartTimerHandler(c *gin.Context) {
+    projectID, _ := strconv.Atoi(c.PostForm("project_id"))
+
+    _, err := StartTimer(GetCurrentUserID(c), projectID, c.PostForm("description"))
+    switch err {
+    case nil:
+    case ErrTimerRunning:
+        redirectDashboardError(c, "Таймер уже запущен")
+        return
+    case ErrProjectNotFound:
+        redirectDashboardError(c, "Проект не найден")
+        return
+    default:
+        redirectDashboardError(c, "Ошибка запуска таймера")
+        return
+    }
+
+    c.Redirect(http.StatusFound, "/dashboard")
+}
+
+// dashboard "stop" button, the new entry shows up in the list;
+// back to the dashboard when the entry got fewer hours or none at all
+func StopTimerHandler(c *gin.Context) {
+    stopped, err := StopTimer(GetCurrentUserID(c), webAudit(c))
+    switch {
+    case err == nil:
+    case err == ErrTimerNotRunning:
+        redirectDashboardError(c, "Таймер не запущен")
+        return
+    default:
+        redirectDashboardError(c, "Ошибка остановки таймера")
+        return
+    }
+
+    switch {
+    case stopped.Log == nil:
+        redirectDashboardError(c, "Таймер остановлен, запись не создана: "+timerProblemText(stopped.Problem))
+    case stopped.Problem != nil:
+        c.Redirect(http.StatusFound, "/dashboard?notice="+url.QueryEscape(fmt.Sprintf(
+            "Таймер остановлен, записано %s ч из %s: %s", formatTimerHours(stopped.Log.Hours),
+            formatTimerHours(stopped.Hours), timerProblemText(stopped.Problem))))
+    default:
+        c.Redirect(http.StatusFound, "/worklog/list")
+    }
+}
+
+// dashboard "discard" button, the timer ends without an entry
+func DiscardTimerHandler(c *gin.Context) {
+    switch err := DiscardTimer(GetCurrentUserID(c)); err {
+    case nil:
+    case ErrTimerNotRunning:
+        redirectDashboardError(c, "Таймер не запущен")
+        return
+    default:
+        redirectDashboardError(c, "Ошибка сброса таймера")
+        return
+    }
+    c.Redirect(http.StatusFound, "/dashboard")
+}
+
+func timerProblemText(err error) string {
+    if errors.Is(err, ErrHoursRequired) {
+        return "время таймера округлилось до 0 часов"
+    }
+    return workLogErrorText(err)
+}
+
+func formatTimerHours(h float64) string {
+    return strconv.FormatFloat(h, 'f', -1, 64)
+}
+
+func redirectDashboardError(c *gin.Context, msg string) {
+    c.Redirect(http.StatusFound, "/dashboard?error="+url.QueryEscape(msg))
+}
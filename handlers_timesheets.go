@@ -0,0 +1,106 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// weeks shown on /timesheets even without a submit
+const recentWeeks = 8
+
+func timesheetErrorText(err error) string {
+    switch err {
+    case ErrInvalidWeek:
+        return "Неверная неделя: ожидается ГГГГ-Wнн, неделя должна уже начаться"
+    case ErrInvalidTransition:
+        return "В текущем статусе недели это действие невозможно"
+    case ErrInvalidReview:
+        return "Неизвестное действие"
+    case ErrOwnTimesheet:
+        return "Свою неделю утверждает руководитель или администратор"
+    case ErrCommentRequired:
+        return "Укажите комментарий"
+    case ErrTimesheetChanged:
+        return "Статус недели уже изменился, обновите страницу"
+    }
+    return "Ошибка сохранения"
+}
+
+// own weeks with their status + the weeks waiting for review
+func TimesheetsPage(c *gin.Context) {
+    renderTimesheetsPage(c, gin.H{})
+}
+
+func renderTimesheetsPage(c *gin.Context, data gin.H) {
+    weeks, err := RecentWeeks(CurrentUserID(c), c.GetString("username"), recentWeeks)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недель")
+        return
+    }
+    queue, err := ReviewQueue(CurrentUserID(c), CurrentRole(c))
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недель")
+        return
+    }
+    pending, err := summarizeWeeks(queue)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недель")
+        return
+    }
+
+    data["weeks"] = weeks
+    data["queue"] = pending
+    data["isReviewer"] = HasPermission(CurrentRole(c), PermViewTeams)
+    c.HTML(http.StatusOK, "timesheets.html", data)
+}
+
+func SubmitTimesheetHandler(c *gin.Context) {
+    _, err := SubmitWeek(CurrentUserID(c), c.GetString("username"), c.PostForm("week"), c.PostForm("comment"))
+    if err != nil {
+        renderTimesheetsPage(c, gin.H{"error": timesheetErrorText(err)})
+        return
+    }
+    renderTimesheetsPage(c, gin.H{"success": "✅ Неделя " + c.PostForm("week") + " отправлена на утверждение"})
+}
+
+// worklogs of the week, history and the review form; loaded by TimesheetAccessRequired
+func TimesheetPage(c *gin.Context) {
+    renderTimesheetPage(c, gin.H{})
+}
+
+func renderTimesheetPage(c *gin.Context, data gin.H) {
+    t := CurrentTimesheet(c)
+    week, err := SummarizeWeek(*t)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недели")
+        return
+    }
+    logs, err := worklogStore.List(t.UserID, WeekFilter(t.Year, t.Week))
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недели")
+        return
+    }
+    events, err := sheetStore.Events(t.ID)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки недели")
+        return
+    }
+
+    data["week"] = week
+    data["logs"] = logs
+    data["events"] = events
+    data["canReview"] = t.UserID != CurrentUserID(c)
+    c.HTML(http.StatusOK, "timesheet.html", data)
+}
+
+func ReviewTimesheetHandler(c *gin.Context) {
+    t := CurrentTimesheet(c)
+    err := ReviewTimesheet(t, c.PostForm("action"), CurrentUserID(c), c.GetString("username"), c.PostForm("comment"))
+    if err != nil {
+        renderTimesheetPage(c, gin.H{"error": timesheetErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, fmt.Sprintf("/timesheets/%d", t.ID))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+    "github.com/gin-gonic/gin"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// personal access tokens: list, create, revoke
+func TokensPage(c *gin.Context) {
+    renderTokensPage(c, gin.H{})
+}
+
+func renderTokensPage(c *gin.Context, data gin.H) {
+    tokens, err := patStore.List(GetCurrentUserID(c))
+    if err != nil {
+        data["error"] = "Ошибка загрузки токенов"
+    }
+    data["tokens"] = tokens
+    data["scopes"] = allScopes
+    data["now"] = time.Now()
+    c.HTML(http.StatusOK, "tokens.html", data)
+}
+
+func tokenErrorText(err error) string {
+    switch err {
+    case ErrInvalidTokenName:
+        return "Укажите название токена (до 100 символов)"
+    case ErrInvalidTokenExpiry:
+        return "Срок действия: от 1 до 365 дней"
+    case ErrNoScopes:
+        return "Выберите хотя бы одно право"
+    case ErrInvalidScope:
+        return "Неизвестное право"
+    }
+    return "Ошибка создания токена"
+}
+
+// the new token is shown once on the page, later only its prefix
+func CreateTokenHandler(c *gin.Context) {
+    days, _ := strconv.Atoi(c.PostForm("expires_in_days"))
+    scopes, err := ParseScopes(c.PostFormArray("scopes"))
+    var raw string
+    if err == nil {
+        raw, _, err = CreatePersonalToken(GetCurrentUserID(c), c.PostForm("name"), scopes, days)
+    }
+    if err != nil {
+        renderTokensPage(c, gin.H{"error": tokenErrorText(err), "name": c.PostForm("name")})
+        return
+    }
+    renderTokensPage(c, gin.H{"newToken": raw})
+}
+
+func DeleteTokenHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    err := patStore.Delete(GetCurrentUserID(c), id)
+    if err != nil && err != ErrPersonalTokenNotFound {
+        c.String(http.StatusInternalServerError, "Ошибка удаления токена")
+        return
+    }
+    c.Redirect(http.StatusFound, "/tokens")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+func renderTrash(c *gin.Context, status int, errText string) {
+    logs, err := worklogStore.ListTrash(GetCurrentUserID(c))
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки корзины")
+        return
+    }
+    purgeAt := map[int]string{}
+    for i := range logs {
+        if at := TrashPurgeAt(&logs[i]); !at.IsZero() {
+            purgeAt[logs[i].ID] = at.Local().Format("02.01.2006")
+        }
+    }
+    c.HTML(status, "trash.html", gin.H{
+        "logs":        logs,
+        "purgeAt":     purgeAt,
+        "canOverride": HasPermission(CurrentRole(c), PermOverrideLocks),
+        "error":       errText,
+    })
+}
+
+// deleted worklogs until they are restored or purged
+func TrashPage(c *gin.Context) {
+    renderTrash(c, http.StatusOK, "")
+}
+
+func RestoreTrashHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    _, err := RestoreTrashedWorkLog(GetCurrentUserID(c), id, webAudit(c))
+    switch {
+    case err == nil:
+        c.Redirect(http.StatusFound, "/worklog/trash")
+    case errors.Is(err, ErrWorkLogNotFound):
+        WebWorkLogNotFound(c)
+    case isWorkLogRuleError(err):
+        renderTrash(c, http.StatusConflict, "Запись не восстановлена: "+workLogErrorText(err))
+    default:
+        c.String(http.StatusInternalServerError, "Ошибка восстановления")
+    }
+}
+
+// delete for good, only the history keeps the values
+func PurgeTrashHandler(c *gin.Context) {
+    id, _ := strconv.Atoi(c.Param("id"))
+    err := worklogStore.Purge(GetCurrentUserID(c), id, webAudit(c))
+    if err == ErrWorkLogNotFound {
+        WebWorkLogNotFound(c)
+        return
+    }
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка удаления")
+        return
+    }
+    c.Redirect(http.StatusFound, "/worklog/trash")
+}
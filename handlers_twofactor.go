@@ -0,0 +1,181 @@
+package main
+
+import (
+    "encoding/base64"
+    "html/template"
+    "net/http"
+    "time"
+
+    "github.com/gin-contrib/sessions"
+    "github.com/gin-gonic/gin"
+    "github.com/skip2/go-qrcode"
+)
+
+// ========== second login step ==========
+
+// Password was right, the session waits for the code (user_id is not set yet).
+// The session only holds the challenge, its attempts are counted on the server
+// like for the API, so replaying an old session cookie gives no new attempts.
+func startTwoFactorLogin(c *gin.Context, user *User) {
+    challenge, err := NewMFAChallenge(user.ID)
+    if err != nil {
+        c.HTML(http.StatusInternalServerError, "login.html", gin.H{"error": "Ошибка входа, попробуйте позже"})
+        return
+    }
+    session := sessions.Default(c)
+    session.Clear()
+    session.Set("mfa_challenge", challenge)
+    session.Set("mfa_started", time.Now().Unix())
+    session.Save()
+    c.Redirect(http.StatusFound, "/login/2fa")
+}
+
+// session between password and code, the challenge itself is checked on submit
+func hasPendingTwoFactor(c *gin.Context) bool {
+    session := sessions.Default(c)
+    challenge, _ := session.Get("mfa_challenge").(string)
+    started, _ := session.Get("mfa_started").(int64)
+    return challenge != "" && time.Since(time.Unix(started, 0)) <= mfaChallengeTTL
+}
+
+func LoginTwoFactorPage(c *gin.Context) {
+    if !hasPendingTwoFactor(c) {
+        c.Redirect(http.StatusFound, "/login")
+        return
+    }
+    c.HTML(http.StatusOK, "login_2fa.html", gin.H{})
+}
+
+func LoginTwoFactorHandler(c *gin.Context) {
+    session := sessions.Default(c)
+    challenge, _ := session.Get("mfa_challenge").(string)
+    attempt := LoginAttempt{IP: c.ClientIP(), Channel: ChannelWeb}
+
+    user, err := VerifyMFAChallenge(challenge, c.PostForm("code"), attempt)
+    if _, ok := err.(*LoginThrottledError); ok {
+        status, text := loginRefused(err)
+        c.HTML(status, "login_2fa.html", gin.H{"error": text})
+        return
+    }
+    switch err {
+    case nil:
+    case ErrInvalidOTP:
+        c.HTML(http.StatusOK, "login_2fa.html", gin.H{"error": "Неверный код"})
+        return
+    case ErrInvalidChallenge:
+        session.Clear()
+        session.Save()
+        c.HTML(http.StatusOK, "login.html", gin.H{"error": "Время ввода кода истекло, войдите снова"})
+        return
+    case ErrUserDisabled:
+        session.Clear()
+        session.Save()
+        c.HTML(http.StatusForbidden, "login.html", gin.H{"error": "Учётная запись отключена администратором"})
+        return
+    default:
+        c.HTML(http.StatusInternalServerError, "login_2fa.html", gin.H{"error": "Ошибка входа, попробуйте позже"})
+        return
+    }
+
+    StartSession(c, user)
+    c.Redirect(http.StatusFound, "/dashboard")
+}
+
+// ========== settings ==========
+
+func currentUser(c *gin.Context) (*User, bool) {
+    user, err := userStore.GetByID(GetCurrentUserID(c))
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Ошибка загрузки пользователя")
+        return nil, false
+    }
+    return user, true
+}
+
+func twoFactorErrorText(err error) string {
+    switch err {
+    case ErrInvalidOTP:
+        return "Неверный или уже использованный код"
+    case ErrTwoFactorEnabled:
+        return "Двухфакторная аутентификация уже включена"
+    case ErrTwoFactorNotEnabled:
+        return "Двухфакторная аутентификация не включена"
+    case ErrTwoFactorNotSetUp:
+        return "Сначала начните настройку"
+    }
+    return "Ошибка двухфакторной аутентификации"
+}
+
+func TwoFactorPage(c *gin.Context) {
+    renderTwoFactorPage(c, gin.H{})
+}
+
+func renderTwoFactorPage(c *gin.Context, data gin.H) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    data["enabled"] = user.TOTPEnabled
+    if user.TOTPEnabled {
+        data["codesLeft"], _ = mfaStore.CountRecoveryCodes(user.ID)
+    } else if data["setup"] == true && user.TOTPSecret != "" {
+        uri := TOTPProvisioningURI(user.Username, user.TOTPSecret)
+        data["secret"] = user.TOTPSecret
+        data["uri"] = template.URL(uri) // otpauth: is not a scheme html/template trusts
+        if png, err := qrcode.Encode(uri, qrcode.Medium, 220); err == nil {
+            data["qr"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+        }
+    }
+    c.HTML(http.StatusOK, "twofactor.html", data)
+}
+
+// new secret + QR code, 2FA is on only after the code is confirmed
+func SetupTwoFactorHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    if _, err := StartTwoFactorSetup(user); err != nil {
+        renderTwoFactorPage(c, gin.H{"error": twoFactorErrorText(err)})
+        return
+    }
+    renderTwoFactorPage(c, gin.H{"setup": true})
+}
+
+func EnableTwoFactorHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    codes, err := ConfirmTwoFactor(user, c.PostForm("code"))
+    if err != nil {
+        renderTwoFactorPage(c, gin.H{"error": twoFactorErrorText(err), "setup": true})
+        return
+    }
+    renderTwoFactorPage(c, gin.H{"recoveryCodes": codes})
+}
+
+func RecoveryCodesHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    codes, err := RegenerateRecoveryCodes(user, c.PostForm("code"))
+    if err != nil {
+        renderTwoFactorPage(c, gin.H{"error": twoFactorErrorText(err)})
+        return
+    }
+    renderTwoFactorPage(c, gin.H{"recoveryCodes": codes})
+}
+
+func DisableTwoFactorHandler(c *gin.Context) {
+    user, ok := currentUser(c)
+    if !ok {
+        return
+    }
+    if err := DisableTwoFactor(user, c.PostForm("code")); err != nil {
+        renderTwoFactorPage(c, gin.H{"error": twoFactorErrorText(err)})
+        return
+    }
+    c.Redirect(http.StatusFound, "/2fa")
+}
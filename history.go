@@ -0,0 +1,119 @@
+package main
+
+import (
+    "errors"
+
+    "github.com/gin-gonic/gin"
+)
+
+// actions in worklog_history
+const (
+    HistoryCreate  = "create"
+    HistoryUpdate  = "update"
+    HistoryDelete  = "delete"  // moved into the trash
+    HistoryRestore = "restore" // back from the trash or a former version, restored_from says which
+    HistoryPurge   = "purge"   // removed from the trash for good
+)
+
+// worklog_history.channel: ChannelWeb / ChannelAPI like failed_logins, or one of these
+const (
+    ChannelSystem = "system" // no request behind it (trash purge)
+    ChannelCLI    = "cli"    // "import" subcommand
+)
+
+// entries on /worklog/history and GET /worklogs/history
+const recentHistory = 100
+
+var (
+    ErrNothingToRestore = errors.New("history entry has no version to restore")
+    ErrWorkLogPurged    = errors.New("worklog was deleted for good, its versions can not be restored")
+)
+
+// who changes a worklog and from where, stored with the change
+type Audit struct {
+    ActorID   int
+    ActorName string
+    IP        string
+    Channel   string
+    RestoreOf int // history id when a former version is restored
+    // an admin asked to write into a closed period (see lockOverride); the store
+    // marks the changes that needed it in worklog_history
+    OverrideLock bool
+}
+
+func webAudit(c *gin.Context) *Audit {
+    return &Audit{ActorID: CurrentUserID(c), ActorName: c.GetString("username"), IP: c.ClientIP(), Channel: ChannelWeb,
+        OverrideLock: webLockOverride(c)}
+}
+
+func apiAudit(c *gin.Context) *Audit {
+    return &Audit{ActorID: CurrentUserID(c), ActorName: c.GetString("username"), IP: c.ClientIP(), Channel: ChannelAPI,
+        OverrideLock: apiLockOverride(c)}
+}
+
+// the version a history entry leads to: after create/update/restore the new values,
+// after delete the values the worklog had
+func (ch *WorkLogChange) Version() *WorkLog {
+    if ch.New != nil {
+        return ch.New
+    }
+    return ch.Old
+}
+
+// worklogs of the changes that were purged, their versions can not be restored;
+// a purge is the last change of a worklog, so it is in any list that has older ones
+func purgedWorkLogs(changes []WorkLogChange) map[int]bool {
+    purged := map[int]bool{}
+    for _, ch := range changes {
+        if ch.Action == HistoryPurge {
+            purged[ch.WorkLogID] = true
+        }
+    }
+    return purged
+}
+
+// Bring back the version of a history entry: a worklog in the trash comes out of it,
+// an existing one is set to those values; a purged one stays gone (ErrWorkLogPurged).
+// The same rules as for an edit apply (invoice, approved week, closed period, overlaps).
+func RestoreWorkLogVersion(userID, historyID int, a *Audit) (*WorkLog, error) {
+    ch, err := historyStore.Get(userID, historyID)
+    if err != nil {
+        return nil, err
+    }
+    version := ch.Version()
+    if version == nil {
+        return nil, ErrNothingToRestore
+    }
+
+    restored := *version
+    restored.ID = ch.WorkLogID
+    restored.UserID = userID
+    restored.InvoiceID = 0
+    a.RestoreOf = ch.ID
+
+    current, err := worklogStore.Get(userID, ch.WorkLogID)
+    switch err {
+    case nil:
+        if err := CheckWorkLogEditable(current, a.OverrideLock); err != nil {
+            return nil, err
+        }
+        if err := PrepareWorkLog(&restored, a.OverrideLock); err != nil {
+            return nil, err
+        }
+        err = worklogStore.Update(&restored, a)
+    case ErrWorkLogNotFound:
+        if _, err := worklogStore.GetTrashed(userID, ch.WorkLogID); err == ErrWorkLogNotFound {
+            return nil, ErrWorkLogPurged
+        } else if err != nil {
+            return nil, err
+        }
+        if err := PrepareWorkLog(&restored, a.OverrideLock); err != nil {
+            return nil, err
+        }
+        err = worklogStore.Restore(&restored, a)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &restored, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+)
+
+// every change lands in the history; the version before a delete comes back under the old id
+func TestRestoreDeletedVersion(t *testing.T) {
+    router := setupTestServer(t)
+    createTestUser(t, "bob")
+    alice := createTestUser(t, "alice")
+    user := loginAPI(t, router, "alice")
+    other := loginAPI(t, router, "bob")
+
+    date := time.Now().Format("2006-01-02")
+    log := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: time.Now(), Description: "first", Hours: 1})
+    path := fmt.Sprintf("/api/v1/worklogs/%d", log.ID)
+    body := map[string]interface{}{"date": date, "hours": 2, "description": "second"}
+    if w := user.sendJSON(http.MethodPut, path, body); w.Code != http.StatusOK {
+        t.Fatalf("update: %d %s", w.Code, w.Body.String())
+    }
+    if w := user.sendJSON(http.MethodDelete, path, nil); w.Code != http.StatusOK {
+        t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+    }
+
+    versions := func() []interface{} {
+        t.Helper()
+        w := user.get(path + "/history")
+        if w.Code != http.StatusOK {
+            t.Fatalf("history: %d %s", w.Code, w.Body.String())
+        }
+        return decodeTestJSON(t, w)["history"].([]interface{})
+    }
+    history := versions()
+    if len(history) != 3 {
+        t.Fatalf("history after create, update, delete: %v", history)
+    }
+    deleted := history[2].(map[string]interface{})
+    if deleted["action"] != HistoryDelete || deleted["old"].(map[string]interface{})["description"] != "second" {
+        t.Fatalf("delete entry: %v", deleted)
+    }
+    if w := other.get(path + "/history"); w.Code != http.StatusNotFound {
+        t.Fatalf("history of another user: %d %s", w.Code, w.Body.String())
+    }
+
+    restorePath := fmt.Sprintf("/api/v1/worklogs/history/%v/restore", deleted["id"])
+    if w := other.sendJSON(http.MethodPost, restorePath, nil); w.Code != http.StatusNotFound {
+        t.Fatalf("restore by another user: %d %s", w.Code, w.Body.String())
+    }
+    w := user.sendJSON(http.MethodPost, restorePath, nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("restore: %d %s", w.Code, w.Body.String())
+    }
+    if got, err := worklogStore.Get(alice.ID, log.ID); err != nil || got.Description != "second" || got.Hours != 2 {
+        t.Fatalf("restored worklog: %+v %v", got, err)
+    }
+
+    history = versions()
+    restored := history[len(history)-1].(map[string]interface{})
+    if len(history) != 4 || restored["action"] != HistoryRestore || restored["restored_from"] != deleted["id"] {
+        t.Fatalf("restore entry: %v", history)
+    }
+}
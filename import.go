@@ -0,0 +1,584 @@
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/xuri/excelize/v2"
+)
+
+// whole file, a spreadsheet of hours stays far below
+const maxImportSize = 2 << 20
+
+const maxImportRows = 5000
+
+var (
+    ErrImportFormat   = errors.New("unsupported file, expected .csv or .xlsx")
+    ErrImportTooLarge = fmt.Errorf("file is larger than %d MB or has more than %d rows", maxImportSize>>20, maxImportRows)
+    ErrImportHeader   = errors.New("header row needs date, description and hours (or start/end) columns")
+    ErrImportEmpty    = errors.New("file has no rows to import")
+    // a parallel write broke a rule for a checked row, nothing was imported
+    ErrImportChanged = errors.New("worklogs changed during the import, check the file again")
+
+    // per row
+    ErrImportDate        = errors.New("date must be DD.MM.YYYY or YYYY-MM-DD")
+    ErrImportHours       = errors.New("hours must be a number")
+    ErrImportBillable    = errors.New("billable must be yes/no (да/нет)")
+    ErrImportDescription = errors.New("description is empty")
+)
+
+// header names, Russian as written by /worklog/export, English as in the API;
+// other columns (rate, amount) are ignored
+var importColumns = map[string]string{
+    "дата":          "date",
+    "date":          "date",
+    "описание":      "description",
+    "description":   "description",
+    "часы":          "hours",
+    "hours":         "hours",
+    "оплачиваемо":   "billable",
+    "billable":      "billable",
+    "проект":        "project",
+    "project":       "project",
+    "теги":          "tags",
+    "tags":          "tags",
+    "начало":        "start_time",
+    "start_time":    "start_time",
+    "окончание":     "end_time",
+    "end_time":      "end_time",
+    "перерыв, мин":  "break_minutes",
+    "break_minutes": "break_minutes",
+}
+
+// totals the export writes below the entries
+var importSummaryRows = map[string]bool{
+    "итого:":               true,
+    "оплачиваемые часы:":   true,
+    "неоплачиваемые часы:": true,
+}
+
+var importDateLayouts = []string{"02.01.2006", "2.1.2006", "2006-01-02"}
+
+// one line of the file
+type importRecord struct {
+    Line  int
+    Cells []string
+}
+
+// one entry of the file after the checks
+type ImportRow struct {
+    Line      int // as in the spreadsheet, header = 1
+    Log       WorkLog
+    Err       error // why it is not imported
+    Duplicate bool  // same date, description and hours as an existing entry or an earlier row
+}
+
+// dry run or done import
+type ImportResult struct {
+    Rows       []ImportRow
+    Valid      int // rows that are imported (or would be)
+    Invalid    int
+    Duplicates int
+    Hours      float64 // of the valid rows
+    Imported   bool    // false for a dry run
+}
+
+type ImportOptions struct {
+    DryRun            bool
+    IncludeDuplicates bool
+}
+
+// Check every row of a CSV or XLSX file like a new entry and, unless it is a
+// dry run, create the valid ones in one transaction. Rows with errors (and
+// duplicates, unless included) are skipped; nothing is created if none is valid.
+// a.OverrideLock lets an admin import into a closed period.
+func ImportWorkLogs(userID int, filename string, data []byte, opts ImportOptions, a *Audit) (*ImportResult, error) {
+    records, err := readImportRecords(filename, data)
+    if err != nil {
+        return nil, err
+    }
+    rows, err := parseImportRecords(userID, records)
+    if err != nil {
+        return nil, err
+    }
+    return importRows(userID, rows, opts, a, nil)
+}
+
+// checks the parsed rows and creates the valid ones; newProjects returns the projects
+// they need, created in the same transaction (not on a dry run)
+func importRows(userID int, rows []ImportRow, opts ImportOptions, a *Audit, newProjects func([]*ImportRow) []Project) (*ImportResult, error) {
+    if len(rows) == 0 {
+        return nil, ErrImportEmpty
+    }
+    if len(rows) > maxImportRows {
+        return nil, ErrImportTooLarge
+    }
+
+    result := &ImportResult{Rows: rows}
+    if err := checkImportRows(userID, result, opts, a.OverrideLock); err != nil {
+        return nil, err
+    }
+    if opts.DryRun || result.Valid == 0 {
+        return result, nil
+    }
+
+    var valid []*ImportRow
+    for r := range result.Rows {
+        if importable(result.Rows[r], opts) {
+            valid = append(valid, &result.Rows[r])
+        }
+    }
+    var projects []Project
+    if newProjects != nil {
+        projects = newProjects(valid)
+    }
+    logs := make([]WorkLog, 0, len(valid))
+    for _, row := range valid {
+        logs = append(logs, row.Log)
+    }
+    if err := worklogStore.CreateMany(logs, projects, a); err != nil {
+        if isWorkLogRuleError(err) {
+            return nil, fmt.Errorf("%w: %v", ErrImportChanged, err)
+        }
+        return nil, err
+    }
+
+    // ids for the result
+    for i, row := range valid {
+        row.Log.ID, row.Log.ProjectID = logs[i].ID, logs[i].ProjectID
+    }
+    result.Imported = true
+    return result, nil
+}
+
+func importable(row ImportRow, opts ImportOptions) bool {
+    return row.Err == nil && (!row.Duplicate || opts.IncludeDuplicates)
+}
+
+// the rules of a new entry per row, plus overlaps and 24h/day against the rows before it
+func checkImportRows(userID int, result *ImportResult, opts ImportOptions, overrideLock bool) error {
+    existing := make(map[string][]WorkLog) // per day, loaded once
+    accepted := make(map[string][]WorkLog) // valid rows of the file per day
+
+    for i := range result.Rows {
+        row := &result.Rows[i]
+        if row.Err != nil {
+            result.Invalid++
+            continue
+        }
+        day := row.Log.Date.Format("2006-01-02")
+        others, ok := existing[day]
+        if !ok {
+            var err error
+            if others, err = worklogStore.List(userID, WorkLogFilter{DateFrom: day, DateTo: day}); err != nil {
+                return err
+            }
+            existing[day] = others
+        }
+        if err := PrepareWorkLog(&row.Log, overrideLock); err != nil {
+            if !isWorkLogRuleError(err) {
+                return err
+            }
+            // a second import of a file with times overlaps itself; that is a duplicate, not an error
+            if !opts.IncludeDuplicates && hasSameEntry(row.Log, others) {
+                row.Duplicate = true
+                result.Duplicates++
+                continue
+            }
+            row.Err = err
+            result.Invalid++
+            continue
+        }
+
+        row.Duplicate = hasSameEntry(row.Log, others) || hasSameEntry(row.Log, accepted[day])
+        if row.Duplicate {
+            result.Duplicates++
+            if !opts.IncludeDuplicates {
+                continue
+            }
+        }
+
+        // PrepareWorkLog saw the database only
+        if err := checkDay(&row.Log, append(append([]WorkLog{}, others...), accepted[day]...)); err != nil {
+            row.Err = err
+            result.Invalid++
+            continue
+        }
+        accepted[day] = append(accepted[day], row.Log)
+        result.Valid++
+        result.Hours += row.Log.Hours
+    }
+    return nil
+}
+
+func hasSameEntry(log WorkLog, logs []WorkLog) bool {
+    for _, other := range logs {
+        if strings.EqualFold(strings.TrimSpace(other.Description), strings.TrimSpace(log.Description)) &&
+            other.Hours == log.Hours && other.Date.Format("2006-01-02") == log.Date.Format("2006-01-02") {
+            return true
+        }
+    }
+    return false
+}
+
+// rows of the first sheet (xlsx) or of the csv, format by extension or content
+func readImportRecords(filename string, data []byte) ([]importRecord, error) {
+    if len(data) > maxImportSize {
+        return nil, ErrImportTooLarge
+    }
+    switch strings.ToLower(filepath.Ext(filename)) {
+    case ".xlsx":
+        return readXLSXRecords(data)
+    case ".csv", ".txt":
+        return readCSVRecords(data)
+    case "":
+        // API bodies without a name: xlsx is a zip file
+        if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+            return readXLSXRecords(data)
+        }
+        return readCSVRecords(data)
+    }
+    return nil, ErrImportFormat
+}
+
+func readXLSXRecords(data []byte) ([]importRecord, error) {
+    f, err := excelize.OpenReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, ErrImportFormat
+    }
+    defer f.Close()
+
+    sheets := f.GetSheetList()
+    if len(sheets) == 0 {
+        return nil, ErrImportEmpty
+    }
+    // raw values: dates typed into Excel come as serial numbers instead of a locale format
+    rows, err := f.GetRows(sheets[0], excelize.Options{RawCellValue: true})
+    if err != nil {
+        return nil, err
+    }
+    records := make([]importRecord, 0, len(rows))
+    for i, cells := range rows {
+        records = append(records, importRecord{Line: i + 1, Cells: cells})
+    }
+    return records, nil
+}
+
+func readCSVRecords(data []byte) ([]importRecord, error) {
+    data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM of Excel's "CSV UTF-8"
+
+    r := csv.NewReader(bytes.NewReader(data))
+    r.Comma = csvDelimiter(data)
+    r.FieldsPerRecord = -1
+    r.LazyQuotes = true
+
+    var records []importRecord
+    for {
+        cells, err := r.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrImportFormat, err)
+        }
+        line, _ := r.FieldPos(0)
+        records = append(records, importRecord{Line: line, Cells: cells})
+        if len(records) > maxImportRows+1 {
+            return nil, ErrImportTooLarge
+        }
+    }
+    return records, nil
+}
+
+// ";" from Excel in Russian locales, "," or tab otherwise; decided by the first line
+func csvDelimiter(data []byte) rune {
+    first := data
+    if i := bytes.IndexByte(data, '\n'); i >= 0 {
+        first = data[:i]
+    }
+    best, count := ',', bytes.Count(first, []byte{','})
+    for _, d := range []rune{';', '\t'} {
+        if n := bytes.Count(first, []byte(string(d))); n > count {
+            best, count = d, n
+        }
+    }
+    return best
+}
+
+// header = first non-empty record; summary and empty rows are left out
+func parseImportRecords(userID int, records []importRecord) ([]ImportRow, error) {
+    i := 0
+    for i < len(records) && isEmptyRecord(records[i].Cells) {
+        i++
+    }
+    if i == len(records) {
+        return nil, ErrImportEmpty
+    }
+
+    columns := make(map[string]int)
+    for n, name := range records[i].Cells {
+        key, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]
+        if _, seen := columns[key]; ok && !seen {
+            columns[key] = n
+        }
+    }
+    _, hasDate := columns["date"]
+    _, hasDescription := columns["description"]
+    _, hasHours := columns["hours"]
+    _, hasTimes := columns["start_time"]
+    if !hasDate || !hasDescription || !(hasHours || hasTimes) {
+        return nil, ErrImportHeader
+    }
+
+    projects, err := projectStore.List(userID)
+    if err != nil {
+        return nil, err
+    }
+    projectIDs := make(map[string]int)
+    for _, p := range projects {
+        projectIDs[strings.ToLower(p.Name)] = p.ID
+    }
+
+    var rows []ImportRow
+    for _, rec := range records[i+1:] {
+        cell := func(key string) string {
+            n, ok := columns[key]
+            if !ok || n >= len(rec.Cells) {
+                return ""
+            }
+            return importText(strings.TrimSpace(rec.Cells[n]))
+        }
+        if isEmptyRecord(rec.Cells) {
+            continue
+        }
+        if cell("date") == "" && importSummaryRows[strings.ToLower(cell("description"))] {
+            continue
+        }
+
+        log, err := parseImportRow(cell, projectIDs)
+        log.UserID = userID
+        rows = append(rows, ImportRow{Line: rec.Line, Log: log, Err: err})
+    }
+    return rows, nil
+}
+
+// without the ' that exportText puts before text looking like a formula
+func importText(s string) string {
+    if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+        return strings.TrimSpace(s[1:])
+    }
+    return s
+}
+
+func isEmptyRecord(cells []string) bool {
+    for _, c := range cells {
+        if strings.TrimSpace(c) != "" {
+            return false
+        }
+    }
+    return true
+}
+
+// values of one row; the rules (hours range, times, tags) are left to PrepareWorkLog
+func parseImportRow(cell func(string) string, projectIDs map[string]int) (WorkLog, error) {
+    log := WorkLog{
+        Description: cell("description"),
+        StartTime:   cell("start_time"),
+        EndTime:     cell("end_time"),
+        Tags:        strings.Split(cell("tags"), ","),
+    }
+
+    date, err := parseImportDate(cell("date"))
+    if err != nil {
+        return log, err
+    }
+    log.Date = date
+
+    if log.Description == "" {
+        return log, ErrImportDescription
+    }
+    if v := cell("hours"); v != "" {
+        if log.Hours, err = strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64); err != nil {
+            return log, ErrImportHours
+        }
+    }
+    if v := cell("break_minutes"); v != "" {
+        if log.BreakMinutes, err = strconv.Atoi(v); err != nil {
+            return log, ErrInvalidBreak
+        }
+    }
+    if log.Billable, err = parseImportBool(cell("billable")); err != nil {
+        return log, err
+    }
+    if name := cell("project"); name != "" {
+        id, ok := projectIDs[strings.ToLower(name)]
+        if !ok {
+            return log, ErrProjectNotFound
+        }
+        log.ProjectID, log.ProjectName = id, name
+    }
+    return log, nil
+}
+
+func parseImportDate(v string) (time.Time, error) {
+    for _, layout := range importDateLayouts {
+        if t, err := time.Parse(layout, v); err == nil {
+            return t, nil
+        }
+    }
+    // xlsx date cell as a serial number
+    if serial, err := strconv.ParseFloat(v, 64); err == nil && serial > 1 {
+        t, err := excelize.ExcelDateToTime(serial, false)
+        if err == nil {
+            return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+        }
+    }
+    return time.Time{}, ErrImportDate
+}
+
+func parseImportBool(v string) (bool, error) {
+    switch strings.ToLower(v) {
+    case "", "нет", "no", "false", "0":
+        return false, nil
+    case "да", "yes", "true", "1", "x":
+        return true, nil
+    }
+    return false, ErrImportBillable
+}
+
+// "import [-dry-run] [-duplicates] csv|toggl|clockify|harvest <username> <file>":
+// the same import as on the web page, straight into the database of the config
+func runImportCommand(args []string) error {
+    fs := flag.NewFlagSet("import", flag.ContinueOnError)
+    dryRun := fs.Bool("dry-run", false, "only check the file and print the report")
+    duplicates := fs.Bool("duplicates", false, "import rows that exist already, too")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() != 3 {
+        return errors.New("usage: import [-dry-run] [-duplicates] csv|toggl|clockify|harvest <username> <file>")
+    }
+    source, username, path := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+    if source == "csv" {
+        source = ""
+    }
+
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    if err := InitDB(config.DatabaseDriver, config.DatabaseDSN(), false); err != nil {
+        return err
+    }
+    defer db.Close()
+
+    user, err := userStore.GetByUsername(username)
+    if err != nil {
+        return fmt.Errorf("import: %s: %w", username, err)
+    }
+    opts := ImportOptions{DryRun: *dryRun, IncludeDuplicates: *duplicates}
+    a := &Audit{ActorName: "cli", Channel: ChannelCLI}
+
+    var result *ImportResult
+    var mapping *ImportMapping
+    if source == "" {
+        result, err = ImportWorkLogs(user.ID, path, content, opts, a)
+    } else {
+        result, mapping, err = ImportTrackerFile(user.ID, source, path, content, opts, a)
+    }
+    if err != nil {
+        return fmt.Errorf("import: %w", err)
+    }
+    printImportReport(os.Stdout, result, mapping, *dryRun)
+    return nil
+}
+
+func printImportReport(w io.Writer, result *ImportResult, mapping *ImportMapping, dryRun bool) {
+    for _, row := range result.Rows {
+        switch {
+        case row.Err != nil:
+            fmt.Fprintf(w, "line %d: %v\n", row.Line, row.Err)
+        case row.Duplicate:
+            fmt.Fprintf(w, "line %d: duplicate of %s %q\n", row.Line, row.Log.Date.Format("2006-01-02"), row.Log.Description)
+        }
+    }
+    if mapping != nil {
+        for _, p := range mapping.Projects {
+            state := "existing"
+            if p.New && p.ID != 0 {
+                state = "created"
+            } else if p.New {
+                state = "new"
+            }
+            fmt.Fprintf(w, "project %q (%s): %d entries\n", p.Name, state, p.Entries)
+        }
+        codes := make([]string, 0, len(mapping.Skipped))
+        for code := range mapping.Skipped {
+            codes = append(codes, code)
+        }
+        sort.Strings(codes)
+        for _, code := range codes {
+            fmt.Fprintf(w, "skipped %s: %d entries\n", code, mapping.Skipped[code])
+        }
+        if len(mapping.IgnoredFields) > 0 {
+            fmt.Fprintf(w, "ignored fields: %s\n", strings.Join(mapping.IgnoredFields, ", "))
+        }
+    }
+    verb := "imported"
+    if dryRun {
+        verb = "would import"
+    }
+    fmt.Fprintf(w, "%s %d entries (%.2f h), %d duplicates, %d with errors\n",
+        verb, result.Valid, result.Hours, result.Duplicates, result.Invalid)
+}
+
+// true for problems with the file itself, as opposed to database errors
+func isImportFileError(err error) bool {
+    for _, target := range []error{ErrImportFormat, ErrImportTooLarge, ErrImportHeader, ErrImportEmpty, ErrImportSource, ErrTrackerFormat, ErrImportChanged} {
+        if errors.Is(err, target) {
+            return true
+        }
+    }
+    return false
+}
+
+// row and file errors for the import page
+func importErrorText(err error) string {
+    switch {
+    case errors.Is(err, ErrImportFormat):
+        return "поддерживаются файлы .csv и .xlsx"
+    case errors.Is(err, ErrImportTooLarge):
+        return fmt.Sprintf("файл больше %d МБ или длиннее %d строк", maxImportSize>>20, maxImportRows)
+    case errors.Is(err, ErrImportHeader):
+        return "в первой строке нужны столбцы «Дата», «Описание» и «Часы» (или «Начало» и «Окончание»)"
+    case errors.Is(err, ErrImportEmpty):
+        return "в файле нет записей"
+    case errors.Is(err, ErrImportSource):
+        return "неизвестный источник"
+    case errors.Is(err, ErrTrackerFormat):
+        return "нужен экспорт трекера в формате .csv или .json"
+    case errors.Is(err, ErrImportChanged):
+        return "записи изменились во время импорта, ничего не импортировано, проверьте файл ещё раз"
+    case errors.Is(err, ErrImportRunning):
+        return "запись ещё идёт (таймер не остановлен)"
+    case errors.Is(err, ErrImportDate):
+        return "дата должна быть в формате ДД.ММ.ГГГГ или ГГГГ-ММ-ДД"
+    case errors.Is(err, ErrImportHours):
+        return "часы должны быть числом"
+    case errors.Is(err, ErrImportBillable):
+        return "«Оплачиваемо» должно быть «да» или «нет»"
+    case errors.Is(err, ErrImportDescription):
+        return "пустое описание"
+    }
+    return workLogErrorText(err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+)
+
+// the fields the export writes and the import reads back
+func importTestKey(log WorkLog) string {
+    tags := append([]string(nil), log.Tags...)
+    sort.Strings(tags)
+    return fmt.Sprintf("%s|%s|%v|%v|%s|%s|%s|%s|%d", log.Date.Format("2006-01-02"), log.Description, log.Hours,
+        log.Billable, log.ProjectName, strings.Join(tags, ","), log.StartTime, log.EndTime, log.BreakMinutes)
+}
+
+// what WriteWorkLogExport writes, ImportWorkLogs takes back: the totals below the rows are skipped,
+// the ' before formula-like text is dropped
+func TestExportImportRoundTrip(t *testing.T) {
+    tests := []struct {
+        name     string
+        filename string
+        opts     ExportOptions
+        // xlsx has only date, description, hours and billable
+        key func(WorkLog) string
+    }{
+        {"csv", "export.csv", ExportOptions{Format: ExportCSV, Delimiter: ';', Decimal: ","}, importTestKey},
+        {"csv comma", "export.csv", ExportOptions{Format: ExportCSV, Delimiter: ',', Decimal: "."}, importTestKey},
+        {"xlsx", "export.xlsx", ExportOptions{Format: ExportXLSX}, func(log WorkLog) string {
+            return fmt.Sprintf("%s|%s|%v|%v", log.Date.Format("2006-01-02"), log.Description, log.Hours, log.Billable)
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestDB(t)
+            alice := createTestUser(t, "alice")
+            bob := createTestUser(t, "bob")
+            for _, userID := range []int{alice.ID, bob.ID} {
+                if err := projectStore.Create(&Project{UserID: userID, Name: "Site"}); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            projects, err := projectStore.List(alice.ID)
+            if err != nil {
+                t.Fatal(err)
+            }
+            day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+            for _, log := range []WorkLog{
+                {Date: day, Description: "=HYPERLINK(\"http://x\")", Hours: 2, Billable: true, ProjectID: projects[0].ID,
+                    Tags: []string{"review", "-x"}, StartTime: "09:00", EndTime: "11:30", BreakMinutes: 30},
+                {Date: day.AddDate(0, 0, 1), Description: "@SUM(1)", Hours: 1.5},
+                {Date: day.AddDate(0, 0, 2), Description: "+1 review; \"quoted\", with comma", Hours: 0.25, Billable: true},
+            } {
+                log.UserID = alice.ID
+                createTestWorkLog(t, log)
+            }
+            exported, err := worklogStore.List(alice.ID, WorkLogFilter{Sort: SortDateAsc})
+            if err != nil {
+                t.Fatal(err)
+            }
+            data := writeTestExport(t, alice.ID, tt.opts)
+
+            // alice's own file: every row is already there
+            again, err := ImportWorkLogs(alice.ID, tt.filename, data, ImportOptions{DryRun: true},
+                &Audit{ActorName: "test", Channel: ChannelCLI})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if again.Duplicates != len(exported) || again.Valid != 0 || again.Invalid != 0 {
+                t.Fatalf("own export again: %d duplicates, %d valid, %d invalid: %+v",
+                    again.Duplicates, again.Valid, again.Invalid, again.Rows)
+            }
+
+            result, err := ImportWorkLogs(bob.ID, tt.filename, data, ImportOptions{},
+                &Audit{ActorID: bob.ID, ActorName: "bob", Channel: ChannelCLI})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !result.Imported || len(result.Rows) != len(exported) || result.Valid != len(exported) || result.Invalid != 0 {
+                t.Fatalf("import: %+v", result)
+            }
+            imported, err := worklogStore.List(bob.ID, WorkLogFilter{Sort: SortDateAsc})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(imported) != len(exported) {
+                t.Fatalf("%d worklogs imported, want %d", len(imported), len(exported))
+            }
+            for i := range exported {
+                if got, want := tt.key(imported[i]), tt.key(exported[i]); got != want {
+                    t.Errorf("row %d: %s, want %s", i+1, got, want)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,689 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "math"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// exports of other trackers that can be imported
+const (
+    SourceToggl    = "toggl"    // Toggl Track: detailed report CSV, time entries / detailed report JSON
+    SourceClockify = "clockify" // Clockify: detailed report CSV, time entries / detailed report JSON
+    SourceHarvest  = "harvest"  // Harvest: detailed time report CSV, time entries JSON (API v2)
+)
+
+var (
+    ErrImportSource  = errors.New("unknown source, expected toggl, clockify or harvest")
+    ErrTrackerFormat = errors.New("unsupported file, expected the .csv or .json export of the tracker")
+    ErrImportRunning = errors.New("entry is still running")
+)
+
+// values of the foreign export that do not make it into the worklogs, for the mapping report
+const (
+    SkipTaskAsTag   = "task_as_tag"   // task name became a tag (Harvest has no tags)
+    SkipSeconds     = "seconds"       // start/end are kept to the minute
+    SkipOvernight   = "overnight"     // start/end over midnight: only the hours are kept
+    SkipTagIDs      = "tag_ids"       // JSON with tag ids but no names: entries without tags
+    SkipProjectIDs  = "project_ids"   // JSON with a project id but no name: entries without project
+    SkipLongTag     = "long_tag"      // tag longer than allowed
+    SkipTooManyTags = "too_many_tags" // tags beyond the limit of an entry
+)
+
+// texts of the skip codes for the import page
+var importSkipTexts = map[string]string{
+    SkipTaskAsTag:   "задача записана тегом",
+    SkipSeconds:     "секунды начала и окончания отброшены",
+    SkipOvernight:   "запись через полночь: время начала и окончания не перенесено, только часы",
+    SkipTagIDs:      "теги заданы только номерами, без названий: не перенесены",
+    SkipProjectIDs:  "проект задан только номером, без названия: запись без проекта",
+    SkipLongTag:     "слишком длинный тег не перенесён",
+    SkipTooManyTags: "теги сверх лимита на запись не перенесены",
+}
+
+// what the mapping did with the export, next to the per-row result
+type ImportMapping struct {
+    Source        string
+    Projects      []ImportProject
+    IgnoredFields []string       // columns / keys of the export that are not mapped at all
+    Skipped       map[string]int // Skip* code -> entries it happened to
+}
+
+// a project name of the export and where its entries go
+type ImportProject struct {
+    Name    string
+    Client  string
+    ID      int  // existing project, 0 = new
+    New     bool // created by the import (on a dry run: would be)
+    Entries int
+}
+
+// one entry of the foreign export, before the rules
+type trackerEntry struct {
+    Line        int // csv line or position in the JSON list
+    Date        time.Time
+    Start, End  time.Time // zero if the tracker only has a duration
+    Hours       float64
+    Description string
+    Project     string
+    Client      string
+    Task        string
+    Tags        []string
+    Billable    bool
+    Err         error
+}
+
+// Import the export of another tracker: entries become worklogs (same checks,
+// duplicates and transaction as ImportWorkLogs), projects are matched by name and
+// missing ones - with their client - are created when the import commits.
+func ImportTrackerFile(userID int, source, filename string, data []byte, opts ImportOptions, a *Audit) (*ImportResult, *ImportMapping, error) {
+    if len(data) > maxImportSize {
+        return nil, nil, ErrImportTooLarge
+    }
+    mapping := &ImportMapping{Source: source, Skipped: make(map[string]int)}
+
+    var entries []trackerEntry
+    var err error
+    switch source {
+    case SourceToggl, SourceClockify, SourceHarvest:
+        if isJSONImport(filename, data) {
+            entries, err = readTrackerJSON(source, data, mapping)
+        } else {
+            entries, err = readTrackerCSV(source, data, mapping)
+        }
+    default:
+        return nil, nil, ErrImportSource
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+
+    rows, err := mapTrackerEntries(userID, entries, mapping)
+    if err != nil {
+        return nil, nil, err
+    }
+    var created []Project
+    result, err := importRows(userID, rows, opts, a, func(valid []*ImportRow) []Project {
+        created = newImportProjects(userID, valid, mapping)
+        return created
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    // ids of the projects the import created
+    for _, p := range created {
+        for i := range mapping.Projects {
+            if mp := &mapping.Projects[i]; mp.New && strings.EqualFold(mp.Name, p.Name) {
+                mp.ID = p.ID
+            }
+        }
+    }
+    return result, mapping, nil
+}
+
+func isJSONImport(filename string, data []byte) bool {
+    if strings.ToLower(filepath.Ext(filename)) == ".json" {
+        return true
+    }
+    trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+    return len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{')
+}
+
+// header names per tracker (lowercase) -> field of trackerEntry
+var trackerColumns = map[string]map[string]string{
+    SourceToggl: {
+        "description": "description",
+        "project":     "project",
+        "client":      "client",
+        "task":        "task",
+        "tags":        "tags",
+        "billable":    "billable",
+        "start date":  "start_date",
+        "start time":  "start_time",
+        "end date":    "end_date",
+        "end time":    "end_time",
+        "duration":    "duration",
+    },
+    SourceClockify: {
+        "description":        "description",
+        "project":            "project",
+        "client":             "client",
+        "task":               "task",
+        "tags":               "tags",
+        "billable":           "billable",
+        "start date":         "start_date",
+        "start time":         "start_time",
+        "end date":           "end_date",
+        "end time":           "end_time",
+        "duration (h)":       "duration",
+        "duration (decimal)": "hours",
+    },
+    SourceHarvest: {
+        "date":      "date",
+        "notes":     "description",
+        "project":   "project",
+        "client":    "client",
+        "task":      "task",
+        "hours":     "hours",
+        "billable?": "billable",
+    },
+}
+
+func readTrackerCSV(source string, data []byte, mapping *ImportMapping) ([]trackerEntry, error) {
+    records, err := readCSVRecords(data)
+    if err != nil {
+        return nil, ErrTrackerFormat
+    }
+    if len(records) == 0 {
+        return nil, ErrImportEmpty
+    }
+
+    columns := make(map[string]int)
+    for n, name := range records[0].Cells {
+        key, ok := trackerColumns[source][strings.ToLower(strings.TrimSpace(name))]
+        if !ok {
+            if name = strings.TrimSpace(name); name != "" {
+                mapping.IgnoredFields = append(mapping.IgnoredFields, name)
+            }
+            continue
+        }
+        columns[key] = n
+    }
+    _, hasDate := columns["date"]
+    _, hasStart := columns["start_date"]
+    if !hasDate && !hasStart {
+        return nil, ErrImportHeader
+    }
+
+    var entries []trackerEntry
+    for _, rec := range records[1:] {
+        if isEmptyRecord(rec.Cells) {
+            continue
+        }
+        fields := make(map[string]string)
+        for key, n := range columns {
+            if n < len(rec.Cells) {
+                fields[key] = strings.TrimSpace(rec.Cells[n])
+            }
+        }
+        entry := trackerCSVEntry(fields)
+        entry.Line = rec.Line
+        entries = append(entries, entry)
+    }
+    return entries, nil
+}
+
+func trackerCSVEntry(f map[string]string) trackerEntry {
+    e := trackerEntry{
+        Description: f["description"],
+        Project:     f["project"],
+        Client:      f["client"],
+        Task:        f["task"],
+        Tags:        splitTrackerTags(f["tags"]),
+    }
+    var err error
+    if e.Billable, err = parseImportBool(f["billable"]); err != nil {
+        e.Err = err
+        return e
+    }
+
+    if f["date"] != "" {
+        // Harvest: a day and hours
+        if e.Date, err = parseTrackerDate(f["date"]); err != nil {
+            e.Err = err
+            return e
+        }
+    } else {
+        if e.Start, err = parseTrackerDateTime(f["start_date"], f["start_time"]); err != nil {
+            e.Err = err
+            return e
+        }
+        e.Date = e.Start
+        endDate := f["end_date"]
+        if endDate == "" {
+            endDate = f["start_date"]
+        }
+        if f["end_time"] != "" {
+            if e.End, err = parseTrackerDateTime(endDate, f["end_time"]); err != nil {
+                e.Err = err
+                return e
+            }
+        }
+    }
+
+    switch {
+    case f["hours"] != "":
+        if e.Hours, err = strconv.ParseFloat(strings.Replace(f["hours"], ",", ".", 1), 64); err != nil {
+            e.Err = ErrImportHours
+        }
+    case f["duration"] != "":
+        if e.Hours, err = parseTrackerDuration(f["duration"]); err != nil {
+            e.Err = ErrImportHours
+        }
+    case !e.End.IsZero():
+        e.Hours = e.End.Sub(e.Start).Hours()
+    }
+    if e.Err == nil && !e.Start.IsZero() && e.End.IsZero() && e.Hours == 0 {
+        e.Err = ErrImportRunning
+    }
+    return e
+}
+
+// "a, b" in Toggl and Clockify
+func splitTrackerTags(s string) []string {
+    var tags []string
+    for _, tag := range strings.Split(s, ",") {
+        if tag = strings.TrimSpace(tag); tag != "" {
+            tags = append(tags, tag)
+        }
+    }
+    return tags
+}
+
+// Clockify writes the date in the format of the workspace settings, US by default
+var trackerDateLayouts = []string{"2006-01-02", "01/02/2006", "02.01.2006", "02-01-2006"}
+
+var trackerTimeLayouts = []string{"15:04:05", "15:04", "03:04:05 PM", "3:04:05 PM", "03:04 PM", "3:04 PM", "3:04PM"}
+
+func parseTrackerDate(v string) (time.Time, error) {
+    for _, layout := range trackerDateLayouts {
+        if t, err := time.Parse(layout, v); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, ErrImportDate
+}
+
+func parseTrackerDateTime(date, clock string) (time.Time, error) {
+    day, err := parseTrackerDate(date)
+    if err != nil {
+        return time.Time{}, err
+    }
+    for _, layout := range trackerTimeLayouts {
+        if t, err := time.Parse(layout, strings.ToUpper(clock)); err == nil {
+            return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+                time.Duration(t.Second())*time.Second), nil
+        }
+    }
+    return time.Time{}, ErrInvalidTimes
+}
+
+// "1:30:00" or "01:30"
+func parseTrackerDuration(v string) (float64, error) {
+    parts := strings.Split(v, ":")
+    if len(parts) < 2 || len(parts) > 3 {
+        return 0, ErrImportHours
+    }
+    var total float64
+    for i, part := range parts {
+        n, err := strconv.Atoi(part)
+        if err != nil {
+            return 0, ErrImportHours
+        }
+        total += float64(n) / []float64{1, 60, 3600}[i]
+    }
+    return total, nil
+}
+
+// JSON exports: a list of entries, or an object with the list under one of these keys
+var trackerJSONLists = []string{"time_entries", "timeentries", "timeEntries", "data"}
+
+func readTrackerJSON(source string, data []byte, mapping *ImportMapping) ([]trackerEntry, error) {
+    data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+    var items []map[string]interface{}
+    if err := json.Unmarshal(data, &items); err != nil {
+        var wrapper map[string]json.RawMessage
+        if err := json.Unmarshal(data, &wrapper); err != nil {
+            return nil, ErrTrackerFormat
+        }
+        found := false
+        for _, key := range trackerJSONLists {
+            if raw, ok := wrapper[key]; ok {
+                if err := json.Unmarshal(raw, &items); err != nil {
+                    return nil, ErrTrackerFormat
+                }
+                found = true
+                break
+            }
+        }
+        if !found {
+            return nil, ErrTrackerFormat
+        }
+    }
+
+    used := map[string]bool{}
+    var entries []trackerEntry
+    for i, item := range items {
+        var e trackerEntry
+        switch source {
+        case SourceToggl:
+            e = togglJSONEntry(jsonItem{item, used}, mapping)
+        case SourceClockify:
+            e = clockifyJSONEntry(jsonItem{item, used}, mapping)
+        case SourceHarvest:
+            e = harvestJSONEntry(jsonItem{item, used})
+        }
+        e.Line = i + 1
+        entries = append(entries, e)
+    }
+
+    ignored := map[string]bool{}
+    for _, item := range items {
+        for key := range item {
+            if !used[key] && !ignored[key] {
+                ignored[key] = true
+                mapping.IgnoredFields = append(mapping.IgnoredFields, key)
+            }
+        }
+    }
+    sort.Strings(mapping.IgnoredFields)
+    return entries, nil
+}
+
+// one object of a JSON export; remembers which keys were read
+type jsonItem struct {
+    values map[string]interface{}
+    used   map[string]bool
+}
+
+func (j jsonItem) get(keys ...string) interface{} {
+    for _, key := range keys {
+        if v, ok := j.values[key]; ok {
+            j.used[key] = true
+            if v != nil {
+                return v
+            }
+        }
+    }
+    return nil
+}
+
+// strings, or the "name" of nested objects like {"project": {"name": ...}}
+func (j jsonItem) str(keys ...string) string {
+    switch v := j.get(keys...).(type) {
+    case string:
+        return strings.TrimSpace(v)
+    case map[string]interface{}:
+        if name, ok := v["name"].(string); ok {
+            return strings.TrimSpace(name)
+        }
+    }
+    return ""
+}
+
+func (j jsonItem) num(keys ...string) (float64, bool) {
+    v, ok := j.get(keys...).(float64)
+    return v, ok
+}
+
+func (j jsonItem) flag(keys ...string) bool {
+    v, _ := j.get(keys...).(bool)
+    return v
+}
+
+// plain names, or objects with a name (Clockify detailed report)
+func (j jsonItem) names(keys ...string) []string {
+    list, _ := j.get(keys...).([]interface{})
+    var names []string
+    for _, v := range list {
+        switch v := v.(type) {
+        case string:
+            names = append(names, v)
+        case map[string]interface{}:
+            if name, ok := v["name"].(string); ok {
+                names = append(names, name)
+            }
+        }
+    }
+    return names
+}
+
+func (j jsonItem) time(keys ...string) (time.Time, error) {
+    s := j.str(keys...)
+    if s == "" {
+        return time.Time{}, nil
+    }
+    t, err := time.Parse(time.RFC3339, s)
+    if err != nil {
+        return time.Time{}, ErrImportDate
+    }
+    // the day and clock of this server, like the timer
+    return t.Local(), nil
+}
+
+func togglJSONEntry(j jsonItem, mapping *ImportMapping) trackerEntry {
+    e := trackerEntry{
+        Description: j.str("description"),
+        Project:     j.str("project", "project_name"),
+        Client:      j.str("client", "client_name"),
+        Task:        j.str("task", "task_name"),
+        Tags:        j.names("tags"),
+        Billable:    j.flag("billable", "is_billable"),
+    }
+    if _, ok := j.num("project_id", "pid"); ok && e.Project == "" {
+        mapping.Skipped[SkipProjectIDs]++
+    }
+    if e.Start, e.Err = j.time("start"); e.Err != nil {
+        return e
+    }
+    if e.End, e.Err = j.time("stop", "end"); e.Err != nil {
+        return e
+    }
+    if e.Start.IsZero() {
+        e.Err = ErrImportDate
+        return e
+    }
+    e.Date = e.Start
+
+    // API: duration in seconds, negative while running; reports: dur in milliseconds
+    if dur, ok := j.num("duration"); ok {
+        if dur < 0 {
+            e.Err = ErrImportRunning
+            return e
+        }
+        e.Hours = dur / 3600
+    } else if dur, ok := j.num("dur"); ok {
+        e.Hours = dur / 3600000
+    }
+    if e.End.IsZero() && e.Hours == 0 {
+        e.Err = ErrImportRunning
+    }
+    return e
+}
+
+func clockifyJSONEntry(j jsonItem, mapping *ImportMapping) trackerEntry {
+    e := trackerEntry{
+        Description: j.str("description"),
+        Project:     j.str("projectName", "project"),
+        Client:      j.str("clientName", "client"),
+        Task:        j.str("taskName", "task"),
+        Tags:        j.names("tags"),
+        Billable:    j.flag("billable"),
+    }
+    if j.str("projectId") != "" && e.Project == "" {
+        mapping.Skipped[SkipProjectIDs]++
+    }
+    if ids, _ := j.get("tagIds").([]interface{}); len(ids) > 0 && len(e.Tags) == 0 {
+        mapping.Skipped[SkipTagIDs]++
+    }
+
+    interval, _ := j.get("timeInterval").(map[string]interface{})
+    inner := jsonItem{interval, map[string]bool{}}
+    if e.Start, e.Err = inner.time("start"); e.Err != nil {
+        return e
+    }
+    if e.End, e.Err = inner.time("end"); e.Err != nil {
+        return e
+    }
+    if e.Start.IsZero() {
+        e.Err = ErrImportDate
+        return e
+    }
+    e.Date = e.Start
+    if e.End.IsZero() {
+        e.Err = ErrImportRunning
+        return e
+    }
+    e.Hours = e.End.Sub(e.Start).Hours()
+    return e
+}
+
+func harvestJSONEntry(j jsonItem) trackerEntry {
+    e := trackerEntry{
+        Description: j.str("notes"),
+        Project:     j.str("project"),
+        Client:      j.str("client"),
+        Task:        j.str("task"),
+        Billable:    j.flag("billable"),
+    }
+    if j.flag("is_running") {
+        e.Err = ErrImportRunning
+        return e
+    }
+    var err error
+    if e.Date, err = time.Parse("2006-01-02", j.str("spent_date")); err != nil {
+        e.Err = ErrImportDate
+        return e
+    }
+    e.Hours, _ = j.num("hours")
+
+    // "8:00am" with timestamp timers, empty otherwise
+    if start, end := j.str("started_time"), j.str("ended_time"); start != "" && end != "" {
+        if e.Start, e.Err = parseTrackerDateTime(j.str("spent_date"), start); e.Err != nil {
+            return e
+        }
+        if e.End, e.Err = parseTrackerDateTime(j.str("spent_date"), end); e.Err != nil {
+            return e
+        }
+    }
+    return e
+}
+
+// trackerEntry -> ImportRow; projects are matched by name (new ones get ID 0 until the commit)
+func mapTrackerEntries(userID int, entries []trackerEntry, mapping *ImportMapping) ([]ImportRow, error) {
+    projects, err := projectStore.List(userID)
+    if err != nil {
+        return nil, err
+    }
+    existing := make(map[string]Project)
+    for _, p := range projects {
+        existing[strings.ToLower(p.Name)] = p
+    }
+    byName := make(map[string]int) // lowercase name -> index in mapping.Projects
+
+    rows := make([]ImportRow, 0, len(entries))
+    for _, e := range entries {
+        row := ImportRow{Line: e.Line, Err: e.Err}
+        log := &row.Log
+        log.UserID = userID
+        log.Date = time.Date(e.Date.Year(), e.Date.Month(), e.Date.Day(), 0, 0, 0, 0, time.UTC)
+        log.Hours = math.Round(e.Hours*100) / 100
+        log.Billable = e.Billable
+        log.Description = e.Description
+        log.Tags = mapTrackerTags(mapping, e)
+
+        // entries without a description are common elsewhere, not here
+        if log.Description == "" {
+            log.Description = e.Task
+        }
+        if log.Description == "" {
+            log.Description = e.Project
+        }
+        if row.Err == nil && log.Description == "" {
+            row.Err = ErrImportDescription
+        }
+
+        if !e.Start.IsZero() && !e.End.IsZero() {
+            switch {
+            case e.End.Format("2006-01-02") != e.Start.Format("2006-01-02") || !e.End.After(e.Start):
+                mapping.Skipped[SkipOvernight]++
+            default:
+                if e.Start.Second() != 0 || e.End.Second() != 0 {
+                    mapping.Skipped[SkipSeconds]++
+                }
+                log.StartTime = e.Start.Format("15:04")
+                log.EndTime = e.End.Format("15:04")
+                // times to the minute can make an entry empty, the hours then stand alone
+                if log.StartTime == log.EndTime {
+                    log.StartTime, log.EndTime = "", ""
+                }
+            }
+        }
+
+        if e.Project != "" {
+            key := strings.ToLower(e.Project)
+            i, ok := byName[key]
+            if !ok {
+                p, found := existing[key]
+                mp := ImportProject{Name: e.Project, Client: e.Client, ID: p.ID, New: !found}
+                if found {
+                    mp.Name, mp.Client = p.Name, p.ClientName
+                }
+                mapping.Projects = append(mapping.Projects, mp)
+                i = len(mapping.Projects) - 1
+                byName[key] = i
+            }
+            mapping.Projects[i].Entries++
+            log.ProjectID = mapping.Projects[i].ID
+            log.ProjectName = mapping.Projects[i].Name
+        }
+        rows = append(rows, row)
+    }
+    sort.Slice(mapping.Projects, func(a, b int) bool {
+        return strings.ToLower(mapping.Projects[a].Name) < strings.ToLower(mapping.Projects[b].Name)
+    })
+    return rows, nil
+}
+
+// tags as names; Harvest tasks become tags, too long or too many tags are dropped
+func mapTrackerTags(mapping *ImportMapping, e trackerEntry) []string {
+    names := e.Tags
+    if e.Task != "" && len(e.Tags) == 0 && mapping.Source == SourceHarvest {
+        names = []string{e.Task}
+        mapping.Skipped[SkipTaskAsTag]++
+    }
+    var tags []string
+    long := false
+    for _, name := range names {
+        name = strings.TrimSpace(strings.ReplaceAll(name, ",", " "))
+        if utf8.RuneCountInString(name) > maxTagLength {
+            long = true
+            continue
+        }
+        tags = append(tags, name)
+    }
+    if long {
+        mapping.Skipped[SkipLongTag]++
+    }
+    if len(tags) > maxTagsPerEntry {
+        tags = tags[:maxTagsPerEntry]
+        mapping.Skipped[SkipTooManyTags]++
+    }
+    return tags
+}
+
+// new projects (with the name of their client) of the rows about to be imported,
+// CreateMany creates them together with the worklogs
+func newImportProjects(userID int, rows []*ImportRow, mapping *ImportMapping) []Project {
+    needed := make(map[string]bool)
+    for _, row := range rows {
+        if row.Log.ProjectID == 0 && row.Log.ProjectName != "" {
+            needed[strings.ToLower(row.Log.ProjectName)] = true
+        }
+    }
+
+    var projects []Project
+    for _, mp := range mapping.Projects {
+        if mp.New && needed[strings.ToLower(mp.Name)] {
+            projects = append(projects, Project{UserID: userID, Name: mp.Name, ClientName: mp.Client})
+        }
+    }
+    return projects
+}
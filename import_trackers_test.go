@@ -0,0 +1,172 @@
+package main
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// one export of each tracker in testdata/import: rows, errors and the mapping report
+func TestImportTrackerFixtures(t *testing.T) {
+    // JSON times are read in the zone of the server; in UTC+14 the fixtures would cross midnight
+    local := time.Local
+    time.Local = time.UTC
+    t.Cleanup(func() { time.Local = local })
+
+    tests := []struct {
+        source, file string
+        rows         []string      // importTestKey of the valid rows
+        errs         map[int]error // line -> error of the invalid rows
+        projects     []ImportProject
+        skipped      map[string]int
+        ignored      []string
+    }{
+        {
+            source: SourceToggl, file: "toggl.csv",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|design,review|09:00|10:30|0",
+                "2026-03-02|Standup|0.25|false|Mobile app||11:00|11:15|0",
+                "2026-03-03|Late deploy|1|false|Internal|ops|||0",
+            },
+            errs: map[int]error{5: ErrImportRunning},
+            projects: []ImportProject{
+                {Name: "Internal", New: true, Entries: 1},
+                {Name: "Mobile app", Client: "Globex", New: true, Entries: 1},
+                {Name: "Site", Client: "ACME", Entries: 2},
+            },
+            skipped: map[string]int{SkipSeconds: 1, SkipOvernight: 1},
+            ignored: []string{"User", "Email", "Amount (EUR)"},
+        },
+        {
+            source: SourceClockify, file: "clockify.csv",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|review|09:00|10:30|0",
+                "2026-03-03|Backend|2|false|Mobile app|api,review|13:00|15:00|0",
+            },
+            projects: []ImportProject{
+                {Name: "Mobile app", Client: "Globex", New: true, Entries: 1},
+                {Name: "Site", Client: "ACME", Entries: 1},
+            },
+            skipped: map[string]int{},
+            ignored: []string{"User", "Group", "Email", "Billable Rate (EUR)", "Billable Amount (EUR)"},
+        },
+        {
+            source: SourceHarvest, file: "harvest.csv",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|design|||0",
+                "2026-03-03|Development|2|false|Mobile app|development|||0",
+            },
+            errs: map[int]error{4: ErrImportHours},
+            projects: []ImportProject{
+                {Name: "Mobile app", Client: "Globex", New: true, Entries: 1},
+                {Name: "Site", Client: "ACME", Entries: 2},
+            },
+            skipped: map[string]int{SkipTaskAsTag: 3},
+            ignored: []string{"Project Code", "Hours Rounded", "Invoiced?", "First Name", "Last Name", "Roles", "Employee?"},
+        },
+        {
+            source: SourceToggl, file: "toggl.json",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|design,review|09:00|10:30|0",
+                "2026-03-03|Project only as id|1|false|||09:00|10:00|0",
+            },
+            errs:     map[int]error{3: ErrImportRunning},
+            projects: []ImportProject{{Name: "Site", Client: "ACME", Entries: 1}},
+            skipped:  map[string]int{SkipProjectIDs: 1},
+            ignored:  []string{"id", "workspace_id"},
+        },
+        {
+            source: SourceClockify, file: "clockify.json",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|review|09:00|10:30|0",
+                "2026-03-03|Tags only as ids|1|false|||09:00|10:00|0",
+            },
+            projects: []ImportProject{{Name: "Site", Client: "ACME", Entries: 1}},
+            skipped:  map[string]int{SkipProjectIDs: 1, SkipTagIDs: 1},
+            ignored:  []string{"id", "userId"},
+        },
+        {
+            source: SourceHarvest, file: "harvest.json",
+            rows: []string{
+                "2026-03-02|Homepage|1.5|true|Site|design|09:00|10:30|0",
+            },
+            errs:     map[int]error{2: ErrImportRunning},
+            projects: []ImportProject{{Name: "Site", Client: "ACME", Entries: 2}},
+            skipped:  map[string]int{SkipTaskAsTag: 2},
+            ignored:  []string{"id"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.file, func(t *testing.T) {
+            setupTestDB(t)
+            alice := createTestUser(t, "alice")
+            acme := &Client{UserID: alice.ID, Name: "ACME"}
+            if err := clientStore.Create(acme); err != nil {
+                t.Fatal(err)
+            }
+            site := &Project{UserID: alice.ID, ClientID: acme.ID, Name: "Site"}
+            if err := projectStore.Create(site); err != nil {
+                t.Fatal(err)
+            }
+            data, err := os.ReadFile(filepath.Join("testdata", "import", tt.file))
+            if err != nil {
+                t.Fatal(err)
+            }
+            a := &Audit{ActorID: alice.ID, ActorName: "alice", Channel: ChannelCLI}
+
+            result, mapping, err := ImportTrackerFile(alice.ID, tt.source, tt.file, data, ImportOptions{DryRun: true}, a)
+            if err != nil {
+                t.Fatal(err)
+            }
+            var rows []string
+            for _, row := range result.Rows {
+                if row.Err == nil {
+                    rows = append(rows, importTestKey(row.Log))
+                } else if want := tt.errs[row.Line]; !errors.Is(row.Err, want) {
+                    t.Errorf("line %d: %v, want %v", row.Line, row.Err, want)
+                }
+            }
+            if !reflect.DeepEqual(rows, tt.rows) {
+                t.Errorf("rows:\n%q\nwant\n%q", rows, tt.rows)
+            }
+            if result.Valid != len(tt.rows) || result.Invalid != len(tt.errs) {
+                t.Errorf("%d valid, %d invalid", result.Valid, result.Invalid)
+            }
+
+            want := append([]ImportProject(nil), tt.projects...)
+            for i := range want {
+                if !want[i].New {
+                    want[i].ID = site.ID
+                }
+            }
+            if !reflect.DeepEqual(mapping.Projects, want) {
+                t.Errorf("projects: %+v, want %+v", mapping.Projects, want)
+            }
+            if !reflect.DeepEqual(mapping.Skipped, tt.skipped) {
+                t.Errorf("skipped: %v, want %v", mapping.Skipped, tt.skipped)
+            }
+            if !reflect.DeepEqual(mapping.IgnoredFields, tt.ignored) {
+                t.Errorf("ignored fields: %q, want %q", mapping.IgnoredFields, tt.ignored)
+            }
+
+            // the commit creates the new projects with their client
+            result, mapping, err = ImportTrackerFile(alice.ID, tt.source, tt.file, data, ImportOptions{}, a)
+            if err != nil || !result.Imported {
+                t.Fatalf("import: %+v %v", result, err)
+            }
+            for _, mp := range mapping.Projects {
+                p, err := projectStore.Get(alice.ID, mp.ID)
+                if err != nil || p.Name != mp.Name || p.ClientName != mp.Client {
+                    t.Errorf("project %+v: %+v %v", mp, p, err)
+                }
+            }
+            logs, err := worklogStore.List(alice.ID, WorkLogFilter{})
+            if err != nil || len(logs) != len(tt.rows) {
+                t.Fatalf("%d worklogs, want %d: %v", len(logs), len(tt.rows), err)
+            }
+        })
+    }
+}
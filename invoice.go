@@ -0,0 +1,113 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "time"
+)
+
+var (
+    ErrNothingToInvoice = errors.New("no uninvoiced billable worklogs for this client and period")
+    ErrInvalidPeriod    = errors.New("invalid period, expected date_from <= date_to")
+    ErrDraftChanged     = errors.New("worklogs or rates changed since the preview, check the invoice again")
+)
+
+// Invoice for clientID and the period [from, to] without saving it: billable,
+// not yet invoiced worklogs of the client's projects, priced with the user's RateBook.
+func DraftInvoice(userID, clientID int, from, to time.Time) (*Invoice, error) {
+    if to.Before(from) {
+        return nil, ErrInvalidPeriod
+    }
+    client, err := clientStore.Get(userID, clientID)
+    if err != nil {
+        return nil, err
+    }
+
+    logs, err := worklogStore.List(userID, WorkLogFilter{
+        DateFrom:       from.Format("2006-01-02"),
+        DateTo:         to.Format("2006-01-02"),
+        ClientID:       clientID,
+        BillableOnly:   true,
+        UninvoicedOnly: true,
+        Sort:           SortDateAsc,
+    })
+    if err != nil {
+        return nil, err
+    }
+    if len(logs) == 0 {
+        return nil, ErrNothingToInvoice
+    }
+
+    book, err := LoadRateBook(userID)
+    if err != nil {
+        return nil, err
+    }
+
+    inv := &Invoice{
+        UserID:     userID,
+        ClientID:   client.ID,
+        ClientName: client.Name,
+        PeriodFrom: from,
+        PeriodTo:   to,
+        Currency:   config.Currency,
+    }
+    for _, log := range logs {
+        line := InvoiceLine{
+            WorkLogID:   log.ID,
+            Date:        log.Date,
+            ProjectName: log.ProjectName,
+            Description: log.Description,
+            Hours:       log.Hours,
+            Rate:        book.RateFor(log),
+            Amount:      book.Amount(log),
+            UpdatedAt:   log.UpdatedAt,
+        }
+        inv.Lines = append(inv.Lines, line)
+        inv.TotalHours += line.Hours
+        inv.TotalAmount += line.Amount
+    }
+    inv.TotalHours = roundMoney(inv.TotalHours)
+    inv.TotalAmount = roundMoney(inv.TotalAmount)
+    return inv, nil
+}
+
+// what the preview showed: worklogs with their updated_at, hours, descriptions and rates.
+// The form and the API send it back with the submit, IssueInvoice compares it with a new draft.
+func (inv *Invoice) Fingerprint() string {
+    h := sha256.New()
+    for _, line := range inv.Lines {
+        fmt.Fprintf(h, "%d|%s|%v|%v|%q\n", line.WorkLogID, line.UpdatedAt.UTC().Format(time.RFC3339Nano),
+            line.Hours, line.Rate, line.Description)
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// draft + save: the invoice gets its number, its worklogs become read-only.
+// fingerprint is the one of the previewed draft, "" when nothing was previewed (API without dry_run);
+// a draft that differs from the preview is refused with ErrDraftChanged.
+func IssueInvoice(userID, clientID int, from, to time.Time, fingerprint string) (*Invoice, error) {
+    inv, err := DraftInvoice(userID, clientID, from, to)
+    if err != nil {
+        return nil, err
+    }
+    if fingerprint != "" && inv.Fingerprint() != fingerprint {
+        return nil, ErrDraftChanged
+    }
+    inv.IssuedAt = time.Now()
+    if err := invoiceStore.Create(inv); err != nil {
+        return nil, err
+    }
+    return inv, nil
+}
+
+// date_from / date_to of the invoice form and API request
+func parseInvoicePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+    from, err1 := time.Parse("2006-01-02", fromStr)
+    to, err2 := time.Parse("2006-01-02", toStr)
+    if err1 != nil || err2 != nil || to.Before(from) {
+        return time.Time{}, time.Time{}, ErrInvalidPeriod
+    }
+    return from, to, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+    "fmt"
+    "io"
+
+    "github.com/go-pdf/fpdf"
+    "github.com/xuri/excelize/v2"
+    "golang.org/x/image/font/gofont/gobold"
+    "golang.org/x/image/font/gofont/goregular"
+)
+
+// invoice as .xlsx, same look as the worklog export
+func WriteInvoiceXLSX(w io.Writer, inv *Invoice) error {
+    f := excelize.NewFile()
+    defer f.Close()
+    sheetName := "Счёт " + inv.Number
+    index, err := f.NewSheet(sheetName)
+    if err != nil {
+        return err
+    }
+
+    titleStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+    f.SetCellValue(sheetName, "A1", "Счёт № "+inv.Number)
+    f.SetCellStyle(sheetName, "A1", "A1", titleStyle)
+    f.SetCellValue(sheetName, "A2", "Клиент:")
+    f.SetCellValue(sheetName, "B2", inv.ClientName)
+    f.SetCellValue(sheetName, "A3", "Период:")
+    f.SetCellValue(sheetName, "B3", inv.PeriodFrom.Format("02.01.2006")+" - "+inv.PeriodTo.Format("02.01.2006"))
+    f.SetCellValue(sheetName, "A4", "Дата:")
+    f.SetCellValue(sheetName, "B4", inv.IssuedAt.Format("02.01.2006"))
+
+    headers := []string{"Дата", "Проект", "Описание", "Часы", "Ставка, " + inv.Currency, "Сумма, " + inv.Currency}
+    for i, h := range headers {
+        cell, _ := excelize.CoordinatesToCellName(i+1, 6)
+        f.SetCellValue(sheetName, cell, h)
+    }
+    headerStyle, _ := f.NewStyle(&excelize.Style{
+        Font: &excelize.Font{Bold: true, Size: 12},
+        Fill: excelize.Fill{Type: "pattern", Color: []string{"#667eea"}, Pattern: 1},
+        Alignment: &excelize.Alignment{Horizontal: "center"},
+    })
+    f.SetCellStyle(sheetName, "A6", "F6", headerStyle)
+
+    row := 7
+    for _, line := range inv.Lines {
+        f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), line.Date.Format("02.01.2006"))
+        f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), line.ProjectName)
+        f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), line.Description)
+        f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.Hours)
+        f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.Rate)
+        f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.Amount)
+        row++
+    }
+
+    row++
+    f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), "ИТОГО:")
+    f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), inv.TotalHours)
+    f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), inv.TotalAmount)
+    totalStyle, _ := f.NewStyle(&excelize.Style{
+        Font: &excelize.Font{Bold: true, Size: 12},
+        Fill: excelize.Fill{Type: "pattern", Color: []string{"#4CAF50"}, Pattern: 1},
+    })
+    f.SetCellStyle(sheetName, fmt.Sprintf("C%d", row), fmt.Sprintf("F%d", row), totalStyle)
+
+    f.SetColWidth(sheetName, "A", "A", 15)
+    f.SetColWidth(sheetName, "B", "B", 20)
+    f.SetColWidth(sheetName, "C", "C", 50)
+    f.SetColWidth(sheetName, "D", "F", 14)
+
+    f.SetActiveSheet(index)
+    f.DeleteSheet("Sheet1")
+    return f.Write(w)
+}
+
+// invoice as .pdf; Go fonts are embedded in the binary and cover Cyrillic
+func WriteInvoicePDF(w io.Writer, inv *Invoice) error {
+    pdf := fpdf.New("P", "mm", "A4", "")
+    pdf.AddUTF8FontFromBytes("go", "", goregular.TTF)
+    pdf.AddUTF8FontFromBytes("go", "B", gobold.TTF)
+    pdf.SetMargins(15, 15, 15)
+    pdf.AddPage()
+
+    pdf.SetFont("go", "B", 16)
+    pdf.Cell(0, 10, "Счёт № "+inv.Number)
+    pdf.Ln(12)
+
+    pdf.SetFont("go", "", 11)
+    for _, kv := range [][2]string{
+        {"Клиент:", inv.ClientName},
+        {"Период:", inv.PeriodFrom.Format("02.01.2006") + " - " + inv.PeriodTo.Format("02.01.2006")},
+        {"Дата:", inv.IssuedAt.Format("02.01.2006")},
+    } {
+        pdf.CellFormat(30, 6, kv[0], "", 0, "L", false, 0, "")
+        pdf.CellFormat(0, 6, kv[1], "", 1, "L", false, 0, "")
+    }
+    pdf.Ln(6)
+
+    // date, project, description, hours, rate, amount = 180mm
+    widths := []float64{22, 30, 68, 18, 20, 22}
+    headers := []string{"Дата", "Проект", "Описание", "Часы", "Ставка", "Сумма"}
+    pdf.SetFont("go", "B", 10)
+    pdf.SetFillColor(102, 126, 234)
+    pdf.SetTextColor(255, 255, 255)
+    for i, h := range headers {
+        pdf.CellFormat(widths[i], 8, h, "1", 0, "C", true, 0, "")
+    }
+    pdf.Ln(-1)
+
+    pdf.SetFont("go", "", 9)
+    pdf.SetTextColor(0, 0, 0)
+    const lineHeight = 5.0
+    for _, line := range inv.Lines {
+        descLines := pdf.SplitText(line.Description, widths[2]-2)
+        if len(descLines) == 0 {
+            descLines = []string{""}
+        }
+        height := float64(len(descLines)) * lineHeight
+        if pdf.GetY()+height > 280 {
+            pdf.AddPage()
+        }
+
+        x, y := pdf.GetXY()
+        pdf.CellFormat(widths[0], height, line.Date.Format("02.01.2006"), "1", 0, "L", false, 0, "")
+        pdf.CellFormat(widths[1], height, line.ProjectName, "1", 0, "L", false, 0, "")
+        pdf.Rect(x+widths[0]+widths[1], y, widths[2], height, "D")
+        for i, text := range descLines {
+            pdf.SetXY(x+widths[0]+widths[1], y+float64(i)*lineHeight)
+            pdf.CellFormat(widths[2], lineHeight, text, "", 0, "L", false, 0, "")
+        }
+        pdf.SetXY(x+widths[0]+widths[1]+widths[2], y)
+        pdf.CellFormat(widths[3], height, fmt.Sprintf("%.2f", line.Hours), "1", 0, "R", false, 0, "")
+        pdf.CellFormat(widths[4], height, fmt.Sprintf("%.2f", line.Rate), "1", 0, "R", false, 0, "")
+        pdf.CellFormat(widths[5], height, fmt.Sprintf("%.2f", line.Amount), "1", 1, "R", false, 0, "")
+    }
+
+    pdf.SetFont("go", "B", 10)
+    pdf.CellFormat(widths[0]+widths[1]+widths[2], 8, "ИТОГО:", "1", 0, "R", false, 0, "")
+    pdf.CellFormat(widths[3], 8, fmt.Sprintf("%.2f", inv.TotalHours), "1", 0, "R", false, 0, "")
+    pdf.CellFormat(widths[4]+widths[5], 8, fmt.Sprintf("%.2f %s", inv.TotalAmount, inv.Currency), "1", 1, "R", false, 0, "")
+
+    return pdf.Output(w)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// client with one billable worklog of alice and a default rate of 100
+func setupInvoiceTest(t *testing.T) (user *User, client *Client, log *WorkLog) {
+    t.Helper()
+    setupTestDB(t)
+    user = createTestUser(t, "alice")
+    client = &Client{UserID: user.ID, Name: "ACME"}
+    if err := clientStore.Create(client); err != nil {
+        t.Fatal(err)
+    }
+    project := &Project{UserID: user.ID, ClientID: client.ID, Name: "site"}
+    if err := projectStore.Create(project); err != nil {
+        t.Fatal(err)
+    }
+    if err := rateStore.Create(&HourlyRate{UserID: user.ID, Rate: 100}); err != nil {
+        t.Fatal(err)
+    }
+    log = createTestWorkLog(t, WorkLog{UserID: user.ID, ProjectID: project.ID, Billable: true,
+        Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), Description: "work", Hours: 2})
+    return user, client, log
+}
+
+func draftTestInvoice(t *testing.T, userID, clientID int) *Invoice {
+    t.Helper()
+    inv, err := DraftInvoice(userID, clientID,
+        time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC))
+    if err != nil {
+        t.Fatal(err)
+    }
+    inv.IssuedAt = time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+    return inv
+}
+
+// the draft is refused when its worklogs or rates changed before it was saved
+func TestCreateInvoiceStaleDraft(t *testing.T) {
+    audit := &Audit{ActorName: "test", Channel: ChannelSystem}
+    tests := []struct {
+        name   string
+        change func(t *testing.T, user *User, log *WorkLog)
+    }{
+        {"hours", func(t *testing.T, user *User, log *WorkLog) {
+            changed := *log
+            changed.Hours = 3
+            if err := worklogStore.Update(&changed, audit); err != nil {
+                t.Fatal(err)
+            }
+        }},
+        {"description only", func(t *testing.T, user *User, log *WorkLog) {
+            changed := *log
+            changed.Description = "other work"
+            if err := worklogStore.Update(&changed, audit); err != nil {
+                t.Fatal(err)
+            }
+        }},
+        {"rate", func(t *testing.T, user *User, log *WorkLog) {
+            if err := rateStore.Create(&HourlyRate{UserID: user.ID, Rate: 150, EffectiveFrom: log.Date}); err != nil {
+                t.Fatal(err)
+            }
+        }},
+        {"moved to the trash", func(t *testing.T, user *User, log *WorkLog) {
+            if err := worklogStore.Delete(user.ID, log.ID, audit); err != nil {
+                t.Fatal(err)
+            }
+        }},
+        {"updated_at only", func(t *testing.T, user *User, log *WorkLog) {
+            if _, err := db.Exec("UPDATE worklogs SET updated_at = ? WHERE id = ?",
+                timeValue(time.Now().Add(time.Hour)), log.ID); err != nil {
+                t.Fatal(err)
+            }
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            user, client, log := setupInvoiceTest(t)
+            inv := draftTestInvoice(t, user.ID, client.ID)
+            tt.change(t, user, log)
+
+            if err := invoiceStore.Create(inv); err != ErrAlreadyInvoiced {
+                t.Fatalf("Create = %v, want ErrAlreadyInvoiced", err)
+            }
+            if invoices, err := invoiceStore.List(user.ID); err != nil || len(invoices) != 0 {
+                t.Fatalf("invoice saved anyway: %v %v", invoices, err)
+            }
+        })
+    }
+
+    t.Run("unchanged", func(t *testing.T) {
+        user, client, log := setupInvoiceTest(t)
+        inv := draftTestInvoice(t, user.ID, client.ID)
+        if err := invoiceStore.Create(inv); err != nil {
+            t.Fatal(err)
+        }
+        if got, err := worklogStore.Get(user.ID, log.ID); err != nil || got.InvoiceID != inv.ID {
+            t.Fatalf("worklog not invoiced: %+v %v", got, err)
+        }
+        if inv.Number != "2026-0001" || inv.TotalAmount != 200 {
+            t.Fatalf("invoice %s, total %v", inv.Number, inv.TotalAmount)
+        }
+    })
+}
+
+// numbers compare as numbers: 2026-10000 follows 2026-9999, each year starts at 1
+func TestInvoiceNumbers(t *testing.T) {
+    user, client, _ := setupInvoiceTest(t)
+    _, err := db.Exec(`INSERT INTO invoices (user_id, client_id, client_name, number, year, seq, period_from, period_to,
+        issued_at, currency, total_hours, total_amount) VALUES (?, ?, 'ACME', '2026-9999', 2026, 9999,
+        '2026-01-01', '2026-01-31', ?, 'EUR', 0, 0)`, user.ID, client.ID, timeValue(time.Now()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := db.Exec("INSERT INTO invoice_counters (user_id, year, last_seq) VALUES (?, 2026, 9999)", user.ID); err != nil {
+        t.Fatal(err)
+    }
+
+    inv := draftTestInvoice(t, user.ID, client.ID)
+    if err := invoiceStore.Create(inv); err != nil {
+        t.Fatal(err)
+    }
+    if inv.Number != "2026-10000" {
+        t.Fatalf("number after 2026-9999: %s", inv.Number)
+    }
+
+    next := &Invoice{UserID: user.ID, ClientID: client.ID, ClientName: "ACME", Currency: "EUR",
+        IssuedAt: time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)}
+    if err := invoiceStore.Create(next); err != nil {
+        t.Fatal(err)
+    }
+    if next.Number != "2027-0001" {
+        t.Fatalf("first number of 2027: %s", next.Number)
+    }
+}
+
+// a cancelled invoice keeps its number and lines, frees its worklogs and its number is never given again
+func TestCancelInvoice(t *testing.T) {
+    user, client, log := setupInvoiceTest(t)
+    first := draftTestInvoice(t, user.ID, client.ID)
+    if err := invoiceStore.Create(first); err != nil {
+        t.Fatal(err)
+    }
+    if err := invoiceStore.Cancel(user.ID, first.ID); err != nil {
+        t.Fatal(err)
+    }
+    if err := invoiceStore.Cancel(user.ID, first.ID); err != ErrInvoiceCancelled {
+        t.Fatalf("second cancel: %v", err)
+    }
+    if err := invoiceStore.Cancel(user.ID+1, first.ID); err != ErrInvoiceNotFound {
+        t.Fatalf("cancel of a foreign invoice: %v", err)
+    }
+
+    cancelled, err := invoiceStore.Get(user.ID, first.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cancelled.Status != InvoiceCancelled || cancelled.CancelledAt.IsZero() || cancelled.Number != "2026-0001" ||
+        len(cancelled.Lines) != 1 {
+        t.Fatalf("cancelled invoice: %+v", cancelled)
+    }
+    if got, err := worklogStore.Get(user.ID, log.ID); err != nil || got.InvoiceID != 0 {
+        t.Fatalf("worklog still invoiced: %+v %v", got, err)
+    }
+
+    // the same worklog again, under the next number
+    second := draftTestInvoice(t, user.ID, client.ID)
+    if err := invoiceStore.Create(second); err != nil {
+        t.Fatal(err)
+    }
+    if second.Number != "2026-0002" || second.Status != InvoiceIssued {
+        t.Fatalf("invoice after the cancelled one: %s %s", second.Number, second.Status)
+    }
+    if invoices, err := invoiceStore.List(user.ID); err != nil || len(invoices) != 2 {
+        t.Fatalf("invoices: %+v %v", invoices, err)
+    }
+}
+
+// invoices issued at the same moment get different numbers, none is skipped
+func TestInvoiceNumbersParallel(t *testing.T) {
+    user, client, _ := setupInvoiceTest(t)
+    start := make(chan struct{})
+    numbers := make(chan string, 10)
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            inv := &Invoice{UserID: user.ID, ClientID: client.ID, ClientName: "ACME", Currency: "EUR",
+                IssuedAt: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}
+            <-start
+            if err := invoiceStore.Create(inv); err != nil {
+                t.Error(err)
+                return
+            }
+            numbers <- inv.Number
+        }()
+    }
+    close(start)
+    wg.Wait()
+    close(numbers)
+
+    seen := make(map[string]bool)
+    for number := range numbers {
+        seen[number] = true
+    }
+    for i := 1; i <= 10; i++ {
+        if number := fmt.Sprintf("2026-%04d", i); !seen[number] {
+            t.Fatalf("%s missing: %v", number, seen)
+        }
+    }
+}
+
+// the submit issues what the preview showed or nothing: web form and API send the fingerprint of the preview
+func TestIssueInvoiceFingerprint(t *testing.T) {
+    user, client, log := setupInvoiceTest(t)
+    router, err := setupRouter()
+    if err != nil {
+        t.Fatal(err)
+    }
+    web := loginWeb(t, router, "alice")
+    api := loginAPI(t, router, "alice")
+    period := map[string]interface{}{"client_id": client.ID, "date_from": "2026-03-01", "date_to": "2026-03-31"}
+
+    dryRun := map[string]interface{}{"dry_run": true}
+    for k, v := range period {
+        dryRun[k] = v
+    }
+    w := api.sendJSON(http.MethodPost, "/api/v1/invoices", dryRun)
+    previewed, _ := decodeTestJSON(t, w)["data"].(map[string]interface{})["fingerprint"].(string)
+    page := web.get(fmt.Sprintf("/invoices?client_id=%d&date_from=2026-03-01&date_to=2026-03-31", client.ID))
+    if previewed == "" || !strings.Contains(page.Body.String(), previewed) {
+        t.Fatalf("fingerprint of the preview: %q %d", previewed, page.Code)
+    }
+
+    // one more worklog and a changed one after the preview
+    createTestWorkLog(t, WorkLog{UserID: user.ID, ProjectID: log.ProjectID, Billable: true,
+        Date: log.Date.AddDate(0, 0, 1), Description: "more work", Hours: 1})
+    changed := *log
+    changed.Hours = 3
+    if err := worklogStore.Update(&changed, &Audit{ActorName: "test", Channel: ChannelSystem}); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := IssueInvoice(user.ID, client.ID, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+        time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), previewed); err != ErrDraftChanged {
+        t.Fatalf("IssueInvoice with a stale fingerprint: %v", err)
+    }
+    issue := map[string]interface{}{"fingerprint": previewed}
+    for k, v := range period {
+        issue[k] = v
+    }
+    if w := api.sendJSON(http.MethodPost, "/api/v1/invoices", issue); w.Code != http.StatusConflict {
+        t.Fatalf("API issue with a stale fingerprint: %d %s", w.Code, w.Body.String())
+    }
+    form := url.Values{"client_id": {fmt.Sprint(client.ID)}, "date_from": {"2026-03-01"}, "date_to": {"2026-03-31"}}
+    for _, fingerprint := range []string{previewed, ""} {
+        form.Set("fingerprint", fingerprint)
+        page = web.postForm("/invoices/create", form)
+        if !strings.Contains(page.Body.String(), invoiceErrorText(ErrDraftChanged)) {
+            t.Fatalf("web issue with fingerprint %q: %d %s", fingerprint, page.Code, page.Body.String())
+        }
+    }
+    if invoices, err := invoiceStore.List(user.ID); err != nil || len(invoices) != 0 {
+        t.Fatalf("invoice issued anyway: %+v %v", invoices, err)
+    }
+
+    // the page shows the new preview, its fingerprint issues the invoice
+    current := draftTestInvoice(t, user.ID, client.ID).Fingerprint()
+    if current == previewed || !strings.Contains(page.Body.String(), current) {
+        t.Fatalf("new preview after the refused submit")
+    }
+    form.Set("fingerprint", current)
+    if w := web.postForm("/invoices/create", form); w.Code != http.StatusFound {
+        t.Fatalf("web issue with the current fingerprint: %d %s", w.Code, w.Body.String())
+    }
+    if invoices, err := invoiceStore.List(user.ID); err != nil || len(invoices) != 1 || invoices[0].TotalHours != 4 {
+        t.Fatalf("issued invoices: %+v %v", invoices, err)
+    }
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// refused before the password is checked, so a flood of guesses does not cost bcrypt time
+type LoginThrottledError struct {
+    RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+    return fmt.Sprintf("too many login attempts, retry in %s", e.RetryAfter)
+}
+
+// seconds for Retry-After and messages, at least 1
+func (e *LoginThrottledError) Seconds() int {
+    return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// status and text for the login page
+func loginRefused(err error) (int, string) {
+    if t, ok := err.(*LoginThrottledError); ok {
+        return http.StatusTooManyRequests, fmt.Sprintf("Слишком много попыток входа. Повторите через %d сек.", t.Seconds())
+    }
+    return http.StatusInternalServerError, "Ошибка входа, попробуйте позже"
+}
+
+// where a login attempt comes from
+type LoginAttempt struct {
+    Username string
+    IP       string
+    Channel  string // ChannelWeb / ChannelAPI
+
+    pending int  // failed_logins row while the attempt is checked
+    failed  bool // RecordLoginFailure kept the row
+}
+
+// usernames are counted as typed, case and spaces do not give extra attempts
+func throttleKey(username string) string {
+    return strings.ToLower(strings.TrimSpace(username))
+}
+
+// wait of a username after n failures: 1s, 2s, 4s, ... and the full lockout from max failures on
+func loginBackoff(n int) time.Duration {
+    if n <= 0 {
+        return 0
+    }
+    if n >= config.LoginMaxFailures {
+        return config.LoginLockout
+    }
+    d := time.Second << uint(n-1)
+    if d > config.LoginLockout {
+        d = config.LoginLockout
+    }
+    return d
+}
+
+// nil if the attempt may go on, *LoginThrottledError if the username or the ip has to wait;
+// the same for existing and unknown usernames. An allowed attempt counts as a failure right
+// away, so parallel guesses can not all pass before the first one is recorded; the caller
+// defers FinishLoginAttempt, which forgets it unless RecordLoginFailure kept it.
+// Refused attempts are not recorded.
+func CheckLoginAllowed(a *LoginAttempt) error {
+    now := time.Now()
+    f := &FailedLogin{Username: throttleKey(a.Username), IP: a.IP, Channel: a.Channel, AttemptedAt: now}
+    id, wait, err := loginStore.Reserve(f, now.Add(-config.LoginLockout), func(n LoginFailures) time.Duration {
+        var wait time.Duration
+        if n.User > 0 {
+            wait = n.UserLast.Add(loginBackoff(n.User)).Sub(now)
+        }
+        // an ip (an office behind NAT) gets no backoff, only a cap per window
+        if n.IP >= config.LoginIPMaxFailures {
+            if w := n.IPLast.Add(config.LoginLockout).Sub(now); w > wait {
+                wait = w
+            }
+        }
+        return wait
+    })
+    if err != nil {
+        return err
+    }
+    if wait > 0 {
+        return &LoginThrottledError{RetryAfter: wait}
+    }
+    a.pending, a.failed = id, false
+    return nil
+}
+
+// wrong password / unknown user / wrong 2FA code
+func RecordLoginFailure(a *LoginAttempt, reason string) {
+    var err error
+    if a.pending != 0 {
+        err = loginStore.Fail(a.pending, reason, time.Now())
+    } else {
+        err = loginStore.Record(&FailedLogin{
+            Username:    throttleKey(a.Username),
+            IP:          a.IP,
+            Channel:     a.Channel,
+            Reason:      reason,
+            AttemptedAt: time.Now(),
+        })
+    }
+    if err != nil {
+        log.Println("record failed login:", err)
+    }
+    a.failed = true
+}
+
+// end of an attempt let through by CheckLoginAllowed: unless it failed it stops counting
+func FinishLoginAttempt(a *LoginAttempt) {
+    if a.pending == 0 || a.failed {
+        return
+    }
+    if err := loginStore.Release(a.pending); err != nil {
+        log.Println("release login attempt:", err)
+    }
+    a.pending = 0
+}
+
+// full login (password and 2FA if on) succeeded, the username starts from zero again
+func RecordLoginSuccess(a LoginAttempt) {
+    if err := loginStore.Clear(throttleKey(a.Username)); err != nil {
+        log.Println("clear failed logins:", err)
+    }
+}
+
+var (
+    dummyHashOnce sync.Once
+    dummyHash     string
+)
+
+// unknown usernames are checked against this, so they take as long as a wrong password
+func CheckPasswordOrDummy(user *User, password string) bool {
+    if user != nil {
+        return CheckPassword(password, user.Password)
+    }
+    dummyHashOnce.Do(func() {
+        dummyHash, _ = HashPassword("not a real password")
+    })
+    CheckPassword(password, dummyHash)
+    return false
+}
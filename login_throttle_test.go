@@ -0,0 +1,75 @@
+package main
+
+import (
+    "sync"
+    "testing"
+)
+
+func countFailedLogins(t *testing.T) int {
+    t.Helper()
+    var n int
+    if err := db.QueryRow("SELECT COUNT(*) FROM failed_logins").Scan(&n); err != nil {
+        t.Fatal(err)
+    }
+    return n
+}
+
+// parallel guesses for one username: only the first gets through, the others see it
+// as a failure already and are refused without a row of their own
+func TestCheckLoginAllowedParallel(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+
+            const guesses = 10
+            attempts := make([]LoginAttempt, guesses)
+            errs := make([]error, guesses)
+            var wg sync.WaitGroup
+            for i := range attempts {
+                attempts[i] = LoginAttempt{Username: "alice", IP: "192.0.2.1", Channel: ChannelAPI}
+                wg.Add(1)
+                go func(i int) {
+                    defer wg.Done()
+                    errs[i] = CheckLoginAllowed(&attempts[i])
+                }(i)
+            }
+            wg.Wait()
+
+            allowed := -1
+            for i, err := range errs {
+                switch err.(type) {
+                case nil:
+                    if allowed >= 0 {
+                        t.Fatalf("attempts %d and %d both allowed", allowed, i)
+                    }
+                    allowed = i
+                case *LoginThrottledError:
+                default:
+                    t.Fatalf("attempt %d: %v", i, err)
+                }
+            }
+            if allowed < 0 {
+                t.Fatal("no attempt allowed")
+            }
+            if n := countFailedLogins(t); n != 1 {
+                t.Fatalf("%d failed_logins rows while one attempt runs, want 1", n)
+            }
+
+            // the attempt turned out fine: nothing is left that counts for the username or the ip
+            FinishLoginAttempt(&attempts[allowed])
+            if n := countFailedLogins(t); n != 0 {
+                t.Fatalf("%d failed_logins rows after a good attempt, want 0", n)
+            }
+            next := LoginAttempt{Username: "alice", IP: "192.0.2.1", Channel: ChannelAPI}
+            if err := CheckLoginAllowed(&next); err != nil {
+                t.Fatalf("attempt after a good one: %v", err)
+            }
+            RecordLoginFailure(&next, LoginFailPassword)
+            FinishLoginAttempt(&next)
+            var reason string
+            if err := db.QueryRow("SELECT reason FROM failed_logins").Scan(&reason); err != nil || reason != LoginFailPassword {
+                t.Fatalf("failed attempt kept as %q %v", reason, err)
+            }
+        })
+    }
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "mime"
+    "net/smtp"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// outgoing mail (password reset links), chosen by config.Mailer
+type Mailer interface {
+    Send(to, subject, body string) error
+}
+
+// set in main from config
+var mailer Mailer
+
+func NewMailer(cfg *Config) Mailer {
+    switch cfg.Mailer {
+    case "smtp":
+        return &SMTPMailer{
+            Addr:     cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort),
+            Host:     cfg.SMTPHost,
+            Username: cfg.SMTPUsername,
+            Password: cfg.SMTPPassword,
+            From:     cfg.MailFrom,
+        }
+    case "file":
+        return &FileMailer{Path: cfg.MailFile, From: cfg.MailFrom}
+    }
+    return &LogMailer{}
+}
+
+// plain text mail with a utf-8 subject
+func formatMail(from, to, subject, body string) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", from)
+    fmt.Fprintf(&b, "To: %s\r\n", to)
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+    b.WriteString("\r\n")
+    b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+    return b.String()
+}
+
+// sends through an SMTP server, STARTTLS when the server offers it
+type SMTPMailer struct {
+    Addr     string // host:port
+    Host     string
+    Username string // empty = no auth
+    Password string
+    From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+    var auth smtp.Auth
+    if m.Username != "" {
+        auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+    }
+    return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(formatMail(m.From, to, subject, body)))
+}
+
+// local testing: mails are appended to a file
+type FileMailer struct {
+    Path string
+    From string
+    mu   sync.Mutex
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, err = fmt.Fprintf(f, "%s\r\n\r\n----------\r\n", formatMail(m.From, to, subject, body))
+    return err
+}
+
+// development default: mails only go to the server log
+type LogMailer struct{}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+    log.Printf("mail to %s: %s\n%s", to, subject, body)
+    return nil
+}
@@ -4,26 +4,91 @@ import (
     "github.com/gin-gonic/gin"
     "github.com/gin-contrib/sessions"
     "github.com/gin-contrib/sessions/cookie"
+    "flag"
+    "fmt"
     "log"
     "html/template"
     "net/http"
+    "strings"
 )
 
 func main() {
+    configPath := flag.String("config", "", "path to config file (.yaml or .toml), CONFIG_FILE env also works")
+    flag.Usage = func() {
+        fmt.Fprintln(flag.CommandLine.Output(), "usage: my-tracker [-config file] [migrate ... | 2fa reset <username> | user add|role <username> <role> | import [-dry-run] <source> <username> <file>]")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    // config: defaults -> file -> env
+    var err error
+    config, err = LoadConfig(*configPath)
+    if err != nil {
+        log.Fatal(err)
+    }
+    gin.SetMode(config.GinMode)
+    mailer = NewMailer(config)
+
+    // subcommands
+    if args := flag.Args(); len(args) > 0 {
+        switch args[0] {
+        case "migrate":
+            if err := runMigrateCommand(args[1:]); err != nil {
+                log.Fatal(err)
+            }
+            return
+        case "2fa":
+            if err := runTwoFactorCommand(args[1:]); err != nil {
+                log.Fatal(err)
+            }
+            return
+        case "user":
+            if err := runUserCommand(args[1:]); err != nil {
+                log.Fatal(err)
+            }
+            return
+        case "import":
+            if err := runImportCommand(args[1:]); err != nil {
+                log.Fatal(err)
+            }
+            return
+        default:
+            log.Fatalf("unknown command %q", args[0])
+        }
+    }
+
     // initio. db 
-    if err := InitDB(); err != nil {
+    if err := InitDB(config.DatabaseDriver, config.DatabaseDSN(), config.AutoMigrate); err != nil {
         log.Fatal("fehler db:", err)
     }
+    StartTrashPurge(config.TrashRetention)
+    warnIfNoAdmin()
 
+    r, err := setupRouter()
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    log.Println("🚀 up see there http://localhost" + config.ListenAddr())
+    log.Println("📡 API has to be available there http://localhost" + config.ListenAddr() + "/api/v1")
+    r.Run(config.ListenAddr())
+}
+
+// all web and API routes; config and the stores have to be set up before
+func setupRouter() (*gin.Engine, error) {
     r := gin.Default()
+    // ClientIP (login throttling) reads X-Forwarded-For only from these
+    if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+        return nil, fmt.Errorf("trusted proxies: %w", err)
+    }
     
     // conf or put settings for sessions 
-    store := cookie.NewStore([]byte("super-secret-key-change-me-in-production"))
+    store := cookie.NewStore([]byte(config.SessionSecret))
     store.Options(sessions.Options{
         Path:     "/",
         MaxAge:   0, // Session cookie - out session after browser closed
         HttpOnly: true,
-        Secure:   false,
+        Secure:   config.CookieSecure,
         SameSite: http.SameSiteLaxMode,
     })
     r.Use(sessions.Sessions("mysession", store))
@@ -33,6 +98,7 @@ func main() {
         "add": func(a, b float64) float64 {
             return a + b
         },
+        "join": strings.Join,
     })
     
     // load HTML temlates
@@ -47,22 +113,107 @@ func main() {
     r.POST("/login", LoginHandler)
     r.GET("/register", RegisterPage)
     r.POST("/register", RegisterHandler)
+    r.GET("/login/2fa", LoginTwoFactorPage)
+    r.POST("/login/2fa", LoginTwoFactorHandler)
+    r.GET("/password/forgot", ForgotPasswordPage)
+    r.POST("/password/forgot", ForgotPasswordHandler)
+    r.GET("/password/reset", ResetPasswordPage)
+    r.POST("/password/reset", ResetPasswordHandler)
+    r.GET("/email/confirm", ConfirmEmailPage)
     
     // secured routes only after creds done successful 
     authorized := r.Group("/")
     authorized.Use(AuthRequired())
-    authorized.Use(CheckInactivity())
+    authorized.Use(CheckInactivity(config.InactivityTimeout))
     {
         authorized.GET("/dashboard", DashboardPage)
+        authorized.POST("/timer/start", StartTimerHandler)
+        authorized.POST("/timer/stop", StopTimerHandler)
+        authorized.POST("/timer/discard", DiscardTimerHandler)
         authorized.GET("/worklog/new", NewWorkLogPage)
         authorized.POST("/worklog/create", CreateWorkLogHandler)
         authorized.GET("/worklog/list", WorkLogListPage)
         authorized.GET("/reports", ReportsPage)
-        authorized.GET("/worklog/edit/:id", EditWorkLogPage)
-        authorized.POST("/worklog/update/:id", UpdateWorkLogHandler)
-        authorized.POST("/worklog/delete/:id", DeleteWorkLogHandler)
+        authorized.GET("/worklog/edit/:id", WorkLogOwnerRequired(WebWorkLogNotFound), EditWorkLogPage)
+        authorized.POST("/worklog/update/:id", WorkLogOwnerRequired(WebWorkLogNotFound), UpdateWorkLogHandler)
+        authorized.POST("/worklog/delete/:id", WorkLogOwnerRequired(WebWorkLogNotFound), DeleteWorkLogHandler)
         authorized.GET("/worklog/export", ExportWorkLogHandler)
+        authorized.GET("/worklog/history", WorkLogHistoryPage)
+        authorized.GET("/worklog/history/:id", WorkLogVersionsPage)
+        authorized.POST("/worklog/restore/:id", RestoreWorkLogHandler)
+        authorized.GET("/worklog/import", ImportPage)
+        authorized.POST("/worklog/import", ImportWorkLogsHandler)
+        authorized.GET("/worklog/trash", TrashPage)
+        authorized.POST("/worklog/trash/restore/:id", RestoreTrashHandler)
+        authorized.POST("/worklog/trash/delete/:id", PurgeTrashHandler)
+        
+        // weekly approval: own weeks, review by team managers and admins
+        timesheet := TimesheetAccessRequired(WebTimesheetNotFound)
+        authorized.GET("/timesheets", TimesheetsPage)
+        authorized.POST("/timesheets/submit", SubmitTimesheetHandler)
+        authorized.GET("/timesheets/:id", timesheet, TimesheetPage)
+        authorized.POST("/timesheets/:id/review", timesheet, ReviewTimesheetHandler)
+        
+        // projects + clients
+        authorized.GET("/projects", ProjectsPage)
+        authorized.POST("/projects/create", CreateProjectHandler)
+        authorized.POST("/projects/update/:id", UpdateProjectHandler)
+        authorized.POST("/projects/delete/:id", DeleteProjectHandler)
+        authorized.POST("/clients/create", CreateClientHandler)
+        authorized.POST("/clients/update/:id", UpdateClientHandler)
+        authorized.POST("/clients/delete/:id", DeleteClientHandler)
+        authorized.POST("/rates/create", CreateRateHandler)
+        authorized.POST("/rates/delete/:id", DeleteRateHandler)
+        authorized.GET("/invoices", InvoicesPage)
+        authorized.POST("/invoices/create", CreateInvoiceHandler)
+        authorized.GET("/invoices/:id", InvoicePage)
+        authorized.GET("/invoices/:id/pdf", InvoicePDFHandler)
+        authorized.GET("/invoices/:id/xlsx", InvoiceXLSXHandler)
+        authorized.POST("/invoices/cancel/:id", CancelInvoiceHandler)
+        authorized.GET("/tokens", TokensPage)
+        authorized.POST("/tokens/create", CreateTokenHandler)
+        authorized.POST("/tokens/delete/:id", DeleteTokenHandler)
+        authorized.GET("/2fa", TwoFactorPage)
+        authorized.POST("/2fa/setup", SetupTwoFactorHandler)
+        authorized.POST("/2fa/enable", EnableTwoFactorHandler)
+        authorized.POST("/2fa/recovery-codes", RecoveryCodesHandler)
+        authorized.POST("/2fa/disable", DisableTwoFactorHandler)
+        authorized.GET("/account", AccountPage)
+        authorized.POST("/account/email", UpdateEmailHandler)
+        authorized.POST("/account/password", ChangePasswordHandler)
         authorized.GET("/logout", LogoutHandler)
+        
+        // admin area: managers see the users, admins manage them
+        admin := authorized.Group("/admin", RequirePermission(PermViewUsers, WebForbidden))
+        manageUsers := RequirePermission(PermManageUsers, WebForbidden)
+        admin.GET("/users", AdminUsersPage)
+        admin.POST("/users/:id/role", manageUsers, AdminSetRoleHandler)
+        admin.POST("/users/:id/disable", manageUsers, AdminDisableUserHandler)
+        admin.POST("/users/:id/enable", manageUsers, AdminEnableUserHandler)
+        admin.POST("/users/:id/delete", manageUsers, AdminDeleteUserHandler)
+        admin.POST("/users/:id/password", manageUsers, AdminResetPasswordHandler)
+        admin.POST("/users/:id/2fa-reset", manageUsers, AdminResetTwoFactorHandler)
+        manageLocks := RequirePermission(PermManageLocks, WebForbidden)
+        admin.GET("/locks", manageLocks, AdminLocksPage)
+        admin.POST("/locks/set", manageLocks, SetLockHandler)
+        admin.POST("/locks/delete/:user_id", manageLocks, DeleteLockHandler)
+        
+        // teams: admins manage all, managers see the members of their teams (read-only);
+        // the team check comes first, a team the user may not see is 404 for everybody
+        teams := authorized.Group("/teams")
+        viewTeams := RequirePermission(PermViewTeams, WebForbidden)
+        manageTeams := RequirePermission(PermManageTeams, WebForbidden)
+        teamViewer := TeamViewerRequired(WebTeamNotFound)
+        teamMember := TeamMemberRequired(WebTeamNotFound)
+        teams.GET("", viewTeams, TeamsPage)
+        teams.POST("/create", manageTeams, CreateTeamHandler)
+        teams.POST("/delete/:id", teamViewer, manageTeams, DeleteTeamHandler)
+        teams.GET("/:id", teamViewer, TeamPage)
+        teams.POST("/:id/members", teamViewer, manageTeams, SetTeamMemberHandler)
+        teams.POST("/:id/members/:user_id/remove", teamViewer, manageTeams, RemoveTeamMemberHandler)
+        teams.GET("/:id/members/:user_id/worklogs", teamMember, WorkLogListPage)
+        teams.GET("/:id/members/:user_id/reports", teamMember, ReportsPage)
+        teams.GET("/:id/members/:user_id/export", teamMember, ExportWorkLogHandler)
     }
     
     // ========== API ROUTES ==========
@@ -72,23 +223,125 @@ func main() {
         // public API endpoints
         api.POST("/auth/login", APILogin)
         api.POST("/auth/register", APIRegister)
+        api.POST("/auth/refresh", APIRefreshToken)
+        api.POST("/auth/2fa/verify", APIVerifyTwoFactor)
+        api.POST("/auth/password/forgot", APIForgotPassword)
+        api.POST("/auth/password/reset", APIResetPassword)
+        api.POST("/auth/email/confirm", APIConfirmEmail)
         
         // isecured API endpoints (needs JWT token)
         apiAuth := api.Group("/")
         apiAuth.Use(JWTAuthMiddleware())
         {
+            // JWT only, personal tokens can not manage tokens, 2FA or the account
+            apiAuth.POST("/auth/logout", JWTRequired(), APILogout)
+            apiAuth.GET("/tokens", JWTRequired(), APIGetPersonalTokens)
+            apiAuth.POST("/tokens", JWTRequired(), APICreatePersonalToken)
+            apiAuth.DELETE("/tokens/:id", JWTRequired(), APIDeletePersonalToken)
+            apiAuth.GET("/2fa", JWTRequired(), APIGetTwoFactor)
+            apiAuth.POST("/2fa/setup", JWTRequired(), APISetupTwoFactor)
+            apiAuth.POST("/2fa/enable", JWTRequired(), APIEnableTwoFactor)
+            apiAuth.POST("/2fa/recovery-codes", JWTRequired(), APIRegenerateRecoveryCodes)
+            apiAuth.POST("/2fa/disable", JWTRequired(), APIDisableTwoFactor)
+            apiAuth.GET("/account", JWTRequired(), APIGetAccount)
+            apiAuth.PUT("/account", JWTRequired(), APIUpdateAccount)
+            apiAuth.POST("/account/password", JWTRequired(), APIChangePassword)
+            
+            // admin: JWT only, managers read, admins write
+            apiAdmin := apiAuth.Group("/admin", JWTRequired(), RequirePermission(PermViewUsers, APIForbidden))
+            apiManageUsers := RequirePermission(PermManageUsers, APIForbidden)
+            apiAdmin.GET("/users", APIAdminGetUsers)
+            apiAdmin.PUT("/users/:id", apiManageUsers, APIAdminUpdateUser)
+            apiAdmin.DELETE("/users/:id", apiManageUsers, APIAdminDeleteUser)
+            apiAdmin.POST("/users/:id/password", apiManageUsers, APIAdminResetPassword)
+            apiAdmin.POST("/users/:id/2fa/reset", apiManageUsers, APIAdminResetTwoFactor)
+            apiManageLocks := RequirePermission(PermManageLocks, APIForbidden)
+            apiAdmin.GET("/locks", apiManageLocks, APIAdminGetLocks)
+            apiAdmin.PUT("/locks/global", apiManageLocks, APIAdminSetLock)
+            apiAdmin.DELETE("/locks/global", apiManageLocks, APIAdminDeleteLock)
+            apiAdmin.PUT("/locks/users/:id", apiManageLocks, APIAdminSetLock)
+            apiAdmin.DELETE("/locks/users/:id", apiManageLocks, APIAdminDeleteLock)
+            
+            // scopes only limit personal access tokens, a JWT has all of them
+            readLogs := RequireScope(ScopeWorkLogsRead)
+            writeLogs := RequireScope(ScopeWorkLogsWrite)
+            readProjects := RequireScope(ScopeProjectsRead)
+            writeProjects := RequireScope(ScopeProjectsWrite)
+            readInvoices := RequireScope(ScopeInvoicesRead)
+            writeInvoices := RequireScope(ScopeInvoicesWrite)
+            
             // Worklogs
-            apiAuth.GET("/worklogs", APIGetWorkLogs)
-            apiAuth.POST("/worklogs", APICreateWorkLog)
-            apiAuth.PUT("/worklogs/:id", APIUpdateWorkLog)
-            apiAuth.DELETE("/worklogs/:id", APIDeleteWorkLog)
+            apiAuth.GET("/worklogs", readLogs, APIGetWorkLogs)
+            apiAuth.POST("/worklogs", writeLogs, APICreateWorkLog)
+            apiAuth.PUT("/worklogs/:id", writeLogs, WorkLogOwnerRequired(APIWorkLogNotFound), APIUpdateWorkLog)
+            apiAuth.DELETE("/worklogs/:id", writeLogs, WorkLogOwnerRequired(APIWorkLogNotFound), APIDeleteWorkLog)
+            apiAuth.GET("/worklogs/export", readLogs, APIExportWorkLogs)
+            apiAuth.GET("/worklogs/history", readLogs, APIGetWorkLogHistory)
+            apiAuth.GET("/worklogs/:id/history", readLogs, APIGetWorkLogVersions)
+            apiAuth.POST("/worklogs/history/:id/restore", writeLogs, APIRestoreWorkLog)
+            apiAuth.POST("/worklogs/import", writeLogs, APIImportWorkLogs)
+            apiAuth.GET("/worklogs/trash", readLogs, APIGetTrash)
+            apiAuth.POST("/worklogs/trash/:id/restore", writeLogs, APIRestoreTrash)
+            apiAuth.DELETE("/worklogs/trash/:id", writeLogs, APIPurgeTrash)
+            
+            apiAuth.GET("/tags", readLogs, APIGetTags)
+            
+            // Weekly approval: reviews need a JWT
+            apiTimesheet := TimesheetAccessRequired(APITimesheetNotFound)
+            apiAuth.GET("/timesheets", readLogs, APIGetTimesheets)
+            apiAuth.POST("/timesheets", writeLogs, APISubmitTimesheet)
+            apiAuth.GET("/timesheets/queue", readLogs, APIGetTimesheetQueue)
+            apiAuth.GET("/timesheets/:id", readLogs, apiTimesheet, APIGetTimesheet)
+            apiAuth.POST("/timesheets/:id/review", JWTRequired(), apiTimesheet, APIReviewTimesheet)
+            
+            // Timer
+            apiAuth.GET("/timer", readLogs, APIGetTimer)
+            apiAuth.POST("/timer/start", writeLogs, APIStartTimer)
+            apiAuth.POST("/timer/stop", writeLogs, APIStopTimer)
+            apiAuth.DELETE("/timer", writeLogs, APIDiscardTimer)
+            
+            // Projects + clients
+            apiAuth.GET("/projects", readProjects, APIGetProjects)
+            apiAuth.POST("/projects", writeProjects, APICreateProject)
+            apiAuth.GET("/projects/:id", readProjects, APIGetProject)
+            apiAuth.PUT("/projects/:id", writeProjects, APIUpdateProject)
+            apiAuth.DELETE("/projects/:id", writeProjects, APIDeleteProject)
+            apiAuth.GET("/clients", readProjects, APIGetClients)
+            apiAuth.POST("/clients", writeProjects, APICreateClient)
+            apiAuth.PUT("/clients/:id", writeProjects, APIUpdateClient)
+            apiAuth.DELETE("/clients/:id", writeProjects, APIDeleteClient)
+            
+            // Hourly rates
+            apiAuth.GET("/rates", readProjects, APIGetRates)
+            apiAuth.POST("/rates", writeProjects, APICreateRate)
+            apiAuth.DELETE("/rates/:id", writeProjects, APIDeleteRate)
+            
+            // Invoices
+            apiAuth.GET("/invoices", readInvoices, APIGetInvoices)
+            apiAuth.POST("/invoices", writeInvoices, APICreateInvoice)
+            apiAuth.GET("/invoices/:id", readInvoices, APIGetInvoice)
+            apiAuth.GET("/invoices/:id/pdf", readInvoices, APIInvoicePDF)
+            apiAuth.GET("/invoices/:id/xlsx", readInvoices, APIInvoiceXLSX)
+            apiAuth.POST("/invoices/:id/cancel", writeInvoices, APICancelInvoice)
             
             // Statistics
-            apiAuth.GET("/stats", APIGetStats)
+            apiAuth.GET("/stats", readLogs, APIGetStats)
+            
+            // Teams: managers read their teams, admins manage all (JWT only);
+            // a team the user may not see is 404 before any permission check
+            apiTeams := apiAuth.Group("/teams")
+            apiViewTeams := RequirePermission(PermViewTeams, APIForbidden)
+            apiManageTeams := RequirePermission(PermManageTeams, APIForbidden)
+            apiTeamViewer := TeamViewerRequired(APITeamNotFound)
+            apiTeams.GET("", readLogs, apiViewTeams, APIGetTeams)
+            apiTeams.POST("", JWTRequired(), apiManageTeams, APICreateTeam)
+            apiTeams.GET("/:id", readLogs, apiTeamViewer, APIGetTeam)
+            apiTeams.DELETE("/:id", JWTRequired(), apiTeamViewer, apiManageTeams, APIDeleteTeam)
+            apiTeams.POST("/:id/members", JWTRequired(), apiTeamViewer, apiManageTeams, APISetTeamMember)
+            apiTeams.DELETE("/:id/members/:user_id", JWTRequired(), apiTeamViewer, apiManageTeams, APIRemoveTeamMember)
+            apiTeams.GET("/:id/stats", readLogs, apiTeamViewer, APIGetTeamStats)
+            apiTeams.GET("/:id/worklogs", readLogs, apiTeamViewer, APIGetTeamWorkLogs)
         }
     }
- 
-    log.Println("🚀 up see there http://localhost:8080")
-    log.Println("📡 API has to be available there http://localhost:8080/api/v1")
-    r.Run(":8080")
+    return r, nil
 }
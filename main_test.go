@@ -0,0 +1,261 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+
+    embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+    "github.com/gin-gonic/gin"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// password of every test user
+const testPassword = "secret12"
+
+func TestMain(m *testing.M) {
+    gin.SetMode(gin.TestMode)
+    gin.DefaultWriter = io.Discard
+    log.SetOutput(io.Discard)
+    passwordCost = bcrypt.MinCost
+    code := m.Run()
+    if testPostgres.stop != nil {
+        testPostgres.stop()
+    }
+    os.Exit(code)
+}
+
+// default config and a migrated sqlite file of its own for the test
+func setupTestDB(t *testing.T) {
+    t.Helper()
+    openTestDB(t, DialectSQLite, filepath.Join(t.TempDir(), "test.db"))
+}
+
+func openTestDB(t *testing.T, driver, dsn string) {
+    t.Helper()
+    config = DefaultConfig()
+    config.GinMode = gin.TestMode
+    config.DatabaseDriver = driver
+    if err := OpenDB(driver, dsn); err != nil {
+        t.Fatalf("open %s: %v", driver, err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    migrator, err := NewMigrator(db)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := migrator.Up(0); err != nil {
+        t.Fatalf("migrate %s: %v", driver, err)
+    }
+}
+
+// Postgres for the tests: TEST_POSTGRES_DSN, otherwise an embedded server that is
+// started on first use (its binaries are downloaded once into ~/.embedded-postgres-go)
+var testPostgres struct {
+    once    sync.Once
+    dsn     string
+    err     error
+    stop    func()
+    schemas atomic.Int64
+}
+
+// same as setupTestDB on a schema of its own in Postgres, skipped when there is none
+func setupPostgresTestDB(t *testing.T) {
+    t.Helper()
+    dsn := os.Getenv("TEST_POSTGRES_DSN")
+    if dsn == "" {
+        if testing.Short() {
+            t.Skip("postgres: no TEST_POSTGRES_DSN and -short")
+        }
+        testPostgres.once.Do(startTestPostgres)
+        if testPostgres.err != nil {
+            t.Skipf("postgres: no TEST_POSTGRES_DSN and embedded postgres did not start: %v", testPostgres.err)
+        }
+        dsn = testPostgres.dsn
+    }
+
+    admin, err := sql.Open("postgres", dsn)
+    if err != nil {
+        t.Fatal(err)
+    }
+    schema := fmt.Sprintf("tracker_test_%d_%d", os.Getpid(), testPostgres.schemas.Add(1))
+    if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+        admin.Close()
+        t.Fatalf("postgres: %v", err)
+    }
+    // registered first, so it runs after db.Close of openTestDB
+    t.Cleanup(func() {
+        admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+        admin.Close()
+    })
+
+    // unknown keys are run-time parameters for lib/pq
+    if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+        q := u.Query()
+        q.Set("search_path", schema)
+        u.RawQuery = q.Encode()
+        dsn = u.String()
+    } else {
+        dsn += " search_path=" + schema
+    }
+    openTestDB(t, DialectPostgres, dsn)
+}
+
+func startTestPostgres() {
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        testPostgres.err = err
+        return
+    }
+    port := l.Addr().(*net.TCPAddr).Port
+    l.Close()
+
+    dir, err := os.MkdirTemp("", "tracker-postgres")
+    if err != nil {
+        testPostgres.err = err
+        return
+    }
+    cfg := embeddedpostgres.DefaultConfig().
+        Version(embeddedpostgres.V16).
+        Port(uint32(port)).
+        RuntimePath(filepath.Join(dir, "runtime")).
+        DataPath(filepath.Join(dir, "data")).
+        Logger(io.Discard)
+    pg := embeddedpostgres.NewDatabase(cfg)
+    if err := pg.Start(); err != nil {
+        os.RemoveAll(dir)
+        testPostgres.err = err
+        return
+    }
+    testPostgres.dsn = cfg.GetConnectionURL() + "?sslmode=disable"
+    testPostgres.stop = func() {
+        pg.Stop()
+        os.RemoveAll(dir)
+    }
+}
+
+// database and all routes
+func setupTestServer(t *testing.T) http.Handler {
+    t.Helper()
+    setupTestDB(t)
+    r, err := setupRouter()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return r
+}
+
+func createTestUser(t *testing.T, username string) *User {
+    t.Helper()
+    if err := CreateUser(username, testPassword); err != nil {
+        t.Fatalf("create user %s: %v", username, err)
+    }
+    user, err := userStore.GetByUsername(username)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return user
+}
+
+func createTestWorkLog(t *testing.T, log WorkLog) *WorkLog {
+    t.Helper()
+    if err := worklogStore.Create(&log, &Audit{ActorID: log.UserID, ActorName: "test", Channel: ChannelSystem}); err != nil {
+        t.Fatalf("create worklog: %v", err)
+    }
+    return &log
+}
+
+// requests against the router with the cookies of earlier answers (web) or a bearer token (API)
+type testClient struct {
+    t       *testing.T
+    router  http.Handler
+    cookies map[string]*http.Cookie
+    token   string
+}
+
+func newTestClient(t *testing.T, router http.Handler) *testClient {
+    return &testClient{t: t, router: router, cookies: make(map[string]*http.Cookie)}
+}
+
+func (c *testClient) do(method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+    c.t.Helper()
+    req := httptest.NewRequest(method, path, body)
+    if contentType != "" {
+        req.Header.Set("Content-Type", contentType)
+    }
+    for _, cookie := range c.cookies {
+        req.AddCookie(cookie)
+    }
+    if c.token != "" {
+        req.Header.Set("Authorization", "Bearer "+c.token)
+    }
+
+    w := httptest.NewRecorder()
+    c.router.ServeHTTP(w, req)
+    for _, cookie := range w.Result().Cookies() {
+        if cookie.MaxAge < 0 {
+            delete(c.cookies, cookie.Name)
+        } else {
+            c.cookies[cookie.Name] = cookie
+        }
+    }
+    return w
+}
+
+func (c *testClient) get(path string) *httptest.ResponseRecorder {
+    c.t.Helper()
+    return c.do(http.MethodGet, path, "", nil)
+}
+
+func (c *testClient) postForm(path string, values url.Values) *httptest.ResponseRecorder {
+    c.t.Helper()
+    return c.do(http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+func (c *testClient) sendJSON(method, path string, body interface{}) *httptest.ResponseRecorder {
+    c.t.Helper()
+    data, err := json.Marshal(body)
+    if err != nil {
+        c.t.Fatal(err)
+    }
+    return c.do(method, path, "application/json", strings.NewReader(string(data)))
+}
+
+// session of username after the login form
+func loginWeb(t *testing.T, router http.Handler, username string) *testClient {
+    t.Helper()
+    c := newTestClient(t, router)
+    w := c.postForm("/login", url.Values{"username": {username}, "password": {testPassword}})
+    if w.Code != http.StatusFound || w.Header().Get("Location") != "/dashboard" {
+        t.Fatalf("web login %s: %d %s", username, w.Code, w.Body.String())
+    }
+    return c
+}
+
+// access token of username from POST /api/v1/auth/login
+func loginAPI(t *testing.T, router http.Handler, username string) *testClient {
+    t.Helper()
+    c := newTestClient(t, router)
+    w := c.sendJSON(http.MethodPost, "/api/v1/auth/login", gin.H{"username": username, "password": testPassword})
+    var resp struct {
+        Token string `json:"token"`
+    }
+    if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Token == "" {
+        t.Fatalf("api login %s: %d %s", username, w.Code, w.Body.String())
+    }
+    c.token = resp.Token
+    return c
+}
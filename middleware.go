@@ -6,10 +6,9 @@ import (
     "time"
 )
 
-const inactivityTimeout = 30 * time.Minute // 30 
-
 // Middleware check is it authenticity 
-func CheckInactivity() gin.HandlerFunc {
+// timeout comes from config (INACTIVITY_TIMEOUT, 30m by default)
+func CheckInactivity(inactivityTimeout time.Duration) gin.HandlerFunc {
     return func(c *gin.Context) {
         session := sessions.Default(c)
         lastActivity := session.Get("last_activity")
@@ -19,7 +18,7 @@ func CheckInactivity() gin.HandlerFunc {
             if ok {
                 elapsed := time.Since(time.Unix(lastTime, 0))
                 
-                // if more than 30 minutes - go out 
+                // if more than timeout - go out 
                 if elapsed > inactivityTimeout {
                     session.Clear()
                     session.Save()
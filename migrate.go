@@ -0,0 +1,361 @@
+package main
+
+import (
+    "embed"
+    "flag"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// migrations/<dialect>/NNNN_name.up.sql + migrations/<dialect>/NNNN_name.down.sql,
+// both dialects must have the same versions
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+    Version int
+    Name    string
+    Up      string
+    Down    string
+}
+
+// all embedded migrations for dialect, ordered by version
+func LoadMigrations(dialect string) ([]Migration, error) {
+    dir := "migrations/" + dialect + "/"
+    files, err := fs.Glob(migrationFiles, dir+"*.sql")
+    if err != nil {
+        return nil, err
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("no migrations for %q", dialect)
+    }
+
+    byVersion := make(map[int]*Migration)
+    for _, file := range files {
+        base := strings.TrimPrefix(file, dir)
+
+        var direction string
+        switch {
+        case strings.HasSuffix(base, ".up.sql"):
+            direction = "up"
+        case strings.HasSuffix(base, ".down.sql"):
+            direction = "down"
+        default:
+            return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", base)
+        }
+
+        stem := strings.TrimSuffix(base, "."+direction+".sql")
+        versionStr, name, ok := strings.Cut(stem, "_")
+        if !ok {
+            return nil, fmt.Errorf("migration %s: expected NNNN_name", base)
+        }
+        version, err := strconv.Atoi(versionStr)
+        if err != nil || version <= 0 {
+            return nil, fmt.Errorf("migration %s: bad version", base)
+        }
+
+        body, err := migrationFiles.ReadFile(file)
+        if err != nil {
+            return nil, err
+        }
+
+        m := byVersion[version]
+        if m == nil {
+            m = &Migration{Version: version, Name: name}
+            byVersion[version] = m
+        }
+        if m.Name != name {
+            return nil, fmt.Errorf("migration %d: names differ (%s / %s)", version, m.Name, name)
+        }
+        if direction == "up" {
+            m.Up = string(body)
+        } else {
+            m.Down = string(body)
+        }
+    }
+
+    var migrations []Migration
+    for _, m := range byVersion {
+        if m.Up == "" {
+            return nil, fmt.Errorf("migration %d_%s: up file missing", m.Version, m.Name)
+        }
+        migrations = append(migrations, *m)
+    }
+    sort.Slice(migrations, func(i, j int) bool {
+        return migrations[i].Version < migrations[j].Version
+    })
+    return migrations, nil
+}
+
+// applies migrations and keeps track of them in schema_version
+type Migrator struct {
+    db         *DB
+    migrations []Migration
+    DryRun     bool      // only print what would run
+    Log        io.Writer // progress output, may be nil
+}
+
+func NewMigrator(db *DB) (*Migrator, error) {
+    migrations, err := LoadMigrations(db.Dialect)
+    if err != nil {
+        return nil, err
+    }
+    return &Migrator{db: db, migrations: migrations, Log: io.Discard}, nil
+}
+
+func (m *Migrator) logf(format string, args ...interface{}) {
+    if m.Log != nil {
+        fmt.Fprintf(m.Log, format+"\n", args...)
+    }
+}
+
+func (m *Migrator) ensureVersionTable() error {
+    _, err := m.db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_version (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TEXT NOT NULL
+        )
+    `)
+    return err
+}
+
+// applied versions, ascending
+func (m *Migrator) Applied() ([]int, error) {
+    if err := m.ensureVersionTable(); err != nil {
+        return nil, err
+    }
+
+    rows, err := m.db.Query("SELECT version FROM schema_version ORDER BY version")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var versions []int
+    for rows.Next() {
+        var v int
+        if err := rows.Scan(&v); err != nil {
+            return nil, err
+        }
+        versions = append(versions, v)
+    }
+    return versions, rows.Err()
+}
+
+// highest applied version, 0 for an empty database
+func (m *Migrator) Current() (int, error) {
+    applied, err := m.Applied()
+    if err != nil || len(applied) == 0 {
+        return 0, err
+    }
+    return applied[len(applied)-1], nil
+}
+
+// latest known version
+func (m *Migrator) Latest() int {
+    if len(m.migrations) == 0 {
+        return 0
+    }
+    return m.migrations[len(m.migrations)-1].Version
+}
+
+// migrations not applied yet, in order
+func (m *Migrator) Pending() ([]Migration, error) {
+    applied, err := m.Applied()
+    if err != nil {
+        return nil, err
+    }
+    done := make(map[int]bool)
+    for _, v := range applied {
+        done[v] = true
+    }
+
+    var pending []Migration
+    for _, mig := range m.migrations {
+        if !done[mig.Version] {
+            pending = append(pending, mig)
+        }
+    }
+    return pending, nil
+}
+
+// apply pending migrations up to target (0 = latest)
+func (m *Migrator) Up(target int) error {
+    pending, err := m.Pending()
+    if err != nil {
+        return err
+    }
+
+    for _, mig := range pending {
+        if target > 0 && mig.Version > target {
+            break
+        }
+        if err := m.apply(mig, mig.Up, true); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// roll back applied migrations, newest first, while version > target
+func (m *Migrator) Down(target int) error {
+    applied, err := m.Applied()
+    if err != nil {
+        return err
+    }
+
+    byVersion := make(map[int]Migration)
+    for _, mig := range m.migrations {
+        byVersion[mig.Version] = mig
+    }
+
+    for i := len(applied) - 1; i >= 0 && applied[i] > target; i-- {
+        mig, ok := byVersion[applied[i]]
+        if !ok {
+            return fmt.Errorf("migration %d is applied but unknown to this binary", applied[i])
+        }
+        if mig.Down == "" {
+            return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+        }
+        if err := m.apply(mig, mig.Down, false); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// one migration = one transaction (schema + schema_version row)
+func (m *Migrator) apply(mig Migration, body string, up bool) error {
+    direction := "down"
+    if up {
+        direction = "up"
+    }
+
+    if m.DryRun {
+        m.logf("-- [dry-run] %s %04d_%s\n%s", direction, mig.Version, mig.Name, strings.TrimSpace(body))
+        return nil
+    }
+
+    tx, err := m.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(body); err != nil {
+        return fmt.Errorf("migration %04d_%s %s: %w", mig.Version, mig.Name, direction, err)
+    }
+
+    if up {
+        _, err = tx.Exec(
+            "INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+            mig.Version, mig.Name, time.Now().UTC().Format(time.RFC3339),
+        )
+    } else {
+        _, err = tx.Exec("DELETE FROM schema_version WHERE version = ?", mig.Version)
+    }
+    if err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    m.logf("%s %04d_%s", direction, mig.Version, mig.Name)
+    return nil
+}
+
+// one line per migration: version, name, applied or pending
+func (m *Migrator) Status(w io.Writer) error {
+    applied, err := m.Applied()
+    if err != nil {
+        return err
+    }
+    done := make(map[int]bool)
+    for _, v := range applied {
+        done[v] = true
+    }
+
+    for _, mig := range m.migrations {
+        state := "pending"
+        if done[mig.Version] {
+            state = "applied"
+        }
+        fmt.Fprintf(w, "%04d_%-40s %s\n", mig.Version, mig.Name, state)
+    }
+    return nil
+}
+
+// "migrate" subcommand: migrate [-dry-run] [-to N] up|down|status
+func runMigrateCommand(args []string) error {
+    flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+    dryRun := flags.Bool("dry-run", false, "print SQL instead of running it")
+    to := flags.Int("to", -1, "target version (up: default latest, down: default one step back)")
+    flags.Usage = func() {
+        fmt.Fprintln(flags.Output(), "usage: migrate [-dry-run] [-to N] up|down|status")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        flags.Usage()
+        return fmt.Errorf("migrate: expected exactly one of up, down, status")
+    }
+
+    if err := OpenDB(config.DatabaseDriver, config.DatabaseDSN()); err != nil {
+        return err
+    }
+    defer db.Close()
+
+    migrator, err := NewMigrator(db)
+    if err != nil {
+        return err
+    }
+    migrator.DryRun = *dryRun
+    migrator.Log = os.Stdout
+
+    switch flags.Arg(0) {
+    case "up":
+        target := *to
+        if target < 0 {
+            target = 0
+        }
+        return migrator.Up(target)
+    case "down":
+        target := *to
+        if target < 0 {
+            // one step back
+            applied, err := migrator.Applied()
+            if err != nil {
+                return err
+            }
+            if len(applied) == 0 {
+                fmt.Println("nothing to roll back")
+                return nil
+            }
+            target = 0
+            if len(applied) > 1 {
+                target = applied[len(applied)-2]
+            }
+        }
+        return migrator.Down(target)
+    case "status":
+        current, err := migrator.Current()
+        if err != nil {
+            return err
+        }
+        fmt.Printf("current version: %d, latest: %d\n", current, migrator.Latest())
+        return migrator.Status(os.Stdout)
+    default:
+        flags.Usage()
+        return fmt.Errorf("migrate: unknown command %q", flags.Arg(0))
+    }
+}
@@ -0,0 +1,17 @@
+CREATE TABLE clients (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE projects (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    client_id INTEGER REFERENCES clients(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+ALTER TABLE worklogs ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+CREATE INDEX idx_worklogs_project ON worklogs (project_id);
@@ -0,0 +1,7 @@
+-- one running timer per user, row is deleted when the timer is stopped
+CREATE TABLE timers (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
+    description TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE worklog_tags (
+    worklog_id INTEGER NOT NULL REFERENCES worklogs(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (worklog_id, tag_id)
+);
+CREATE INDEX idx_worklog_tags_tag ON worklog_tags (tag_id);
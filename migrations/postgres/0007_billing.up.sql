@@ -0,0 +1,13 @@
+ALTER TABLE worklogs ADD COLUMN billable BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- project_id set: rate of the project, client_id set: rate of the client,
+-- both NULL: default rate of the user. effective_from NULL = from the beginning
+CREATE TABLE hourly_rates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    client_id INTEGER REFERENCES clients(id) ON DELETE CASCADE,
+    rate DOUBLE PRECISION NOT NULL,
+    effective_from DATE
+);
+CREATE INDEX idx_hourly_rates_user ON hourly_rates (user_id);
@@ -0,0 +1,51 @@
+CREATE TABLE invoices (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    client_id INTEGER REFERENCES clients(id) ON DELETE SET NULL,
+    client_name TEXT NOT NULL,
+    number TEXT NOT NULL,
+    period_from DATE NOT NULL,
+    period_to DATE NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    currency TEXT NOT NULL,
+    total_hours DOUBLE PRECISION NOT NULL,
+    total_amount DOUBLE PRECISION NOT NULL,
+    -- number "YYYY-NNNN" as numbers
+    year INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    -- invoices are cancelled, not deleted: the number and the lines stay, the worklogs are freed
+    status TEXT NOT NULL DEFAULT 'issued',
+    cancelled_at TIMESTAMPTZ,
+    UNIQUE (user_id, number)
+);
+CREATE UNIQUE INDEX idx_invoices_seq ON invoices (user_id, year, seq);
+
+-- last number given per user and year; it only grows, so a cancelled invoice never gives its number away
+CREATE TABLE invoice_counters (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    year INTEGER NOT NULL,
+    last_seq INTEGER NOT NULL,
+    PRIMARY KEY (user_id, year)
+);
+
+-- copy of the billed worklogs: the invoice stays the same when rates, projects or worklogs change
+CREATE TABLE invoice_lines (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    worklog_id INTEGER,
+    date DATE NOT NULL,
+    project TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    hours DOUBLE PRECISION NOT NULL,
+    rate DOUBLE PRECISION NOT NULL,
+    amount DOUBLE PRECISION NOT NULL
+);
+CREATE INDEX idx_invoice_lines_invoice ON invoice_lines (invoice_id);
+
+-- set = billed, the worklog can not be changed any more
+ALTER TABLE worklogs ADD COLUMN invoice_id INTEGER REFERENCES invoices(id) ON DELETE SET NULL;
+CREATE INDEX idx_worklogs_invoice ON worklogs (invoice_id);
+
+-- changed on every create/update/restore; an invoice only takes worklogs unchanged since its draft
+ALTER TABLE worklogs ADD COLUMN updated_at TIMESTAMPTZ;
+UPDATE worklogs SET updated_at = date_trunc('second', NOW());
@@ -0,0 +1,19 @@
+-- rotating refresh tokens, only the sha256 of the token is stored;
+-- all tokens of one login share family_id, reuse of an old one revokes the family
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    family_id TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    issued_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- access tokens revoked before they expire (logout), checked by jti
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
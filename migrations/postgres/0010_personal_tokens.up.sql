@@ -0,0 +1,14 @@
+-- long lived tokens for scripts, only the sha256 of the token is stored;
+-- scopes are space separated, e.g. "worklogs:read projects:write"
+CREATE TABLE personal_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL,
+    token_prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ
+);
+CREATE INDEX idx_personal_tokens_user ON personal_tokens (user_id);
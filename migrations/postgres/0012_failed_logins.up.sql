@@ -0,0 +1,14 @@
+-- every failed login (wrong password, wrong 2FA code, blocked by the throttle);
+-- username is what was typed (lowercase), it may not exist.
+-- cleared = true after a successful login of that username, only uncleared rows count for the lockout
+CREATE TABLE failed_logins (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL,
+    cleared BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX idx_failed_logins_username ON failed_logins (username, attempted_at);
+CREATE INDEX idx_failed_logins_ip ON failed_logins (ip, attempted_at);
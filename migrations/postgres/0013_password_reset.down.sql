@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS email_changes;
+DROP TABLE IF EXISTS password_resets;
+ALTER TABLE users DROP COLUMN auth_version;
+DROP INDEX IF EXISTS idx_users_email;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,28 @@
+-- optional, lowercase; where password reset links go
+ALTER TABLE users ADD COLUMN email TEXT;
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+-- +1 on every password change/reset: sessions and JWTs of an older version are rejected
+ALTER TABLE users ADD COLUMN auth_version INTEGER NOT NULL DEFAULT 0;
+
+-- single use reset links, sha256 only
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+CREATE INDEX idx_password_resets_user ON password_resets (user_id);
+
+-- a new email is set only from the link mailed to it, sha256 only
+CREATE TABLE email_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+CREATE INDEX idx_email_changes_user ON email_changes (user_id);
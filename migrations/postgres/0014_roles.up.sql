@@ -0,0 +1,7 @@
+-- user / manager / admin, permissions per role are in roles.go
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+-- disabled accounts can not log in, their sessions and tokens are rejected
+ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- an existing installation needs an admin: the oldest account
+UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users);
@@ -0,0 +1,14 @@
+CREATE TABLE teams (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- role: member = hours are visible to the team's managers, manager = sees the members' hours (read-only)
+CREATE TABLE team_members (
+    team_id INTEGER NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    role TEXT NOT NULL DEFAULT 'member',
+    PRIMARY KEY (team_id, user_id)
+);
+CREATE INDEX idx_team_members_user ON team_members (user_id);
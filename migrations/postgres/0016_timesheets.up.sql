@@ -0,0 +1,25 @@
+-- one row per user and ISO week once the week was submitted;
+-- status: submitted, approved (worklogs of the week are locked), rejected, draft (reopened)
+CREATE TABLE timesheets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    year INTEGER NOT NULL,
+    week INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (user_id, year, week)
+);
+CREATE INDEX idx_timesheets_status ON timesheets (status);
+
+-- every state change: who and when; actor_name stays when the actor is deleted
+CREATE TABLE timesheet_events (
+    id SERIAL PRIMARY KEY,
+    timesheet_id INTEGER NOT NULL REFERENCES timesheets(id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    actor_name TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_timesheet_events_timesheet ON timesheet_events (timesheet_id);
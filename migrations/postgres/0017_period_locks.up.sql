@@ -0,0 +1,12 @@
+-- closed periods: worklogs dated on or before lock_date can not be created, changed or deleted;
+-- user_id NULL = the global lock, otherwise the lock of one user (the later of both applies)
+CREATE TABLE period_locks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER UNIQUE REFERENCES users(id),
+    lock_date DATE NOT NULL,
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- UNIQUE lets any number of NULLs through, one global lock only
+CREATE UNIQUE INDEX idx_period_locks_global ON period_locks ((user_id IS NULL)) WHERE user_id IS NULL;
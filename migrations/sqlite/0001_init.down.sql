@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS worklogs;
+DROP TABLE IF EXISTS users;
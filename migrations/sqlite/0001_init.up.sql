@@ -0,0 +1,15 @@
+-- IF NOT EXISTS: databases created before migrations already have these tables
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS worklogs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    date TEXT NOT NULL,
+    description TEXT,
+    hours REAL NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
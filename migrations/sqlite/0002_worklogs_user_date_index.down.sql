@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_worklogs_user_date;
@@ -0,0 +1,2 @@
+-- every list/report/export query filters by user and date
+CREATE INDEX IF NOT EXISTS idx_worklogs_user_date ON worklogs (user_id, date);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_worklogs_project;
+ALTER TABLE worklogs DROP COLUMN project_id;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS clients;
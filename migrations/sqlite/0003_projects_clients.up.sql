@@ -0,0 +1,21 @@
+CREATE TABLE clients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    client_id INTEGER,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (client_id) REFERENCES clients(id),
+    UNIQUE (user_id, name)
+);
+
+-- no REFERENCES here: SQLite can not DROP a column that is part of a foreign key
+ALTER TABLE worklogs ADD COLUMN project_id INTEGER;
+CREATE INDEX idx_worklogs_project ON worklogs (project_id);
@@ -0,0 +1,8 @@
+-- one running timer per user, row is deleted when the timer is stopped
+CREATE TABLE timers (
+    user_id INTEGER PRIMARY KEY,
+    project_id INTEGER,
+    description TEXT NOT NULL DEFAULT '',
+    started_at TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE worklogs DROP COLUMN break_minutes;
+ALTER TABLE worklogs DROP COLUMN end_time;
+ALTER TABLE worklogs DROP COLUMN start_time;
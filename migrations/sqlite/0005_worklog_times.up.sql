@@ -0,0 +1,4 @@
+-- optional "HH:MM" interval, hours are computed from it when set
+ALTER TABLE worklogs ADD COLUMN start_time TEXT;
+ALTER TABLE worklogs ADD COLUMN end_time TEXT;
+ALTER TABLE worklogs ADD COLUMN break_minutes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE worklog_tags (
+    worklog_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (worklog_id, tag_id),
+    FOREIGN KEY (worklog_id) REFERENCES worklogs(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_worklog_tags_tag ON worklog_tags (tag_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS hourly_rates;
+ALTER TABLE worklogs DROP COLUMN billable;
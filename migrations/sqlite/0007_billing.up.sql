@@ -0,0 +1,16 @@
+ALTER TABLE worklogs ADD COLUMN billable INTEGER NOT NULL DEFAULT 0;
+
+-- project_id set: rate of the project, client_id set: rate of the client,
+-- both NULL: default rate of the user. effective_from NULL = from the beginning
+CREATE TABLE hourly_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    project_id INTEGER,
+    client_id INTEGER,
+    rate REAL NOT NULL,
+    effective_from TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (project_id) REFERENCES projects(id),
+    FOREIGN KEY (client_id) REFERENCES clients(id)
+);
+CREATE INDEX idx_hourly_rates_user ON hourly_rates (user_id);
@@ -0,0 +1,7 @@
+ALTER TABLE worklogs DROP COLUMN updated_at;
+DROP INDEX IF EXISTS idx_worklogs_invoice;
+ALTER TABLE worklogs DROP COLUMN invoice_id;
+DROP TABLE IF EXISTS invoice_lines;
+DROP TABLE IF EXISTS invoice_counters;
+DROP INDEX IF EXISTS idx_invoices_seq;
+DROP TABLE IF EXISTS invoices;
@@ -0,0 +1,55 @@
+CREATE TABLE invoices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    client_id INTEGER,
+    client_name TEXT NOT NULL,
+    number TEXT NOT NULL,
+    period_from TEXT NOT NULL,
+    period_to TEXT NOT NULL,
+    issued_at TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    total_hours REAL NOT NULL,
+    total_amount REAL NOT NULL,
+    -- number "YYYY-NNNN" as numbers
+    year INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    -- invoices are cancelled, not deleted: the number and the lines stay, the worklogs are freed
+    status TEXT NOT NULL DEFAULT 'issued',
+    cancelled_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (client_id) REFERENCES clients(id),
+    UNIQUE (user_id, number)
+);
+CREATE UNIQUE INDEX idx_invoices_seq ON invoices (user_id, year, seq);
+
+-- last number given per user and year; it only grows, so a cancelled invoice never gives its number away
+CREATE TABLE invoice_counters (
+    user_id INTEGER NOT NULL,
+    year INTEGER NOT NULL,
+    last_seq INTEGER NOT NULL,
+    PRIMARY KEY (user_id, year),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- copy of the billed worklogs: the invoice stays the same when rates, projects or worklogs change
+CREATE TABLE invoice_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invoice_id INTEGER NOT NULL,
+    worklog_id INTEGER,
+    date TEXT NOT NULL,
+    project TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    hours REAL NOT NULL,
+    rate REAL NOT NULL,
+    amount REAL NOT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_invoice_lines_invoice ON invoice_lines (invoice_id);
+
+-- set = billed, the worklog can not be changed any more
+ALTER TABLE worklogs ADD COLUMN invoice_id INTEGER;
+CREATE INDEX idx_worklogs_invoice ON worklogs (invoice_id);
+
+-- changed on every create/update/restore; an invoice only takes worklogs unchanged since its draft
+ALTER TABLE worklogs ADD COLUMN updated_at TEXT;
+UPDATE worklogs SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now');
@@ -0,0 +1,20 @@
+-- rotating refresh tokens, only the sha256 of the token is stored;
+-- all tokens of one login share family_id, reuse of an old one revokes the family
+CREATE TABLE refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    family_id TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    issued_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    used_at TEXT,
+    revoked_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- access tokens revoked before they expire (logout), checked by jti
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TEXT NOT NULL
+);
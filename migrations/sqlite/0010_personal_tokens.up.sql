@@ -0,0 +1,15 @@
+-- long lived tokens for scripts, only the sha256 of the token is stored;
+-- scopes are space separated, e.g. "worklogs:read projects:write"
+CREATE TABLE personal_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token_prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    last_used_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_personal_tokens_user ON personal_tokens (user_id);
@@ -0,0 +1,14 @@
+-- every failed login (wrong password, wrong 2FA code, blocked by the throttle);
+-- username is what was typed (lowercase), it may not exist.
+-- cleared = 1 after a successful login of that username, only uncleared rows count for the lockout
+CREATE TABLE failed_logins (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    attempted_at TEXT NOT NULL,
+    cleared INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_failed_logins_username ON failed_logins (username, attempted_at);
+CREATE INDEX idx_failed_logins_ip ON failed_logins (ip, attempted_at);
@@ -0,0 +1,30 @@
+-- optional, lowercase; where password reset links go
+ALTER TABLE users ADD COLUMN email TEXT;
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+-- +1 on every password change/reset: sessions and JWTs of an older version are rejected
+ALTER TABLE users ADD COLUMN auth_version INTEGER NOT NULL DEFAULT 0;
+
+-- single use reset links, sha256 only
+CREATE TABLE password_resets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    used_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_password_resets_user ON password_resets (user_id);
+
+-- a new email is set only from the link mailed to it, sha256 only
+CREATE TABLE email_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    used_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_email_changes_user ON email_changes (user_id);
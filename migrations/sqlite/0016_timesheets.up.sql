@@ -0,0 +1,28 @@
+-- one row per user and ISO week once the week was submitted;
+-- status: submitted, approved (worklogs of the week are locked), rejected, draft (reopened)
+CREATE TABLE timesheets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    year INTEGER NOT NULL,
+    week INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    UNIQUE (user_id, year, week),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_timesheets_status ON timesheets (status);
+
+-- every state change: who and when; actor_name stays when the actor is deleted
+CREATE TABLE timesheet_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timesheet_id INTEGER NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    FOREIGN KEY (timesheet_id) REFERENCES timesheets(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_timesheet_events_timesheet ON timesheet_events (timesheet_id);
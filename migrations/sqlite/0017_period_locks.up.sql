@@ -0,0 +1,13 @@
+-- closed periods: worklogs dated on or before lock_date can not be created, changed or deleted;
+-- user_id NULL = the global lock, otherwise the lock of one user (the later of both applies)
+CREATE TABLE period_locks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER UNIQUE,
+    lock_date TEXT NOT NULL,
+    updated_by TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- UNIQUE lets any number of NULLs through, one global lock only
+CREATE UNIQUE INDEX idx_period_locks_global ON period_locks ((user_id IS NULL)) WHERE user_id IS NULL;
@@ -0,0 +1,20 @@
+-- append-only audit trail of the worklogs: every create / update / delete / restore with the
+-- values before and after (JSON), who, from where and when, and whether an admin wrote into
+-- a closed period. No foreign keys on purpose: the history outlives deleted worklogs.
+CREATE TABLE worklog_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    worklog_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    old_values TEXT,
+    new_values TEXT,
+    restored_from INTEGER,
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    channel TEXT NOT NULL,
+    lock_override INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+CREATE INDEX idx_worklog_history_worklog ON worklog_history (user_id, worklog_id);
+CREATE INDEX idx_worklog_history_created ON worklog_history (user_id, created_at);
@@ -0,0 +1,5 @@
+-- entries still in the trash would come back, so they go for good
+DELETE FROM worklog_tags WHERE worklog_id IN (SELECT id FROM worklogs WHERE deleted_at IS NOT NULL);
+DELETE FROM worklogs WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_worklogs_deleted;
+ALTER TABLE worklogs DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+-- soft delete: set = in the trash, hidden everywhere, purged after TRASH_RETENTION
+ALTER TABLE worklogs ADD COLUMN deleted_at TEXT;
+CREATE INDEX idx_worklogs_deleted ON worklogs (deleted_at);
@@ -3,15 +3,192 @@ package main
 import "time"
 
 type User struct {
-    ID       int
-    Username string
-    Password string
+    ID          int
+    Username    string
+    Password    string
+    TOTPSecret  string // base32, "" = 2FA never set up
+    TOTPEnabled bool   // false while the setup is not confirmed with a code
+    Email       string // "" = not set, no password reset possible
+    AuthVersion int    // sessions and JWTs carry it, a password change increments it
+    Role        string // RoleUser / RoleManager / RoleAdmin
+    Disabled    bool   // set by an admin, no login, sessions and tokens rejected
 }
 
 type WorkLog struct {
+    ID           int
+    UserID       int
+    ProjectID    int // 0 = no project
+    ProjectName  string
+    Date         time.Time
+    StartTime    string // "HH:MM", "" = not set
+    EndTime      string // "HH:MM", "" = not set
+    BreakMinutes int
+    Description  string
+    Hours        float64
+    Billable     bool
+    InvoiceID    int       // 0 = not invoiced yet
+    Tags         []string  // names, sorted
+    DeletedAt    time.Time // zero = not in the trash
+    UpdatedAt    time.Time // last create/update/restore, seconds
+}
+
+type Client struct {
+    ID     int
+    UserID int
+    Name   string
+}
+
+type Project struct {
+    ID         int
+    UserID     int
+    ClientID   int // 0 = no client
+    ClientName string
+    Name       string
+}
+
+type Tag struct {
+    ID     int
+    UserID int
+    Name   string
+}
+
+// hourly rate of a project, of a client or the default rate of the user
+// (ProjectID and ClientID both 0), valid from EffectiveFrom until the next rate of the same scope
+type HourlyRate struct {
+    ID            int
+    UserID        int
+    ProjectID     int
+    ProjectName   string
+    ClientID      int
+    ClientName    string
+    Rate          float64
+    EffectiveFrom time.Time // zero = from the beginning
+}
+
+type Invoice struct {
     ID          int
     UserID      int
+    ClientID    int // 0 once the client is deleted
+    ClientName  string
+    Number      string // "2025-0001", per user and year
+    PeriodFrom  time.Time
+    PeriodTo    time.Time
+    IssuedAt    time.Time
+    Currency    string
+    TotalHours  float64
+    TotalAmount float64
+    Status      string        // InvoiceIssued or InvoiceCancelled
+    CancelledAt time.Time     // zero while issued
+    Lines       []InvoiceLine // only filled by InvoiceStore.Get
+}
+
+// billed worklog as it was when the invoice was issued
+type InvoiceLine struct {
+    WorkLogID   int
     Date        time.Time
+    ProjectName string
     Description string
     Hours       float64
+    Rate        float64
+    Amount      float64
+    UpdatedAt   time.Time // of the worklog in the draft, not saved; Create refuses if it changed since
+}
+
+// running timer, at most one per user
+type Timer struct {
+    UserID      int
+    ProjectID   int
+    ProjectName string
+    Description string
+    StartedAt   time.Time
+}
+
+// server side half of a refresh token, the token itself is only known to the client
+type RefreshToken struct {
+    ID        int
+    UserID    int
+    FamilyID  string // same for all tokens rotated from one login
+    TokenHash string // sha256 hex
+    IssuedAt  time.Time
+    ExpiresAt time.Time
+    UsedAt    time.Time // zero = not rotated yet
+    RevokedAt time.Time // zero = active
+}
+
+// token for scripts and integrations, works like a JWT but only for its scopes
+type PersonalToken struct {
+    ID         int
+    UserID     int
+    Username   string
+    Role       string // of the owner, a token never has more than its user
+    Name       string
+    Prefix     string // first characters of the token, to tell tokens apart in the list
+    Scopes     []string
+    CreatedAt  time.Time
+    ExpiresAt  time.Time
+    LastUsedAt time.Time // zero = never used
+}
+
+type Team struct {
+    ID          int
+    Name        string
+    CreatedAt   time.Time
+    MemberCount int
+}
+
+type TeamMember struct {
+    TeamID   int
+    UserID   int
+    Username string
+    Role     string // TeamRoleMember / TeamRoleManager
+    Disabled bool   // the user account, not the membership
+}
+
+// approval state of one ISO week of a user
+type Timesheet struct {
+    ID        int
+    UserID    int
+    Username  string
+    Year      int
+    Week      int
+    Status    string // TimesheetSubmitted / TimesheetApproved / TimesheetRejected / TimesheetDraft
+    UpdatedAt time.Time
+}
+
+// one state change of a timesheet
+type TimesheetEvent struct {
+    ID          int
+    TimesheetID int
+    FromStatus  string // TimesheetDraft for the first submit
+    ToStatus    string
+    ActorID     int // 0 when the actor was deleted
+    ActorName   string
+    Comment     string
+    CreatedAt   time.Time
+}
+
+// closed period: worklogs dated on or before LockDate are frozen
+type PeriodLock struct {
+    UserID    int // 0 = the global lock
+    Username  string
+    LockDate  time.Time
+    UpdatedBy string
+    UpdatedAt time.Time
+}
+
+// one entry of worklog_history
+type WorkLogChange struct {
+    ID           int
+    WorkLogID    int
+    UserID       int      // owner of the worklog
+    Action       string   // HistoryCreate / HistoryUpdate / HistoryDelete / HistoryRestore / HistoryPurge
+    Old          *WorkLog // nil for create
+    New          *WorkLog // nil for delete and purge
+    RestoredFrom int      // history id the restore came from
+    ActorID      int
+    ActorName    string
+    IP           string
+    Channel      string // ChannelWeb / ChannelAPI / ChannelSystem
+    LockOverride bool   // an admin wrote into a closed period
+    CreatedAt    time.Time
 }
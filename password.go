@@ -0,0 +1,224 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "net/mail"
+    "net/url"
+    "strings"
+    "time"
+)
+
+const (
+    minPasswordLength = 6
+    // a new reset mail for the same user at most this often
+    passwordResetCooldown = 2 * time.Minute
+    // lifetime of the link that confirms a new email
+    emailChangeTTL = 24 * time.Hour
+)
+
+var (
+    ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+    ErrWrongPassword    = errors.New("current password is wrong")
+    ErrInvalidEmail     = errors.New("invalid email")
+)
+
+func ValidatePassword(password string) error {
+    if len(password) < minPasswordLength {
+        return ErrPasswordTooShort
+    }
+    return nil
+}
+
+// "" stays "" (no email), anything else must be a plain address; stored lowercase
+func NormalizeEmail(email string) (string, error) {
+    email = strings.ToLower(strings.TrimSpace(email))
+    if email == "" {
+        return "", nil
+    }
+    addr, err := mail.ParseAddress(email)
+    if err != nil || addr.Address != email || addr.Name != "" {
+        return "", ErrInvalidEmail
+    }
+    return email, nil
+}
+
+// email for reset links, "" removes it at once; a new one waits for the link mailed
+// to it (true). An email of another account gets no mail but the same answer.
+// wrong current passwords count as failed logins, a stolen session can not redirect resets
+func ChangeEmail(user *User, current, email string, a LoginAttempt) (bool, error) {
+    email, err := NormalizeEmail(email)
+    if err != nil {
+        return false, err
+    }
+    a.Username = user.Username
+    if err := CheckLoginAllowed(&a); err != nil {
+        return false, err
+    }
+    defer FinishLoginAttempt(&a)
+    if !CheckPassword(current, user.Password) {
+        RecordLoginFailure(&a, LoginFailPassword)
+        return false, ErrWrongPassword
+    }
+
+    if email == user.Email {
+        return false, nil
+    }
+    if email == "" {
+        return false, userStore.SetEmail(user.ID, "")
+    }
+    return true, RequestEmailChange(user, email)
+}
+
+// mail a confirmation link to the new email of user, nothing if another account has it
+func RequestEmailChange(user *User, email string) error {
+    if _, err := userStore.GetByEmail(email); err != ErrUserNotFound {
+        return err
+    }
+
+    token, err := randomToken()
+    if err != nil {
+        return err
+    }
+    now := time.Now()
+    if err := emailStore.Create(user.ID, email, hashToken(token), now, now.Add(emailChangeTTL)); err != nil {
+        return err
+    }
+
+    link := config.BaseURL + "/email/confirm?token=" + url.QueryEscape(token)
+    body := fmt.Sprintf(`Здравствуйте, %s!
+
+Чтобы указать этот адрес в трекере рабочего времени, откройте ссылку:
+
+%s
+
+Ссылка действует %d ч. На этот адрес будут приходить ссылки для сброса пароля.
+Если вы ничего не меняли, просто проигнорируйте это письмо.
+`, user.Username, link, int(emailChangeTTL.Hours()))
+
+    go func(to string) {
+        if err := mailer.Send(to, "Подтверждение email", body); err != nil {
+            log.Println("email confirmation mail:", err)
+        }
+    }(email)
+    return nil
+}
+
+// the email from the link becomes the user's; ErrEmailTaken if someone got it meanwhile
+func ConfirmEmail(token string) error {
+    userID, email, err := emailStore.Use(hashToken(token), time.Now())
+    if err != nil {
+        return err
+    }
+    return userStore.SetEmail(userID, email)
+}
+
+// the user's other sessions, JWTs, refresh and personal tokens stop working;
+// returns the user with the new auth version for the caller's own session/tokens.
+// wrong current passwords count as failed logins, a stolen session can not guess it
+func ChangePassword(user *User, current, newPassword string, a LoginAttempt) (*User, error) {
+    a.Username = user.Username
+    if err := CheckLoginAllowed(&a); err != nil {
+        return nil, err
+    }
+    defer FinishLoginAttempt(&a)
+    if !CheckPassword(current, user.Password) {
+        RecordLoginFailure(&a, LoginFailPassword)
+        return nil, ErrWrongPassword
+    }
+    if err := ValidatePassword(newPassword); err != nil {
+        return nil, err
+    }
+    return setPassword(user.ID, newPassword)
+}
+
+func setPassword(userID int, password string) (*User, error) {
+    hash, err := HashPassword(password)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := userStore.ReplacePassword(userID, hash); err != nil {
+        return nil, err
+    }
+    return userStore.GetByID(userID)
+}
+
+// mail a reset link if login (username or email) belongs to a user with an email;
+// unknown logins are not an error, the caller answers the same either way
+func RequestPasswordReset(login string) error {
+    login = strings.TrimSpace(login)
+    user, err := userStore.GetByUsername(login)
+    if err == ErrUserNotFound && strings.Contains(login, "@") {
+        user, err = userStore.GetByEmail(strings.ToLower(login))
+    }
+    if err == ErrUserNotFound {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if user.Email == "" {
+        return nil
+    }
+
+    now := time.Now()
+    last, err := resetStore.LastCreated(user.ID)
+    if err != nil {
+        return err
+    }
+    if now.Sub(last) < passwordResetCooldown {
+        return nil
+    }
+
+    token, err := randomToken()
+    if err != nil {
+        return err
+    }
+    if err := resetStore.Create(user.ID, hashToken(token), now, now.Add(config.PasswordResetTTL)); err != nil {
+        return err
+    }
+
+    link := config.BaseURL + "/password/reset?token=" + url.QueryEscape(token)
+    body := fmt.Sprintf(`Здравствуйте, %s!
+
+Для сброса пароля в трекере рабочего времени откройте ссылку:
+
+%s
+
+Ссылка действует %d мин. и работает один раз.
+Если вы не запрашивали сброс, просто проигнорируйте это письмо.
+`, user.Username, link, int(config.PasswordResetTTL.Minutes()))
+
+    // in the background: a slow mail server must not tell apart existing accounts
+    go func(to string) {
+        if err := mailer.Send(to, "Сброс пароля", body); err != nil {
+            log.Println("password reset mail:", err)
+        }
+    }(user.Email)
+    return nil
+}
+
+// link is still usable (for showing the form)
+func CheckResetToken(token string) error {
+    _, err := resetStore.Check(hashToken(token), time.Now())
+    return err
+}
+
+// new password by reset link; the link is used up, everything else is logged out
+func ResetPassword(token, newPassword string) error {
+    if err := ValidatePassword(newPassword); err != nil {
+        return err
+    }
+    userID, err := resetStore.Use(hashToken(token), time.Now())
+    if err != nil {
+        return err
+    }
+    user, err := setPassword(userID, newPassword)
+    if err != nil {
+        return err
+    }
+    // the owner proved access to the mailbox, a lockout from guessing ends here
+    RecordLoginSuccess(LoginAttempt{Username: user.Username})
+    return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+)
+
+type testMail struct {
+    to, subject, body string
+}
+
+// keeps the mails of a test instead of sending them
+type testMailer struct {
+    mails chan testMail
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+    m.mails <- testMail{to, subject, body}
+    return nil
+}
+
+func setupTestMailer(t *testing.T) *testMailer {
+    old := mailer
+    m := &testMailer{mails: make(chan testMail, 10)}
+    mailer = m
+    t.Cleanup(func() { mailer = old })
+    return m
+}
+
+// mails go out in the background, wait for the next one
+func (m *testMailer) next(t *testing.T) testMail {
+    t.Helper()
+    select {
+    case mail := <-m.mails:
+        return mail
+    case <-time.After(5 * time.Second):
+        t.Fatal("no mail sent")
+    }
+    return testMail{}
+}
+
+func (m *testMailer) none(t *testing.T) {
+    t.Helper()
+    select {
+    case mail := <-m.mails:
+        t.Fatalf("unexpected mail to %s: %s", mail.to, mail.subject)
+    case <-time.After(100 * time.Millisecond):
+    }
+}
+
+var testMailToken = regexp.MustCompile(`token=(\S+)`)
+
+func (mail testMail) token(t *testing.T) string {
+    t.Helper()
+    m := testMailToken.FindStringSubmatch(mail.body)
+    if m == nil {
+        t.Fatalf("no link in the mail:\n%s", mail.body)
+    }
+    token, err := url.QueryUnescape(m[1])
+    if err != nil {
+        t.Fatal(err)
+    }
+    return token
+}
+
+func testUserEmail(t *testing.T, userID int) string {
+    t.Helper()
+    user, err := userStore.GetByID(userID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return user.Email
+}
+
+func TestChangeEmailWeb(t *testing.T) {
+    router := setupTestServer(t)
+    mails := setupTestMailer(t)
+    alice := createTestUser(t, "alice")
+    bob := createTestUser(t, "bob")
+    if err := userStore.SetEmail(bob.ID, "bob@example.com"); err != nil {
+        t.Fatal(err)
+    }
+    c := loginWeb(t, router, "alice")
+
+    // a session alone does not point the reset links elsewhere
+    w := c.postForm("/account/email", url.Values{"email": {"mallory@example.com"}, "current_password": {"guess"}})
+    if !strings.Contains(w.Body.String(), "Неверный текущий пароль") {
+        t.Fatalf("wrong password: %d %s", w.Code, w.Body.String())
+    }
+    if n := countFailedLogins(t); n != 1 {
+        t.Fatalf("%d failed logins after a wrong password, want 1", n)
+    }
+    passTestLoginBackoff(t)
+
+    // taken and free addresses get the same answer, only the free one a mail
+    const sent = "На новый адрес отправлена ссылка"
+    w = c.postForm("/account/email", url.Values{"email": {"bob@example.com"}, "current_password": {testPassword}})
+    if !strings.Contains(w.Body.String(), sent) {
+        t.Fatalf("taken email: %d %s", w.Code, w.Body.String())
+    }
+    mails.none(t)
+    w = c.postForm("/account/email", url.Values{"email": {"Alice@Example.com"}, "current_password": {testPassword}})
+    if !strings.Contains(w.Body.String(), sent) {
+        t.Fatalf("new email: %d %s", w.Code, w.Body.String())
+    }
+    mail := mails.next(t)
+    if mail.to != "alice@example.com" {
+        t.Fatalf("confirmation went to %s", mail.to)
+    }
+    if email := testUserEmail(t, alice.ID); email != "" {
+        t.Fatalf("email %q set before the confirmation", email)
+    }
+
+    // the link works without a session, once
+    confirm := "/email/confirm?token=" + url.QueryEscape(mail.token(t))
+    if w := newTestClient(t, router).get(confirm); !strings.Contains(w.Body.String(), "Email подтверждён") {
+        t.Fatalf("confirm: %d %s", w.Code, w.Body.String())
+    }
+    if email := testUserEmail(t, alice.ID); email != "alice@example.com" {
+        t.Fatalf("email after the confirmation: %q", email)
+    }
+    if w := c.get(confirm); !strings.Contains(w.Body.String(), "Ссылка недействительна") {
+        t.Fatalf("second use of the link: %d %s", w.Code, w.Body.String())
+    }
+
+    // removing needs the password too, but no mail
+    w = c.postForm("/account/email", url.Values{"email": {""}, "current_password": {testPassword}})
+    if !strings.Contains(w.Body.String(), "Email сохранён") || testUserEmail(t, alice.ID) != "" {
+        t.Fatalf("remove email: %d %s", w.Code, w.Body.String())
+    }
+    mails.none(t)
+}
+
+func TestChangeEmailAPI(t *testing.T) {
+    router := setupTestServer(t)
+    mails := setupTestMailer(t)
+    alice := createTestUser(t, "alice")
+    bob := createTestUser(t, "bob")
+    if err := userStore.SetEmail(bob.ID, "bob@example.com"); err != nil {
+        t.Fatal(err)
+    }
+    c := loginAPI(t, router, "alice")
+
+    if w := c.sendJSON(http.MethodPut, "/api/v1/account", map[string]string{"email": "mallory@example.com"}); w.Code != http.StatusBadRequest {
+        t.Fatalf("without current password: %d %s", w.Code, w.Body.String())
+    }
+    w := c.sendJSON(http.MethodPut, "/api/v1/account", map[string]string{"email": "mallory@example.com", "current_password": "guess"})
+    if w.Code != http.StatusForbidden {
+        t.Fatalf("wrong password: %d %s", w.Code, w.Body.String())
+    }
+    passTestLoginBackoff(t)
+
+    taken := c.sendJSON(http.MethodPut, "/api/v1/account", map[string]string{"email": "bob@example.com", "current_password": testPassword})
+    mails.none(t)
+    free := c.sendJSON(http.MethodPut, "/api/v1/account", map[string]string{"email": "alice@example.com", "current_password": testPassword})
+    if taken.Code != http.StatusAccepted || free.Code != taken.Code || free.Body.String() != taken.Body.String() {
+        t.Fatalf("taken %d %s, free %d %s", taken.Code, taken.Body.String(), free.Code, free.Body.String())
+    }
+    mail := mails.next(t)
+
+    if w := c.sendJSON(http.MethodPost, "/api/v1/auth/email/confirm", map[string]string{"token": "nope"}); w.Code != http.StatusBadRequest {
+        t.Fatalf("unknown token: %d %s", w.Code, w.Body.String())
+    }
+    if w := c.sendJSON(http.MethodPost, "/api/v1/auth/email/confirm", map[string]string{"token": mail.token(t)}); w.Code != http.StatusNoContent {
+        t.Fatalf("confirm: %d %s", w.Code, w.Body.String())
+    }
+    if resp := decodeTestJSON(t, c.get("/api/v1/account")); resp["email"] != "alice@example.com" {
+        t.Fatalf("account after the confirmation: %v", resp)
+    }
+    if email := testUserEmail(t, alice.ID); email != "alice@example.com" {
+        t.Fatalf("stored email %q", email)
+    }
+}
+
+// a registration does not tell whether the email belongs to someone else either
+func TestRegisterTakenEmail(t *testing.T) {
+    router := setupTestServer(t)
+    mails := setupTestMailer(t)
+    bob := createTestUser(t, "bob")
+    if err := userStore.SetEmail(bob.ID, "bob@example.com"); err != nil {
+        t.Fatal(err)
+    }
+
+    c := newTestClient(t, router)
+    w := c.postForm("/register", url.Values{"username": {"mallory"}, "email": {"bob@example.com"},
+        "password": {testPassword}, "password_confirm": {testPassword}})
+    if w.Code != http.StatusFound {
+        t.Fatalf("register with a taken email: %d %s", w.Code, w.Body.String())
+    }
+    mails.none(t)
+
+    w = c.sendJSON(http.MethodPost, "/api/v1/auth/register", map[string]string{
+        "username": "carol", "email": "carol@example.com", "password": testPassword})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("api register: %d %s", w.Code, w.Body.String())
+    }
+    if mail := mails.next(t); mail.to != "carol@example.com" {
+        t.Fatalf("confirmation went to %s", mail.to)
+    }
+}
+
+// the password change ends other sessions and an email change asked for before it
+func TestChangePassword(t *testing.T) {
+    router := setupTestServer(t)
+    mails := setupTestMailer(t)
+    createTestUser(t, "alice")
+    c := loginWeb(t, router, "alice")
+    other := loginWeb(t, router, "alice")
+
+    c.postForm("/account/email", url.Values{"email": {"mallory@example.com"}, "current_password": {testPassword}})
+    token := mails.next(t).token(t)
+
+    w := c.postForm("/account/password", url.Values{"current_password": {testPassword},
+        "password": {"newsecret"}, "password_confirm": {"newsecret"}})
+    if !strings.Contains(w.Body.String(), "Пароль изменён") {
+        t.Fatalf("change password: %d %s", w.Code, w.Body.String())
+    }
+    if w := c.get("/account"); w.Code != http.StatusOK {
+        t.Fatalf("own session after the change: %d", w.Code)
+    }
+    if w := other.get("/account"); w.Code != http.StatusFound {
+        t.Fatalf("other session after the change: %d", w.Code)
+    }
+    if err := ConfirmEmail(token); err != ErrInvalidEmailToken {
+        t.Fatalf("email change from before the password change: %v", err)
+    }
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    ErrPeriodLocked    = errors.New("period is closed")
+    ErrInvalidLockDate = errors.New("lock_date must be YYYY-MM-DD")
+)
+
+// ErrPeriodLocked with the lock date that applies
+type PeriodLockedError struct {
+    Until time.Time
+}
+
+func (e *PeriodLockedError) Error() string {
+    return fmt.Sprintf("%s up to %s", ErrPeriodLocked, e.Until.Format("2006-01-02"))
+}
+
+func (e *PeriodLockedError) Is(target error) bool {
+    return target == ErrPeriodLocked
+}
+
+// the later of the global and the user's own lock date, zero = nothing closed
+func LockDateFor(userID int) (time.Time, error) {
+    global, own, err := lockStore.LockDates(userID)
+    if own.After(global) {
+        return own, err
+    }
+    return global, err
+}
+
+// lock date as YYYY-MM-DD for the templates, "" if nothing is closed
+func lockDay(userID int) string {
+    until, err := LockDateFor(userID)
+    if err != nil || until.IsZero() {
+        return ""
+    }
+    return until.Format("2006-01-02")
+}
+
+// date must be after the lock date of the user, unless an admin overrides the lock
+func CheckPeriodOpen(userID int, date time.Time, overrideLock bool) error {
+    global, own, err := lockStore.LockDates(userID)
+    if err != nil {
+        return err
+    }
+    return periodOpen(global, own, date, overrideLock)
+}
+
+// the rule behind CheckPeriodOpen, also checked by the store inside its transaction
+func periodOpen(global, own, date time.Time, overrideLock bool) error {
+    until := global
+    if own.After(global) {
+        until = own
+    }
+    // compared as strings, timer dates carry a time of day
+    if until.IsZero() || date.Format("2006-01-02") > until.Format("2006-01-02") || overrideLock {
+        return nil
+    }
+    return &PeriodLockedError{Until: until}
+}
+
+// An admin's explicit request to write into a closed period: true only if asked for
+// and allowed. It goes with the Audit of the change, worklog_history marks its use.
+func lockOverride(c *gin.Context, asked bool) bool {
+    return asked && HasPermission(CurrentRole(c), PermOverrideLocks)
+}
+
+// override_lock=1 from the worklog forms
+func webLockOverride(c *gin.Context) bool {
+    return lockOverride(c, c.PostForm("override_lock") == "1")
+}
+
+// ?override_lock=true on the worklog endpoints
+func apiLockOverride(c *gin.Context) bool {
+    return lockOverride(c, c.Query("override_lock") == "true")
+}
+
+// userID 0 = the global lock
+func SetPeriodLock(actor *User, userID int, date string) error {
+    lockDate, err := time.Parse("2006-01-02", date)
+    if err != nil {
+        return ErrInvalidLockDate
+    }
+    target, err := lockTarget(userID)
+    if err != nil {
+        return err
+    }
+    if err := lockStore.Set(&PeriodLock{UserID: userID, LockDate: lockDate, UpdatedBy: actor.Username}); err != nil {
+        return err
+    }
+    log.Printf("admin %s: lock date %s for %s", actor.Username, date, target)
+    return nil
+}
+
+func ClearPeriodLock(actor *User, userID int) error {
+    target, err := lockTarget(userID)
+    if err != nil {
+        return err
+    }
+    if err := lockStore.Delete(userID); err != nil {
+        return err
+    }
+    log.Printf("admin %s: removed lock date for %s", actor.Username, target)
+    return nil
+}
+
+// name for the log, checks that the user exists
+func lockTarget(userID int) (string, error) {
+    if userID == 0 {
+        return "everyone", nil
+    }
+    user, err := userStore.GetByID(userID)
+    if err != nil {
+        return "", err
+    }
+    return user.Username, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+)
+
+// admins setting the same lock at once leave one row, the global lock included
+func TestSetPeriodLockParallel(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            alice := createTestUser(t, "alice")
+
+            start := make(chan struct{})
+            errs := make(chan error, 20)
+            var wg sync.WaitGroup
+            for i := 0; i < 10; i++ {
+                for _, userID := range []int{0, alice.ID} {
+                    wg.Add(1)
+                    go func(i, userID int) {
+                        defer wg.Done()
+                        <-start
+                        errs <- lockStore.Set(&PeriodLock{UserID: userID, LockDate: time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC),
+                            UpdatedBy: fmt.Sprintf("admin%d", i)})
+                    }(i, userID)
+                }
+            }
+            close(start)
+            wg.Wait()
+            close(errs)
+            for err := range errs {
+                if err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            locks, err := lockStore.List()
+            if err != nil || len(locks) != 2 || locks[0].UserID != 0 || locks[1].UserID != alice.ID {
+                t.Fatalf("locks: %+v %v", locks, err)
+            }
+            if _, err := db.Exec("INSERT INTO period_locks (user_id, lock_date, updated_by, updated_at) VALUES (NULL, ?, 'x', ?)",
+                "2026-02-01", timeValue(time.Now())); err == nil {
+                t.Fatal("second global lock inserted")
+            }
+        })
+    }
+}
+
+// writes into a closed period: refused unless an admin overrides, and then marked in the history
+func TestLockOverride(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    admin := createTestUser(t, "admin")
+    if err := userStore.SetRole(admin.ID, RoleAdmin); err != nil {
+        t.Fatal(err)
+    }
+    user := loginAPI(t, router, "alice")
+    root := loginAPI(t, router, "admin")
+
+    closed := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+    open := time.Now()
+    old := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: closed, Description: "closed", Hours: 1})
+    adminLog := createTestWorkLog(t, WorkLog{UserID: admin.ID, Date: closed, Description: "closed", Hours: 1})
+    openLog := createTestWorkLog(t, WorkLog{UserID: admin.ID, Date: open, Description: "open", Hours: 1})
+
+    // the lock comes after the checks of a request: the store refuses the write itself
+    if err := lockStore.Set(&PeriodLock{LockDate: closed.AddDate(0, 0, 1), UpdatedBy: "admin"}); err != nil {
+        t.Fatal(err)
+    }
+    changed := *old
+    changed.Hours = 2
+    if err := worklogStore.Update(&changed, &Audit{ActorName: "test", Channel: ChannelSystem}); !errors.Is(err, ErrPeriodLocked) {
+        t.Fatalf("store update in a closed period: %v", err)
+    }
+    if err := worklogStore.Delete(alice.ID, old.ID, &Audit{ActorName: "test", Channel: ChannelSystem}); !errors.Is(err, ErrPeriodLocked) {
+        t.Fatalf("store delete in a closed period: %v", err)
+    }
+
+    body := map[string]interface{}{"date": closed.Format("2006-01-02"), "hours": 3, "description": "changed"}
+    path := fmt.Sprintf("/api/v1/worklogs/%d", old.ID)
+    if w := user.sendJSON(http.MethodPut, path+"?override_lock=true", body); w.Code != http.StatusConflict {
+        t.Fatalf("plain user with override_lock: %d %s", w.Code, w.Body.String())
+    }
+
+    // only the write into the closed period is marked
+    adminPath := fmt.Sprintf("/api/v1/worklogs/%d", adminLog.ID)
+    if w := root.sendJSON(http.MethodPut, adminPath, body); w.Code != http.StatusConflict {
+        t.Fatalf("admin without override_lock: %d %s", w.Code, w.Body.String())
+    }
+    if w := root.sendJSON(http.MethodPut, adminPath+"?override_lock=true", body); w.Code != http.StatusOK {
+        t.Fatalf("admin with override_lock: %d %s", w.Code, w.Body.String())
+    }
+    openBody := map[string]interface{}{"date": open.Format("2006-01-02"), "hours": 2, "description": "open"}
+    if w := root.sendJSON(http.MethodPut, fmt.Sprintf("/api/v1/worklogs/%d?override_lock=true", openLog.ID), openBody); w.Code != http.StatusOK {
+        t.Fatalf("admin update outside the closed period: %d %s", w.Code, w.Body.String())
+    }
+
+    lastOverride := func(id int) interface{} {
+        t.Helper()
+        history := decodeTestJSON(t, root.get(fmt.Sprintf("/api/v1/worklogs/%d/history", id)))["history"].([]interface{})
+        return history[len(history)-1].(map[string]interface{})["lock_override"]
+    }
+    if v := lastOverride(adminLog.ID); v != true {
+        t.Fatalf("lock_override of the closed period update: %v", v)
+    }
+    if v := lastOverride(openLog.ID); v != false {
+        t.Fatalf("lock_override of the open period update: %v", v)
+    }
+}
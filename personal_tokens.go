@@ -0,0 +1,140 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// personal tokens start with this, JWTs never do ("eyJ...")
+const personalTokenPrefix = "mtp_"
+
+const maxPersonalTokenDays = 365
+
+// write includes read of the same area
+const (
+    ScopeWorkLogsRead  = "worklogs:read"  // worklogs, tags, stats, timer state
+    ScopeWorkLogsWrite = "worklogs:write" // + create/update/delete worklogs, start/stop timer
+    ScopeProjectsRead  = "projects:read"  // projects, clients, rates
+    ScopeProjectsWrite = "projects:write"
+    ScopeInvoicesRead  = "invoices:read" // invoices with pdf/xlsx
+    ScopeInvoicesWrite = "invoices:write"
+)
+
+// in the order they are shown on the tokens page
+var allScopes = []string{
+    ScopeWorkLogsRead, ScopeWorkLogsWrite,
+    ScopeProjectsRead, ScopeProjectsWrite,
+    ScopeInvoicesRead, ScopeInvoicesWrite,
+}
+
+var (
+    ErrInvalidScope       = errors.New("unknown scope")
+    ErrNoScopes           = errors.New("at least one scope is required")
+    ErrInvalidTokenName   = errors.New("token name is required (max 100 characters)")
+    ErrInvalidTokenExpiry = errors.New("token must expire in 1 to 365 days")
+)
+
+// checks and dedups scopes, keeps the allScopes order
+func ParseScopes(values []string) ([]string, error) {
+    seen := map[string]bool{}
+    for _, v := range values {
+        for _, s := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+            if !isKnownScope(s) {
+                return nil, ErrInvalidScope
+            }
+            seen[s] = true
+        }
+    }
+    var scopes []string
+    for _, s := range allScopes {
+        if seen[s] {
+            scopes = append(scopes, s)
+        }
+    }
+    if len(scopes) == 0 {
+        return nil, ErrNoScopes
+    }
+    return scopes, nil
+}
+
+func isKnownScope(scope string) bool {
+    for _, s := range allScopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// "x:read" is also granted by "x:write"
+func HasScope(scopes []string, scope string) bool {
+    write := strings.TrimSuffix(scope, ":read") + ":write"
+    for _, s := range scopes {
+        if s == scope || s == write {
+            return true
+        }
+    }
+    return false
+}
+
+// the raw token is returned only here, the database keeps its hash
+func CreatePersonalToken(userID int, name string, scopes []string, days int) (string, *PersonalToken, error) {
+    name = strings.TrimSpace(name)
+    if name == "" || len([]rune(name)) > 100 {
+        return "", nil, ErrInvalidTokenName
+    }
+    if days < 1 || days > maxPersonalTokenDays {
+        return "", nil, ErrInvalidTokenExpiry
+    }
+
+    secret, err := randomToken()
+    if err != nil {
+        return "", nil, err
+    }
+    raw := personalTokenPrefix + secret
+    now := time.Now().Truncate(time.Second)
+    t := &PersonalToken{
+        UserID:    userID,
+        Name:      name,
+        Prefix:    raw[:len(personalTokenPrefix)+6],
+        Scopes:    scopes,
+        CreatedAt: now,
+        ExpiresAt: now.AddDate(0, 0, days),
+    }
+    if err := patStore.Create(t, hashToken(raw)); err != nil {
+        return "", nil, err
+    }
+    return raw, t, nil
+}
+
+func isPersonalToken(token string) bool {
+    return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+// route needs scope; JWT requests have every scope
+func RequireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if v, ok := c.Get("token_scopes"); ok && !HasScope(v.([]string), scope) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Token scope " + scope + " required"})
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// token management and logout: personal tokens can not create or revoke tokens
+func JWTRequired() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if _, ok := c.Get("token_scopes"); ok {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed with a personal access token"})
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
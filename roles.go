@@ -0,0 +1,78 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    RoleUser    = "user"    // own worklogs, projects, invoices
+    RoleManager = "manager" // + sees the user list and the hours of the teams they manage
+    RoleAdmin   = "admin"   // + manages users
+)
+
+var allRoles = []string{RoleUser, RoleManager, RoleAdmin}
+
+// what a role may do beyond its own data
+type Permission string
+
+const (
+    PermViewUsers     Permission = "users:view"
+    PermManageUsers   Permission = "users:manage"
+    PermViewTeams     Permission = "teams:view"     // teams with the team role manager
+    PermManageTeams   Permission = "teams:manage"   // every team, members
+    PermManageLocks   Permission = "locks:manage"   // closed periods
+    PermOverrideLocks Permission = "locks:override" // write into a closed period when asked explicitly, marked in worklog_history
+)
+
+var rolePermissions = map[string][]Permission{
+    RoleUser:    {},
+    RoleManager: {PermViewUsers, PermViewTeams},
+    RoleAdmin:   {PermViewUsers, PermManageUsers, PermViewTeams, PermManageTeams, PermManageLocks, PermOverrideLocks},
+}
+
+var ErrInvalidRole = errors.New("role must be user, manager or admin")
+
+func ValidRole(role string) bool {
+    _, ok := rolePermissions[role]
+    return ok
+}
+
+// unknown roles have no permissions
+func HasPermission(role string, p Permission) bool {
+    for _, granted := range rolePermissions[role] {
+        if granted == p {
+            return true
+        }
+    }
+    return false
+}
+
+// role set by AuthRequired (from the db) or JWTAuthMiddleware (from the token)
+func CurrentRole(c *gin.Context) string {
+    return c.GetString("role")
+}
+
+// Middleware like WorkLogOwnerRequired: web and API pass their own answer
+func RequirePermission(p Permission, forbidden gin.HandlerFunc) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !HasPermission(CurrentRole(c), p) {
+            forbidden(c)
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// 403 for web routes
+func WebForbidden(c *gin.Context) {
+    c.String(http.StatusForbidden, "Недостаточно прав")
+}
+
+// 403 for API routes
+func APIForbidden(c *gin.Context) {
+    c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    ErrUserNotFound    = errors.New("user not found")
+    ErrProjectNotFound = errors.New("project not found")
+    ErrClientNotFound  = errors.New("client not found")
+    ErrEmailTaken      = errors.New("email is used by another account")
+    ErrUserDisabled    = errors.New("user is disabled")
+)
+
+// storage behind handlers and API, one code path for both
+type WorkLogStore interface {
+    List(userID int, f WorkLogFilter) ([]WorkLog, error)
+    ListForUsers(userIDs []int, f WorkLogFilter) ([]WorkLog, error) // team views, same filters
+    Each(userID int, f WorkLogFilter, fn func(WorkLog) error) error // List row by row, for exports
+    Get(userID, id int) (*WorkLog, error)
+    // every change is written to worklog_history in the same transaction, a says who made it;
+    // the week status (ErrWeekSubmitted, ErrWeekApproved) and for Create, Update and Restore the
+    // day (ErrOverlap, ErrDayLimit) are checked again in that transaction
+    Create(log *WorkLog, a *Audit) error
+    // in one transaction, for imports: first the projects (ID 0, with a client found or created by
+    // ClientName unless it is empty), logs without ProjectID get the one of the project named ProjectName;
+    // every log is checked like in Create, the first one that fails stops the whole import
+    CreateMany(logs []WorkLog, projects []Project, a *Audit) error
+    Update(log *WorkLog, a *Audit) error   // invoiced worklogs are not touched (ErrWorkLogNotFound)
+    Delete(userID, id int, a *Audit) error // moves it into the trash
+
+    // trash: deleted worklogs, hidden from everything above until purged
+    ListTrash(userID int) ([]WorkLog, error) // last deleted first
+    GetTrashed(userID, id int) (*WorkLog, error)
+    Restore(log *WorkLog, a *Audit) error     // out of the trash with the values of log
+    Purge(userID, id int, a *Audit) error     // deletes a trashed worklog for good
+    PurgeTrash(before time.Time) (int, error) // all worklogs trashed before, for the retention job
+}
+
+type ProjectStore interface {
+    List(userID int) ([]Project, error)
+    Get(userID, id int) (*Project, error)
+    Create(p *Project) error
+    Update(p *Project) error
+    Delete(userID, id int) error // worklogs keep their hours, project_id becomes NULL, rates are removed
+}
+
+type ClientStore interface {
+    List(userID int) ([]Client, error)
+    Get(userID, id int) (*Client, error)
+    Create(cl *Client) error
+    Update(cl *Client) error
+    Delete(userID, id int) error // projects of the client stay, client_id becomes NULL, rates are removed
+}
+
+type UserStore interface {
+    Create(username, passwordHash string) error // role user, admins come from "user add" / "user role"
+    List() ([]User, error)
+    GetByUsername(username string) (*User, error)
+    GetByID(id int) (*User, error)
+    GetByEmail(email string) (*User, error)
+    SetEmail(userID int, email string) error // "" removes it, ErrEmailTaken if another user has it
+    // new password hash, auth_version+1, refresh tokens revoked, personal tokens, reset
+    // links and pending email changes deleted, in one transaction; returns the new auth version
+    ReplacePassword(userID int, passwordHash string) (int, error)
+    // role change and disabling also increment auth_version: sessions and JWTs carry the role
+    SetRole(userID int, role string) error
+    SetDisabled(userID int, disabled bool) error // disabling also revokes refresh tokens
+    // the user and everything that belongs to them, failed_logins stay for the audit
+    Delete(userID int) error
+}
+
+// set in InitDB
+var (
+    worklogStore WorkLogStore
+    projectStore ProjectStore
+    clientStore  ClientStore
+    timerStore   TimerStore
+    tagStore     TagStore
+    rateStore    RateStore
+    invoiceStore InvoiceStore
+    userStore    UserStore
+    tokenStore   TokenStore
+    patStore     PersonalTokenStore
+    mfaStore     TwoFactorStore
+    loginStore   LoginAttemptStore
+    resetStore   PasswordResetStore
+    emailStore   EmailChangeStore
+    teamStore    TeamStore
+    sheetStore   TimesheetStore
+    lockStore    PeriodLockStore
+    historyStore WorkLogHistoryStore
+)
+
+// sort values accepted by WorkLogFilter.Sort
+const (
+    SortDateDesc  = "date_desc"
+    SortDateAsc   = "date_asc"
+    SortHoursDesc = "hours_desc"
+    SortHoursAsc  = "hours_asc"
+)
+
+// filters for list/export/stats; zero value = everything, newest first
+type WorkLogFilter struct {
+    DateFrom       string   // YYYY-MM-DD, inclusive
+    DateTo         string   // YYYY-MM-DD, inclusive
+    Search         string   // substring of description
+    ProjectID      int      // 0 = all projects
+    Tags           []string // entry must have all of them
+    ClientID       int      // 0 = all; entries without project never match a client
+    BillableOnly   bool
+    UninvoicedOnly bool
+    Sort           string
+    Limit          int // 0 = no limit
+    Offset         int
+}
+
+func validSort(sort string) bool {
+    switch sort {
+    case SortDateDesc, SortDateAsc, SortHoursDesc, SortHoursAsc:
+        return true
+    }
+    return false
+}
+
+// date_from, date_to, search, project_id, tag, sort, limit, offset from query string,
+// tag may be repeated or comma separated
+func ParseWorkLogFilter(c *gin.Context) (WorkLogFilter, error) {
+    f := WorkLogFilter{
+        DateFrom: c.Query("date_from"),
+        DateTo:   c.Query("date_to"),
+        Search:   c.Query("search"),
+        Sort:     c.DefaultQuery("sort", SortDateDesc),
+    }
+
+    if v := c.Query("project_id"); v != "" {
+        id, err := strconv.Atoi(v)
+        if err != nil || id < 0 {
+            return f, errors.New("invalid project_id")
+        }
+        f.ProjectID = id
+    }
+
+    tags, err := ParseTags(strings.Join(c.QueryArray("tag"), ","))
+    if err != nil {
+        return f, errors.New("invalid tag")
+    }
+    f.Tags = tags
+
+    for _, d := range []string{f.DateFrom, f.DateTo} {
+        if d == "" {
+            continue
+        }
+        if _, err := time.Parse("2006-01-02", d); err != nil {
+            return f, errors.New("invalid date, expected YYYY-MM-DD")
+        }
+    }
+
+    if !validSort(f.Sort) {
+        return f, errors.New("invalid sort")
+    }
+
+    if v := c.Query("limit"); v != "" {
+        if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+            return f, errors.New("invalid limit")
+        }
+    }
+    if v := c.Query("offset"); v != "" {
+        if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+            return f, errors.New("invalid offset")
+        }
+    }
+    return f, nil
+}
+
+// logs of CreateMany that go to one of its new projects, matched by name like the import does
+func linkNewProjects(logs []WorkLog, projects []Project) {
+    ids := make(map[string]int)
+    for _, p := range projects {
+        ids[strings.ToLower(p.Name)] = p.ID
+    }
+    for i := range logs {
+        if id, ok := ids[strings.ToLower(logs[i].ProjectName)]; ok && logs[i].ProjectID == 0 {
+            logs[i].ProjectID = id
+        }
+    }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var ErrInvalidEmailToken = errors.New("invalid or expired email confirmation link")
+
+type EmailChangeStore interface {
+    // pending change to email; an older pending change of the user is dropped
+    Create(userID int, email, tokenHash string, createdAt, expiresAt time.Time) error
+    // mark the link used and return its user and email; used, expired or unknown = ErrInvalidEmailToken
+    Use(tokenHash string, now time.Time) (int, string, error)
+}
+
+// EmailChangeStore on top of SQLite or Postgres
+type SQLEmailChangeStore struct {
+    db *DB
+}
+
+func NewSQLEmailChangeStore(db *DB) *SQLEmailChangeStore {
+    return &SQLEmailChangeStore{db: db}
+}
+
+func (s *SQLEmailChangeStore) Create(userID int, email, tokenHash string, createdAt, expiresAt time.Time) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // only the newest address counts, a typo is fixed by asking again
+    if _, err := tx.Exec(
+        "DELETE FROM email_changes WHERE user_id = ? OR expires_at < ?", userID, timeValue(createdAt)); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(
+        "INSERT INTO email_changes (user_id, email, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+        userID, email, tokenHash, timeValue(createdAt), timeValue(expiresAt)); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLEmailChangeStore) Use(tokenHash string, now time.Time) (int, string, error) {
+    var userID int
+    var email string
+    err := s.db.QueryRow(
+        `UPDATE email_changes SET used_at = ?
+        WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+        RETURNING user_id, email`,
+        timeValue(now), tokenHash, timeValue(now),
+    ).Scan(&userID, &email)
+    if err == sql.ErrNoRows {
+        return 0, "", ErrInvalidEmailToken
+    }
+    return userID, email, err
+}
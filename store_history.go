@@ -0,0 +1,185 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "time"
+)
+
+var ErrHistoryNotFound = errors.New("history entry not found")
+
+// read side of worklog_history; rows are written by the worklog and timer
+// stores in the transaction of the change (see recordWorkLogChange)
+type WorkLogHistoryStore interface {
+    // changes of one worklog of the user, oldest first
+    ForWorkLog(userID, worklogID int) ([]WorkLogChange, error)
+    // latest changes of the user, newest first
+    Recent(userID, limit int) ([]WorkLogChange, error)
+    Get(userID, id int) (*WorkLogChange, error)
+}
+
+// WorkLogHistoryStore on top of SQLite or Postgres
+type SQLWorkLogHistoryStore struct {
+    db *DB
+}
+
+func NewSQLWorkLogHistoryStore(db *DB) *SQLWorkLogHistoryStore {
+    return &SQLWorkLogHistoryStore{db: db}
+}
+
+// values of a worklog as stored in old_values / new_values
+type worklogSnapshot struct {
+    Date         string   `json:"date"`
+    ProjectID    int      `json:"project_id,omitempty"`
+    ProjectName  string   `json:"project_name,omitempty"`
+    StartTime    string   `json:"start_time,omitempty"`
+    EndTime      string   `json:"end_time,omitempty"`
+    BreakMinutes int      `json:"break_minutes,omitempty"`
+    Description  string   `json:"description"`
+    Hours        float64  `json:"hours"`
+    Billable     bool     `json:"billable"`
+    InvoiceID    int      `json:"invoice_id,omitempty"`
+    Tags         []string `json:"tags,omitempty"`
+}
+
+func snapshotValue(log *WorkLog) (interface{}, error) {
+    if log == nil {
+        return nil, nil
+    }
+    data, err := json.Marshal(worklogSnapshot{
+        Date:         log.Date.Format("2006-01-02"),
+        ProjectID:    log.ProjectID,
+        ProjectName:  log.ProjectName,
+        StartTime:    log.StartTime,
+        EndTime:      log.EndTime,
+        BreakMinutes: log.BreakMinutes,
+        Description:  log.Description,
+        Hours:        log.Hours,
+        Billable:     log.Billable,
+        InvoiceID:    log.InvoiceID,
+        Tags:         log.Tags,
+    })
+    return string(data), err
+}
+
+func parseSnapshot(value sql.NullString, id, userID int) (*WorkLog, error) {
+    if !value.Valid {
+        return nil, nil
+    }
+    var s worklogSnapshot
+    if err := json.Unmarshal([]byte(value.String), &s); err != nil {
+        return nil, err
+    }
+    date, err := time.Parse("2006-01-02", s.Date)
+    if err != nil {
+        return nil, err
+    }
+    return &WorkLog{
+        ID:           id,
+        UserID:       userID,
+        ProjectID:    s.ProjectID,
+        ProjectName:  s.ProjectName,
+        Date:         date,
+        StartTime:    s.StartTime,
+        EndTime:      s.EndTime,
+        BreakMinutes: s.BreakMinutes,
+        Description:  s.Description,
+        Hours:        s.Hours,
+        Billable:     s.Billable,
+        InvoiceID:    s.InvoiceID,
+        Tags:         s.Tags,
+    }, nil
+}
+
+// append one change, q is the transaction of the change itself;
+// overridden = the change went into a closed period (see checkWorkLogWrite)
+func recordWorkLogChange(q querier, action string, before, after *WorkLog, a *Audit, overridden bool) error {
+    if a == nil {
+        a = &Audit{Channel: ChannelSystem}
+    }
+    oldValue, err := snapshotValue(before)
+    if err != nil {
+        return err
+    }
+    newValue, err := snapshotValue(after)
+    if err != nil {
+        return err
+    }
+    if a.RestoreOf != 0 {
+        action = HistoryRestore
+    }
+
+    log := after
+    if log == nil {
+        log = before
+    }
+    _, err = q.Exec(`
+        INSERT INTO worklog_history (worklog_id, user_id, action, old_values, new_values, restored_from,
+            actor_id, actor_name, ip, channel, lock_override, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        log.ID, log.UserID, action, oldValue, newValue, nullID(a.RestoreOf),
+        nullID(a.ActorID), a.ActorName, a.IP, a.Channel, overridden, timeValue(time.Now()))
+    return err
+}
+
+const historySelect = `
+    SELECT id, worklog_id, user_id, action, old_values, new_values, restored_from,
+        actor_id, actor_name, ip, channel, lock_override, created_at
+    FROM worklog_history`
+
+func scanWorkLogChange(row rowScanner) (*WorkLogChange, error) {
+    ch := &WorkLogChange{}
+    var oldValue, newValue sql.NullString
+    var restoredFrom, actorID sql.NullInt64
+    var createdAt dbTime
+    err := row.Scan(&ch.ID, &ch.WorkLogID, &ch.UserID, &ch.Action, &oldValue, &newValue, &restoredFrom,
+        &actorID, &ch.ActorName, &ch.IP, &ch.Channel, &ch.LockOverride, &createdAt)
+    if err != nil {
+        return nil, err
+    }
+    ch.RestoredFrom = int(restoredFrom.Int64)
+    ch.ActorID = int(actorID.Int64)
+    ch.CreatedAt = createdAt.Time
+    if ch.Old, err = parseSnapshot(oldValue, ch.WorkLogID, ch.UserID); err != nil {
+        return nil, err
+    }
+    if ch.New, err = parseSnapshot(newValue, ch.WorkLogID, ch.UserID); err != nil {
+        return nil, err
+    }
+    return ch, nil
+}
+
+func (s *SQLWorkLogHistoryStore) list(query string, args ...interface{}) ([]WorkLogChange, error) {
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var changes []WorkLogChange
+    for rows.Next() {
+        ch, err := scanWorkLogChange(rows)
+        if err != nil {
+            return nil, err
+        }
+        changes = append(changes, *ch)
+    }
+    return changes, rows.Err()
+}
+
+func (s *SQLWorkLogHistoryStore) ForWorkLog(userID, worklogID int) ([]WorkLogChange, error) {
+    return s.list(historySelect+` WHERE user_id = ? AND worklog_id = ? ORDER BY id`, userID, worklogID)
+}
+
+func (s *SQLWorkLogHistoryStore) Recent(userID, limit int) ([]WorkLogChange, error) {
+    return s.list(historySelect+` WHERE user_id = ? ORDER BY id DESC LIMIT ?`, userID, limit)
+}
+
+func (s *SQLWorkLogHistoryStore) Get(userID, id int) (*WorkLogChange, error) {
+    ch, err := scanWorkLogChange(s.db.QueryRow(historySelect+` WHERE id = ? AND user_id = ?`, id, userID))
+    if err == sql.ErrNoRows {
+        return nil, ErrHistoryNotFound
+    }
+    return ch, err
+}
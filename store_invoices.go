@@ -0,0 +1,227 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+)
+
+var (
+    ErrInvoiceNotFound  = errors.New("invoice not found")
+    ErrAlreadyInvoiced  = errors.New("worklog is already invoiced or was changed")
+    ErrInvoiceCancelled = errors.New("invoice is already cancelled")
+)
+
+const (
+    InvoiceIssued    = "issued"
+    InvoiceCancelled = "cancelled" // keeps its number and lines, the worklogs are free again
+)
+
+type InvoiceStore interface {
+    List(userID int) ([]Invoice, error) // newest first, without lines
+    Get(userID, id int) (*Invoice, error)
+    // number the invoice, save it with its lines and mark the worklogs, in one transaction
+    Create(inv *Invoice) error
+    // cancel: the worklogs become editable and can be invoiced again, the number is not given again
+    Cancel(userID, id int) error
+}
+
+// InvoiceStore on top of SQLite or Postgres
+type SQLInvoiceStore struct {
+    db *DB
+}
+
+func NewSQLInvoiceStore(db *DB) *SQLInvoiceStore {
+    return &SQLInvoiceStore{db: db}
+}
+
+const invoiceSelect = `
+    SELECT id, user_id, client_id, client_name, number, period_from, period_to, issued_at,
+        currency, total_hours, total_amount, status, cancelled_at
+    FROM invoices`
+
+func scanInvoice(row rowScanner) (*Invoice, error) {
+    inv := &Invoice{}
+    var clientID sql.NullInt64
+    var from, to dbDate
+    var issuedAt, cancelledAt dbTime
+    err := row.Scan(&inv.ID, &inv.UserID, &clientID, &inv.ClientName, &inv.Number, &from, &to, &issuedAt,
+        &inv.Currency, &inv.TotalHours, &inv.TotalAmount, &inv.Status, &cancelledAt)
+    if err != nil {
+        return nil, err
+    }
+    inv.ClientID = int(clientID.Int64)
+    inv.PeriodFrom = from.Time
+    inv.PeriodTo = to.Time
+    inv.IssuedAt = issuedAt.Time
+    inv.CancelledAt = cancelledAt.Time
+    return inv, nil
+}
+
+func (s *SQLInvoiceStore) List(userID int) ([]Invoice, error) {
+    rows, err := s.db.Query(invoiceSelect+` WHERE user_id = ? ORDER BY issued_at DESC, id DESC`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var invoices []Invoice
+    for rows.Next() {
+        inv, err := scanInvoice(rows)
+        if err != nil {
+            return nil, err
+        }
+        invoices = append(invoices, *inv)
+    }
+    return invoices, rows.Err()
+}
+
+func (s *SQLInvoiceStore) Get(userID, id int) (*Invoice, error) {
+    inv, err := scanInvoice(s.db.QueryRow(invoiceSelect+` WHERE id = ? AND user_id = ?`, id, userID))
+    if err == sql.ErrNoRows {
+        return nil, ErrInvoiceNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := s.db.Query(`
+        SELECT worklog_id, date, project, description, hours, rate, amount
+        FROM invoice_lines WHERE invoice_id = ? ORDER BY date, id`, inv.ID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var line InvoiceLine
+        var worklogID sql.NullInt64
+        var date dbDate
+        err := rows.Scan(&worklogID, &date, &line.ProjectName, &line.Description, &line.Hours, &line.Rate, &line.Amount)
+        if err != nil {
+            return nil, err
+        }
+        line.WorkLogID = int(worklogID.Int64)
+        line.Date = date.Time
+        inv.Lines = append(inv.Lines, line)
+    }
+    return inv, rows.Err()
+}
+
+func (s *SQLInvoiceStore) Create(inv *Invoice) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // next number of the year from the counter row, which is locked until the commit;
+    // a rolled back invoice takes its number back with it, a cancelled one keeps it
+    year := inv.IssuedAt.Year()
+    var seq int
+    err = tx.QueryRow(
+        `INSERT INTO invoice_counters (user_id, year, last_seq) VALUES (?, ?, 1)
+        ON CONFLICT (user_id, year) DO UPDATE SET last_seq = invoice_counters.last_seq + 1
+        RETURNING last_seq`, inv.UserID, year,
+    ).Scan(&seq)
+    if err != nil {
+        return err
+    }
+    inv.Number = fmt.Sprintf("%d-%04d", year, seq)
+    inv.Status = InvoiceIssued
+
+    err = tx.QueryRow(
+        `INSERT INTO invoices (user_id, client_id, client_name, number, year, seq, period_from, period_to, issued_at,
+            currency, total_hours, total_amount)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+        inv.UserID, nullID(inv.ClientID), inv.ClientName, inv.Number, year, seq,
+        inv.PeriodFrom.Format("2006-01-02"), inv.PeriodTo.Format("2006-01-02"), timeValue(inv.IssuedAt),
+        inv.Currency, inv.TotalHours, inv.TotalAmount,
+    ).Scan(&inv.ID)
+    if err != nil {
+        return err
+    }
+
+    // rates as they are now: a rate changed since the draft would bill a different amount
+    rates, err := listRates(tx, inv.UserID)
+    if err != nil {
+        return err
+    }
+    projects, err := listProjects(tx, inv.UserID)
+    if err != nil {
+        return err
+    }
+    book := NewRateBook(rates, projects)
+
+    for _, line := range inv.Lines {
+        // only worklogs that are still uninvoiced and unchanged since the draft, otherwise nothing is saved
+        log, err := getWorkLog(tx, inv.UserID, line.WorkLogID)
+        if err == ErrWorkLogNotFound {
+            return ErrAlreadyInvoiced
+        }
+        if err != nil {
+            return err
+        }
+        if log.InvoiceID != 0 || !log.UpdatedAt.Equal(line.UpdatedAt) || log.Hours != line.Hours ||
+            log.Description != line.Description || book.RateFor(*log) != line.Rate {
+            return ErrAlreadyInvoiced
+        }
+        // updated_at again in the UPDATE: a change after the read above does not slip through
+        result, err := tx.Exec(
+            `UPDATE worklogs SET invoice_id = ?
+            WHERE id = ? AND user_id = ? AND invoice_id IS NULL AND deleted_at IS NULL AND updated_at = ?`,
+            inv.ID, line.WorkLogID, inv.UserID, timeValue(log.UpdatedAt),
+        )
+        if err != nil {
+            return err
+        }
+        if err := requireAffected(result, ErrAlreadyInvoiced); err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(
+            `INSERT INTO invoice_lines (invoice_id, worklog_id, date, project, description, hours, rate, amount)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+            inv.ID, line.WorkLogID, line.Date.Format("2006-01-02"), line.ProjectName, line.Description,
+            line.Hours, line.Rate, line.Amount,
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+func (s *SQLInvoiceStore) Cancel(userID, id int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    var status string
+    err = tx.QueryRow("SELECT status FROM invoices WHERE id = ? AND user_id = ?", id, userID).Scan(&status)
+    if err == sql.ErrNoRows {
+        return ErrInvoiceNotFound
+    }
+    if err != nil {
+        return err
+    }
+
+    // status in the WHERE: of two cancels at the same moment only one frees the worklogs
+    result, err := tx.Exec("UPDATE invoices SET status = ?, cancelled_at = ? WHERE id = ? AND user_id = ? AND status = ?",
+        InvoiceCancelled, timeValue(time.Now()), id, userID, InvoiceIssued)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrInvoiceCancelled); err != nil {
+        return err
+    }
+
+    _, err = tx.Exec("UPDATE worklogs SET invoice_id = NULL WHERE invoice_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    return tx.Commit()
+}
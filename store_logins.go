@@ -0,0 +1,130 @@
+package main
+
+import (
+    "time"
+)
+
+// failed_logins.reason
+const (
+    LoginFailPending  = "pending"  // attempt still being checked, counts like a failure meanwhile
+    LoginFailPassword = "password" // wrong password or unknown user, the same on purpose
+    LoginFailOTP      = "otp"      // wrong 2FA code
+)
+
+// failed_logins.channel, worklog_history.channel
+const (
+    ChannelWeb = "web"
+    ChannelAPI = "api"
+)
+
+type FailedLogin struct {
+    Username    string
+    IP          string
+    Channel     string
+    Reason      string
+    AttemptedAt time.Time
+}
+
+// failures of a username and of an ip, and when the last one of each was
+type LoginFailures struct {
+    User     int
+    UserLast time.Time
+    IP       int
+    IPLast   time.Time
+}
+
+type LoginAttemptStore interface {
+    Record(f *FailedLogin) error
+    // in one transaction: the failures since the time (uncleared ones for the username, all for
+    // the ip) go to wait; unless it returns > 0, f is inserted as pending and its id returned.
+    // Parallel attempts are serialized, each one sees the pending rows of the others.
+    Reserve(f *FailedLogin, since time.Time, wait func(LoginFailures) time.Duration) (int, time.Duration, error)
+    // the pending attempt failed for reason
+    Fail(id int, reason string, at time.Time) error
+    // the pending attempt did not fail, it is forgotten
+    Release(id int) error
+    // successful login: earlier failures stay for the audit but stop counting
+    Clear(username string) error
+}
+
+// LoginAttemptStore on top of SQLite or Postgres
+type SQLLoginAttemptStore struct {
+    db *DB
+}
+
+func NewSQLLoginAttemptStore(db *DB) *SQLLoginAttemptStore {
+    return &SQLLoginAttemptStore{db: db}
+}
+
+func (s *SQLLoginAttemptStore) Record(f *FailedLogin) error {
+    _, err := s.db.Exec(
+        "INSERT INTO failed_logins (username, ip, channel, reason, attempted_at) VALUES (?, ?, ?, ?, ?)",
+        f.Username, f.IP, f.Channel, f.Reason, timeValue(f.AttemptedAt))
+    return err
+}
+
+func (s *SQLLoginAttemptStore) Reserve(f *FailedLogin, since time.Time, wait func(LoginFailures) time.Duration) (int, time.Duration, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return 0, 0, err
+    }
+    defer tx.Rollback()
+
+    // Postgres: one check at a time (plain reads still go on);
+    // SQLite: the INSERT first takes the write lock, the next check waits for the commit
+    if tx.Dialect == DialectPostgres {
+        if _, err := tx.Exec("LOCK TABLE failed_logins IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+            return 0, 0, err
+        }
+    }
+    var id int
+    err = tx.QueryRow(
+        "INSERT INTO failed_logins (username, ip, channel, reason, attempted_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+        f.Username, f.IP, f.Channel, LoginFailPending, timeValue(f.AttemptedAt),
+    ).Scan(&id)
+    if err != nil {
+        return 0, 0, err
+    }
+
+    var n LoginFailures
+    n.User, n.UserLast, err = loginFailures(tx, id, since, "username = ? AND cleared = ?", f.Username, false)
+    if err != nil {
+        return 0, 0, err
+    }
+    n.IP, n.IPLast, err = loginFailures(tx, id, since, "ip = ?", f.IP)
+    if err != nil {
+        return 0, 0, err
+    }
+    // refused attempts are not recorded, the rollback drops the row
+    if d := wait(n); d > 0 {
+        return 0, d, nil
+    }
+    return id, 0, tx.Commit()
+}
+
+// failures matching where + its args since the time, without the row self
+func loginFailures(q querier, self int, since time.Time, where string, args ...interface{}) (int, time.Time, error) {
+    var n int
+    var last dbTime
+    args = append(args, self, timeValue(since))
+    err := q.QueryRow(
+        `SELECT COUNT(*), MAX(attempted_at) FROM failed_logins
+        WHERE `+where+` AND id <> ? AND attempted_at > ?`, args...,
+    ).Scan(&n, &last)
+    return n, last.Time, err
+}
+
+func (s *SQLLoginAttemptStore) Fail(id int, reason string, at time.Time) error {
+    _, err := s.db.Exec("UPDATE failed_logins SET reason = ?, attempted_at = ? WHERE id = ?", reason, timeValue(at), id)
+    return err
+}
+
+func (s *SQLLoginAttemptStore) Release(id int) error {
+    _, err := s.db.Exec("DELETE FROM failed_logins WHERE id = ? AND reason = ?", id, LoginFailPending)
+    return err
+}
+
+func (s *SQLLoginAttemptStore) Clear(username string) error {
+    _, err := s.db.Exec("UPDATE failed_logins SET cleared = ? WHERE username = ? AND cleared = ?", true, username, false)
+    return err
+}
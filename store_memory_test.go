@@ -0,0 +1,280 @@
+package main
+
+import (
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// WorkLogStore in a map, for tests of code that only needs worklogs.
+// No history is written; a is ignored.
+type MemoryWorkLogStore struct {
+    mu       sync.Mutex
+    logs     map[int]WorkLog
+    lastID   int
+    projects map[int]Project // for ProjectName and the ClientID filter
+}
+
+func NewMemoryWorkLogStore() *MemoryWorkLogStore {
+    return &MemoryWorkLogStore{logs: make(map[int]WorkLog), projects: make(map[int]Project)}
+}
+
+// worklogStore is the returned memory store until the end of the test
+func useMemoryWorkLogStore(t *testing.T) *MemoryWorkLogStore {
+    old := worklogStore
+    mem := NewMemoryWorkLogStore()
+    worklogStore = mem
+    t.Cleanup(func() { worklogStore = old })
+    return mem
+}
+
+// project that worklogs of the store may point to
+func (s *MemoryWorkLogStore) AddProject(p Project) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.projects[p.ID] = p
+}
+
+func (s *MemoryWorkLogStore) List(userID int, f WorkLogFilter) ([]WorkLog, error) {
+    return s.ListForUsers([]int{userID}, f)
+}
+
+func (s *MemoryWorkLogStore) ListForUsers(userIDs []int, f WorkLogFilter) ([]WorkLog, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    users := make(map[int]bool)
+    for _, id := range userIDs {
+        users[id] = true
+    }
+    var logs []WorkLog
+    for _, log := range s.logs {
+        if users[log.UserID] && log.DeletedAt.IsZero() && s.matches(log, f) {
+            logs = append(logs, s.copyOf(log))
+        }
+    }
+
+    less := map[string]func(a, b WorkLog) bool{
+        SortDateDesc: func(a, b WorkLog) bool {
+            return a.Date.After(b.Date) || a.Date.Equal(b.Date) && a.ID > b.ID
+        },
+        SortDateAsc: func(a, b WorkLog) bool {
+            return a.Date.Before(b.Date) || a.Date.Equal(b.Date) && a.ID < b.ID
+        },
+        SortHoursDesc: func(a, b WorkLog) bool {
+            return a.Hours > b.Hours || a.Hours == b.Hours && (a.Date.After(b.Date) || a.Date.Equal(b.Date) && a.ID > b.ID)
+        },
+        SortHoursAsc: func(a, b WorkLog) bool {
+            return a.Hours < b.Hours || a.Hours == b.Hours && (a.Date.After(b.Date) || a.Date.Equal(b.Date) && a.ID > b.ID)
+        },
+    }[f.Sort]
+    if less == nil {
+        less = func(a, b WorkLog) bool {
+            return a.Date.After(b.Date) || a.Date.Equal(b.Date) && a.ID > b.ID
+        }
+    }
+    sort.Slice(logs, func(i, j int) bool { return less(logs[i], logs[j]) })
+
+    if f.Offset >= len(logs) {
+        return nil, nil
+    }
+    logs = logs[f.Offset:]
+    if f.Limit > 0 && f.Limit < len(logs) {
+        logs = logs[:f.Limit]
+    }
+    return logs, nil
+}
+
+func (s *MemoryWorkLogStore) matches(log WorkLog, f WorkLogFilter) bool {
+    day := log.Date.Format("2006-01-02")
+    switch {
+    case f.DateFrom != "" && day < f.DateFrom,
+        f.DateTo != "" && day > f.DateTo,
+        f.Search != "" && !strings.Contains(strings.ToLower(log.Description), strings.ToLower(f.Search)),
+        f.ProjectID > 0 && log.ProjectID != f.ProjectID,
+        f.ClientID > 0 && (log.ProjectID == 0 || s.projects[log.ProjectID].ClientID != f.ClientID),
+        f.BillableOnly && !log.Billable,
+        f.UninvoicedOnly && log.InvoiceID != 0:
+        return false
+    }
+    for _, tag := range f.Tags {
+        found := false
+        for _, t := range log.Tags {
+            found = found || t == tag
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+func (s *MemoryWorkLogStore) Each(userID int, f WorkLogFilter, fn func(WorkLog) error) error {
+    logs, err := s.List(userID, f)
+    if err != nil {
+        return err
+    }
+    for _, log := range logs {
+        if err := fn(log); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *MemoryWorkLogStore) Get(userID, id int) (*WorkLog, error) {
+    return s.find(userID, id, false)
+}
+
+func (s *MemoryWorkLogStore) GetTrashed(userID, id int) (*WorkLog, error) {
+    return s.find(userID, id, true)
+}
+
+func (s *MemoryWorkLogStore) find(userID, id int, trashed bool) (*WorkLog, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    log, ok := s.logs[id]
+    if !ok || log.UserID != userID || log.DeletedAt.IsZero() == trashed {
+        return nil, ErrWorkLogNotFound
+    }
+    log = s.copyOf(log)
+    return &log, nil
+}
+
+func (s *MemoryWorkLogStore) Create(log *WorkLog, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.insert(log)
+}
+
+// clients of new projects are not kept, only ClientID as given
+func (s *MemoryWorkLogStore) CreateMany(logs []WorkLog, projects []Project, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for i := range projects {
+        for id := range s.projects {
+            if id > projects[i].ID {
+                projects[i].ID = id
+            }
+        }
+        projects[i].ID++
+        s.projects[projects[i].ID] = projects[i]
+    }
+    linkNewProjects(logs, projects)
+    for i := range logs {
+        if err := s.insert(&logs[i]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *MemoryWorkLogStore) insert(log *WorkLog) error {
+    s.lastID++
+    log.ID = s.lastID
+    stored := *log
+    stored.InvoiceID, stored.DeletedAt, stored.UpdatedAt = 0, time.Time{}, time.Now().UTC().Truncate(time.Second)
+    s.logs[log.ID] = s.copyOf(stored)
+    return nil
+}
+
+func (s *MemoryWorkLogStore) Update(log *WorkLog, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    old, ok := s.logs[log.ID]
+    if !ok || old.UserID != log.UserID || old.InvoiceID != 0 || !old.DeletedAt.IsZero() {
+        return ErrWorkLogNotFound
+    }
+    s.replace(old, log)
+    return nil
+}
+
+func (s *MemoryWorkLogStore) Delete(userID, id int, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    log, ok := s.logs[id]
+    if !ok || log.UserID != userID || log.InvoiceID != 0 || !log.DeletedAt.IsZero() {
+        return ErrWorkLogNotFound
+    }
+    // seconds, like deleted_at in the database
+    log.DeletedAt = time.Now().UTC().Truncate(time.Second)
+    s.logs[id] = log
+    return nil
+}
+
+func (s *MemoryWorkLogStore) ListTrash(userID int) ([]WorkLog, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    var logs []WorkLog
+    for _, log := range s.logs {
+        if log.UserID == userID && !log.DeletedAt.IsZero() {
+            logs = append(logs, s.copyOf(log))
+        }
+    }
+    sort.Slice(logs, func(i, j int) bool {
+        a, b := logs[i], logs[j]
+        return a.DeletedAt.After(b.DeletedAt) || a.DeletedAt.Equal(b.DeletedAt) && a.ID > b.ID
+    })
+    return logs, nil
+}
+
+func (s *MemoryWorkLogStore) Restore(log *WorkLog, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    old, ok := s.logs[log.ID]
+    if !ok || old.UserID != log.UserID || old.DeletedAt.IsZero() {
+        return ErrWorkLogNotFound
+    }
+    old.DeletedAt = time.Time{}
+    s.replace(old, log)
+    return nil
+}
+
+// values of log over old, the store keeps the invoice and the trash state
+func (s *MemoryWorkLogStore) replace(old WorkLog, log *WorkLog) {
+    stored := *log
+    stored.InvoiceID, stored.DeletedAt = old.InvoiceID, old.DeletedAt
+    stored.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+    s.logs[log.ID] = s.copyOf(stored)
+}
+
+func (s *MemoryWorkLogStore) Purge(userID, id int, a *Audit) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    log, ok := s.logs[id]
+    if !ok || log.UserID != userID || log.DeletedAt.IsZero() {
+        return ErrWorkLogNotFound
+    }
+    delete(s.logs, id)
+    return nil
+}
+
+func (s *MemoryWorkLogStore) PurgeTrash(before time.Time) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for id, log := range s.logs {
+        if !log.DeletedAt.IsZero() && log.DeletedAt.Before(before) {
+            delete(s.logs, id)
+            n++
+        }
+    }
+    return n, nil
+}
+
+// log as the SQL store returns it: name of its project, sorted tags, nil for none
+func (s *MemoryWorkLogStore) copyOf(log WorkLog) WorkLog {
+    log.ProjectName = ""
+    if log.ProjectID != 0 {
+        log.ProjectName = s.projects[log.ProjectID].Name
+    }
+    if len(log.Tags) == 0 {
+        log.Tags = nil
+    } else {
+        log.Tags = append([]string(nil), log.Tags...)
+        sort.Strings(log.Tags)
+    }
+    return log
+}
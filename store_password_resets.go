@@ -0,0 +1,71 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+type PasswordResetStore interface {
+    Create(userID int, tokenHash string, createdAt, expiresAt time.Time) error
+    // newest reset link of the user, zero time if there is none
+    LastCreated(userID int) (time.Time, error)
+    // mark the link used and return its user; used, expired or unknown = ErrInvalidResetToken
+    Use(tokenHash string, now time.Time) (int, error)
+    // user of a link that is still valid, without using it (for the reset form)
+    Check(tokenHash string, now time.Time) (int, error)
+}
+
+// PasswordResetStore on top of SQLite or Postgres
+type SQLPasswordResetStore struct {
+    db *DB
+}
+
+func NewSQLPasswordResetStore(db *DB) *SQLPasswordResetStore {
+    return &SQLPasswordResetStore{db: db}
+}
+
+func (s *SQLPasswordResetStore) Create(userID int, tokenHash string, createdAt, expiresAt time.Time) error {
+    // expired links are useless, keep the table small
+    if _, err := s.db.Exec("DELETE FROM password_resets WHERE expires_at < ?", timeValue(createdAt)); err != nil {
+        return err
+    }
+    _, err := s.db.Exec(
+        "INSERT INTO password_resets (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+        userID, tokenHash, timeValue(createdAt), timeValue(expiresAt))
+    return err
+}
+
+func (s *SQLPasswordResetStore) LastCreated(userID int) (time.Time, error) {
+    var last dbTime
+    err := s.db.QueryRow("SELECT MAX(created_at) FROM password_resets WHERE user_id = ?", userID).Scan(&last)
+    return last.Time, err
+}
+
+func (s *SQLPasswordResetStore) Use(tokenHash string, now time.Time) (int, error) {
+    var userID int
+    err := s.db.QueryRow(
+        `UPDATE password_resets SET used_at = ?
+        WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+        RETURNING user_id`,
+        timeValue(now), tokenHash, timeValue(now),
+    ).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return 0, ErrInvalidResetToken
+    }
+    return userID, err
+}
+
+func (s *SQLPasswordResetStore) Check(tokenHash string, now time.Time) (int, error) {
+    var userID int
+    err := s.db.QueryRow(
+        "SELECT user_id FROM password_resets WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+        tokenHash, timeValue(now),
+    ).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return 0, ErrInvalidResetToken
+    }
+    return userID, err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var ErrPeriodLockNotFound = errors.New("period lock not found")
+
+type PeriodLockStore interface {
+    List() ([]PeriodLock, error) // the global lock first, then per user
+    // global and own lock date of the user, zero when not set
+    LockDates(userID int) (time.Time, time.Time, error)
+    Set(l *PeriodLock) error // l.UserID = 0 for the global lock
+    Delete(userID int) error // 0 = the global lock
+}
+
+// PeriodLockStore on top of SQLite or Postgres
+type SQLPeriodLockStore struct {
+    db *DB
+}
+
+func NewSQLPeriodLockStore(db *DB) *SQLPeriodLockStore {
+    return &SQLPeriodLockStore{db: db}
+}
+
+// the global lock has user_id NULL
+func lockWhere(userID int) (string, []interface{}) {
+    if userID == 0 {
+        return "user_id IS NULL", nil
+    }
+    return "user_id = ?", []interface{}{userID}
+}
+
+func (s *SQLPeriodLockStore) List() ([]PeriodLock, error) {
+    rows, err := s.db.Query(`
+        SELECT l.user_id, u.username, l.lock_date, l.updated_by, l.updated_at
+        FROM period_locks l
+        LEFT JOIN users u ON u.id = l.user_id
+        ORDER BY l.user_id IS NOT NULL, u.username`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var locks []PeriodLock
+    for rows.Next() {
+        var l PeriodLock
+        var userID sql.NullInt64
+        var username sql.NullString
+        var lockDate dbDate
+        var updatedAt dbTime
+        if err := rows.Scan(&userID, &username, &lockDate, &l.UpdatedBy, &updatedAt); err != nil {
+            return nil, err
+        }
+        l.UserID = int(userID.Int64)
+        l.Username = username.String
+        l.LockDate = lockDate.Time
+        l.UpdatedAt = updatedAt.Time
+        locks = append(locks, l)
+    }
+    return locks, rows.Err()
+}
+
+func (s *SQLPeriodLockStore) LockDates(userID int) (time.Time, time.Time, error) {
+    return lockDates(s.db, userID)
+}
+
+// LockDates on q, the worklog store checks them inside its transaction
+func lockDates(q querier, userID int) (time.Time, time.Time, error) {
+    var global, own time.Time
+    rows, err := q.Query("SELECT user_id, lock_date FROM period_locks WHERE user_id IS NULL OR user_id = ?", userID)
+    if err != nil {
+        return global, own, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var id sql.NullInt64
+        var lockDate dbDate
+        if err := rows.Scan(&id, &lockDate); err != nil {
+            return global, own, err
+        }
+        if id.Valid {
+            own = lockDate.Time
+        } else {
+            global = lockDate.Time
+        }
+    }
+    return global, own, rows.Err()
+}
+
+// one upsert: two admins setting the same lock at once still leave one row
+func (s *SQLPeriodLockStore) Set(l *PeriodLock) error {
+    if l.UpdatedAt.IsZero() {
+        l.UpdatedAt = time.Now()
+    }
+    // the global lock is unique by idx_period_locks_global
+    target := "(user_id)"
+    if l.UserID == 0 {
+        target = "((user_id IS NULL)) WHERE user_id IS NULL"
+    }
+    _, err := s.db.Exec(`
+        INSERT INTO period_locks (user_id, lock_date, updated_by, updated_at) VALUES (?, ?, ?, ?)
+        ON CONFLICT `+target+` DO UPDATE
+        SET lock_date = excluded.lock_date, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+        nullID(l.UserID), l.LockDate.Format("2006-01-02"), l.UpdatedBy, timeValue(l.UpdatedAt))
+    return err
+}
+
+func (s *SQLPeriodLockStore) Delete(userID int) error {
+    where, args := lockWhere(userID)
+    result, err := s.db.Exec("DELETE FROM period_locks WHERE "+where, args...)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrPeriodLockNotFound)
+}
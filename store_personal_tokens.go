@@ -0,0 +1,100 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "strings"
+    "time"
+)
+
+var ErrPersonalTokenNotFound = errors.New("personal token not found")
+
+type PersonalTokenStore interface {
+    List(userID int) ([]PersonalToken, error) // newest first, expired ones too
+    Create(t *PersonalToken, hash string) error
+    Delete(userID, id int) error
+    // token by hash if it has not expired, last_used_at is set to now
+    Authenticate(hash string, now time.Time) (*PersonalToken, error)
+}
+
+// PersonalTokenStore on top of SQLite or Postgres
+type SQLPersonalTokenStore struct {
+    db *DB
+}
+
+func NewSQLPersonalTokenStore(db *DB) *SQLPersonalTokenStore {
+    return &SQLPersonalTokenStore{db: db}
+}
+
+const personalTokenSelect = `
+    SELECT t.id, t.user_id, u.username, u.role, t.name, t.token_prefix, t.scopes, t.created_at, t.expires_at, t.last_used_at
+    FROM personal_tokens t
+    JOIN users u ON u.id = t.user_id`
+
+func scanPersonalToken(row rowScanner) (*PersonalToken, error) {
+    t := &PersonalToken{}
+    var scopes string
+    var createdAt, expiresAt, lastUsedAt dbTime
+    err := row.Scan(&t.ID, &t.UserID, &t.Username, &t.Role, &t.Name, &t.Prefix, &scopes, &createdAt, &expiresAt, &lastUsedAt)
+    if err != nil {
+        return nil, err
+    }
+    t.Scopes = strings.Fields(scopes)
+    t.CreatedAt = createdAt.Time
+    t.ExpiresAt = expiresAt.Time
+    t.LastUsedAt = lastUsedAt.Time
+    return t, nil
+}
+
+func (s *SQLPersonalTokenStore) List(userID int) ([]PersonalToken, error) {
+    rows, err := s.db.Query(personalTokenSelect+" WHERE t.user_id = ? ORDER BY t.id DESC", userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var tokens []PersonalToken
+    for rows.Next() {
+        t, err := scanPersonalToken(rows)
+        if err != nil {
+            return nil, err
+        }
+        tokens = append(tokens, *t)
+    }
+    return tokens, rows.Err()
+}
+
+func (s *SQLPersonalTokenStore) Create(t *PersonalToken, hash string) error {
+    return s.db.QueryRow(
+        `INSERT INTO personal_tokens (user_id, name, token_prefix, token_hash, scopes, created_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+        t.UserID, t.Name, t.Prefix, hash, strings.Join(t.Scopes, " "),
+        timeValue(t.CreatedAt), timeValue(t.ExpiresAt),
+    ).Scan(&t.ID)
+}
+
+func (s *SQLPersonalTokenStore) Delete(userID, id int) error {
+    result, err := s.db.Exec("DELETE FROM personal_tokens WHERE id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrPersonalTokenNotFound)
+}
+
+func (s *SQLPersonalTokenStore) Authenticate(hash string, now time.Time) (*PersonalToken, error) {
+    t, err := scanPersonalToken(s.db.QueryRow(
+        personalTokenSelect+" WHERE t.token_hash = ? AND t.expires_at > ? AND u.disabled = ?",
+        hash, timeValue(now), false))
+    if err == sql.ErrNoRows {
+        return nil, ErrPersonalTokenNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    if _, err := s.db.Exec("UPDATE personal_tokens SET last_used_at = ? WHERE id = ?", timeValue(now), t.ID); err != nil {
+        return nil, err
+    }
+    t.LastUsedAt = now
+    return t, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+    "database/sql"
+)
+
+// ProjectStore on top of SQLite or Postgres
+type SQLProjectStore struct {
+    db *DB
+}
+
+func NewSQLProjectStore(db *DB) *SQLProjectStore {
+    return &SQLProjectStore{db: db}
+}
+
+const projectSelect = `
+    SELECT p.id, p.user_id, p.client_id, c.name, p.name
+    FROM projects p
+    LEFT JOIN clients c ON c.id = p.client_id`
+
+func scanProject(row rowScanner) (*Project, error) {
+    p := &Project{}
+    var clientID sql.NullInt64
+    var clientName sql.NullString
+    if err := row.Scan(&p.ID, &p.UserID, &clientID, &clientName, &p.Name); err != nil {
+        return nil, err
+    }
+    p.ClientID = int(clientID.Int64)
+    p.ClientName = clientName.String
+    return p, nil
+}
+
+func (s *SQLProjectStore) List(userID int) ([]Project, error) {
+    return listProjects(s.db, userID)
+}
+
+func listProjects(q querier, userID int) ([]Project, error) {
+    rows, err := q.Query(projectSelect+` WHERE p.user_id = ? ORDER BY p.name`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var projects []Project
+    for rows.Next() {
+        p, err := scanProject(rows)
+        if err != nil {
+            return nil, err
+        }
+        projects = append(projects, *p)
+    }
+    return projects, rows.Err()
+}
+
+func (s *SQLProjectStore) Get(userID, id int) (*Project, error) {
+    p, err := scanProject(s.db.QueryRow(projectSelect+` WHERE p.id = ? AND p.user_id = ?`, id, userID))
+    if err == sql.ErrNoRows {
+        return nil, ErrProjectNotFound
+    }
+    return p, err
+}
+
+func (s *SQLProjectStore) Create(p *Project) error {
+    return insertProject(s.db, p)
+}
+
+func insertProject(q querier, p *Project) error {
+    return q.QueryRow(
+        "INSERT INTO projects (user_id, client_id, name) VALUES (?, ?, ?) RETURNING id",
+        p.UserID, nullID(p.ClientID), p.Name,
+    ).Scan(&p.ID)
+}
+
+func (s *SQLProjectStore) Update(p *Project) error {
+    result, err := s.db.Exec(
+        "UPDATE projects SET client_id = ?, name = ? WHERE id = ? AND user_id = ?",
+        nullID(p.ClientID), p.Name, p.ID, p.UserID,
+    )
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrProjectNotFound)
+}
+
+func (s *SQLProjectStore) Delete(userID, id int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // SQLite does not enforce ON DELETE SET NULL without PRAGMA foreign_keys
+    _, err = tx.Exec("UPDATE worklogs SET project_id = NULL WHERE project_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    _, err = tx.Exec("DELETE FROM hourly_rates WHERE project_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+
+    result, err := tx.Exec("DELETE FROM projects WHERE id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrProjectNotFound); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// ClientStore on top of SQLite or Postgres
+type SQLClientStore struct {
+    db *DB
+}
+
+func NewSQLClientStore(db *DB) *SQLClientStore {
+    return &SQLClientStore{db: db}
+}
+
+func (s *SQLClientStore) List(userID int) ([]Client, error) {
+    return listClients(s.db, userID)
+}
+
+func listClients(q querier, userID int) ([]Client, error) {
+    rows, err := q.Query("SELECT id, user_id, name FROM clients WHERE user_id = ? ORDER BY name", userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var clients []Client
+    for rows.Next() {
+        var cl Client
+        if err := rows.Scan(&cl.ID, &cl.UserID, &cl.Name); err != nil {
+            return nil, err
+        }
+        clients = append(clients, cl)
+    }
+    return clients, rows.Err()
+}
+
+func (s *SQLClientStore) Get(userID, id int) (*Client, error) {
+    cl := &Client{}
+    err := s.db.QueryRow("SELECT id, user_id, name FROM clients WHERE id = ? AND user_id = ?", id, userID).
+        Scan(&cl.ID, &cl.UserID, &cl.Name)
+    if err == sql.ErrNoRows {
+        return nil, ErrClientNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return cl, nil
+}
+
+func (s *SQLClientStore) Create(cl *Client) error {
+    return insertClient(s.db, cl)
+}
+
+func insertClient(q querier, cl *Client) error {
+    return q.QueryRow(
+        "INSERT INTO clients (user_id, name) VALUES (?, ?) RETURNING id",
+        cl.UserID, cl.Name,
+    ).Scan(&cl.ID)
+}
+
+func (s *SQLClientStore) Update(cl *Client) error {
+    result, err := s.db.Exec("UPDATE clients SET name = ? WHERE id = ? AND user_id = ?", cl.Name, cl.ID, cl.UserID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrClientNotFound)
+}
+
+func (s *SQLClientStore) Delete(userID, id int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec("UPDATE projects SET client_id = NULL WHERE client_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    _, err = tx.Exec("DELETE FROM hourly_rates WHERE client_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    // invoices keep the client name
+    _, err = tx.Exec("UPDATE invoices SET client_id = NULL WHERE client_id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+
+    result, err := tx.Exec("DELETE FROM clients WHERE id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrClientNotFound); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
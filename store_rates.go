@@ -0,0 +1,81 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+)
+
+var ErrRateNotFound = errors.New("rate not found")
+
+type RateStore interface {
+    List(userID int) ([]HourlyRate, error) // all scopes, oldest first
+    Create(r *HourlyRate) error
+    Delete(userID, id int) error
+}
+
+// RateStore on top of SQLite or Postgres
+type SQLRateStore struct {
+    db *DB
+}
+
+func NewSQLRateStore(db *DB) *SQLRateStore {
+    return &SQLRateStore{db: db}
+}
+
+func (s *SQLRateStore) List(userID int) ([]HourlyRate, error) {
+    return listRates(s.db, userID)
+}
+
+// q may be a transaction that needs the rates as they are in it
+func listRates(q querier, userID int) ([]HourlyRate, error) {
+    rows, err := q.Query(`
+        SELECT r.id, r.user_id, r.project_id, p.name, r.client_id, c.name, r.rate, r.effective_from
+        FROM hourly_rates r
+        LEFT JOIN projects p ON p.id = r.project_id
+        LEFT JOIN clients c ON c.id = r.client_id
+        WHERE r.user_id = ?
+        ORDER BY r.effective_from IS NOT NULL, r.effective_from, r.id`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var rates []HourlyRate
+    for rows.Next() {
+        var r HourlyRate
+        var projectID, clientID sql.NullInt64
+        var projectName, clientName sql.NullString
+        var from dbDate
+        err := rows.Scan(&r.ID, &r.UserID, &projectID, &projectName, &clientID, &clientName, &r.Rate, &from)
+        if err != nil {
+            return nil, err
+        }
+        r.ProjectID = int(projectID.Int64)
+        r.ProjectName = projectName.String
+        r.ClientID = int(clientID.Int64)
+        r.ClientName = clientName.String
+        r.EffectiveFrom = from.Time
+        rates = append(rates, r)
+    }
+    return rates, rows.Err()
+}
+
+func (s *SQLRateStore) Create(r *HourlyRate) error {
+    var from interface{}
+    if !r.EffectiveFrom.IsZero() {
+        from = r.EffectiveFrom.Format("2006-01-02")
+    }
+    return s.db.QueryRow(
+        `INSERT INTO hourly_rates (user_id, project_id, client_id, rate, effective_from)
+        VALUES (?, ?, ?, ?, ?) RETURNING id`,
+        r.UserID, nullID(r.ProjectID), nullID(r.ClientID), r.Rate, from,
+    ).Scan(&r.ID)
+}
+
+func (s *SQLRateStore) Delete(userID, id int) error {
+    result, err := s.db.Exec("DELETE FROM hourly_rates WHERE id = ? AND user_id = ?", id, userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrRateNotFound)
+}
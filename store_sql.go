@@ -0,0 +1,1003 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// WorkLogStore on top of SQLite or Postgres (see DB)
+type SQLWorkLogStore struct {
+    db *DB
+}
+
+func NewSQLWorkLogStore(db *DB) *SQLWorkLogStore {
+    return &SQLWorkLogStore{db: db}
+}
+
+// columns read by scanWorkLog
+const worklogSelect = `
+    SELECT w.id, w.user_id, w.project_id, p.name, w.date, w.start_time, w.end_time, w.break_minutes,
+        w.description, w.hours, w.billable, w.invoice_id, w.deleted_at, w.updated_at
+    FROM worklogs w
+    LEFT JOIN projects p ON p.id = w.project_id`
+
+// a column of a worklog list order and its value in a worklog, for Each to go on after it
+type worklogSortColumn struct {
+    column string
+    desc   bool
+    value  func(WorkLog) interface{}
+}
+
+func (c worklogSortColumn) sql() string {
+    if c.desc {
+        return c.column + " DESC"
+    }
+    return c.column + " ASC"
+}
+
+var (
+    worklogByID    = func(log WorkLog) interface{} { return log.ID }
+    worklogByDate  = func(log WorkLog) interface{} { return log.Date.Format("2006-01-02") }
+    worklogByHours = func(log WorkLog) interface{} { return log.Hours }
+)
+
+// every order ends with the id, so a row has one place in it
+var worklogOrderBy = map[string][]worklogSortColumn{
+    SortDateDesc:  {{"w.date", true, worklogByDate}, {"w.id", true, worklogByID}},
+    SortDateAsc:   {{"w.date", false, worklogByDate}, {"w.id", false, worklogByID}},
+    SortHoursDesc: {{"w.hours", true, worklogByHours}, {"w.date", true, worklogByDate}, {"w.id", true, worklogByID}},
+    SortHoursAsc:  {{"w.hours", false, worklogByHours}, {"w.date", true, worklogByDate}, {"w.id", true, worklogByID}},
+}
+
+func (s *SQLWorkLogStore) List(userID int, f WorkLogFilter) ([]WorkLog, error) {
+    return s.ListForUsers([]int{userID}, f)
+}
+
+func (s *SQLWorkLogStore) ListForUsers(userIDs []int, f WorkLogFilter) ([]WorkLog, error) {
+    if len(userIDs) == 0 {
+        return nil, nil
+    }
+    query, args := s.listQuery(userIDs, f, nil)
+    return s.listWorkLogs(query, args)
+}
+
+// the rows are closed before the tags are loaded and the worklogs returned
+func (s *SQLWorkLogStore) listWorkLogs(query string, args []interface{}) ([]WorkLog, error) {
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var logs []WorkLog
+    for rows.Next() {
+        log, err := scanWorkLog(rows)
+        if err != nil {
+            return nil, err
+        }
+        logs = append(logs, *log)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+
+    if err := loadWorkLogTags(s.db, logs); err != nil {
+        return nil, err
+    }
+    return logs, nil
+}
+
+// like List, but eachChunk worklogs at a time, each chunk a query of its own that goes on
+// after the last row of the one before. fn runs with no rows open: a slow download holds
+// no SQLite read lock (which would make every write fail) and no Postgres connection
+func (s *SQLWorkLogStore) Each(userID int, f WorkLogFilter, fn func(WorkLog) error) error {
+    left := f.Limit
+    var after *WorkLog
+    for {
+        chunk := f
+        chunk.Limit = eachChunk
+        if left > 0 && left < eachChunk {
+            chunk.Limit = left
+        }
+        if after != nil {
+            chunk.Offset = 0
+        }
+        query, args := s.listQuery([]int{userID}, chunk, after)
+        logs, err := s.listWorkLogs(query, args)
+        if err != nil {
+            return err
+        }
+        for _, log := range logs {
+            if err := fn(log); err != nil {
+                return err
+            }
+        }
+
+        if len(logs) < chunk.Limit {
+            return nil
+        }
+        if left > 0 {
+            if left -= len(logs); left == 0 {
+                return nil
+            }
+        }
+        after = &logs[len(logs)-1]
+    }
+}
+
+// a var for the tests
+var eachChunk = 500
+
+// SELECT of ListForUsers / Each with the conditions of f; rows after the worklog after
+// in the order of f when it is set
+func (s *SQLWorkLogStore) listQuery(userIDs []int, f WorkLogFilter, after *WorkLog) (string, []interface{}) {
+    // the trash is left out of every list, report, export and stat
+    query := worklogSelect + ` WHERE w.user_id IN (?` + strings.Repeat(", ?", len(userIDs)-1) + `) AND w.deleted_at IS NULL`
+    var args []interface{}
+    for _, id := range userIDs {
+        args = append(args, id)
+    }
+
+    if f.DateFrom != "" {
+        query += ` AND w.date >= ?`
+        args = append(args, f.DateFrom)
+    }
+    if f.DateTo != "" {
+        query += ` AND w.date <= ?`
+        args = append(args, f.DateTo)
+    }
+    if f.Search != "" {
+        query += ` AND w.description ` + s.db.Like() + ` ?`
+        args = append(args, "%"+f.Search+"%")
+    }
+    if f.ProjectID > 0 {
+        query += ` AND w.project_id = ?`
+        args = append(args, f.ProjectID)
+    }
+    if f.ClientID > 0 {
+        query += ` AND p.client_id = ?`
+        args = append(args, f.ClientID)
+    }
+    if f.BillableOnly {
+        query += ` AND w.billable = ?`
+        args = append(args, true)
+    }
+    if f.UninvoicedOnly {
+        query += ` AND w.invoice_id IS NULL`
+    }
+    // entry must have every tag of the filter
+    for _, tag := range f.Tags {
+        query += ` AND w.id IN (
+            SELECT wt.worklog_id FROM worklog_tags wt JOIN tags t ON t.id = wt.tag_id
+            WHERE t.user_id = w.user_id AND t.name = ?)`
+        args = append(args, tag)
+    }
+
+    order, ok := worklogOrderBy[f.Sort]
+    if !ok {
+        order = worklogOrderBy[SortDateDesc]
+    }
+    if after != nil {
+        // after the row in every column's direction: (a < ? OR (a = ? AND (b < ? OR (b = ? AND id < ?))))
+        var cond string
+        var condArgs []interface{}
+        for i := len(order) - 1; i >= 0; i-- {
+            col := order[i]
+            op := " > ?"
+            if col.desc {
+                op = " < ?"
+            }
+            value := col.value(*after)
+            if cond == "" {
+                cond, condArgs = col.column+op, []interface{}{value}
+                continue
+            }
+            cond = "(" + col.column + op + " OR (" + col.column + " = ? AND " + cond + "))"
+            condArgs = append([]interface{}{value, value}, condArgs...)
+        }
+        query += ` AND ` + cond
+        args = append(args, condArgs...)
+    }
+    columns := make([]string, len(order))
+    for i, col := range order {
+        columns[i] = col.sql()
+    }
+    query += ` ORDER BY ` + strings.Join(columns, ", ")
+
+    if f.Limit > 0 {
+        query += ` LIMIT ? OFFSET ?`
+        args = append(args, f.Limit, f.Offset)
+    } else if f.Offset > 0 {
+        query += ` LIMIT ` + s.db.NoLimit() + ` OFFSET ?`
+        args = append(args, f.Offset)
+    }
+    return query, args
+}
+
+func (s *SQLWorkLogStore) Get(userID, id int) (*WorkLog, error) {
+    return getWorkLog(s.db, userID, id)
+}
+
+// q may be the transaction of a change, for the values before and after it;
+// worklogs in the trash are not found
+func getWorkLog(q querier, userID, id int) (*WorkLog, error) {
+    return findWorkLog(q, worklogSelect+` WHERE w.id = ? AND w.user_id = ? AND w.deleted_at IS NULL`, id, userID)
+}
+
+func getTrashedWorkLog(q querier, userID, id int) (*WorkLog, error) {
+    return findWorkLog(q, worklogSelect+` WHERE w.id = ? AND w.user_id = ? AND w.deleted_at IS NOT NULL`, id, userID)
+}
+
+func findWorkLog(q querier, query string, args ...interface{}) (*WorkLog, error) {
+    row := q.QueryRow(query, args...)
+    log, err := scanWorkLog(row)
+    if err == sql.ErrNoRows {
+        return nil, ErrWorkLogNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    logs := []WorkLog{*log}
+    if err := loadWorkLogTags(q, logs); err != nil {
+        return nil, err
+    }
+    return &logs[0], nil
+}
+
+func (s *SQLWorkLogStore) Create(log *WorkLog, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := lockWorkLogs(tx, log.UserID); err != nil {
+        return err
+    }
+    overridden, err := checkWorkLogWrite(tx, nil, log, a)
+    if err != nil {
+        return err
+    }
+    if err := insertWorkLog(tx, log); err != nil {
+        return err
+    }
+    if err := recordInsert(tx, log, a, overridden); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// all or nothing, sets the ids of logs
+func (s *SQLWorkLogStore) CreateMany(logs []WorkLog, projects []Project, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    locked := make(map[int]bool)
+    for _, log := range logs {
+        if !locked[log.UserID] {
+            if err := lockWorkLogs(tx, log.UserID); err != nil {
+                return err
+            }
+            locked[log.UserID] = true
+        }
+    }
+    if len(projects) > 0 {
+        if err := insertImportProjects(tx, projects); err != nil {
+            return err
+        }
+        linkNewProjects(logs, projects)
+    }
+    for i := range logs {
+        // the day includes the rows inserted before
+        overridden, err := checkWorkLogWrite(tx, nil, &logs[i], a)
+        if err != nil {
+            return err
+        }
+        if err := insertWorkLog(tx, &logs[i]); err != nil {
+            return err
+        }
+        if err := recordInsert(tx, &logs[i], a, overridden); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// projects of CreateMany, clients are looked up by name (case-insensitive in Go,
+// SQLite LOWER only knows ASCII) and created once when missing
+func insertImportProjects(tx *Tx, projects []Project) error {
+    clients, err := listClients(tx, projects[0].UserID)
+    if err != nil {
+        return err
+    }
+    clientIDs := make(map[string]int)
+    for _, cl := range clients {
+        clientIDs[strings.ToLower(cl.Name)] = cl.ID
+    }
+
+    for i := range projects {
+        p := &projects[i]
+        if key := strings.ToLower(p.ClientName); key != "" && p.ClientID == 0 {
+            if _, ok := clientIDs[key]; !ok {
+                cl := Client{UserID: p.UserID, Name: p.ClientName}
+                if err := insertClient(tx, &cl); err != nil {
+                    return fmt.Errorf("client %s: %w", p.ClientName, err)
+                }
+                clientIDs[key] = cl.ID
+            }
+            p.ClientID = clientIDs[key]
+        }
+        if err := insertProject(tx, p); err != nil {
+            return fmt.Errorf("project %s: %w", p.Name, err)
+        }
+    }
+    return nil
+}
+
+// Parallel writes of one user's worklogs (web and API, timer stop and form, imports) wait
+// for each other from here to the commit. Postgres locks the user row; SQLite takes its
+// write lock with a write that changes nothing, reads first would let two writers through.
+func lockWorkLogs(tx *Tx, userID int) error {
+    query := "UPDATE users SET id = id WHERE id = ?"
+    if tx.Dialect == DialectPostgres {
+        query = "SELECT id FROM users WHERE id = ? FOR UPDATE"
+    }
+    _, err := tx.Exec(query, userID)
+    return err
+}
+
+// the checks of PrepareWorkLog and CheckWorkLogEditable that depend on other rows, again
+// inside the transaction of the write after lockWorkLogs: they saw the database before a
+// parallel write, submit or new lock date. before = nil for new entries, after = nil for deletes.
+// true if a closed period was written with a.OverrideLock, worklog_history records that
+func checkWorkLogWrite(tx *Tx, before, after *WorkLog, a *Audit) (bool, error) {
+    overridden := false
+    for _, log := range []*WorkLog{before, after} {
+        if log == nil {
+            continue
+        }
+        global, own, err := lockDates(tx, log.UserID)
+        if err != nil {
+            return false, err
+        }
+        if err := periodOpen(global, own, log.Date, false); err != nil {
+            if a == nil || !a.OverrideLock {
+                return false, err
+            }
+            overridden = true
+        }
+        year, week := log.Date.ISOWeek()
+        status, err := weekStatus(tx, log.UserID, year, week)
+        if err != nil {
+            return false, err
+        }
+        if err := weekOpen(status); err != nil {
+            return false, err
+        }
+    }
+    if after == nil {
+        return overridden, nil
+    }
+    others, err := dayWorkLogs(tx, after.UserID, after.Date)
+    if err != nil {
+        return false, err
+    }
+    return overridden, checkDay(after, others)
+}
+
+// worklogs of the user on the day of date, without their tags
+func dayWorkLogs(q querier, userID int, date time.Time) ([]WorkLog, error) {
+    rows, err := q.Query(worklogSelect+` WHERE w.user_id = ? AND w.date = ? AND w.deleted_at IS NULL`,
+        userID, date.Format("2006-01-02"))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var logs []WorkLog
+    for rows.Next() {
+        log, err := scanWorkLog(rows)
+        if err != nil {
+            return nil, err
+        }
+        logs = append(logs, *log)
+    }
+    return logs, rows.Err()
+}
+
+// history entry for a new worklog, with the values as stored
+func recordInsert(q querier, log *WorkLog, a *Audit, overridden bool) error {
+    stored, err := getWorkLog(q, log.UserID, log.ID)
+    if err != nil {
+        return err
+    }
+    return recordWorkLogChange(q, HistoryCreate, nil, stored, a, overridden)
+}
+
+// shared by WorkLogStore.Create and stores that add worklogs inside their own transaction,
+// q should be a *Tx when log has tags
+func insertWorkLog(q querier, log *WorkLog) error {
+    // RETURNING works on both backends, LastInsertId only on SQLite
+    err := q.QueryRow(
+        `INSERT INTO worklogs (user_id, project_id, date, start_time, end_time, break_minutes, description, hours, billable, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+        log.UserID, nullID(log.ProjectID), log.Date.Format("2006-01-02"),
+        nullString(log.StartTime), nullString(log.EndTime), log.BreakMinutes, log.Description, log.Hours, log.Billable,
+        timeValue(time.Now()),
+    ).Scan(&log.ID)
+    if err != nil || len(log.Tags) == 0 {
+        return err
+    }
+    return saveWorkLogTags(q, log)
+}
+
+func (s *SQLWorkLogStore) Update(log *WorkLog, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := lockWorkLogs(tx, log.UserID); err != nil {
+        return err
+    }
+    old, err := getWorkLog(tx, log.UserID, log.ID)
+    if err != nil {
+        return err
+    }
+    overridden, err := checkWorkLogWrite(tx, old, log, a)
+    if err != nil {
+        return err
+    }
+    result, err := tx.Exec(
+        `UPDATE worklogs SET project_id = ?, date = ?, start_time = ?, end_time = ?, break_minutes = ?,
+            description = ?, hours = ?, billable = ?, updated_at = ?
+        WHERE id = ? AND user_id = ? AND invoice_id IS NULL AND deleted_at IS NULL`,
+        nullID(log.ProjectID), log.Date.Format("2006-01-02"),
+        nullString(log.StartTime), nullString(log.EndTime), log.BreakMinutes, log.Description, log.Hours, log.Billable,
+        timeValue(time.Now()), log.ID, log.UserID,
+    )
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrWorkLogNotFound); err != nil {
+        return err
+    }
+
+    if err := saveWorkLogTags(tx, log); err != nil {
+        return err
+    }
+    if err := deleteUnusedTags(tx, log.UserID); err != nil {
+        return err
+    }
+    stored, err := getWorkLog(tx, log.UserID, log.ID)
+    if err != nil {
+        return err
+    }
+    if err := recordWorkLogChange(tx, HistoryUpdate, old, stored, a, overridden); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLWorkLogStore) Delete(userID, id int, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := lockWorkLogs(tx, userID); err != nil {
+        return err
+    }
+    old, err := getWorkLog(tx, userID, id)
+    if err != nil {
+        return err
+    }
+    overridden, err := checkWorkLogWrite(tx, old, nil, a)
+    if err != nil {
+        return err
+    }
+
+    // into the trash, tags stay for a restore
+    result, err := tx.Exec(
+        "UPDATE worklogs SET deleted_at = ? WHERE id = ? AND user_id = ? AND invoice_id IS NULL AND deleted_at IS NULL",
+        timeValue(time.Now()), id, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrWorkLogNotFound); err != nil {
+        return err
+    }
+    if err := recordWorkLogChange(tx, HistoryDelete, old, nil, a, overridden); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLWorkLogStore) ListTrash(userID int) ([]WorkLog, error) {
+    rows, err := s.db.Query(worklogSelect+` WHERE w.user_id = ? AND w.deleted_at IS NOT NULL
+        ORDER BY w.deleted_at DESC, w.id DESC`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var logs []WorkLog
+    for rows.Next() {
+        log, err := scanWorkLog(rows)
+        if err != nil {
+            return nil, err
+        }
+        logs = append(logs, *log)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+
+    if err := loadWorkLogTags(s.db, logs); err != nil {
+        return nil, err
+    }
+    return logs, nil
+}
+
+func (s *SQLWorkLogStore) GetTrashed(userID, id int) (*WorkLog, error) {
+    return getTrashedWorkLog(s.db, userID, id)
+}
+
+func (s *SQLWorkLogStore) Restore(log *WorkLog, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := lockWorkLogs(tx, log.UserID); err != nil {
+        return err
+    }
+    overridden, err := checkWorkLogWrite(tx, nil, log, a)
+    if err != nil {
+        return err
+    }
+    result, err := tx.Exec(
+        `UPDATE worklogs SET project_id = ?, date = ?, start_time = ?, end_time = ?, break_minutes = ?,
+            description = ?, hours = ?, billable = ?, deleted_at = NULL, updated_at = ?
+        WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`,
+        nullID(log.ProjectID), log.Date.Format("2006-01-02"),
+        nullString(log.StartTime), nullString(log.EndTime), log.BreakMinutes, log.Description, log.Hours, log.Billable,
+        timeValue(time.Now()), log.ID, log.UserID,
+    )
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrWorkLogNotFound); err != nil {
+        return err
+    }
+
+    if err := saveWorkLogTags(tx, log); err != nil {
+        return err
+    }
+    if err := deleteUnusedTags(tx, log.UserID); err != nil {
+        return err
+    }
+    stored, err := getWorkLog(tx, log.UserID, log.ID)
+    if err != nil {
+        return err
+    }
+    if err := recordWorkLogChange(tx, HistoryRestore, nil, stored, a, overridden); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLWorkLogStore) Purge(userID, id int, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := purgeWorkLog(tx, userID, id, a); err != nil {
+        return err
+    }
+    if err := deleteUnusedTags(tx, userID); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLWorkLogStore) PurgeTrash(before time.Time) (int, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    rows, err := tx.Query(
+        "SELECT id, user_id FROM worklogs WHERE deleted_at IS NOT NULL AND deleted_at < ?", timeValue(before))
+    if err != nil {
+        return 0, err
+    }
+    var expired [][2]int
+    for rows.Next() {
+        var id, userID int
+        if err := rows.Scan(&id, &userID); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        expired = append(expired, [2]int{id, userID})
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, err
+    }
+
+    users := map[int]bool{}
+    for _, e := range expired {
+        if err := purgeWorkLog(tx, e[1], e[0], nil); err != nil {
+            return 0, err
+        }
+        users[e[1]] = true
+    }
+    for userID := range users {
+        if err := deleteUnusedTags(tx, userID); err != nil {
+            return 0, err
+        }
+    }
+    return len(expired), tx.Commit()
+}
+
+// a worklog out of the trash for good, the history keeps its last values
+func purgeWorkLog(tx *Tx, userID, id int, a *Audit) error {
+    old, err := getTrashedWorkLog(tx, userID, id)
+    if err != nil {
+        return err
+    }
+    // SQLite does not enforce ON DELETE CASCADE without PRAGMA foreign_keys
+    if _, err := tx.Exec("DELETE FROM worklog_tags WHERE worklog_id = ?", id); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("DELETE FROM worklogs WHERE id = ? AND user_id = ?", id, userID); err != nil {
+        return err
+    }
+    return recordWorkLogChange(tx, HistoryPurge, old, nil, a, false)
+}
+
+// UserStore on top of SQLite or Postgres
+type SQLUserStore struct {
+    db *DB
+}
+
+func NewSQLUserStore(db *DB) *SQLUserStore {
+    return &SQLUserStore{db: db}
+}
+
+func (s *SQLUserStore) Create(username, passwordHash string) error {
+    _, err := s.db.Exec("INSERT INTO users (username, password, role) VALUES (?, ?, ?)",
+        username, passwordHash, RoleUser)
+    return err
+}
+
+func (s *SQLUserStore) List() ([]User, error) {
+    rows, err := s.db.Query(userSelect + " ORDER BY username")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var users []User
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            return nil, err
+        }
+        users = append(users, *user)
+    }
+    return users, rows.Err()
+}
+
+const userSelect = "SELECT id, username, password, totp_secret, totp_enabled, email, auth_version, role, disabled FROM users"
+
+func scanUser(row rowScanner) (*User, error) {
+    user := &User{}
+    var secret, email sql.NullString
+    err := row.Scan(&user.ID, &user.Username, &user.Password, &secret, &user.TOTPEnabled, &email, &user.AuthVersion,
+        &user.Role, &user.Disabled)
+    if err == sql.ErrNoRows {
+        return nil, ErrUserNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    user.TOTPSecret = secret.String
+    user.Email = email.String
+    return user, nil
+}
+
+func (s *SQLUserStore) GetByUsername(username string) (*User, error) {
+    return scanUser(s.db.QueryRow(userSelect+" WHERE username = ?", username))
+}
+
+func (s *SQLUserStore) GetByID(id int) (*User, error) {
+    return scanUser(s.db.QueryRow(userSelect+" WHERE id = ?", id))
+}
+
+func (s *SQLUserStore) GetByEmail(email string) (*User, error) {
+    return scanUser(s.db.QueryRow(userSelect+" WHERE email = ?", email))
+}
+
+func (s *SQLUserStore) SetEmail(userID int, email string) error {
+    if email != "" {
+        other, err := s.GetByEmail(email)
+        if err == nil && other.ID != userID {
+            return ErrEmailTaken
+        }
+        if err != nil && err != ErrUserNotFound {
+            return err
+        }
+    }
+    result, err := s.db.Exec("UPDATE users SET email = ? WHERE id = ?", nullString(email), userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) ReplacePassword(userID int, passwordHash string) (int, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    var version int
+    err = tx.QueryRow(
+        "UPDATE users SET password = ?, auth_version = auth_version + 1 WHERE id = ? RETURNING auth_version",
+        passwordHash, userID,
+    ).Scan(&version)
+    if err == sql.ErrNoRows {
+        return 0, ErrUserNotFound
+    }
+    if err != nil {
+        return 0, err
+    }
+
+    now := timeValue(time.Now())
+    if _, err := tx.Exec(
+        "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID); err != nil {
+        return 0, err
+    }
+    if _, err := tx.Exec("DELETE FROM personal_tokens WHERE user_id = ?", userID); err != nil {
+        return 0, err
+    }
+    if _, err := tx.Exec("DELETE FROM password_resets WHERE user_id = ?", userID); err != nil {
+        return 0, err
+    }
+    // an email change asked for with the old password must not get the account back
+    if _, err := tx.Exec("DELETE FROM email_changes WHERE user_id = ?", userID); err != nil {
+        return 0, err
+    }
+    return version, tx.Commit()
+}
+
+func (s *SQLUserStore) SetRole(userID int, role string) error {
+    result, err := s.db.Exec(
+        "UPDATE users SET role = ?, auth_version = auth_version + 1 WHERE id = ?", role, userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) SetDisabled(userID int, disabled bool) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.Exec(
+        "UPDATE users SET disabled = ?, auth_version = auth_version + 1 WHERE id = ?", disabled, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrUserNotFound); err != nil {
+        return err
+    }
+    if disabled {
+        if _, err := tx.Exec(
+            "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+            timeValue(time.Now()), userID); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// children first, Postgres checks every foreign key
+var userDeletes = []string{
+    "DELETE FROM worklog_tags WHERE worklog_id IN (SELECT id FROM worklogs WHERE user_id = ?)",
+    "DELETE FROM worklogs WHERE user_id = ?",
+    "DELETE FROM invoice_lines WHERE invoice_id IN (SELECT id FROM invoices WHERE user_id = ?)",
+    "DELETE FROM invoices WHERE user_id = ?",
+    "DELETE FROM invoice_counters WHERE user_id = ?",
+    "DELETE FROM hourly_rates WHERE user_id = ?",
+    "DELETE FROM timers WHERE user_id = ?",
+    "DELETE FROM projects WHERE user_id = ?",
+    "DELETE FROM clients WHERE user_id = ?",
+    "DELETE FROM tags WHERE user_id = ?",
+    "DELETE FROM refresh_tokens WHERE user_id = ?",
+    "DELETE FROM personal_tokens WHERE user_id = ?",
+    "DELETE FROM recovery_codes WHERE user_id = ?",
+    "DELETE FROM mfa_challenges WHERE user_id = ?",
+    "DELETE FROM password_resets WHERE user_id = ?",
+    "DELETE FROM email_changes WHERE user_id = ?",
+    "DELETE FROM team_members WHERE user_id = ?",
+    "DELETE FROM timesheet_events WHERE timesheet_id IN (SELECT id FROM timesheets WHERE user_id = ?)",
+    "DELETE FROM timesheets WHERE user_id = ?",
+    "DELETE FROM period_locks WHERE user_id = ?",
+    // approvals they gave stay in the history of the other users, by name
+    "UPDATE timesheet_events SET actor_id = NULL WHERE actor_id = ?",
+}
+
+func (s *SQLUserStore) Delete(userID int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for _, query := range userDeletes {
+        if _, err := tx.Exec(query, userID); err != nil {
+            return err
+        }
+    }
+    result, err := tx.Exec("DELETE FROM users WHERE id = ?", userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrUserNotFound); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+// *DB and *Tx
+type querier interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanWorkLog(row rowScanner) (*WorkLog, error) {
+    log := &WorkLog{}
+    var date dbDate
+    var deletedAt, updatedAt dbTime
+    var projectID, invoiceID sql.NullInt64
+    var projectName, startTime, endTime, description sql.NullString
+    err := row.Scan(&log.ID, &log.UserID, &projectID, &projectName, &date,
+        &startTime, &endTime, &log.BreakMinutes, &description, &log.Hours, &log.Billable, &invoiceID, &deletedAt, &updatedAt)
+    if err != nil {
+        return nil, err
+    }
+
+    log.StartTime = startTime.String
+    log.EndTime = endTime.String
+    log.ProjectID = int(projectID.Int64)
+    log.InvoiceID = int(invoiceID.Int64)
+    log.ProjectName = projectName.String
+    log.Description = description.String
+    log.Date = date.Time
+    log.DeletedAt = deletedAt.Time
+    log.UpdatedAt = updatedAt.Time
+    return log, nil
+}
+
+// date column: TEXT "YYYY-MM-DD" in SQLite, DATE in Postgres
+type dbDate struct {
+    time.Time
+}
+
+func (d *dbDate) Scan(value interface{}) error {
+    switch v := value.(type) {
+    case nil:
+        d.Time = time.Time{}
+    case time.Time:
+        d.Time = v
+    case string:
+        return d.parse(v)
+    case []byte:
+        return d.parse(string(v))
+    default:
+        return fmt.Errorf("dbDate: unsupported type %T", value)
+    }
+    return nil
+}
+
+// timestamp column: TEXT RFC3339 in SQLite, TIMESTAMPTZ in Postgres
+type dbTime struct {
+    time.Time
+}
+
+func (t *dbTime) Scan(value interface{}) error {
+    switch v := value.(type) {
+    case nil:
+        t.Time = time.Time{}
+    case time.Time:
+        t.Time = v
+    case string:
+        return t.parse(v)
+    case []byte:
+        return t.parse(string(v))
+    default:
+        return fmt.Errorf("dbTime: unsupported type %T", value)
+    }
+    return nil
+}
+
+func (t *dbTime) parse(s string) error {
+    parsed, err := time.Parse(time.RFC3339, s)
+    if err != nil {
+        return err
+    }
+    t.Time = parsed
+    return nil
+}
+
+// how timestamps are written, sorts correctly as text in SQLite
+func timeValue(t time.Time) string {
+    return t.UTC().Format(time.RFC3339)
+}
+
+func (d *dbDate) parse(s string) error {
+    if len(s) > 10 {
+        s = s[:10]
+    }
+    t, err := time.Parse("2006-01-02", s)
+    if err != nil {
+        return err
+    }
+    d.Time = t
+    return nil
+}
+
+// optional reference: 0 is stored as NULL
+func nullID(id int) interface{} {
+    if id == 0 {
+        return nil
+    }
+    return id
+}
+
+// optional text: "" is stored as NULL
+func nullString(s string) interface{} {
+    if s == "" {
+        return nil
+    }
+    return s
+}
+
+// 0 rows affected -> notFound
+func requireAffected(result sql.Result, notFound error) error {
+    n, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return notFound
+    }
+    return nil
+}
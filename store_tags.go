@@ -0,0 +1,128 @@
+package main
+
+import (
+    "strings"
+)
+
+type TagStore interface {
+    List(userID int) ([]Tag, error) // tags in use, by name
+}
+
+// TagStore on top of SQLite or Postgres
+type SQLTagStore struct {
+    db *DB
+}
+
+func NewSQLTagStore(db *DB) *SQLTagStore {
+    return &SQLTagStore{db: db}
+}
+
+func (s *SQLTagStore) List(userID int) ([]Tag, error) {
+    // tags only worklogs in the trash carry are left out, they come back with a restore
+    rows, err := s.db.Query(`
+        SELECT id, user_id, name FROM tags
+        WHERE user_id = ? AND id IN (
+            SELECT wt.tag_id FROM worklog_tags wt JOIN worklogs w ON w.id = wt.worklog_id WHERE w.deleted_at IS NULL)
+        ORDER BY name`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var tags []Tag
+    for rows.Next() {
+        var t Tag
+        if err := rows.Scan(&t.ID, &t.UserID, &t.Name); err != nil {
+            return nil, err
+        }
+        tags = append(tags, t)
+    }
+    return tags, rows.Err()
+}
+
+// replace the tags of log.ID with log.Tags, creating missing tags
+func saveWorkLogTags(q querier, log *WorkLog) error {
+    if _, err := q.Exec("DELETE FROM worklog_tags WHERE worklog_id = ?", log.ID); err != nil {
+        return err
+    }
+
+    for _, name := range log.Tags {
+        _, err := q.Exec(
+            "INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT (user_id, name) DO NOTHING",
+            log.UserID, name,
+        )
+        if err != nil {
+            return err
+        }
+
+        var tagID int
+        err = q.QueryRow("SELECT id FROM tags WHERE user_id = ? AND name = ?", log.UserID, name).Scan(&tagID)
+        if err != nil {
+            return err
+        }
+
+        if _, err := q.Exec("INSERT INTO worklog_tags (worklog_id, tag_id) VALUES (?, ?)", log.ID, tagID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// tags nobody uses any more disappear from the tag list
+func deleteUnusedTags(q querier, userID int) error {
+    _, err := q.Exec(
+        "DELETE FROM tags WHERE user_id = ? AND id NOT IN (SELECT tag_id FROM worklog_tags)",
+        userID,
+    )
+    return err
+}
+
+// fill Tags of every log, one query per 500 entries
+func loadWorkLogTags(q querier, logs []WorkLog) error {
+    byID := make(map[int]*WorkLog, len(logs))
+    for i := range logs {
+        logs[i].Tags = nil
+        byID[logs[i].ID] = &logs[i]
+    }
+
+    const chunk = 500
+    for start := 0; start < len(logs); start += chunk {
+        end := start + chunk
+        if end > len(logs) {
+            end = len(logs)
+        }
+
+        args := make([]interface{}, 0, end-start)
+        for _, log := range logs[start:end] {
+            args = append(args, log.ID)
+        }
+        placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+
+        rows, err := q.Query(`
+            SELECT wt.worklog_id, t.name
+            FROM worklog_tags wt
+            JOIN tags t ON t.id = wt.tag_id
+            WHERE wt.worklog_id IN (`+placeholders+`)
+            ORDER BY t.name`, args...)
+        if err != nil {
+            return err
+        }
+        for rows.Next() {
+            var id int
+            var name string
+            if err := rows.Scan(&id, &name); err != nil {
+                rows.Close()
+                return err
+            }
+            if log := byID[id]; log != nil {
+                log.Tags = append(log.Tags, name)
+            }
+        }
+        err = rows.Err()
+        rows.Close()
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
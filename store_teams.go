@@ -0,0 +1,182 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var (
+    ErrTeamNotFound       = errors.New("team not found")
+    ErrTeamMemberNotFound = errors.New("team member not found")
+)
+
+type TeamStore interface {
+    List() ([]Team, error)
+    // teams where the user has the team role manager
+    ListManaged(userID int) ([]Team, error)
+    Get(id int) (*Team, error)
+    Create(t *Team) error
+    Delete(id int) error // memberships go with it, worklogs stay with their users
+    Members(teamID int) ([]TeamMember, error)
+    // add the user or change their team role
+    SetMember(teamID, userID int, role string) error
+    RemoveMember(teamID, userID int) error
+    // team role of the user, ErrTeamMemberNotFound if not in the team
+    MemberRole(teamID, userID int) (string, error)
+    // true if userID is a member of a team where managerID is team manager
+    Manages(managerID, userID int) (bool, error)
+}
+
+// TeamStore on top of SQLite or Postgres
+type SQLTeamStore struct {
+    db *DB
+}
+
+func NewSQLTeamStore(db *DB) *SQLTeamStore {
+    return &SQLTeamStore{db: db}
+}
+
+const teamSelect = `
+    SELECT t.id, t.name, t.created_at, (SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id)
+    FROM teams t`
+
+func scanTeam(row rowScanner) (*Team, error) {
+    t := &Team{}
+    var createdAt dbTime
+    if err := row.Scan(&t.ID, &t.Name, &createdAt, &t.MemberCount); err != nil {
+        return nil, err
+    }
+    t.CreatedAt = createdAt.Time
+    return t, nil
+}
+
+func (s *SQLTeamStore) list(query string, args ...interface{}) ([]Team, error) {
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var teams []Team
+    for rows.Next() {
+        t, err := scanTeam(rows)
+        if err != nil {
+            return nil, err
+        }
+        teams = append(teams, *t)
+    }
+    return teams, rows.Err()
+}
+
+func (s *SQLTeamStore) List() ([]Team, error) {
+    return s.list(teamSelect + ` ORDER BY t.name`)
+}
+
+func (s *SQLTeamStore) ListManaged(userID int) ([]Team, error) {
+    return s.list(teamSelect+`
+        WHERE t.id IN (SELECT team_id FROM team_members WHERE user_id = ? AND role = ?)
+        ORDER BY t.name`, userID, TeamRoleManager)
+}
+
+func (s *SQLTeamStore) Get(id int) (*Team, error) {
+    t, err := scanTeam(s.db.QueryRow(teamSelect+` WHERE t.id = ?`, id))
+    if err == sql.ErrNoRows {
+        return nil, ErrTeamNotFound
+    }
+    return t, err
+}
+
+func (s *SQLTeamStore) Create(t *Team) error {
+    if t.CreatedAt.IsZero() {
+        t.CreatedAt = time.Now()
+    }
+    return s.db.QueryRow(
+        "INSERT INTO teams (name, created_at) VALUES (?, ?) RETURNING id",
+        t.Name, timeValue(t.CreatedAt),
+    ).Scan(&t.ID)
+}
+
+func (s *SQLTeamStore) Delete(id int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // SQLite runs without foreign_keys, ON DELETE CASCADE is not enough
+    if _, err := tx.Exec("DELETE FROM team_members WHERE team_id = ?", id); err != nil {
+        return err
+    }
+    result, err := tx.Exec("DELETE FROM teams WHERE id = ?", id)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrTeamNotFound); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLTeamStore) Members(teamID int) ([]TeamMember, error) {
+    rows, err := s.db.Query(`
+        SELECT m.team_id, m.user_id, u.username, m.role, u.disabled
+        FROM team_members m
+        JOIN users u ON u.id = m.user_id
+        WHERE m.team_id = ?
+        ORDER BY u.username`, teamID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var members []TeamMember
+    for rows.Next() {
+        var m TeamMember
+        if err := rows.Scan(&m.TeamID, &m.UserID, &m.Username, &m.Role, &m.Disabled); err != nil {
+            return nil, err
+        }
+        members = append(members, m)
+    }
+    return members, rows.Err()
+}
+
+func (s *SQLTeamStore) SetMember(teamID, userID int, role string) error {
+    result, err := s.db.Exec("UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?", role, teamID, userID)
+    if err != nil {
+        return err
+    }
+    if n, err := result.RowsAffected(); err != nil || n > 0 {
+        return err
+    }
+    _, err = s.db.Exec("INSERT INTO team_members (team_id, user_id, role) VALUES (?, ?, ?)", teamID, userID, role)
+    return err
+}
+
+func (s *SQLTeamStore) RemoveMember(teamID, userID int) error {
+    result, err := s.db.Exec("DELETE FROM team_members WHERE team_id = ? AND user_id = ?", teamID, userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrTeamMemberNotFound)
+}
+
+func (s *SQLTeamStore) MemberRole(teamID, userID int) (string, error) {
+    var role string
+    err := s.db.QueryRow(
+        "SELECT role FROM team_members WHERE team_id = ? AND user_id = ?", teamID, userID).Scan(&role)
+    if err == sql.ErrNoRows {
+        return "", ErrTeamMemberNotFound
+    }
+    return role, err
+}
+
+func (s *SQLTeamStore) Manages(managerID, userID int) (bool, error) {
+    var n int
+    err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM team_members m
+        JOIN team_members mm ON mm.team_id = m.team_id
+        WHERE mm.user_id = ? AND mm.role = ? AND m.user_id = ? AND m.role = ?`,
+        managerID, TeamRoleManager, userID, TeamRoleMember).Scan(&n)
+    return n > 0, err
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+)
+
+// a WorkLogStore and what its worklogs refer to
+type workLogStoreFixture struct {
+    store      WorkLogStore
+    addUser    func(t *testing.T, username string) int
+    addProject func(t *testing.T, userID int, name string, withClient bool) (projectID, clientID int)
+}
+
+// SQLWorkLogStore on the database of setup (setupTestDB or setupPostgresTestDB)
+func sqlWorkLogStoreFixture(t *testing.T, setup func(t *testing.T)) workLogStoreFixture {
+    setup(t)
+    return workLogStoreFixture{
+        store: worklogStore,
+        addUser: func(t *testing.T, username string) int {
+            return createTestUser(t, username).ID
+        },
+        addProject: func(t *testing.T, userID int, name string, withClient bool) (int, int) {
+            t.Helper()
+            p := &Project{UserID: userID, Name: name}
+            if withClient {
+                cl := &Client{UserID: userID, Name: name + " client"}
+                if err := clientStore.Create(cl); err != nil {
+                    t.Fatal(err)
+                }
+                p.ClientID = cl.ID
+            }
+            if err := projectStore.Create(p); err != nil {
+                t.Fatal(err)
+            }
+            return p.ID, p.ClientID
+        },
+    }
+}
+
+func memoryWorkLogStoreFixture(t *testing.T) workLogStoreFixture {
+    mem := NewMemoryWorkLogStore()
+    users, ids := 0, 0
+    return workLogStoreFixture{
+        store: mem,
+        addUser: func(t *testing.T, username string) int {
+            users++
+            return users
+        },
+        addProject: func(t *testing.T, userID int, name string, withClient bool) (int, int) {
+            ids++
+            p := Project{ID: ids, UserID: userID, Name: name}
+            if withClient {
+                ids++
+                p.ClientID = ids
+            }
+            mem.AddProject(p)
+            return p.ID, p.ClientID
+        },
+    }
+}
+
+// the SQL store on both backends and the in-memory store of the tests have to behave the same
+func TestWorkLogStore(t *testing.T) {
+    fixtures := map[string]func(t *testing.T) workLogStoreFixture{
+        "sqlite": func(t *testing.T) workLogStoreFixture {
+            return sqlWorkLogStoreFixture(t, setupTestDB)
+        },
+        "postgres": func(t *testing.T) workLogStoreFixture {
+            return sqlWorkLogStoreFixture(t, setupPostgresTestDB)
+        },
+        "memory": memoryWorkLogStoreFixture,
+    }
+    for name, setup := range fixtures {
+        t.Run(name, func(t *testing.T) {
+            testWorkLogStore(t, setup(t))
+        })
+    }
+}
+
+func testWorkLogStore(t *testing.T, fx workLogStoreFixture) {
+    s := fx.store
+    u, v := fx.addUser(t, "alice"), fx.addUser(t, "bob")
+    alpha, client := fx.addProject(t, u, "alpha", true)
+    beta, _ := fx.addProject(t, u, "beta", false)
+    audit := &Audit{ActorID: u, ActorName: "test", Channel: ChannelSystem}
+
+    day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+    create := func(log WorkLog) WorkLog {
+        t.Helper()
+        if err := s.Create(&log, audit); err != nil {
+            t.Fatalf("create %q: %v", log.Description, err)
+        }
+        if log.ID == 0 {
+            t.Fatalf("create %q: no id", log.Description)
+        }
+        return log
+    }
+    a := create(WorkLog{UserID: u, ProjectID: alpha, Date: day(2), Description: "Frontend Review", Hours: 2,
+        Billable: true, Tags: []string{"urgent", "client"}})
+    b := create(WorkLog{UserID: u, ProjectID: beta, Date: day(3), Description: "backend work", Hours: 3,
+        Tags: []string{"urgent"}})
+    c := create(WorkLog{UserID: u, Date: day(3), StartTime: "09:00", EndTime: "10:15", BreakMinutes: 15,
+        Description: "meeting", Hours: 1, Billable: true})
+    d := create(WorkLog{UserID: u, ProjectID: alpha, Date: day(5), Description: "review notes", Hours: 4,
+        Billable: true, Tags: []string{"client"}})
+    foreign := create(WorkLog{UserID: v, Date: day(3), Description: "foreign", Hours: 5})
+
+    got, err := s.Get(u, a.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.ProjectName != "alpha" || !got.Date.Equal(a.Date) || got.Hours != 2 || !got.Billable ||
+        got.Description != a.Description || !reflect.DeepEqual(got.Tags, []string{"client", "urgent"}) {
+        t.Fatalf("get: %+v", got)
+    }
+    if got, err := s.Get(u, c.ID); err != nil || got.StartTime != "09:00" || got.EndTime != "10:15" ||
+        got.BreakMinutes != 15 || got.Tags != nil || got.ProjectName != "" {
+        t.Fatalf("get with times: %+v %v", got, err)
+    }
+    if got.UpdatedAt.IsZero() {
+        t.Fatalf("get: no updated_at")
+    }
+    if _, err := s.Get(v, a.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("get of another user: %v", err)
+    }
+
+    ids := func(logs []WorkLog) []int {
+        out := []int{}
+        for _, log := range logs {
+            out = append(out, log.ID)
+        }
+        return out
+    }
+    lists := []struct {
+        name string
+        f    WorkLogFilter
+        want []WorkLog
+    }{
+        {"default", WorkLogFilter{}, []WorkLog{d, c, b, a}},
+        {"date range", WorkLogFilter{DateFrom: "2026-03-03", DateTo: "2026-03-03"}, []WorkLog{c, b}},
+        {"search ignores case", WorkLogFilter{Search: "REVIEW"}, []WorkLog{d, a}},
+        {"project", WorkLogFilter{ProjectID: alpha}, []WorkLog{d, a}},
+        {"client", WorkLogFilter{ClientID: client}, []WorkLog{d, a}},
+        {"one tag", WorkLogFilter{Tags: []string{"urgent"}}, []WorkLog{b, a}},
+        {"all tags", WorkLogFilter{Tags: []string{"urgent", "client"}}, []WorkLog{a}},
+        {"billable", WorkLogFilter{BillableOnly: true}, []WorkLog{d, c, a}},
+        {"uninvoiced", WorkLogFilter{UninvoicedOnly: true}, []WorkLog{d, c, b, a}},
+        {"date asc", WorkLogFilter{Sort: SortDateAsc}, []WorkLog{a, b, c, d}},
+        {"hours desc", WorkLogFilter{Sort: SortHoursDesc}, []WorkLog{d, b, a, c}},
+        {"hours asc", WorkLogFilter{Sort: SortHoursAsc}, []WorkLog{c, a, b, d}},
+        {"limit and offset", WorkLogFilter{Limit: 2, Offset: 1}, []WorkLog{c, b}},
+        {"offset only", WorkLogFilter{Offset: 3}, []WorkLog{a}},
+        {"offset past the end", WorkLogFilter{Offset: 10}, nil},
+    }
+    defer func(n int) { eachChunk = n }(eachChunk)
+    for _, tt := range lists {
+        logs, err := s.List(u, tt.f)
+        if err != nil {
+            t.Fatalf("list %s: %v", tt.name, err)
+        }
+        if !reflect.DeepEqual(ids(logs), ids(tt.want)) {
+            t.Fatalf("list %s: %v, want %v", tt.name, ids(logs), ids(tt.want))
+        }
+
+        // also in chunks smaller than the list, each one going on after the one before
+        for _, chunk := range []int{500, 1, 3} {
+            eachChunk = chunk
+            var each []WorkLog
+            err = s.Each(u, tt.f, func(log WorkLog) error {
+                each = append(each, log)
+                return nil
+            })
+            if err != nil || !reflect.DeepEqual(each, logs) {
+                t.Fatalf("each %s in chunks of %d: %v %v, want the list", tt.name, chunk, ids(each), err)
+            }
+        }
+    }
+
+    stop := errors.New("stop")
+    if err := s.Each(u, WorkLogFilter{}, func(WorkLog) error { return stop }); err != stop {
+        t.Fatalf("each passes the error of fn on: %v", err)
+    }
+    if logs, err := s.ListForUsers([]int{u, v}, WorkLogFilter{DateFrom: "2026-03-03", DateTo: "2026-03-03"}); err != nil ||
+        !reflect.DeepEqual(ids(logs), []int{foreign.ID, c.ID, b.ID}) {
+        t.Fatalf("list for users: %v %v", ids(logs), err)
+    }
+    if logs, err := s.ListForUsers(nil, WorkLogFilter{}); err != nil || len(logs) != 0 {
+        t.Fatalf("list for no users: %v %v", ids(logs), err)
+    }
+
+    many := []WorkLog{
+        {UserID: u, Date: day(9), Description: "import 1", Hours: 1},
+        {UserID: u, Date: day(9), Description: "import 2", Hours: 1, Tags: []string{"imported"}},
+    }
+    if err := s.CreateMany(many, nil, audit); err != nil {
+        t.Fatal(err)
+    }
+    for _, log := range many {
+        if got, err := s.Get(u, log.ID); err != nil || got.Description != log.Description {
+            t.Fatalf("create many: %+v %v", got, err)
+        }
+    }
+
+    changed := a
+    changed.Description, changed.Hours, changed.Tags = "changed", 2.5, []string{"new"}
+    if err := s.Update(&changed, audit); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := s.Get(u, a.ID); err != nil || got.Description != "changed" || got.Hours != 2.5 ||
+        !reflect.DeepEqual(got.Tags, []string{"new"}) {
+        t.Fatalf("after update: %+v %v", got, err)
+    }
+    stolen := changed
+    stolen.UserID = v
+    if err := s.Update(&stolen, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("update of another user: %v", err)
+    }
+
+    // trash
+    if err := s.Delete(v, b.ID, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("delete of another user: %v", err)
+    }
+    for _, log := range []WorkLog{b, c} {
+        if err := s.Delete(u, log.ID, audit); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if _, err := s.Get(u, b.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("get of a trashed worklog: %v", err)
+    }
+    if got, err := s.GetTrashed(u, b.ID); err != nil || got.DeletedAt.IsZero() {
+        t.Fatalf("get trashed: %+v %v", got, err)
+    }
+    if _, err := s.GetTrashed(u, a.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("get trashed of a live worklog: %v", err)
+    }
+    if err := s.Update(&b, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("update of a trashed worklog: %v", err)
+    }
+    if err := s.Delete(u, b.ID, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("second delete: %v", err)
+    }
+    if logs, err := s.List(u, WorkLogFilter{DateFrom: "2026-03-03", DateTo: "2026-03-03"}); err != nil || len(logs) != 0 {
+        t.Fatalf("list shows the trash: %v %v", ids(logs), err)
+    }
+    if logs, err := s.ListTrash(u); err != nil || !reflect.DeepEqual(ids(logs), []int{c.ID, b.ID}) {
+        t.Fatalf("list trash: %v %v", ids(logs), err)
+    }
+    if logs, err := s.ListTrash(v); err != nil || len(logs) != 0 {
+        t.Fatalf("list trash of another user: %v %v", ids(logs), err)
+    }
+
+    restored := b
+    restored.Hours = 6
+    if err := s.Restore(&restored, audit); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := s.Get(u, b.ID); err != nil || got.Hours != 6 || !got.DeletedAt.IsZero() {
+        t.Fatalf("after restore: %+v %v", got, err)
+    }
+    if err := s.Restore(&restored, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("restore of a live worklog: %v", err)
+    }
+
+    if err := s.Purge(u, a.ID, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("purge of a live worklog: %v", err)
+    }
+    if err := s.Purge(v, c.ID, audit); err != ErrWorkLogNotFound {
+        t.Fatalf("purge of another user: %v", err)
+    }
+    if err := s.Purge(u, c.ID, audit); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.GetTrashed(u, c.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("purged worklog still in the trash: %v", err)
+    }
+
+    if err := s.Delete(u, d.ID, audit); err != nil {
+        t.Fatal(err)
+    }
+    if n, err := s.PurgeTrash(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+        t.Fatalf("purge trash before the delete: %d %v", n, err)
+    }
+    if n, err := s.PurgeTrash(time.Now().Add(time.Hour)); err != nil || n != 1 {
+        t.Fatalf("purge trash: %d %v", n, err)
+    }
+    if _, err := s.GetTrashed(u, d.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("expired worklog still in the trash: %v", err)
+    }
+    if logs, err := s.List(u, WorkLogFilter{}); err != nil || len(logs) != 4 {
+        t.Fatalf("live worklogs after the purge: %v %v", ids(logs), err)
+    }
+}
+
+// the projects and clients of an import exist only if its worklogs were created
+func TestCreateManyProjects(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            user := createTestUser(t, "alice")
+            acme := &Client{UserID: user.ID, Name: "ACME"}
+            if err := clientStore.Create(acme); err != nil {
+                t.Fatal(err)
+            }
+            createTestWorkLog(t, WorkLog{UserID: user.ID, Date: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+                Description: "existing", Hours: 1})
+            audit := &Audit{ActorName: "test", Channel: ChannelCLI}
+            newProjects := func() []Project {
+                return []Project{
+                    {UserID: user.ID, Name: "Site", ClientName: "acme"},
+                    {UserID: user.ID, Name: "App", ClientName: "Globex"},
+                    {UserID: user.ID, Name: "Internal"},
+                }
+            }
+            logs := func(hours float64) []WorkLog {
+                return []WorkLog{
+                    {UserID: user.ID, Date: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), Description: "a", Hours: 1, ProjectName: "site"},
+                    {UserID: user.ID, Date: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), Description: "b", Hours: hours, ProjectName: "App"},
+                }
+            }
+
+            // the second worklog goes over 24 hours on the day, nothing of the import is left
+            if err := worklogStore.CreateMany(logs(23), newProjects(), audit); err != ErrDayLimit {
+                t.Fatalf("CreateMany over the day limit: %v", err)
+            }
+            if projects, err := projectStore.List(user.ID); err != nil || len(projects) != 0 {
+                t.Fatalf("projects after a failed import: %v %v", projects, err)
+            }
+            if clients, err := clientStore.List(user.ID); err != nil || len(clients) != 1 {
+                t.Fatalf("clients after a failed import: %v %v", clients, err)
+            }
+
+            created, projects := logs(1), newProjects()
+            if err := worklogStore.CreateMany(created, projects, audit); err != nil {
+                t.Fatal(err)
+            }
+            if projects[0].ClientID != acme.ID || projects[1].ClientID == 0 || projects[1].ClientID == acme.ID || projects[2].ClientID != 0 {
+                t.Fatalf("clients of the new projects: %+v", projects)
+            }
+            for i, want := range []int{projects[0].ID, projects[1].ID} {
+                if got, err := worklogStore.Get(user.ID, created[i].ID); err != nil || got.ProjectID != want {
+                    t.Fatalf("worklog %d: %+v %v, want project %d", i, got, err, want)
+                }
+            }
+        })
+    }
+}
+
+// writes that passed PrepareWorkLog at the same time: the store checks the day again
+// in its transaction, only one of the overlapping entries and 24h in total get in
+func TestParallelWorkLogCreates(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            user := createTestUser(t, "alice")
+            date := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+            create := func(logs []WorkLog) []error {
+                for i := range logs {
+                    if err := PrepareWorkLog(&logs[i], false); err != nil {
+                        t.Fatal(err)
+                    }
+                }
+                start := make(chan struct{})
+                errs := make([]error, len(logs))
+                var wg sync.WaitGroup
+                for i := range logs {
+                    wg.Add(1)
+                    go func(i int) {
+                        defer wg.Done()
+                        <-start
+                        errs[i] = worklogStore.Create(&logs[i], &Audit{ActorID: user.ID, ActorName: "test", Channel: ChannelAPI})
+                    }(i)
+                }
+                close(start)
+                wg.Wait()
+                return errs
+            }
+            count := func(errs []error, target error) (ok, refused int) {
+                for _, err := range errs {
+                    switch {
+                    case err == nil:
+                        ok++
+                    case errors.Is(err, target):
+                        refused++
+                    default:
+                        t.Fatal(err)
+                    }
+                }
+                return ok, refused
+            }
+
+            var overlapping []WorkLog
+            for i := 0; i < 5; i++ {
+                overlapping = append(overlapping, WorkLog{UserID: user.ID, Date: date, StartTime: "09:00", EndTime: "13:00",
+                    Description: fmt.Sprintf("meeting %d", i)})
+            }
+            if ok, refused := count(create(overlapping), ErrOverlap); ok != 1 || refused != 4 {
+                t.Fatalf("overlapping creates: %d stored, %d refused", ok, refused)
+            }
+
+            var long []WorkLog
+            for i := 0; i < 4; i++ {
+                long = append(long, WorkLog{UserID: user.ID, Date: date, Hours: 8, Description: fmt.Sprintf("work %d", i)})
+            }
+            if ok, refused := count(create(long), ErrDayLimit); ok != 2 || refused != 2 {
+                t.Fatalf("creates over 24h: %d stored, %d refused", ok, refused)
+            }
+            if logs, err := worklogStore.List(user.ID, WorkLogFilter{}); err != nil || len(logs) != 3 {
+                t.Fatalf("worklogs of the day: %d %v", len(logs), err)
+            }
+        })
+    }
+}
+
+// CreateMany checks every log in its transaction like Create, against the database and the
+// logs before it; one failing log stops the import, the closed period rows of an override are marked
+func TestCreateManyChecksRows(t *testing.T) {
+    for name, setup := range map[string]func(t *testing.T){"sqlite": setupTestDB, "postgres": setupPostgresTestDB} {
+        t.Run(name, func(t *testing.T) {
+            setup(t)
+            user := createTestUser(t, "alice")
+            day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+            createTestWorkLog(t, WorkLog{UserID: user.ID, Date: day, StartTime: "09:00", EndTime: "13:00", Description: "existing"})
+            audit := &Audit{ActorName: "test", Channel: ChannelCLI}
+            // as the import checked them before, the database may have changed since
+            row := func(date time.Time, start, end, description string) WorkLog {
+                from, _ := parseClock(start)
+                to, _ := parseClock(end)
+                return WorkLog{UserID: user.ID, Date: date, StartTime: start, EndTime: end, Description: description,
+                    Hours: float64(to-from) / 60}
+            }
+            next := day.AddDate(0, 0, 1)
+
+            for name, logs := range map[string][]WorkLog{
+                "overlaps the database": {row(next, "09:00", "10:00", "ok"), row(day, "12:00", "14:00", "late")},
+                "overlaps a row before": {row(next, "09:00", "10:00", "a"), row(next, "09:30", "11:00", "b")},
+            } {
+                if err := worklogStore.CreateMany(logs, nil, audit); !errors.Is(err, ErrOverlap) {
+                    t.Fatalf("%s: %v", name, err)
+                }
+            }
+            if logs, err := worklogStore.List(user.ID, WorkLogFilter{}); err != nil || len(logs) != 1 {
+                t.Fatalf("worklogs after failed imports: %d %v", len(logs), err)
+            }
+
+            if err := lockStore.Set(&PeriodLock{LockDate: day, UpdatedBy: "admin"}); err != nil {
+                t.Fatal(err)
+            }
+            logs := []WorkLog{row(day, "14:00", "15:00", "closed"), row(next, "09:00", "10:00", "open")}
+            if err := worklogStore.CreateMany(logs, nil, audit); !errors.Is(err, ErrPeriodLocked) {
+                t.Fatalf("import into a closed period: %v", err)
+            }
+            audit.OverrideLock = true
+            if err := worklogStore.CreateMany(logs, nil, audit); err != nil {
+                t.Fatal(err)
+            }
+            for i, want := range []bool{true, false} {
+                changes, err := historyStore.ForWorkLog(user.ID, logs[i].ID)
+                if err != nil || len(changes) != 1 || changes[0].LockOverride != want {
+                    t.Fatalf("history of row %d: %+v %v", i, changes, err)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+)
+
+var (
+    ErrTimerRunning    = errors.New("timer already running")
+    ErrTimerNotRunning = errors.New("timer not running")
+)
+
+type TimerStore interface {
+    Get(userID int) (*Timer, error)
+    Start(t *Timer) error
+    // remove the timer and save log in one transaction
+    Finish(t *Timer, log *WorkLog, a *Audit) error
+    Discard(userID int) error // remove the timer without a worklog
+}
+
+// TimerStore on top of SQLite or Postgres
+type SQLTimerStore struct {
+    db *DB
+}
+
+func NewSQLTimerStore(db *DB) *SQLTimerStore {
+    return &SQLTimerStore{db: db}
+}
+
+func (s *SQLTimerStore) Get(userID int) (*Timer, error) {
+    t := &Timer{}
+    var projectID sql.NullInt64
+    var projectName sql.NullString
+    var startedAt dbTime
+    err := s.db.QueryRow(`
+        SELECT t.user_id, t.project_id, p.name, t.description, t.started_at
+        FROM timers t
+        LEFT JOIN projects p ON p.id = t.project_id
+        WHERE t.user_id = ?`, userID,
+    ).Scan(&t.UserID, &projectID, &projectName, &t.Description, &startedAt)
+
+    if err == sql.ErrNoRows {
+        return nil, ErrTimerNotRunning
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    t.ProjectID = int(projectID.Int64)
+    t.ProjectName = projectName.String
+    t.StartedAt = startedAt.Time
+    return t, nil
+}
+
+func (s *SQLTimerStore) Start(t *Timer) error {
+    _, err := s.db.Exec(
+        "INSERT INTO timers (user_id, project_id, description, started_at) VALUES (?, ?, ?, ?)",
+        t.UserID, nullID(t.ProjectID), t.Description, timeValue(t.StartedAt),
+    )
+    if err != nil {
+        // user_id is the primary key: insert fails when a timer is already running
+        if _, getErr := s.Get(t.UserID); getErr == nil {
+            return ErrTimerRunning
+        }
+        return err
+    }
+    return nil
+}
+
+func (s *SQLTimerStore) Finish(t *Timer, log *WorkLog, a *Audit) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := lockWorkLogs(tx, t.UserID); err != nil {
+        return err
+    }
+    // stop twice (two tabs, two API calls) -> only the first one creates a worklog
+    result, err := tx.Exec("DELETE FROM timers WHERE user_id = ?", t.UserID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrTimerNotRunning); err != nil {
+        return err
+    }
+
+    // timers never override a closed period
+    if _, err := checkWorkLogWrite(tx, nil, log, nil); err != nil {
+        return err
+    }
+    if err := insertWorkLog(tx, log); err != nil {
+        return err
+    }
+    if err := recordInsert(tx, log, a, false); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLTimerStore) Discard(userID int) error {
+    result, err := s.db.Exec("DELETE FROM timers WHERE user_id = ?", userID)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrTimerNotRunning)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var (
+    ErrTimesheetNotFound = errors.New("timesheet not found")
+    ErrTimesheetChanged  = errors.New("timesheet was changed meanwhile")
+)
+
+type TimesheetStore interface {
+    Get(id int) (*Timesheet, error)
+    // ErrTimesheetNotFound while the week was never submitted
+    GetWeek(userID, year, week int) (*Timesheet, error)
+    ListForUser(userID int) ([]Timesheet, error)
+    // timesheets with the status of the members of the teams managerID manages, 0 = all users
+    ListByStatus(status string, managerID int) ([]Timesheet, error)
+    // move t to e.ToStatus and record e; t.ID = 0 creates the timesheet.
+    // ErrTimesheetChanged if the status is no longer t.Status
+    Transition(t *Timesheet, e *TimesheetEvent) error
+    Events(timesheetID int) ([]TimesheetEvent, error)
+}
+
+// TimesheetStore on top of SQLite or Postgres
+type SQLTimesheetStore struct {
+    db *DB
+}
+
+func NewSQLTimesheetStore(db *DB) *SQLTimesheetStore {
+    return &SQLTimesheetStore{db: db}
+}
+
+const timesheetSelect = `
+    SELECT s.id, s.user_id, u.username, s.year, s.week, s.status, s.updated_at
+    FROM timesheets s
+    JOIN users u ON u.id = s.user_id`
+
+func scanTimesheet(row rowScanner) (*Timesheet, error) {
+    t := &Timesheet{}
+    var updatedAt dbTime
+    if err := row.Scan(&t.ID, &t.UserID, &t.Username, &t.Year, &t.Week, &t.Status, &updatedAt); err != nil {
+        return nil, err
+    }
+    t.UpdatedAt = updatedAt.Time
+    return t, nil
+}
+
+func (s *SQLTimesheetStore) one(query string, args ...interface{}) (*Timesheet, error) {
+    t, err := scanTimesheet(s.db.QueryRow(query, args...))
+    if err == sql.ErrNoRows {
+        return nil, ErrTimesheetNotFound
+    }
+    return t, err
+}
+
+func (s *SQLTimesheetStore) list(query string, args ...interface{}) ([]Timesheet, error) {
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var sheets []Timesheet
+    for rows.Next() {
+        t, err := scanTimesheet(rows)
+        if err != nil {
+            return nil, err
+        }
+        sheets = append(sheets, *t)
+    }
+    return sheets, rows.Err()
+}
+
+func (s *SQLTimesheetStore) Get(id int) (*Timesheet, error) {
+    return s.one(timesheetSelect+` WHERE s.id = ?`, id)
+}
+
+func (s *SQLTimesheetStore) GetWeek(userID, year, week int) (*Timesheet, error) {
+    return s.one(timesheetSelect+` WHERE s.user_id = ? AND s.year = ? AND s.week = ?`, userID, year, week)
+}
+
+func (s *SQLTimesheetStore) ListForUser(userID int) ([]Timesheet, error) {
+    return s.list(timesheetSelect+` WHERE s.user_id = ? ORDER BY s.year DESC, s.week DESC`, userID)
+}
+
+func (s *SQLTimesheetStore) ListByStatus(status string, managerID int) ([]Timesheet, error) {
+    if managerID == 0 {
+        return s.list(timesheetSelect+` WHERE s.status = ? ORDER BY s.year, s.week, u.username`, status)
+    }
+    return s.list(timesheetSelect+`
+        WHERE s.status = ? AND s.user_id IN (
+            SELECT m.user_id FROM team_members m
+            JOIN team_members mm ON mm.team_id = m.team_id
+            WHERE mm.user_id = ? AND mm.role = ? AND m.role = ?)
+        ORDER BY s.year, s.week, u.username`, status, managerID, TeamRoleManager, TeamRoleMember)
+}
+
+// status of the week, draft if it was never submitted
+func weekStatus(q querier, userID, year, week int) (string, error) {
+    var status string
+    err := q.QueryRow("SELECT status FROM timesheets WHERE user_id = ? AND year = ? AND week = ?",
+        userID, year, week).Scan(&status)
+    if err == sql.ErrNoRows {
+        return TimesheetDraft, nil
+    }
+    return status, err
+}
+
+func (s *SQLTimesheetStore) Transition(t *Timesheet, e *TimesheetEvent) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // waits for worklog writes of the owner: they checked the old status
+    if err := lockWorkLogs(tx, t.UserID); err != nil {
+        return err
+    }
+
+    now := time.Now()
+    if t.ID == 0 {
+        // a concurrent first submit runs into UNIQUE (user_id, year, week)
+        err := tx.QueryRow(
+            "INSERT INTO timesheets (user_id, year, week, status, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+            t.UserID, t.Year, t.Week, e.ToStatus, timeValue(now),
+        ).Scan(&t.ID)
+        if err != nil {
+            return ErrTimesheetChanged
+        }
+    } else {
+        result, err := tx.Exec(
+            "UPDATE timesheets SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+            e.ToStatus, timeValue(now), t.ID, e.FromStatus)
+        if err != nil {
+            return err
+        }
+        if err := requireAffected(result, ErrTimesheetChanged); err != nil {
+            return err
+        }
+    }
+
+    e.TimesheetID = t.ID
+    e.CreatedAt = now
+    err = tx.QueryRow(`
+        INSERT INTO timesheet_events (timesheet_id, from_status, to_status, actor_id, actor_name, comment, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+        e.TimesheetID, e.FromStatus, e.ToStatus, nullID(e.ActorID), e.ActorName, e.Comment, timeValue(now),
+    ).Scan(&e.ID)
+    if err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    t.Status = e.ToStatus
+    t.UpdatedAt = now
+    return nil
+}
+
+func (s *SQLTimesheetStore) Events(timesheetID int) ([]TimesheetEvent, error) {
+    rows, err := s.db.Query(`
+        SELECT id, timesheet_id, from_status, to_status, actor_id, actor_name, comment, created_at
+        FROM timesheet_events
+        WHERE timesheet_id = ?
+        ORDER BY created_at, id`, timesheetID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var events []TimesheetEvent
+    for rows.Next() {
+        var e TimesheetEvent
+        var actorID sql.NullInt64
+        var createdAt dbTime
+        if err := rows.Scan(&e.ID, &e.TimesheetID, &e.FromStatus, &e.ToStatus, &actorID, &e.ActorName, &e.Comment, &createdAt); err != nil {
+            return nil, err
+        }
+        e.ActorID = int(actorID.Int64)
+        e.CreatedAt = createdAt.Time
+        events = append(events, e)
+    }
+    return events, rows.Err()
+}
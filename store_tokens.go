@@ -0,0 +1,154 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var (
+    ErrInvalidRefreshToken = errors.New("invalid refresh token")
+    ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+type TokenStore interface {
+    CreateRefresh(t *RefreshToken) error
+    // mark the old token used and save next in the same family, in one transaction;
+    // (next gets user and family of the old one); an old token that was already used
+    // or revoked revokes the whole family (ErrRefreshTokenReused)
+    Rotate(hash string, next *RefreshToken) error
+    GetRefresh(hash string) (*RefreshToken, error)
+    RevokeFamily(familyID string) error
+    // access tokens are stateless, a revoked jti is kept until the token expires anyway
+    RevokeAccess(jti string, expiresAt time.Time) error
+    IsAccessRevoked(jti string) (bool, error)
+    DeleteExpired(now time.Time) error
+}
+
+// TokenStore on top of SQLite or Postgres
+type SQLTokenStore struct {
+    db *DB
+}
+
+func NewSQLTokenStore(db *DB) *SQLTokenStore {
+    return &SQLTokenStore{db: db}
+}
+
+func (s *SQLTokenStore) CreateRefresh(t *RefreshToken) error {
+    return insertRefreshToken(s.db, t)
+}
+
+func insertRefreshToken(q querier, t *RefreshToken) error {
+    return q.QueryRow(
+        `INSERT INTO refresh_tokens (user_id, family_id, token_hash, issued_at, expires_at)
+        VALUES (?, ?, ?, ?, ?) RETURNING id`,
+        t.UserID, t.FamilyID, t.TokenHash, timeValue(t.IssuedAt), timeValue(t.ExpiresAt),
+    ).Scan(&t.ID)
+}
+
+func getRefreshToken(q querier, hash string) (*RefreshToken, error) {
+    t := &RefreshToken{}
+    var issuedAt, expiresAt, usedAt, revokedAt dbTime
+    err := q.QueryRow(`
+        SELECT id, user_id, family_id, token_hash, issued_at, expires_at, used_at, revoked_at
+        FROM refresh_tokens WHERE token_hash = ?`, hash,
+    ).Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &issuedAt, &expiresAt, &usedAt, &revokedAt)
+
+    if err == sql.ErrNoRows {
+        return nil, ErrInvalidRefreshToken
+    }
+    if err != nil {
+        return nil, err
+    }
+    t.IssuedAt = issuedAt.Time
+    t.ExpiresAt = expiresAt.Time
+    t.UsedAt = usedAt.Time
+    t.RevokedAt = revokedAt.Time
+    return t, nil
+}
+
+func (s *SQLTokenStore) GetRefresh(hash string) (*RefreshToken, error) {
+    return getRefreshToken(s.db, hash)
+}
+
+func (s *SQLTokenStore) Rotate(hash string, next *RefreshToken) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    old, err := getRefreshToken(tx, hash)
+    if err != nil {
+        return err
+    }
+    now := next.IssuedAt
+
+    // used twice = stolen, nobody in the family can be trusted any more
+    if !old.UsedAt.IsZero() || !old.RevokedAt.IsZero() {
+        if err := revokeFamily(tx, old.FamilyID, now); err != nil {
+            return err
+        }
+        if err := tx.Commit(); err != nil {
+            return err
+        }
+        return ErrRefreshTokenReused
+    }
+    if !now.Before(old.ExpiresAt) {
+        return ErrInvalidRefreshToken
+    }
+
+    result, err := tx.Exec(
+        "UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL",
+        timeValue(now), old.ID)
+    if err != nil {
+        return err
+    }
+    // a parallel refresh with the same token won the race
+    if err := requireAffected(result, ErrRefreshTokenReused); err != nil {
+        return err
+    }
+
+    next.UserID = old.UserID
+    next.FamilyID = old.FamilyID
+    if err := insertRefreshToken(tx, next); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    return nil
+}
+
+func revokeFamily(q querier, familyID string, now time.Time) error {
+    _, err := q.Exec(
+        "UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+        timeValue(now), familyID)
+    return err
+}
+
+func (s *SQLTokenStore) RevokeFamily(familyID string) error {
+    return revokeFamily(s.db, familyID, time.Now())
+}
+
+func (s *SQLTokenStore) RevokeAccess(jti string, expiresAt time.Time) error {
+    _, err := s.db.Exec(
+        "INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?) ON CONFLICT (jti) DO NOTHING",
+        jti, timeValue(expiresAt))
+    return err
+}
+
+func (s *SQLTokenStore) IsAccessRevoked(jti string) (bool, error) {
+    var n int
+    err := s.db.QueryRow("SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&n)
+    return n > 0, err
+}
+
+// expired tokens are useless either way, both tables only grow without this
+func (s *SQLTokenStore) DeleteExpired(now time.Time) error {
+    if _, err := s.db.Exec("DELETE FROM revoked_tokens WHERE expires_at < ?", timeValue(now)); err != nil {
+        return err
+    }
+    _, err := s.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", timeValue(now))
+    return err
+}
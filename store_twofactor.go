@@ -0,0 +1,181 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+)
+
+var (
+    ErrInvalidOTP       = errors.New("invalid code")
+    ErrInvalidChallenge = errors.New("invalid or expired challenge")
+)
+
+// a challenge is dropped after this many wrong codes
+const maxChallengeAttempts = 5
+
+type TwoFactorStore interface {
+    // new secret, 2FA stays off until Enable (an enabled 2FA is not touched)
+    SetSecret(userID int, secret string) error
+    // turn 2FA on and replace the recovery codes, in one transaction
+    Enable(userID int, codeHashes []string) error
+    // 2FA off: secret, recovery codes and challenges are removed (disable + admin reset)
+    Reset(userID int) error
+    // accept a TOTP time step only once, ErrInvalidOTP for an already used one
+    UseStep(userID int, step int64) error
+
+    ReplaceRecoveryCodes(userID int, codeHashes []string) error
+    UseRecoveryCode(userID int, codeHash string, now time.Time) error // ErrInvalidOTP if unknown or used
+    CountRecoveryCodes(userID int) (int, error)                       // unused ones
+
+    CreateChallenge(tokenHash string, userID int, expiresAt time.Time) error
+    // user of a valid challenge, counts the attempt
+    ChallengeUser(tokenHash string, now time.Time) (int, error)
+    DeleteChallenge(tokenHash string) error
+}
+
+// TwoFactorStore on top of SQLite or Postgres
+type SQLTwoFactorStore struct {
+    db *DB
+}
+
+func NewSQLTwoFactorStore(db *DB) *SQLTwoFactorStore {
+    return &SQLTwoFactorStore{db: db}
+}
+
+func (s *SQLTwoFactorStore) SetSecret(userID int, secret string) error {
+    result, err := s.db.Exec(
+        "UPDATE users SET totp_secret = ?, totp_last_step = NULL WHERE id = ? AND totp_enabled = ?",
+        secret, userID, false)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrUserNotFound)
+}
+
+func (s *SQLTwoFactorStore) Enable(userID int, codeHashes []string) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.Exec(
+        "UPDATE users SET totp_enabled = ? WHERE id = ? AND totp_secret IS NOT NULL", true, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrUserNotFound); err != nil {
+        return err
+    }
+    if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLTwoFactorStore) Reset(userID int) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.Exec(
+        "UPDATE users SET totp_secret = NULL, totp_enabled = ?, totp_last_step = NULL WHERE id = ?", false, userID)
+    if err != nil {
+        return err
+    }
+    if err := requireAffected(result, ErrUserNotFound); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("DELETE FROM mfa_challenges WHERE user_id = ?", userID); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLTwoFactorStore) UseStep(userID int, step int64) error {
+    result, err := s.db.Exec(
+        "UPDATE users SET totp_last_step = ? WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)",
+        step, userID, step)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrInvalidOTP)
+}
+
+func (s *SQLTwoFactorStore) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func replaceRecoveryCodes(q querier, userID int, codeHashes []string) error {
+    if _, err := q.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+        return err
+    }
+    for _, h := range codeHashes {
+        if _, err := q.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, h); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *SQLTwoFactorStore) UseRecoveryCode(userID int, codeHash string, now time.Time) error {
+    result, err := s.db.Exec(
+        "UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+        timeValue(now), userID, codeHash)
+    if err != nil {
+        return err
+    }
+    return requireAffected(result, ErrInvalidOTP)
+}
+
+func (s *SQLTwoFactorStore) CountRecoveryCodes(userID int) (int, error) {
+    var n int
+    err := s.db.QueryRow(
+        "SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&n)
+    return n, err
+}
+
+func (s *SQLTwoFactorStore) CreateChallenge(tokenHash string, userID int, expiresAt time.Time) error {
+    // old challenges are useless, keep the table small
+    if _, err := s.db.Exec("DELETE FROM mfa_challenges WHERE expires_at < ?", timeValue(time.Now())); err != nil {
+        return err
+    }
+    _, err := s.db.Exec(
+        "INSERT INTO mfa_challenges (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+        tokenHash, userID, timeValue(expiresAt))
+    return err
+}
+
+func (s *SQLTwoFactorStore) ChallengeUser(tokenHash string, now time.Time) (int, error) {
+    var userID int
+    err := s.db.QueryRow(
+        `UPDATE mfa_challenges SET attempts = attempts + 1
+        WHERE token_hash = ? AND expires_at > ? AND attempts < ?
+        RETURNING user_id`,
+        tokenHash, timeValue(now), maxChallengeAttempts,
+    ).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return 0, ErrInvalidChallenge
+    }
+    return userID, err
+}
+
+func (s *SQLTwoFactorStore) DeleteChallenge(tokenHash string) error {
+    _, err := s.db.Exec("DELETE FROM mfa_challenges WHERE token_hash = ?", tokenHash)
+    return err
+}
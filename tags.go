@@ -0,0 +1,73 @@
+package main
+
+import (
+    "errors"
+    "sort"
+    "strings"
+    "unicode/utf8"
+)
+
+var ErrInvalidTag = errors.New("tags must be at most 32 characters, at most 10 per entry")
+
+const (
+    maxTagLength    = 32
+    maxTagsPerEntry = 10
+)
+
+// trim, lowercase, drop leading "#", skip empty and duplicate names; result is sorted
+func NormalizeTags(names []string) ([]string, error) {
+    seen := make(map[string]bool)
+    var tags []string
+    for _, name := range names {
+        name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#")))
+        if name == "" || seen[name] {
+            continue
+        }
+        if utf8.RuneCountInString(name) > maxTagLength || strings.Contains(name, ",") {
+            return nil, ErrInvalidTag
+        }
+        seen[name] = true
+        tags = append(tags, name)
+    }
+    if len(tags) > maxTagsPerEntry {
+        return nil, ErrInvalidTag
+    }
+    sort.Strings(tags)
+    return tags, nil
+}
+
+// "meeting, bugfix" from forms and query strings
+func ParseTags(s string) ([]string, error) {
+    return NormalizeTags(strings.Split(s, ","))
+}
+
+type TagHours struct {
+    Tag   string
+    Hours float64
+}
+
+// hours per tag, most hours first. An entry with several tags counts for each
+// of them, so the sum can be more than the total; entries without tags go to untagged.
+func HoursByTag(logs []WorkLog) (tags []TagHours, untagged float64) {
+    byTag := make(map[string]float64)
+    for _, log := range logs {
+        if len(log.Tags) == 0 {
+            untagged += log.Hours
+            continue
+        }
+        for _, tag := range log.Tags {
+            byTag[tag] += log.Hours
+        }
+    }
+
+    for tag, hours := range byTag {
+        tags = append(tags, TagHours{Tag: tag, Hours: hours})
+    }
+    sort.Slice(tags, func(i, j int) bool {
+        if tags[i].Hours != tags[j].Hours {
+            return tags[i].Hours > tags[j].Hours
+        }
+        return tags[i].Tag < tags[j].Tag
+    })
+    return tags, untagged
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    TeamRoleMember  = "member"  // hours visible to the team's managers
+    TeamRoleManager = "manager" // sees the members' worklogs, reports and exports, read-only
+)
+
+var allTeamRoles = []string{TeamRoleMember, TeamRoleManager}
+
+var (
+    ErrInvalidTeamRole = errors.New("team role must be member or manager")
+    ErrTeamManagerRole = errors.New("team managers need the role manager or admin")
+    ErrInvalidTeamName = errors.New("team name must be 1-100 characters")
+)
+
+// admins see every team, managers the teams where they are team manager
+func CanViewTeam(userID int, role string, teamID int) (bool, error) {
+    if HasPermission(role, PermManageTeams) {
+        return true, nil
+    }
+    if !HasPermission(role, PermViewTeams) {
+        return false, nil
+    }
+    teamRole, err := teamStore.MemberRole(teamID, userID)
+    if err == ErrTeamMemberNotFound {
+        return false, nil
+    }
+    return teamRole == TeamRoleManager, err
+}
+
+func VisibleTeams(userID int, role string) ([]Team, error) {
+    if HasPermission(role, PermManageTeams) {
+        return teamStore.List()
+    }
+    if !HasPermission(role, PermViewTeams) {
+        return nil, nil
+    }
+    return teamStore.ListManaged(userID)
+}
+
+func CreateTeam(name string) (*Team, error) {
+    name = strings.TrimSpace(name)
+    if name == "" || len(name) > 100 {
+        return nil, ErrInvalidTeamName
+    }
+    t := &Team{Name: name}
+    return t, teamStore.Create(t)
+}
+
+// add a user by name or change their team role
+func SetTeamMember(teamID int, username, teamRole string) (*User, error) {
+    if teamRole != TeamRoleMember && teamRole != TeamRoleManager {
+        return nil, ErrInvalidTeamRole
+    }
+    user, err := userStore.GetByUsername(strings.TrimSpace(username))
+    if err != nil {
+        return nil, err
+    }
+    if teamRole == TeamRoleManager && !HasPermission(user.Role, PermViewTeams) {
+        return nil, ErrTeamManagerRole
+    }
+    return user, teamStore.SetMember(teamID, user.ID, teamRole)
+}
+
+// ========== middleware ==========
+
+// Middleware for routes with :id of a team: a team the user may not see is 404,
+// like foreign worklogs. The team is put into context.
+func TeamViewerRequired(notFound gin.HandlerFunc) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        team, err := loadVisibleTeam(c)
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        c.Set("team", team)
+        c.Next()
+    }
+}
+
+// Middleware for routes with :id and :user_id: the member must be in the team,
+// their user is put into context as "member" (see worklogOwner)
+func TeamMemberRequired(notFound gin.HandlerFunc) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        team, err := loadVisibleTeam(c)
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        userID, err := strconv.Atoi(c.Param("user_id"))
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        if _, err := teamStore.MemberRole(team.ID, userID); err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        member, err := userStore.GetByID(userID)
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        c.Set("team", team)
+        c.Set("member", member)
+        c.Next()
+    }
+}
+
+func loadVisibleTeam(c *gin.Context) (*Team, error) {
+    teamID, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        return nil, ErrTeamNotFound
+    }
+    ok, err := CanViewTeam(CurrentUserID(c), CurrentRole(c), teamID)
+    if err != nil {
+        return nil, err
+    }
+    if !ok {
+        return nil, ErrTeamNotFound
+    }
+    return teamStore.Get(teamID)
+}
+
+// team loaded by TeamViewerRequired / TeamMemberRequired
+func CurrentTeam(c *gin.Context) *Team {
+    return c.MustGet("team").(*Team)
+}
+
+// whose worklogs the list, reports and export show: the team member picked by
+// TeamMemberRequired (read-only), else the current user
+func worklogOwner(c *gin.Context) (int, string) {
+    if v, ok := c.Get("member"); ok {
+        member := v.(*User)
+        return member.ID, member.Username
+    }
+    return GetCurrentUserID(c), GetCurrentUsername(c)
+}
+
+// links and names for the read-only member pages, nil on the own pages
+func memberView(c *gin.Context) gin.H {
+    v, ok := c.Get("member")
+    if !ok {
+        return nil
+    }
+    team := CurrentTeam(c)
+    return gin.H{
+        "team":   team,
+        "member": v.(*User),
+        "base":   fmt.Sprintf("/teams/%d/members/%d", team.ID, v.(*User).ID),
+    }
+}
+
+// list and export address of the worklog list page
+func worklogLinks(c *gin.Context) (string, string) {
+    if view := memberView(c); view != nil {
+        base := view["base"].(string)
+        return base + "/worklogs", base + "/export"
+    }
+    return "/worklog/list", "/worklog/export"
+}
+
+// 404 for web routes
+func WebTeamNotFound(c *gin.Context) {
+    c.String(http.StatusNotFound, "Команда не найдена")
+}
+
+// 404 for API routes
+func APITeamNotFound(c *gin.Context) {
+    c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+}
+
+// ========== aggregates ==========
+
+type MemberStats struct {
+    UserID   int
+    Username string
+    Hours    float64
+    Entries  int
+    BillingSummary
+}
+
+type TeamStats struct {
+    Members    []MemberStats
+    TotalHours float64
+    Billing    BillingSummary
+    Tags       []TagHours
+    Untagged   float64
+}
+
+// hours per member and for the team; amounts with each member's own rates
+func TeamStatsFor(teamID int, f WorkLogFilter) (*TeamStats, error) {
+    members, err := teamStore.Members(teamID)
+    if err != nil {
+        return nil, err
+    }
+    f.Limit, f.Offset = 0, 0
+
+    stats := &TeamStats{Members: []MemberStats{}}
+    var all []WorkLog
+    for _, m := range members {
+        logs, err := worklogStore.List(m.UserID, f)
+        if err != nil {
+            return nil, err
+        }
+        book, err := LoadRateBook(m.UserID)
+        if err != nil {
+            return nil, err
+        }
+
+        ms := MemberStats{UserID: m.UserID, Username: m.Username, Entries: len(logs), BillingSummary: book.Summarize(logs)}
+        for _, log := range logs {
+            ms.Hours += log.Hours
+        }
+        stats.Members = append(stats.Members, ms)
+        stats.TotalHours += ms.Hours
+        stats.Billing.BillableHours += ms.BillableHours
+        stats.Billing.NonBillableHours += ms.NonBillableHours
+        stats.Billing.Amount += ms.Amount
+        all = append(all, logs...)
+    }
+    stats.Billing.Amount = roundMoney(stats.Billing.Amount)
+    stats.Tags, stats.Untagged = HoursByTag(all)
+    return stats, nil
+}
+
+// user ids of the team for ListForUsers
+func teamMemberIDs(teamID int) ([]int, map[int]string, error) {
+    members, err := teamStore.Members(teamID)
+    if err != nil {
+        return nil, nil, err
+    }
+    ids := make([]int, 0, len(members))
+    names := make(map[int]string, len(members))
+    for _, m := range members {
+        ids = append(ids, m.UserID)
+        names[m.UserID] = m.Username
+    }
+    return ids, names, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// team "dev" with alice (member, one worklog) and bob (team manager), team "ops" with carol (team manager);
+// dave is a plain user in "dev"
+func setupTeamTest(t *testing.T) (router http.Handler, dev *Team, alice *User, log *WorkLog) {
+    t.Helper()
+    router = setupTestServer(t)
+    alice = createTestUser(t, "alice")
+    for _, name := range []string{"bob", "carol", "dave", "admin"} {
+        createTestUser(t, name)
+    }
+    for name, role := range map[string]string{"bob": RoleManager, "carol": RoleManager, "admin": RoleAdmin} {
+        user, err := userStore.GetByUsername(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := userStore.SetRole(user.ID, role); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    dev, err := CreateTeam("dev")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ops, err := CreateTeam("ops")
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := []struct {
+        team     *Team
+        username string
+        role     string
+    }{
+        {dev, "alice", TeamRoleMember},
+        {dev, "bob", TeamRoleManager},
+        {dev, "dave", TeamRoleMember},
+        {ops, "carol", TeamRoleManager},
+    }
+    for _, m := range members {
+        if _, err := SetTeamMember(m.team.ID, m.username, m.role); err != nil {
+            t.Fatal(err)
+        }
+    }
+    log = createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: time.Now(), Description: "alice work", Hours: 2})
+    return router, dev, alice, log
+}
+
+// a plain user of the team and the manager of another team get 404 on every route of the team
+func TestTeamAccessNotFound(t *testing.T) {
+    router, dev, alice, _ := setupTeamTest(t)
+    team := fmt.Sprintf("/teams/%d", dev.ID)
+    member := fmt.Sprintf("%s/members/%d", team, alice.ID)
+
+    for _, username := range []string{"dave", "carol"} {
+        web := loginWeb(t, router, username)
+        api := loginAPI(t, router, username)
+        requests := map[string]func() int{
+            "GET " + team:                 func() int { return web.get(team).Code },
+            "GET " + member + "/worklogs": func() int { return web.get(member + "/worklogs").Code },
+            "GET " + member + "/reports":  func() int { return web.get(member + "/reports").Code },
+            "GET " + member + "/export":   func() int { return web.get(member + "/export?format=csv").Code },
+            "POST " + member + "/remove":  func() int { return web.postForm(member+"/remove", nil).Code },
+            "POST " + team + "/members": func() int {
+                return web.postForm(team+"/members", url.Values{"username": {username}, "role": {"member"}}).Code
+            },
+            "GET /api/v1" + team:               func() int { return api.get("/api/v1" + team).Code },
+            "GET /api/v1" + team + "/stats":    func() int { return api.get("/api/v1" + team + "/stats").Code },
+            "GET /api/v1" + team + "/worklogs": func() int { return api.get("/api/v1" + team + "/worklogs").Code },
+            "DELETE /api/v1" + member:          func() int { return api.do(http.MethodDelete, "/api/v1"+member, "", nil).Code },
+            "POST /api/v1" + team + "/members": func() int {
+                return api.sendJSON(http.MethodPost, "/api/v1"+team+"/members", map[string]string{"username": username, "role": "member"}).Code
+            },
+            "GET /api/v1" + team + "?user_id=...": func() int { return api.get(fmt.Sprintf("/api/v1%s/worklogs?user_id=%d", team, alice.ID)).Code },
+        }
+        for name, request := range requests {
+            if code := request(); code != http.StatusNotFound {
+                t.Errorf("%s: %s answered %d, want 404", username, name, code)
+            }
+        }
+    }
+
+    // nothing changed behind the 404
+    members, err := teamStore.Members(dev.ID)
+    if err != nil || len(members) != 3 {
+        t.Fatalf("members of dev: %+v %v", members, err)
+    }
+}
+
+// the team manager only reads: no edit links on the member pages, writes to the member's worklogs are 404,
+// member changes are 403
+func TestTeamMemberPagesReadOnly(t *testing.T) {
+    router, dev, alice, log := setupTeamTest(t)
+    web := loginWeb(t, router, "bob")
+    api := loginAPI(t, router, "bob")
+    member := fmt.Sprintf("/teams/%d/members/%d", dev.ID, alice.ID)
+
+    w := web.get(member + "/worklogs")
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "alice work") {
+        t.Fatalf("member worklogs: %d %s", w.Code, w.Body.String())
+    }
+    for _, link := range []string{"/worklog/edit/", "/worklog/delete/", "/worklog/history"} {
+        if strings.Contains(w.Body.String(), link) {
+            t.Errorf("member page links %s", link)
+        }
+    }
+    if w := web.get(member + "/reports"); w.Code != http.StatusOK {
+        t.Fatalf("member reports: %d", w.Code)
+    }
+
+    path := fmt.Sprintf("/worklog/%%s/%d", log.ID)
+    requests := map[string]func() int{
+        "web update": func() int {
+            return web.postForm(fmt.Sprintf(path, "update"), url.Values{"date": {log.Date.Format("2006-01-02")}, "hours": {"5"}, "description": {"bob"}}).Code
+        },
+        "web delete": func() int { return web.postForm(fmt.Sprintf(path, "delete"), nil).Code },
+        "api update": func() int {
+            return api.sendJSON(http.MethodPut, fmt.Sprintf("/api/v1/worklogs/%d", log.ID),
+                map[string]interface{}{"date": log.Date.Format("2006-01-02"), "hours": 5, "description": "bob"}).Code
+        },
+        "api delete":              func() int { return api.do(http.MethodDelete, fmt.Sprintf("/api/v1/worklogs/%d", log.ID), "", nil).Code },
+        "post to the member page": func() int { return web.postForm(member+"/worklogs", nil).Code },
+    }
+    for name, request := range requests {
+        if code := request(); code != http.StatusNotFound {
+            t.Errorf("%s of a member's worklog: %d, want 404", name, code)
+        }
+    }
+    if got, err := worklogStore.Get(alice.ID, log.ID); err != nil || got.Hours != 2 || got.Description != "alice work" {
+        t.Fatalf("member worklog changed: %+v %v", got, err)
+    }
+
+    if w := web.postForm(member+"/remove", nil); w.Code != http.StatusForbidden {
+        t.Fatalf("team manager removes a member: %d", w.Code)
+    }
+    if w := api.do(http.MethodDelete, "/api/v1"+member, "", nil); w.Code != http.StatusForbidden {
+        t.Fatalf("team manager removes a member through the API: %d", w.Code)
+    }
+    if role, err := teamStore.MemberRole(dev.ID, alice.ID); err != nil || role != TeamRoleMember {
+        t.Fatalf("alice after the refused removal: %q %v", role, err)
+    }
+}
+
+// once removed, a member's hours and pages are gone for the team manager
+func TestTeamRemovedMember(t *testing.T) {
+    router, dev, alice, _ := setupTeamTest(t)
+    web := loginWeb(t, router, "bob")
+    api := loginAPI(t, router, "bob")
+    root := loginAPI(t, router, "admin")
+    team := fmt.Sprintf("/teams/%d", dev.ID)
+    member := fmt.Sprintf("%s/members/%d", team, alice.ID)
+
+    if w := api.get("/api/v1" + team + "/worklogs"); !strings.Contains(w.Body.String(), "alice work") {
+        t.Fatalf("worklogs before the removal: %d %s", w.Code, w.Body.String())
+    }
+    if w := root.do(http.MethodDelete, "/api/v1"+member, "", nil); w.Code != http.StatusNoContent {
+        t.Fatalf("admin removes alice: %d %s", w.Code, w.Body.String())
+    }
+
+    for _, page := range []string{"/worklogs", "/reports", "/export?format=csv"} {
+        if w := web.get(member + page); w.Code != http.StatusNotFound {
+            t.Errorf("%s of a removed member: %d", page, w.Code)
+        }
+    }
+    if w := web.get(team); w.Code != http.StatusOK || strings.Contains(w.Body.String(), member) {
+        t.Errorf("team page still links the removed member: %d", w.Code)
+    }
+    if w := api.get("/api/v1" + team + "/worklogs"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "alice work") {
+        t.Errorf("team worklogs after the removal: %d %s", w.Code, w.Body.String())
+    }
+    if w := api.get(fmt.Sprintf("/api/v1%s/worklogs?user_id=%d", team, alice.ID)); w.Code != http.StatusBadRequest {
+        t.Errorf("worklogs of the removed member: %d %s", w.Code, w.Body.String())
+    }
+    stats := decodeTestJSON(t, api.get("/api/v1"+team+"/stats"))
+    for _, m := range stats["members"].([]interface{}) {
+        if m.(map[string]interface{})["username"] == "alice" {
+            t.Errorf("removed member in the stats: %v", stats)
+        }
+    }
+    if w := api.get("/api/v1" + team); strings.Contains(w.Body.String(), `"alice"`) {
+        t.Errorf("removed member in the team: %s", w.Body.String())
+    }
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strings"
+    "time"
+)
+
+// how elapsed timer time becomes hours (config.TimerRounding):
+//   none          - exact, 2 decimals
+//   nearest:15m   - to the nearest 15 minutes
+//   up:6m         - always up (billing style)
+//   down:15m      - always down
+type RoundingRule struct {
+    Mode string
+    Step time.Duration
+}
+
+func ParseRoundingRule(s string) (RoundingRule, error) {
+    if s == "" || s == "none" {
+        return RoundingRule{Mode: "none"}, nil
+    }
+
+    mode, stepStr, ok := strings.Cut(s, ":")
+    if !ok {
+        return RoundingRule{}, fmt.Errorf("rounding rule %q: expected mode:step, e.g. nearest:15m", s)
+    }
+    switch mode {
+    case "nearest", "up", "down":
+    default:
+        return RoundingRule{}, fmt.Errorf("rounding rule %q: mode must be nearest, up or down", s)
+    }
+
+    step, err := time.ParseDuration(stepStr)
+    if err != nil || step <= 0 {
+        return RoundingRule{}, fmt.Errorf("rounding rule %q: bad step", s)
+    }
+    return RoundingRule{Mode: mode, Step: step}, nil
+}
+
+func (r RoundingRule) Apply(d time.Duration) time.Duration {
+    switch r.Mode {
+    case "nearest":
+        return d.Round(r.Step)
+    case "up":
+        rounded := d.Truncate(r.Step)
+        if rounded < d {
+            rounded += r.Step
+        }
+        return rounded
+    case "down":
+        return d.Truncate(r.Step)
+    }
+    return d
+}
+
+// elapsed time -> rounded hours with two decimals; what is left of the day caps it later
+func (r RoundingRule) Hours(d time.Duration) float64 {
+    return math.Round(r.Apply(d).Hours()*100) / 100
+}
+
+// start a timer for userID, project must belong to the user
+func StartTimer(userID, projectID int, description string) (*Timer, error) {
+    t := &Timer{
+        UserID:      userID,
+        ProjectID:   projectID,
+        Description: strings.TrimSpace(description),
+        StartedAt:   time.Now(),
+    }
+    if err := ValidateWorkLogProject(&WorkLog{UserID: userID, ProjectID: projectID}); err != nil {
+        return nil, err
+    }
+    if err := timerStore.Start(t); err != nil {
+        return nil, err
+    }
+    return t, nil
+}
+
+// what StopTimer made of the timer
+type StoppedTimer struct {
+    Log     *WorkLog // nil when nothing could be recorded, the timer is gone anyway
+    Hours   float64  // timer time after rounding
+    Problem error    // worklog rule why Log is nil or has less than Hours
+}
+
+// Stop the running timer and turn it into a worklog dated on the start day.
+// The hours are cut to what is left of that day (24h with the other entries);
+// when nothing can be recorded (rounded to 0, day full, period closed, week approved)
+// the timer is ended without a worklog and Problem says why. Only on database
+// errors the timer keeps running.
+func StopTimer(userID int, a *Audit) (*StoppedTimer, error) {
+    t, err := timerStore.Get(userID)
+    if err != nil {
+        return nil, err
+    }
+
+    description := t.Description
+    if description == "" {
+        description = "Таймер"
+    }
+
+    stopped := &StoppedTimer{
+        Hours: config.TimerRounding.Hours(time.Since(t.StartedAt)),
+    }
+    log := &WorkLog{
+        UserID:      userID,
+        ProjectID:   t.ProjectID,
+        ProjectName: t.ProjectName,
+        Date:        t.StartedAt.Local(),
+        Description: description,
+        Hours:       stopped.Hours,
+    }
+
+    day := log.Date.Format("2006-01-02")
+    others, err := worklogStore.List(userID, WorkLogFilter{DateFrom: day, DateTo: day})
+    if err != nil {
+        return nil, err
+    }
+    left := maxHoursPerDay
+    for _, other := range others {
+        left -= other.Hours
+    }
+    if left = math.Floor(left*100+1e-6) / 100; log.Hours > left {
+        log.Hours = left
+        stopped.Problem = ErrDayLimit
+    }
+
+    if log.Hours <= 0 {
+        if stopped.Problem == nil {
+            stopped.Problem = ErrHoursRequired
+        }
+        return stopped, timerStore.Discard(userID)
+    }
+    // a parallel write can still fill the day, Finish checks again
+    err = PrepareWorkLog(log, false)
+    if err == nil {
+        err = timerStore.Finish(t, log, a)
+    }
+    if isWorkLogRuleError(err) {
+        stopped.Problem = err
+        return stopped, timerStore.Discard(userID)
+    }
+    if err != nil {
+        return nil, err
+    }
+    stopped.Log = log
+    return stopped, nil
+}
+
+// end the running timer without a worklog
+func DiscardTimer(userID int) error {
+    return timerStore.Discard(userID)
+}
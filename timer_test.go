@@ -0,0 +1,141 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// timer of the client started ago, through the API
+func startTestTimer(t *testing.T, c *testClient, userID int, ago time.Duration) {
+    t.Helper()
+    if w := c.do(http.MethodPost, "/api/v1/timer/start", "", nil); w.Code != http.StatusCreated {
+        t.Fatalf("start timer: %d %s", w.Code, w.Body.String())
+    }
+    if _, err := db.Exec("UPDATE timers SET started_at = ? WHERE user_id = ?", timeValue(time.Now().Add(-ago)), userID); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func stopTestTimer(t *testing.T, c *testClient) map[string]interface{} {
+    t.Helper()
+    w := c.do(http.MethodPost, "/api/v1/timer/stop", "", nil)
+    if w.Code != http.StatusOK {
+        t.Fatalf("stop timer: %d %s", w.Code, w.Body.String())
+    }
+    return decodeTestJSON(t, w)
+}
+
+func decodeTestJSON(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+    t.Helper()
+    var resp map[string]interface{}
+    if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("%v: %s", err, w.Body.String())
+    }
+    return resp
+}
+
+func assertTimerStopped(t *testing.T, c *testClient) {
+    t.Helper()
+    if w := c.get("/api/v1/timer"); w.Body.String() != `{"running":false}` {
+        t.Fatalf("timer still running: %s", w.Body.String())
+    }
+}
+
+func TestStopTimer(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    c := loginAPI(t, router, "alice")
+
+    t.Run("right after start", func(t *testing.T) {
+        startTestTimer(t, c, alice.ID, 0)
+        resp := stopTestTimer(t, c)
+        if resp["worklog"] != nil || resp["reason"] != ErrHoursRequired.Error() {
+            t.Fatalf("stop: %v", resp)
+        }
+        assertTimerStopped(t, c)
+    })
+
+    t.Run("longer than a day", func(t *testing.T) {
+        startTestTimer(t, c, alice.ID, 30*time.Hour)
+        resp := stopTestTimer(t, c)
+        log, _ := resp["worklog"].(map[string]interface{})
+        if log == nil || log["hours"] != 24.0 || resp["timer_hours"] != 30.0 || resp["reason"] != ErrDayLimit.Error() {
+            t.Fatalf("stop: %v", resp)
+        }
+        assertTimerStopped(t, c)
+    })
+
+    t.Run("day almost full", func(t *testing.T) {
+        started := time.Now().Add(-2 * time.Hour)
+        createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: started.Local(), Description: "long day", Hours: 23})
+        startTestTimer(t, c, alice.ID, 2*time.Hour)
+        resp := stopTestTimer(t, c)
+        log, _ := resp["worklog"].(map[string]interface{})
+        if log == nil || log["hours"] != 1.0 || resp["reason"] != ErrDayLimit.Error() {
+            t.Fatalf("stop: %v", resp)
+        }
+        assertTimerStopped(t, c)
+
+        // the day is full now, nothing is left for another timer
+        startTestTimer(t, c, alice.ID, time.Hour)
+        if resp := stopTestTimer(t, c); resp["worklog"] != nil || resp["reason"] != ErrDayLimit.Error() {
+            t.Fatalf("stop on a full day: %v", resp)
+        }
+        assertTimerStopped(t, c)
+    })
+
+    t.Run("closed period", func(t *testing.T) {
+        tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+        lock, _ := time.Parse("2006-01-02", tomorrow)
+        if err := lockStore.Set(&PeriodLock{LockDate: lock, UpdatedBy: "test"}); err != nil {
+            t.Fatal(err)
+        }
+        defer lockStore.Delete(0)
+
+        // a user whose day is still empty
+        bob := createTestUser(t, "bob")
+        c := loginAPI(t, router, "bob")
+        startTestTimer(t, c, bob.ID, time.Hour)
+        resp := stopTestTimer(t, c)
+        if resp["worklog"] != nil || resp["reason"] == nil || !strings.HasPrefix(resp["reason"].(string), ErrPeriodLocked.Error()) {
+            t.Fatalf("stop: %v", resp)
+        }
+        assertTimerStopped(t, c)
+    })
+
+    t.Run("not running", func(t *testing.T) {
+        if w := c.do(http.MethodPost, "/api/v1/timer/stop", "", nil); w.Code != http.StatusConflict {
+            t.Fatalf("stop without a timer: %d %s", w.Code, w.Body.String())
+        }
+    })
+}
+
+func TestDiscardTimer(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    api := loginAPI(t, router, "alice")
+
+    startTestTimer(t, api, alice.ID, time.Hour)
+    if w := api.do(http.MethodDelete, "/api/v1/timer", "", nil); w.Code != http.StatusOK {
+        t.Fatalf("discard: %d %s", w.Code, w.Body.String())
+    }
+    assertTimerStopped(t, api)
+    if w := api.do(http.MethodDelete, "/api/v1/timer", "", nil); w.Code != http.StatusConflict {
+        t.Fatalf("discard without a timer: %d %s", w.Code, w.Body.String())
+    }
+
+    web := loginWeb(t, router, "alice")
+    startTestTimer(t, api, alice.ID, time.Hour)
+    if w := web.postForm("/timer/discard", nil); w.Code != http.StatusFound || w.Header().Get("Location") != "/dashboard" {
+        t.Fatalf("web discard: %d %s", w.Code, w.Header().Get("Location"))
+    }
+    assertTimerStopped(t, api)
+
+    if logs, err := worklogStore.List(alice.ID, WorkLogFilter{}); err != nil || len(logs) != 0 {
+        t.Fatalf("discarded timers left worklogs: %v %v", logs, err)
+    }
+}
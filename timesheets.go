@@ -0,0 +1,349 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// a week without a timesheet row is a draft
+const (
+    TimesheetDraft     = "draft"
+    TimesheetSubmitted = "submitted" // worklogs of submitted and approved weeks can not be
+    TimesheetApproved  = "approved"  // created, changed or deleted: the reviewer approves what they saw
+    TimesheetRejected  = "rejected"
+)
+
+// review actions of team managers and admins
+const (
+    ReviewApprove = "approve" // submitted -> approved
+    ReviewReject  = "reject"  // submitted -> rejected, comment required
+    ReviewReopen  = "reopen"  // approved -> draft, comment required
+)
+
+var (
+    ErrInvalidWeek       = errors.New("week must be YYYY-Www and already started")
+    ErrInvalidTransition = errors.New("not possible in the current status of the week")
+    ErrInvalidReview     = errors.New("action must be approve, reject or reopen")
+    ErrOwnTimesheet      = errors.New("you can not review your own week")
+    ErrCommentRequired   = errors.New("comment is required to reject or reopen a week")
+)
+
+// "2026-W07", the key ReportsPage groups hours by
+func WeekKey(year, week int) string {
+    return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func ParseWeekKey(s string) (int, int, error) {
+    parts := strings.SplitN(strings.ToUpper(strings.TrimSpace(s)), "-W", 2)
+    if len(parts) != 2 {
+        return 0, 0, ErrInvalidWeek
+    }
+    year, err1 := strconv.Atoi(parts[0])
+    week, err2 := strconv.Atoi(parts[1])
+    if err1 != nil || err2 != nil || year < 1970 || week < 1 || week > 53 {
+        return 0, 0, ErrInvalidWeek
+    }
+    // week 53 exists only in some years
+    if y, w := WeekStart(year, week).ISOWeek(); y != year || w != week {
+        return 0, 0, ErrInvalidWeek
+    }
+    return year, week, nil
+}
+
+// Monday of the ISO week (January 4th is always in week 1)
+func WeekStart(year, week int) time.Time {
+    jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+    offset := (int(jan4.Weekday()) + 6) % 7
+    return jan4.AddDate(0, 0, -offset+(week-1)*7)
+}
+
+// date range of the week for WorkLogFilter
+func WeekFilter(year, week int) WorkLogFilter {
+    start := WeekStart(year, week)
+    return WorkLogFilter{
+        DateFrom: start.Format("2006-01-02"),
+        DateTo:   start.AddDate(0, 0, 6).Format("2006-01-02"),
+    }
+}
+
+// worklogs of a submitted week are frozen until it is rejected,
+// of an approved one until it is reopened
+func CheckWeekOpen(userID int, date time.Time) error {
+    year, week := date.ISOWeek()
+    t, err := sheetStore.GetWeek(userID, year, week)
+    if err == ErrTimesheetNotFound {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    return weekOpen(t.Status)
+}
+
+func weekOpen(status string) error {
+    switch status {
+    case TimesheetSubmitted:
+        return ErrWeekSubmitted
+    case TimesheetApproved:
+        return ErrWeekApproved
+    }
+    return nil
+}
+
+// timesheet with its dates and hours for the pages and the API
+type WeekSummary struct {
+    Timesheet
+    Key   string
+    Start time.Time
+    End   time.Time
+    Hours float64
+}
+
+func SummarizeWeek(t Timesheet) (WeekSummary, error) {
+    start := WeekStart(t.Year, t.Week)
+    hours, err := WeekHours(&t)
+    return WeekSummary{Timesheet: t, Key: WeekKey(t.Year, t.Week), Start: start, End: start.AddDate(0, 0, 6), Hours: hours}, err
+}
+
+func summarizeWeeks(sheets []Timesheet) ([]WeekSummary, error) {
+    weeks := []WeekSummary{}
+    for _, t := range sheets {
+        w, err := SummarizeWeek(t)
+        if err != nil {
+            return nil, err
+        }
+        weeks = append(weeks, w)
+    }
+    return weeks, nil
+}
+
+// the last n weeks (drafts included) and all older submitted ones, newest first
+func RecentWeeks(userID int, username string, n int) ([]WeekSummary, error) {
+    stored, err := sheetStore.ListForUser(userID)
+    if err != nil {
+        return nil, err
+    }
+    byKey := make(map[string]Timesheet, len(stored))
+    for _, t := range stored {
+        byKey[WeekKey(t.Year, t.Week)] = t
+    }
+
+    var sheets []Timesheet
+    now := time.Now()
+    for i := 0; i < n; i++ {
+        year, week := now.AddDate(0, 0, -7*i).ISOWeek()
+        t, ok := byKey[WeekKey(year, week)]
+        if !ok {
+            t = Timesheet{UserID: userID, Username: username, Year: year, Week: week, Status: TimesheetDraft}
+        }
+        sheets = append(sheets, t)
+        delete(byKey, WeekKey(year, week))
+    }
+    // stored is ordered newest first
+    for _, t := range stored {
+        if _, ok := byKey[WeekKey(t.Year, t.Week)]; ok {
+            sheets = append(sheets, t)
+        }
+    }
+    return summarizeWeeks(sheets)
+}
+
+// worklog id -> status of its submitted or approved week, for the lock marks in the list
+func frozenWorkLogs(userID int, logs []WorkLog) map[int]string {
+    frozen := map[int]string{}
+    sheets, err := sheetStore.ListForUser(userID)
+    if err != nil || len(sheets) == 0 {
+        return frozen
+    }
+    weeks := map[string]string{}
+    for _, t := range sheets {
+        if weekOpen(t.Status) != nil {
+            weeks[WeekKey(t.Year, t.Week)] = t.Status
+        }
+    }
+    for _, log := range logs {
+        if status, ok := weeks[WeekKey(log.Date.ISOWeek())]; ok {
+            frozen[log.ID] = status
+        }
+    }
+    return frozen
+}
+
+// timesheet of the week, a draft that is not stored yet if it was never submitted
+func LoadWeek(userID, year, week int) (*Timesheet, error) {
+    t, err := sheetStore.GetWeek(userID, year, week)
+    if err == ErrTimesheetNotFound {
+        return &Timesheet{UserID: userID, Year: year, Week: week, Status: TimesheetDraft}, nil
+    }
+    return t, err
+}
+
+// hours of the week, shown next to the status
+func WeekHours(t *Timesheet) (float64, error) {
+    logs, err := worklogStore.List(t.UserID, WeekFilter(t.Year, t.Week))
+    if err != nil {
+        return 0, err
+    }
+    var hours float64
+    for _, log := range logs {
+        hours += log.Hours
+    }
+    return hours, nil
+}
+
+// the owner submits a draft or rejected week
+func SubmitWeek(userID int, username, weekKey, comment string) (*Timesheet, error) {
+    year, week, err := ParseWeekKey(weekKey)
+    if err != nil {
+        return nil, err
+    }
+    if WeekStart(year, week).After(time.Now()) {
+        return nil, ErrInvalidWeek
+    }
+    t, err := LoadWeek(userID, year, week)
+    if err != nil {
+        return nil, err
+    }
+    if t.Status != TimesheetDraft && t.Status != TimesheetRejected {
+        return nil, ErrInvalidTransition
+    }
+    return t, moveTimesheet(t, TimesheetSubmitted, userID, username, strings.TrimSpace(comment))
+}
+
+// approve, reject or reopen; the caller passed TimesheetAccessRequired,
+// so anyone but the owner is a reviewer of the week
+func ReviewTimesheet(t *Timesheet, action string, actorID int, actorName, comment string) error {
+    if t.UserID == actorID {
+        return ErrOwnTimesheet
+    }
+    comment = strings.TrimSpace(comment)
+
+    var from, to string
+    switch action {
+    case ReviewApprove:
+        from, to = TimesheetSubmitted, TimesheetApproved
+    case ReviewReject:
+        from, to = TimesheetSubmitted, TimesheetRejected
+    case ReviewReopen:
+        from, to = TimesheetApproved, TimesheetDraft
+    default:
+        return ErrInvalidReview
+    }
+    if t.Status != from {
+        return ErrInvalidTransition
+    }
+    if action != ReviewApprove && comment == "" {
+        return ErrCommentRequired
+    }
+    return moveTimesheet(t, to, actorID, actorName, comment)
+}
+
+func moveTimesheet(t *Timesheet, to string, actorID int, actorName, comment string) error {
+    if r := []rune(comment); len(r) > 1000 {
+        comment = string(r[:1000])
+    }
+    e := &TimesheetEvent{
+        FromStatus: t.Status,
+        ToStatus:   to,
+        ActorID:    actorID,
+        ActorName:  actorName,
+        Comment:    comment,
+    }
+    return sheetStore.Transition(t, e)
+}
+
+// admins review every week, managers the weeks of the members of their teams
+func CanReviewTimesheets(userID int, role string, ownerID int) (bool, error) {
+    if userID == ownerID {
+        return false, nil
+    }
+    if HasPermission(role, PermManageTeams) {
+        return true, nil
+    }
+    if !HasPermission(role, PermViewTeams) {
+        return false, nil
+    }
+    return teamStore.Manages(userID, ownerID)
+}
+
+// submitted weeks waiting for the user, nil for plain users
+func ReviewQueue(userID int, role string) ([]Timesheet, error) {
+    if HasPermission(role, PermManageTeams) {
+        sheets, err := sheetStore.ListByStatus(TimesheetSubmitted, 0)
+        if err != nil {
+            return nil, err
+        }
+        // own weeks are reviewed by someone else
+        queue := sheets[:0]
+        for _, t := range sheets {
+            if t.UserID != userID {
+                queue = append(queue, t)
+            }
+        }
+        return queue, nil
+    }
+    if !HasPermission(role, PermViewTeams) {
+        return nil, nil
+    }
+    return sheetStore.ListByStatus(TimesheetSubmitted, userID)
+}
+
+// ========== middleware ==========
+
+// Middleware for routes with :id of a timesheet: the owner and its reviewers
+// get it as "timesheet", everyone else 404
+func TimesheetAccessRequired(notFound gin.HandlerFunc) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        t, err := loadVisibleTimesheet(c)
+        if err != nil {
+            notFound(c)
+            c.Abort()
+            return
+        }
+        c.Set("timesheet", t)
+        c.Next()
+    }
+}
+
+func loadVisibleTimesheet(c *gin.Context) (*Timesheet, error) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        return nil, ErrTimesheetNotFound
+    }
+    t, err := sheetStore.Get(id)
+    if err != nil {
+        return nil, err
+    }
+    if t.UserID == CurrentUserID(c) {
+        return t, nil
+    }
+    ok, err := CanReviewTimesheets(CurrentUserID(c), CurrentRole(c), t.UserID)
+    if err != nil {
+        return nil, err
+    }
+    if !ok {
+        return nil, ErrTimesheetNotFound
+    }
+    return t, nil
+}
+
+// timesheet loaded by TimesheetAccessRequired
+func CurrentTimesheet(c *gin.Context) *Timesheet {
+    return c.MustGet("timesheet").(*Timesheet)
+}
+
+// 404 for web routes
+func WebTimesheetNotFound(c *gin.Context) {
+    c.String(http.StatusNotFound, "Неделя не найдена")
+}
+
+// 404 for API routes
+func APITimesheetNotFound(c *gin.Context) {
+    c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+}
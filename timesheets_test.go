@@ -0,0 +1,107 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// review of the week through the API, fails the test unless it is done
+func reviewTestWeek(t *testing.T, c *testClient, id int, action string) {
+    t.Helper()
+    w := c.sendJSON(http.MethodPost, fmt.Sprintf("/api/v1/timesheets/%d/review", id),
+        map[string]string{"action": action, "comment": "test"})
+    if w.Code != http.StatusOK {
+        t.Fatalf("%s: %d %s", action, w.Code, w.Body.String())
+    }
+}
+
+// worklogs of submitted and approved weeks can not be written by any path; rejected weeks are open again
+func TestWeekLock(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    admin := createTestUser(t, "admin")
+    if err := userStore.SetRole(admin.ID, RoleAdmin); err != nil {
+        t.Fatal(err)
+    }
+    api := loginAPI(t, router, "alice")
+    web := loginWeb(t, router, "alice")
+    reviewer := loginAPI(t, router, "admin")
+
+    lastWeek := time.Now().AddDate(0, 0, -7)
+    day := lastWeek.Format("2006-01-02")
+    thisWeek := time.Now().Format("2006-01-02")
+    log := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: lastWeek, Description: "review me", Hours: 2})
+    moved := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: time.Now(), Description: "this week", Hours: 1})
+
+    w := api.sendJSON(http.MethodPost, "/api/v1/timesheets", map[string]string{"week": WeekKey(lastWeek.ISOWeek())})
+    if w.Code != http.StatusCreated {
+        t.Fatalf("submit: %d %s", w.Code, w.Body.String())
+    }
+    sheet := decodeTestJSON(t, w)["data"].(map[string]interface{})
+    sheetID := int(sheet["id"].(float64))
+
+    assertFrozen := func(status string, target error) {
+        t.Helper()
+        path := fmt.Sprintf("/api/v1/worklogs/%d", log.ID)
+        requests := map[string]func() int{
+            "api update": func() int {
+                return api.sendJSON(http.MethodPut, path, map[string]interface{}{"date": day, "hours": 3, "description": "changed"}).Code
+            },
+            "api move out": func() int {
+                return api.sendJSON(http.MethodPut, path, map[string]interface{}{"date": thisWeek, "hours": 2, "description": "review me"}).Code
+            },
+            "api move in": func() int {
+                return api.sendJSON(http.MethodPut, fmt.Sprintf("/api/v1/worklogs/%d", moved.ID),
+                    map[string]interface{}{"date": day, "hours": 1, "description": "this week"}).Code
+            },
+            "api create": func() int {
+                return api.sendJSON(http.MethodPost, "/api/v1/worklogs", map[string]interface{}{"date": day, "hours": 1, "description": "late"}).Code
+            },
+            "api delete": func() int { return api.do(http.MethodDelete, path, "", nil).Code },
+            "web delete": func() int { return web.postForm(fmt.Sprintf("/worklog/delete/%d", log.ID), nil).Code },
+        }
+        for name, request := range requests {
+            if code := request(); code != http.StatusConflict {
+                t.Fatalf("%s in a %s week: %d", name, status, code)
+            }
+        }
+        w := web.postForm(fmt.Sprintf("/worklog/update/%d", log.ID), url.Values{"date": {day}, "hours": {"3"}, "description": {"changed"}})
+        if !strings.Contains(w.Body.String(), workLogErrorText(target)) {
+            t.Fatalf("web update in a %s week: %d %s", status, w.Code, w.Body.String())
+        }
+
+        // the store checks the status itself, PrepareWorkLog may have seen the week open
+        changed := *log
+        changed.Hours = 5
+        if err := worklogStore.Update(&changed, &Audit{ActorName: "test", Channel: ChannelSystem}); !errors.Is(err, target) {
+            t.Fatalf("store update in a %s week: %v", status, err)
+        }
+        if err := worklogStore.Delete(alice.ID, log.ID, &Audit{ActorName: "test", Channel: ChannelSystem}); !errors.Is(err, target) {
+            t.Fatalf("store delete in a %s week: %v", status, err)
+        }
+        if got, err := worklogStore.Get(alice.ID, log.ID); err != nil || got.Hours != 2 || got.Description != "review me" {
+            t.Fatalf("worklog of a %s week: %+v %v", status, got, err)
+        }
+        if logs, err := worklogStore.List(alice.ID, WorkLogFilter{DateFrom: day, DateTo: day}); err != nil || len(logs) != 1 {
+            t.Fatalf("worklogs of a %s week: %d %v", status, len(logs), err)
+        }
+    }
+
+    assertFrozen("submitted", ErrWeekSubmitted)
+    reviewTestWeek(t, reviewer, sheetID, ReviewReject)
+    w = api.sendJSON(http.MethodPut, fmt.Sprintf("/api/v1/worklogs/%d", log.ID), map[string]interface{}{"date": day, "hours": 2, "description": "review me"})
+    if w.Code != http.StatusOK {
+        t.Fatalf("update in a rejected week: %d %s", w.Code, w.Body.String())
+    }
+
+    if w := api.sendJSON(http.MethodPost, "/api/v1/timesheets", map[string]string{"week": WeekKey(lastWeek.ISOWeek())}); w.Code != http.StatusCreated {
+        t.Fatalf("submit again: %d %s", w.Code, w.Body.String())
+    }
+    reviewTestWeek(t, reviewer, sheetID, ReviewApprove)
+    assertFrozen("approved", ErrWeekApproved)
+}
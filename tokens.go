@@ -0,0 +1,122 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "log"
+    "time"
+)
+
+// what login, register and refresh hand out
+type TokenPair struct {
+    AccessToken  string
+    RefreshToken string
+    ExpiresIn    int // access token lifetime, seconds
+}
+
+// 32 random bytes, url safe
+func randomToken() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// only the hash is stored, a database dump does not leak usable tokens
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken(now time.Time) (string, *RefreshToken, error) {
+    raw, err := randomToken()
+    if err != nil {
+        return "", nil, err
+    }
+    return raw, &RefreshToken{
+        TokenHash: hashToken(raw),
+        IssuedAt:  now,
+        ExpiresAt: now.Add(config.RefreshTTL),
+    }, nil
+}
+
+// new login = new token family
+func IssueTokens(user *User) (*TokenPair, error) {
+    now := time.Now()
+    if err := tokenStore.DeleteExpired(now); err != nil {
+        log.Println("delete expired tokens:", err)
+    }
+
+    family, err := randomToken()
+    if err != nil {
+        return nil, err
+    }
+    raw, rt, err := newRefreshToken(now)
+    if err != nil {
+        return nil, err
+    }
+    rt.UserID = user.ID
+    rt.FamilyID = family
+    if err := tokenStore.CreateRefresh(rt); err != nil {
+        return nil, err
+    }
+    return newTokenPair(user, raw)
+}
+
+// exchange a refresh token for a new pair, the old refresh token stops working
+func RefreshTokens(refreshToken string) (*TokenPair, *User, error) {
+    raw, next, err := newRefreshToken(time.Now())
+    if err != nil {
+        return nil, nil, err
+    }
+    if err := tokenStore.Rotate(hashToken(refreshToken), next); err != nil {
+        return nil, nil, err
+    }
+
+    user, err := userStore.GetByID(next.UserID)
+    if err == ErrUserNotFound || (err == nil && user.Disabled) {
+        return nil, nil, ErrInvalidRefreshToken
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+    tokens, err := newTokenPair(user, raw)
+    return tokens, user, err
+}
+
+func newTokenPair(user *User, refreshToken string) (*TokenPair, error) {
+    access, err := GenerateJWT(user)
+    if err != nil {
+        return nil, err
+    }
+    return &TokenPair{
+        AccessToken:  access,
+        RefreshToken: refreshToken,
+        ExpiresIn:    int(config.JWTTTL / time.Second),
+    }, nil
+}
+
+// logout: the access token goes to the denylist, the refresh token (if given and
+// owned by the same user) takes its whole family with it
+func RevokeTokens(userID int, jti string, expiresAt time.Time, refreshToken string) error {
+    if err := tokenStore.RevokeAccess(jti, expiresAt); err != nil {
+        return err
+    }
+    if refreshToken == "" {
+        return nil
+    }
+    rt, err := tokenStore.GetRefresh(hashToken(refreshToken))
+    if err == ErrInvalidRefreshToken {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if rt.UserID != userID {
+        return nil
+    }
+    return tokenStore.RevokeFamily(rt.FamilyID)
+}
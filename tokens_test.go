@@ -0,0 +1,155 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// refresh token of a fresh API login, the access token goes into the client
+func loginAPITokens(t *testing.T, router http.Handler, username string) (*testClient, string) {
+    t.Helper()
+    c := newTestClient(t, router)
+    resp := decodeTestJSON(t, c.sendJSON(http.MethodPost, "/api/v1/auth/login", gin.H{"username": username, "password": testPassword}))
+    token, _ := resp["token"].(string)
+    refresh, _ := resp["refresh_token"].(string)
+    if token == "" || refresh == "" {
+        t.Fatalf("api login %s: %v", username, resp)
+    }
+    c.token = token
+    return c, refresh
+}
+
+func refreshTestTokens(c *testClient, refresh string) (int, map[string]interface{}) {
+    c.t.Helper()
+    w := c.sendJSON(http.MethodPost, "/api/v1/auth/refresh", gin.H{"refresh_token": refresh})
+    if w.Code != http.StatusOK {
+        return w.Code, nil
+    }
+    return w.Code, decodeTestJSON(c.t, w)
+}
+
+// a refresh token used twice revokes its whole family, other logins keep working
+func TestRefreshTokenReuse(t *testing.T) {
+    router := setupTestServer(t)
+    createTestUser(t, "alice")
+    c, first := loginAPITokens(t, router, "alice")
+    _, other := loginAPITokens(t, router, "alice")
+
+    code, resp := refreshTestTokens(c, first)
+    if code != http.StatusOK {
+        t.Fatalf("first refresh: %d", code)
+    }
+    second := resp["refresh_token"].(string)
+
+    if code, _ := refreshTestTokens(c, first); code != http.StatusUnauthorized {
+        t.Fatalf("reused refresh token: %d", code)
+    }
+    if code, _ := refreshTestTokens(c, second); code != http.StatusUnauthorized {
+        t.Fatalf("refresh token of the revoked family: %d", code)
+    }
+    if code, _ := refreshTestTokens(c, other); code != http.StatusOK {
+        t.Fatalf("refresh token of another login: %d", code)
+    }
+}
+
+// after logout the access token is denylisted by its jti and the refresh family is gone
+func TestLogoutRevokesTokens(t *testing.T) {
+    router := setupTestServer(t)
+    createTestUser(t, "alice")
+    c, refresh := loginAPITokens(t, router, "alice")
+    other, _ := loginAPITokens(t, router, "alice")
+
+    if w := c.get("/api/v1/worklogs"); w.Code != http.StatusOK {
+        t.Fatalf("before logout: %d", w.Code)
+    }
+    if w := c.sendJSON(http.MethodPost, "/api/v1/auth/logout", gin.H{"refresh_token": refresh}); w.Code != http.StatusNoContent {
+        t.Fatalf("logout: %d %s", w.Code, w.Body.String())
+    }
+
+    claims, err := ValidateJWT(c.token)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if revoked, err := tokenStore.IsAccessRevoked(claims.ID); err != nil || !revoked {
+        t.Fatalf("jti %s not denylisted: %v", claims.ID, err)
+    }
+    if w := c.get("/api/v1/worklogs"); w.Code != http.StatusUnauthorized {
+        t.Fatalf("access token after logout: %d", w.Code)
+    }
+    if code, _ := refreshTestTokens(c, refresh); code != http.StatusUnauthorized {
+        t.Fatalf("refresh token after logout: %d", code)
+    }
+    if w := other.get("/api/v1/worklogs"); w.Code != http.StatusOK {
+        t.Fatalf("another login after logout: %d", w.Code)
+    }
+}
+
+// a read-only personal token reads, but can not write or use the JWT-only routes
+func TestReadOnlyPersonalToken(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    log := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: time.Now(), Description: "alice work", Hours: 2})
+    raw, pat, err := CreatePersonalToken(alice.ID, "script", []string{ScopeWorkLogsRead}, 30)
+    if err != nil {
+        t.Fatal(err)
+    }
+    c := newTestClient(t, router)
+    c.token = raw
+
+    for _, path := range []string{"/api/v1/worklogs", "/api/v1/stats", "/api/v1/tags", "/api/v1/timer"} {
+        if w := c.get(path); w.Code != http.StatusOK {
+            t.Errorf("GET %s: %d", path, w.Code)
+        }
+    }
+
+    worklog := fmt.Sprintf("/api/v1/worklogs/%d", log.ID)
+    body := gin.H{"date": log.Date.Format("2006-01-02"), "hours": 5, "description": "script"}
+    refused := map[string]func() int{
+        // writes
+        "POST /worklogs":       func() int { return c.sendJSON(http.MethodPost, "/api/v1/worklogs", body).Code },
+        "PUT /worklogs/:id":    func() int { return c.sendJSON(http.MethodPut, worklog, body).Code },
+        "DELETE /worklogs/:id": func() int { return c.do(http.MethodDelete, worklog, "", nil).Code },
+        "POST /timer/start":    func() int { return c.sendJSON(http.MethodPost, "/api/v1/timer/start", gin.H{"description": "x"}).Code },
+        "GET /projects":        func() int { return c.get("/api/v1/projects").Code },
+        "POST /invoices":       func() int { return c.sendJSON(http.MethodPost, "/api/v1/invoices", gin.H{}).Code },
+        // JWT only
+        "GET /tokens": func() int { return c.get("/api/v1/tokens").Code },
+        "POST /tokens": func() int {
+            return c.sendJSON(http.MethodPost, "/api/v1/tokens", gin.H{"name": "more", "scopes": []string{ScopeWorkLogsWrite}, "days": 30}).Code
+        },
+        "DELETE /tokens/:id": func() int { return c.do(http.MethodDelete, fmt.Sprintf("/api/v1/tokens/%d", pat.ID), "", nil).Code },
+        "GET /account":       func() int { return c.get("/api/v1/account").Code },
+        "PUT /account":       func() int { return c.sendJSON(http.MethodPut, "/api/v1/account", gin.H{"email": "a@example.com"}).Code },
+        "POST /account/password": func() int {
+            return c.sendJSON(http.MethodPost, "/api/v1/account/password", gin.H{"current_password": testPassword, "new_password": "another-password-1"}).Code
+        },
+        "GET /2fa":          func() int { return c.get("/api/v1/2fa").Code },
+        "POST /2fa/setup":   func() int { return c.sendJSON(http.MethodPost, "/api/v1/2fa/setup", gin.H{}).Code },
+        "POST /auth/logout": func() int { return c.sendJSON(http.MethodPost, "/api/v1/auth/logout", gin.H{}).Code },
+    }
+    for name, request := range refused {
+        if code := request(); code != http.StatusForbidden {
+            t.Errorf("%s with a read-only token: %d, want 403", name, code)
+        }
+    }
+
+    if got, err := worklogStore.Get(alice.ID, log.ID); err != nil || got.Hours != 2 || got.Description != "alice work" {
+        t.Fatalf("worklog changed: %+v %v", got, err)
+    }
+    if tokens, err := patStore.List(alice.ID); err != nil || len(tokens) != 1 {
+        t.Fatalf("personal tokens: %+v %v", tokens, err)
+    }
+
+    // revoked by its owner, the token stops working at once
+    owner := loginAPI(t, router, "alice")
+    if w := owner.do(http.MethodDelete, fmt.Sprintf("/api/v1/tokens/%d", pat.ID), "", nil); w.Code != http.StatusOK {
+        t.Fatalf("revoke: %d %s", w.Code, w.Body.String())
+    }
+    if w := c.get("/api/v1/worklogs"); w.Code != http.StatusUnauthorized {
+        t.Fatalf("revoked personal token: %d", w.Code)
+    }
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    "log"
+    "time"
+)
+
+// how often the retention job looks for expired trash
+const trashPurgeInterval = time.Hour
+
+// Take a worklog out of the trash. It counts like a new entry again, so the
+// rules of a new entry apply (closed period, approved week, overlaps, 24h/day).
+func RestoreTrashedWorkLog(userID, id int, a *Audit) (*WorkLog, error) {
+    trashed, err := worklogStore.GetTrashed(userID, id)
+    if err != nil {
+        return nil, err
+    }
+    if err := PrepareWorkLog(trashed, a.OverrideLock); err != nil {
+        return nil, err
+    }
+    if err := worklogStore.Restore(trashed, a); err != nil {
+        return nil, err
+    }
+    trashed.DeletedAt = time.Time{}
+    return trashed, nil
+}
+
+// when a trashed worklog is purged by the retention job, zero = never
+func TrashPurgeAt(w *WorkLog) time.Time {
+    if config.TrashRetention <= 0 {
+        return time.Time{}
+    }
+    return w.DeletedAt.Add(config.TrashRetention)
+}
+
+// purges the trash older than retention at startup and then every trashPurgeInterval
+func StartTrashPurge(retention time.Duration) {
+    if retention <= 0 {
+        return
+    }
+    go func() {
+        for {
+            purgeExpiredTrash(retention)
+            time.Sleep(trashPurgeInterval)
+        }
+    }()
+}
+
+func purgeExpiredTrash(retention time.Duration) {
+    n, err := worklogStore.PurgeTrash(time.Now().Add(-retention))
+    if err != nil {
+        log.Println("trash purge:", err)
+        return
+    }
+    if n > 0 {
+        log.Printf("trash purge: %d worklogs older than %s removed", n, retention)
+    }
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestPurgeExpiredTrash(t *testing.T) {
+    mem := useMemoryWorkLogStore(t)
+    day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+    live := createTestWorkLog(t, WorkLog{UserID: 1, Date: day, Description: "live", Hours: 1})
+    fresh := createTestWorkLog(t, WorkLog{UserID: 1, Date: day, Description: "fresh", Hours: 1})
+    expired := createTestWorkLog(t, WorkLog{UserID: 2, Date: day, Description: "expired", Hours: 1})
+    for _, log := range []*WorkLog{fresh, expired} {
+        if err := worklogStore.Delete(log.UserID, log.ID, nil); err != nil {
+            t.Fatal(err)
+        }
+    }
+    old := mem.logs[expired.ID]
+    old.DeletedAt = time.Now().Add(-48 * time.Hour)
+    mem.logs[expired.ID] = old
+
+    purgeExpiredTrash(24 * time.Hour)
+
+    if _, err := worklogStore.GetTrashed(2, expired.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("expired worklog still in the trash: %v", err)
+    }
+    if _, err := worklogStore.GetTrashed(1, fresh.ID); err != nil {
+        t.Fatalf("fresh trash was purged: %v", err)
+    }
+    if _, err := worklogStore.Get(1, live.ID); err != nil {
+        t.Fatalf("live worklog was purged: %v", err)
+    }
+}
+
+func TestTrashPurgeAt(t *testing.T) {
+    config = DefaultConfig()
+    deleted := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+    log := &WorkLog{DeletedAt: deleted}
+
+    config.TrashRetention = 30 * 24 * time.Hour
+    if got := TrashPurgeAt(log); !got.Equal(deleted.Add(config.TrashRetention)) {
+        t.Fatalf("TrashPurgeAt = %v", got)
+    }
+    config.TrashRetention = 0
+    if got := TrashPurgeAt(log); !got.IsZero() {
+        t.Fatalf("TrashPurgeAt without retention = %v, want zero", got)
+    }
+}
+
+// a version of a worklog in the trash can come back, one of a purged worklog can not
+func TestRestoreVersionAfterPurge(t *testing.T) {
+    router := setupTestServer(t)
+    alice := createTestUser(t, "alice")
+    audit := &Audit{ActorName: "test", Channel: ChannelSystem}
+    log := createTestWorkLog(t, WorkLog{UserID: alice.ID, Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+        Description: "work", Hours: 1})
+    if err := worklogStore.Delete(alice.ID, log.ID, audit); err != nil {
+        t.Fatal(err)
+    }
+    changes, err := historyStore.ForWorkLog(alice.ID, log.ID)
+    if err != nil || len(changes) == 0 {
+        t.Fatalf("history: %v", err)
+    }
+    created := changes[0].ID
+
+    // RestoreWorkLogVersion marks its audit, the other changes get their own
+    if _, err := RestoreWorkLogVersion(alice.ID, created, &Audit{ActorName: "test", Channel: ChannelSystem}); err != nil {
+        t.Fatalf("restore from the trash: %v", err)
+    }
+    if err := worklogStore.Delete(alice.ID, log.ID, audit); err != nil {
+        t.Fatal(err)
+    }
+    if err := worklogStore.Purge(alice.ID, log.ID, audit); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := RestoreWorkLogVersion(alice.ID, created, &Audit{ActorName: "test", Channel: ChannelSystem}); err != ErrWorkLogPurged {
+        t.Fatalf("restore after the purge = %v, want ErrWorkLogPurged", err)
+    }
+    if _, err := worklogStore.Get(alice.ID, log.ID); err != ErrWorkLogNotFound {
+        t.Fatalf("purged worklog is back: %v", err)
+    }
+
+    c := loginWeb(t, router, "alice")
+    for _, page := range []string{"/worklog/history", fmt.Sprintf("/worklog/history/%d", log.ID)} {
+        if w := c.get(page); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "/worklog/restore/") {
+            t.Fatalf("%s offers a restore of the purged worklog: %d", page, w.Code)
+        }
+    }
+    api := loginAPI(t, router, "alice")
+    if w := api.do(http.MethodPost, fmt.Sprintf("/api/v1/worklogs/history/%d/restore", created), "", nil); w.Code != http.StatusConflict {
+        t.Fatalf("API restore after the purge: %d %s", w.Code, w.Body.String())
+    }
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// TOTP as in RFC 6238 with the defaults every authenticator app knows:
+// HMAC-SHA1, 6 digits, 30 second steps
+const (
+    totpIssuer = "my-tracker"
+    totpDigits = 6
+    totpPeriod = 30
+    totpSkew   = 1 // steps accepted before/after now, clocks of phones drift
+
+    recoveryCodeCount = 10
+    mfaChallengeTTL   = 5 * time.Minute
+)
+
+var (
+    ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+    ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+    ErrTwoFactorNotSetUp   = errors.New("two-factor setup was not started")
+)
+
+// clock of every 2FA check, replaced to check codes against a fixed time
+var totpNow = time.Now
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 160 bit secret, base32 as apps expect it
+func NewTOTPSecret() (string, error) {
+    b := make([]byte, 20)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return totpEncoding.EncodeToString(b), nil
+}
+
+// otpauth:// URI for the QR code
+func TOTPProvisioningURI(username, secret string) string {
+    v := url.Values{}
+    v.Set("secret", secret)
+    v.Set("issuer", totpIssuer)
+    v.Set("algorithm", "SHA1")
+    v.Set("digits", fmt.Sprint(totpDigits))
+    v.Set("period", fmt.Sprint(totpPeriod))
+    label := url.PathEscape(totpIssuer + ":" + username)
+    return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+func totpStep(t time.Time) int64 {
+    return t.Unix() / totpPeriod
+}
+
+// RFC 4226 HOTP for the counter = TOTP time step
+func totpCode(secret string, step int64) (string, error) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return "", err
+    }
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// time step of the matching code, ErrInvalidOTP if none in the window matches
+func MatchTOTP(secret, code string, now time.Time) (int64, error) {
+    code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+    if len(code) != totpDigits {
+        return 0, ErrInvalidOTP
+    }
+    current := totpStep(now)
+    for step := current - totpSkew; step <= current+totpSkew; step++ {
+        expected, err := totpCode(secret, step)
+        if err != nil {
+            return 0, err
+        }
+        if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+            return step, nil
+        }
+    }
+    return 0, ErrInvalidOTP
+}
+
+// TOTP code that was not used before (replays of a seen code fail)
+func VerifyTOTP(user *User, code string) error {
+    if user.TOTPSecret == "" {
+        return ErrTwoFactorNotSetUp
+    }
+    step, err := MatchTOTP(user.TOTPSecret, code, totpNow())
+    if err != nil {
+        return err
+    }
+    return mfaStore.UseStep(user.ID, step)
+}
+
+// second login step: TOTP code or an unused recovery code
+func VerifySecondFactor(user *User, code string) error {
+    if !user.TOTPEnabled {
+        return ErrTwoFactorNotEnabled
+    }
+    if isRecoveryCode(code) {
+        return mfaStore.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)), totpNow())
+    }
+    return VerifyTOTP(user, code)
+}
+
+// "abcd-efgh-ijkl", 60 bit each
+func newRecoveryCodes() (codes, hashes []string, err error) {
+    for i := 0; i < recoveryCodeCount; i++ {
+        b := make([]byte, 8)
+        if _, err := rand.Read(b); err != nil {
+            return nil, nil, err
+        }
+        s := strings.ToLower(totpEncoding.EncodeToString(b))[:12]
+        code := s[:4] + "-" + s[4:8] + "-" + s[8:]
+        codes = append(codes, code)
+        hashes = append(hashes, hashToken(code))
+    }
+    return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+    s := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+    if len(s) != 12 {
+        return s
+    }
+    return s[:4] + "-" + s[4:8] + "-" + s[8:]
+}
+
+// TOTP codes are digits only, recovery codes are longer
+func isRecoveryCode(code string) bool {
+    return len(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))) == 12
+}
+
+// first step of the setup: a fresh secret, 2FA is off until ConfirmTwoFactor
+func StartTwoFactorSetup(user *User) (string, error) {
+    if user.TOTPEnabled {
+        return "", ErrTwoFactorEnabled
+    }
+    secret, err := NewTOTPSecret()
+    if err != nil {
+        return "", err
+    }
+    if err := mfaStore.SetSecret(user.ID, secret); err != nil {
+        return "", err
+    }
+    user.TOTPSecret = secret
+    return secret, nil
+}
+
+// the first valid code turns 2FA on, recovery codes are returned only here
+func ConfirmTwoFactor(user *User, code string) ([]string, error) {
+    if user.TOTPEnabled {
+        return nil, ErrTwoFactorEnabled
+    }
+    if err := VerifyTOTP(user, code); err != nil {
+        return nil, err
+    }
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        return nil, err
+    }
+    if err := mfaStore.Enable(user.ID, hashes); err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// new set of recovery codes, the old ones stop working
+func RegenerateRecoveryCodes(user *User, code string) ([]string, error) {
+    if err := VerifySecondFactor(user, code); err != nil {
+        return nil, err
+    }
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        return nil, err
+    }
+    if err := mfaStore.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// turning 2FA off needs a current code (or a recovery code)
+func DisableTwoFactor(user *User, code string) error {
+    if err := VerifySecondFactor(user, code); err != nil {
+        return err
+    }
+    return mfaStore.Reset(user.ID)
+}
+
+// API login with 2FA: password ok, the challenge is exchanged for tokens with a code
+func NewMFAChallenge(userID int) (string, error) {
+    challenge, err := randomToken()
+    if err != nil {
+        return "", err
+    }
+    if err := mfaStore.CreateChallenge(hashToken(challenge), userID, totpNow().Add(mfaChallengeTTL)); err != nil {
+        return "", err
+    }
+    return challenge, nil
+}
+
+// Second login step of web and API: user of the challenge once the code is right, the
+// challenge is then used up. Every call counts against the challenge on the server
+// (maxChallengeAttempts), the login throttle of the user is checked before the code,
+// a wrong code is recorded as a failed login of a.
+func VerifyMFAChallenge(challenge, code string, a LoginAttempt) (*User, error) {
+    hash := hashToken(challenge)
+    userID, err := mfaStore.ChallengeUser(hash, totpNow())
+    if err != nil {
+        return nil, err
+    }
+    user, err := userStore.GetByID(userID)
+    if err == ErrUserNotFound {
+        return nil, ErrInvalidChallenge
+    }
+    if err != nil {
+        return nil, err
+    }
+    if user.Disabled {
+        return nil, ErrUserDisabled
+    }
+
+    a.Username = user.Username
+    if err := CheckLoginAllowed(&a); err != nil {
+        return nil, err
+    }
+    defer FinishLoginAttempt(&a)
+    if err := VerifySecondFactor(user, code); err != nil {
+        if err == ErrInvalidOTP {
+            RecordLoginFailure(&a, LoginFailOTP)
+        }
+        return nil, err
+    }
+    if err := mfaStore.DeleteChallenge(hash); err != nil {
+        return nil, err
+    }
+    RecordLoginSuccess(a)
+    return user, nil
+}
+
+// "2fa reset <username>": for a user who lost both the phone and the recovery codes
+func runTwoFactorCommand(args []string) error {
+    if len(args) != 2 || args[0] != "reset" {
+        return errors.New("usage: 2fa reset <username>")
+    }
+
+    if err := InitDB(config.DatabaseDriver, config.DatabaseDSN(), false); err != nil {
+        return err
+    }
+    defer db.Close()
+
+    user, err := userStore.GetByUsername(args[1])
+    if err != nil {
+        return fmt.Errorf("2fa: %s: %w", args[1], err)
+    }
+    if err := mfaStore.Reset(user.ID); err != nil {
+        return err
+    }
+    fmt.Printf("two-factor authentication reset for %s\n", user.Username)
+    return nil
+}
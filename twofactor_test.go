@@ -0,0 +1,182 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// totpNow stands still until the returned function moves it on by one code step
+func fixTestTOTPClock(t *testing.T) (next func() time.Time) {
+    old := totpNow
+    now := time.Now().Truncate(totpPeriod * time.Second)
+    totpNow = func() time.Time { return now }
+    t.Cleanup(func() { totpNow = old })
+    return func() time.Time {
+        now = now.Add(totpPeriod * time.Second)
+        return now
+    }
+}
+
+// alice with 2FA on, the setup used the code of the current step
+func createTestTwoFactorUser(t *testing.T, username string) *User {
+    t.Helper()
+    user := createTestUser(t, username)
+    if _, err := StartTwoFactorSetup(user); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := ConfirmTwoFactor(user, testTOTPCode(t, user)); err != nil {
+        t.Fatal(err)
+    }
+    user.TOTPEnabled = true
+    return user
+}
+
+func testTOTPCode(t *testing.T, user *User) string {
+    t.Helper()
+    code, err := totpCode(user.TOTPSecret, totpStep(totpNow()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return code
+}
+
+// failed logins a minute older, so the backoff between guesses is over (not a lockout)
+func passTestLoginBackoff(t *testing.T) {
+    t.Helper()
+    if _, err := db.Exec("UPDATE failed_logins SET attempted_at = ?", timeValue(time.Now().Add(-time.Minute))); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// client after the password step of the web login, waiting for the code
+func startTestTwoFactorLogin(t *testing.T, router http.Handler, username string) *testClient {
+    t.Helper()
+    c := newTestClient(t, router)
+    w := c.postForm("/login", url.Values{"username": {username}, "password": {testPassword}})
+    if w.Code != http.StatusFound || w.Header().Get("Location") != "/login/2fa" {
+        t.Fatalf("password step: %d %s", w.Code, w.Header().Get("Location"))
+    }
+    return c
+}
+
+func TestLoginTwoFactorWeb(t *testing.T) {
+    router := setupTestServer(t)
+    next := fixTestTOTPClock(t)
+    alice := createTestTwoFactorUser(t, "alice")
+
+    t.Run("right code", func(t *testing.T) {
+        c := startTestTwoFactorLogin(t, router, "alice")
+        passTestLoginBackoff(t)
+        if w := c.postForm("/login/2fa", url.Values{"code": {"000000"}}); !strings.Contains(w.Body.String(), "Неверный код") {
+            t.Fatalf("wrong code: %d %s", w.Code, w.Body.String())
+        }
+        next()
+        passTestLoginBackoff(t)
+        w := c.postForm("/login/2fa", url.Values{"code": {testTOTPCode(t, alice)}})
+        if w.Code != http.StatusFound || w.Header().Get("Location") != "/dashboard" {
+            t.Fatalf("right code: %d %s", w.Code, w.Body.String())
+        }
+        if w := c.get("/dashboard"); w.Code != http.StatusOK {
+            t.Fatalf("dashboard after 2FA: %d", w.Code)
+        }
+    })
+
+    t.Run("replayed cookie", func(t *testing.T) {
+        c := startTestTwoFactorLogin(t, router, "alice")
+        saved := make(map[string]*http.Cookie)
+        for name, cookie := range c.cookies {
+            saved[name] = cookie
+        }
+        for i := 0; i < maxChallengeAttempts; i++ {
+            // every guess goes out with the session as it was after the password
+            c.cookies = map[string]*http.Cookie{}
+            for name, cookie := range saved {
+                c.cookies[name] = cookie
+            }
+            passTestLoginBackoff(t)
+            if w := c.postForm("/login/2fa", url.Values{"code": {"000000"}}); w.Code != http.StatusOK {
+                t.Fatalf("guess %d: %d", i, w.Code)
+            }
+        }
+        // the challenge is used up, the right code does not help any more
+        c.cookies = saved
+        next()
+        w := c.postForm("/login/2fa", url.Values{"code": {testTOTPCode(t, alice)}})
+        if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Время ввода кода истекло") {
+            t.Fatalf("right code after %d guesses: %d %s", maxChallengeAttempts, w.Code, w.Body.String())
+        }
+        if err := loginStore.Clear(throttleKey("alice")); err != nil {
+            t.Fatal(err)
+        }
+    })
+
+    t.Run("throttled", func(t *testing.T) {
+        c := startTestTwoFactorLogin(t, router, "alice")
+        for i := 0; i < config.LoginMaxFailures; i++ {
+            RecordLoginFailure(&LoginAttempt{Username: "alice", IP: "192.0.2.1", Channel: ChannelWeb}, LoginFailOTP)
+        }
+        next()
+        code := testTOTPCode(t, alice)
+        if w := c.postForm("/login/2fa", url.Values{"code": {code}}); w.Code != http.StatusTooManyRequests {
+            t.Fatalf("throttled code: %d %s", w.Code, w.Body.String())
+        }
+
+        // the code was not checked, so it is still good once the lockout is over
+        if err := loginStore.Clear(throttleKey("alice")); err != nil {
+            t.Fatal(err)
+        }
+        if w := c.postForm("/login/2fa", url.Values{"code": {code}}); w.Code != http.StatusFound {
+            t.Fatalf("code after the lockout: %d %s", w.Code, w.Body.String())
+        }
+    })
+}
+
+func TestLoginTwoFactorAPI(t *testing.T) {
+    router := setupTestServer(t)
+    next := fixTestTOTPClock(t)
+    alice := createTestTwoFactorUser(t, "alice")
+
+    c := newTestClient(t, router)
+    login := func() string {
+        t.Helper()
+        resp := decodeTestJSON(t, c.sendJSON(http.MethodPost, "/api/v1/auth/login",
+            map[string]string{"username": "alice", "password": testPassword}))
+        challenge, _ := resp["challenge"].(string)
+        if resp["mfa_required"] != true || challenge == "" {
+            t.Fatalf("password step: %v", resp)
+        }
+        return challenge
+    }
+    verify := func(challenge, code string) *httptest.ResponseRecorder {
+        return c.sendJSON(http.MethodPost, "/api/v1/auth/2fa/verify", map[string]string{"challenge": challenge, "code": code})
+    }
+
+    challenge, other := login(), login()
+    for i := 0; i < maxChallengeAttempts; i++ {
+        passTestLoginBackoff(t)
+        if w := verify(challenge, "000000"); w.Code != http.StatusUnauthorized {
+            t.Fatalf("guess %d: %d %s", i, w.Code, w.Body.String())
+        }
+    }
+    next()
+    passTestLoginBackoff(t)
+    if w := verify(challenge, testTOTPCode(t, alice)); w.Code != http.StatusUnauthorized {
+        t.Fatalf("right code on a used up challenge: %d %s", w.Code, w.Body.String())
+    }
+
+    // the wrong guesses count as failed logins of alice, she is locked out on any challenge
+    if w := verify(other, testTOTPCode(t, alice)); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+        t.Fatalf("throttled code: %d %s", w.Code, w.Body.String())
+    }
+    if err := loginStore.Clear(throttleKey("alice")); err != nil {
+        t.Fatal(err)
+    }
+    w := verify(other, testTOTPCode(t, alice))
+    if resp := decodeTestJSON(t, w); w.Code != http.StatusOK || resp["token"] == "" {
+        t.Fatalf("right code: %d %v", w.Code, resp)
+    }
+}
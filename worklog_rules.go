@@ -0,0 +1,191 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "time"
+)
+
+// rule violations, checked the same way for web, API and timer
+var (
+    ErrHoursRequired = errors.New("hours or start_time/end_time required")
+    ErrInvalidTimes  = errors.New("start_time and end_time must be HH:MM, end after start")
+    ErrInvalidBreak  = errors.New("break is longer than the interval")
+    ErrOverlap       = errors.New("time interval overlaps another entry")
+    ErrDayLimit      = errors.New("more than 24 hours on this day")
+    ErrWorkLogLocked = errors.New("worklog is invoiced and can not be changed")
+    ErrWeekApproved  = errors.New("week is approved and can not be changed")
+    ErrWeekSubmitted = errors.New("week is submitted for approval and can not be changed")
+)
+
+const maxHoursPerDay = 24.0
+
+// ErrOverlap with the interval it collides with
+type OverlapError struct {
+    StartTime string
+    EndTime   string
+}
+
+func (e *OverlapError) Error() string {
+    return fmt.Sprintf("%s: %s-%s", ErrOverlap, e.StartTime, e.EndTime)
+}
+
+func (e *OverlapError) Is(target error) bool {
+    return target == ErrOverlap
+}
+
+// minutes since midnight for "HH:MM"
+func parseClock(s string) (int, error) {
+    t, err := time.Parse("15:04", s)
+    if err != nil {
+        return 0, err
+    }
+    return t.Hour()*60 + t.Minute(), nil
+}
+
+// Check a worklog before it is saved and fill in computed fields:
+//   - project must belong to the user
+//   - tags are normalized (see NormalizeTags)
+//   - with start/end: hours = end - start - break
+//   - interval must not overlap other entries of the same user on that day
+//   - all entries of the day together stay within 24 hours
+//   - the week of the date is not submitted or approved
+//   - the date is after the lock date (unless an admin overrides it)
+// log.ID = 0 for new entries, otherwise the entry itself is skipped in the checks.
+func PrepareWorkLog(log *WorkLog, overrideLock bool) error {
+    if err := ValidateWorkLogProject(log); err != nil {
+        return err
+    }
+    if err := CheckPeriodOpen(log.UserID, log.Date, overrideLock); err != nil {
+        return err
+    }
+    if err := CheckWeekOpen(log.UserID, log.Date); err != nil {
+        return err
+    }
+
+    tags, err := NormalizeTags(log.Tags)
+    if err != nil {
+        return err
+    }
+    log.Tags = tags
+
+    var start, end int
+    hasTimes := log.StartTime != "" || log.EndTime != ""
+    if hasTimes {
+        var err1, err2 error
+        start, err1 = parseClock(log.StartTime)
+        end, err2 = parseClock(log.EndTime)
+        if err1 != nil || err2 != nil || end <= start {
+            return ErrInvalidTimes
+        }
+        if log.BreakMinutes < 0 || log.BreakMinutes >= end-start {
+            return ErrInvalidBreak
+        }
+        log.Hours = math.Round(float64(end-start-log.BreakMinutes)/60*100) / 100
+    } else {
+        log.BreakMinutes = 0
+    }
+
+    if log.Hours <= 0 || log.Hours > maxHoursPerDay {
+        return ErrHoursRequired
+    }
+
+    day := log.Date.Format("2006-01-02")
+    others, err := worklogStore.List(log.UserID, WorkLogFilter{DateFrom: day, DateTo: day})
+    if err != nil {
+        return err
+    }
+    return checkDay(log, others)
+}
+
+// overlap and 24h/day of a prepared log against the other entries of its day
+func checkDay(log *WorkLog, others []WorkLog) error {
+    hasTimes := log.StartTime != ""
+    start, _ := parseClock(log.StartTime)
+    end, _ := parseClock(log.EndTime)
+
+    total := log.Hours
+    for _, other := range others {
+        // rows of an import have no id yet
+        if log.ID != 0 && other.ID == log.ID {
+            continue
+        }
+        total += other.Hours
+
+        if !hasTimes || other.StartTime == "" || other.EndTime == "" {
+            continue
+        }
+        otherStart, err1 := parseClock(other.StartTime)
+        otherEnd, err2 := parseClock(other.EndTime)
+        if err1 != nil || err2 != nil {
+            continue
+        }
+        if start < otherEnd && otherStart < end {
+            return &OverlapError{StartTime: other.StartTime, EndTime: other.EndTime}
+        }
+    }
+
+    // small epsilon for float sums like 0.1 + 0.2
+    if total > maxHoursPerDay+1e-9 {
+        return ErrDayLimit
+    }
+    return nil
+}
+
+// Update and delete only for worklogs that are not frozen yet
+// (invoiced worklogs belong to their invoice, submitted and approved weeks to the review,
+// closed periods to finance - only there an admin override helps).
+func CheckWorkLogEditable(log *WorkLog, overrideLock bool) error {
+    if log.InvoiceID != 0 {
+        return ErrWorkLogLocked
+    }
+    if err := CheckPeriodOpen(log.UserID, log.Date, overrideLock); err != nil {
+        return err
+    }
+    return CheckWeekOpen(log.UserID, log.Date)
+}
+
+// rule errors for the web forms
+func workLogErrorText(err error) string {
+    var overlap *OverlapError
+    if errors.As(err, &overlap) {
+        return "интервал пересекается с другой записью (" + overlap.StartTime + "-" + overlap.EndTime + ")"
+    }
+    var closed *PeriodLockedError
+    if errors.As(err, &closed) {
+        return "период по " + closed.Until.Format("02.01.2006") + " закрыт, записи в нём менять нельзя"
+    }
+
+    switch {
+    case errors.Is(err, ErrProjectNotFound):
+        return "проект не найден"
+    case errors.Is(err, ErrHoursRequired):
+        return "укажите часы (0-24) или время начала и окончания"
+    case errors.Is(err, ErrInvalidTimes):
+        return "время окончания должно быть позже времени начала"
+    case errors.Is(err, ErrInvalidBreak):
+        return "перерыв длиннее интервала"
+    case errors.Is(err, ErrDayLimit):
+        return "за этот день получается больше 24 часов"
+    case errors.Is(err, ErrWorkLogLocked):
+        return "запись уже выставлена в счёте и не может быть изменена"
+    case errors.Is(err, ErrWeekApproved):
+        return "неделя утверждена и не может быть изменена"
+    case errors.Is(err, ErrWeekSubmitted):
+        return "неделя отправлена на утверждение и не может быть изменена"
+    case errors.Is(err, ErrInvalidTag):
+        return "тег не длиннее 32 символов, не больше 10 тегов"
+    }
+    return err.Error()
+}
+
+// true for errors caused by the input, not by the database
+func isWorkLogRuleError(err error) bool {
+    for _, target := range []error{ErrProjectNotFound, ErrHoursRequired, ErrInvalidTimes, ErrInvalidBreak, ErrOverlap, ErrDayLimit, ErrInvalidTag, ErrWorkLogLocked, ErrWeekApproved, ErrWeekSubmitted, ErrPeriodLocked} {
+        if errors.Is(err, target) {
+            return true
+        }
+    }
+    return false
+}